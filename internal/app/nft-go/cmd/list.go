@@ -1,15 +1,66 @@
 package cmd
 
 import (
+	"os"
+
+	"github.com/Morwran/nft-go/pkg/nftenc"
+	"github.com/Morwran/nft-go/pkg/protocols"
+
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 )
 
+// listFlags holds the rendering flags shared by the list subcommands
+var listFlags struct {
+	services  bool
+	numeric   bool
+	strict    bool
+	protoDefs string
+}
+
 func newlistCommand() *cobra.Command {
 	c := &cobra.Command{
 		Use:     "list",
 		Short:   "list one of the nftables object: tables, chains, sets, ruleset",
 		Example: "list ruleset",
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return loadProtoDefs(listFlags.protoDefs)
+		},
 	}
+	c.PersistentFlags().BoolVarP(&listFlags.services, "services", "S", false,
+		"translate ports to service names")
+	c.PersistentFlags().BoolVarP(&listFlags.numeric, "numeric", "n", false,
+		"print ports, protocols, interfaces, users, groups and times numerically")
+	c.PersistentFlags().BoolVar(&listFlags.strict, "strict", false,
+		"fail on expressions which can not be decoded instead of printing them opaque in marked rules")
+	c.PersistentFlags().StringVar(&listFlags.protoDefs, "proto-defs", "",
+		"YAML or JSON file defining the fields of custom protocol headers")
 	c.AddCommand(newTablesCommand(), newChainsCommand(), newSetsCommand(), newRuleSetCommand())
 	return c
 }
+
+func encoderOptions() (opts []nftenc.Option) {
+	if listFlags.services {
+		opts = append(opts, nftenc.WithServiceNames())
+	}
+	if listFlags.numeric {
+		opts = append(opts, nftenc.WithNumeric())
+	}
+	if listFlags.strict {
+		opts = append(opts, nftenc.WithStrict())
+	}
+	return opts
+}
+
+// loadProtoDefs registers the protocol headers defined in the file
+func loadProtoDefs(file string) error {
+	if file == "" {
+		return nil
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return errors.WithMessage(err, "failed to open protocol definitions")
+	}
+	defer f.Close() //nolint:errcheck
+	return errors.WithMessagef(protocols.LoadProtoDefs(f), "file='%s'", file)
+}
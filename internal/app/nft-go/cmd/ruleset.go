@@ -1,7 +1,11 @@
 package cmd
 
 import (
+	"bufio"
+	"io"
+
 	"github.com/Morwran/nft-go/pkg/nftenc"
+	"github.com/Morwran/nft-go/pkg/ruleset"
 
 	nftLib "github.com/google/nftables"
 	"github.com/pkg/errors"
@@ -13,47 +17,50 @@ func newRuleSetCommand() *cobra.Command {
 		Use:   "ruleset",
 		Short: "list ruleset",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return listRuleSets()
+			return listRuleset(cmd, ruleset.FetchAll)
 		},
 	}
 	return c
 }
 
-func listRuleSets() error {
-	conn, err := nftLib.New()
+// newConn opens the netlink connection the commands list the ruleset over
+var newConn = func() (*nftLib.Conn, error) {
+	return nftLib.New()
+}
+
+// listRuleset prints the content of the tables fetched from the netfilter
+func listRuleset(cmd *cobra.Command, content ruleset.Content) error {
+	conn, err := newConn()
 	if err != nil {
 		return errors.WithMessage(err, "failed to create netlink connection")
 	}
 	defer conn.CloseLasting() //nolint:errcheck
 
-	return listTables(conn, func(table *nftLib.Table) ([]nftenc.Encoder, error) {
-		var encs []nftenc.Encoder
-		setEncs, err := getSetEncoders(conn, table)
-		if err != nil {
-			return nil, err
-		}
-		encs = append(encs, setEncs...)
-		chainEncs, err := getChainEncoders(conn, table, func(chain *nftLib.Chain) ([]*nftenc.RuleEncoder, error) {
-			return getRuleEncoders(conn, table, chain)
-		})
-		if err != nil {
-			return nil, err
-		}
-		return append(encs, chainEncs...), nil
-	})
+	rs, err := ruleset.Fetch(cmd.Context(), conn, ruleset.Filter{Content: content})
+	if err != nil {
+		return err
+	}
+	opts := encoderOptions()
+	var failures nftenc.DecodeErrors
+	if !listFlags.strict {
+		opts = append(opts, nftenc.WithLenient(failures.Add))
+	}
+	if err = printStream(cmd.OutOrStdout(), nftenc.NewRulesetEncoder(rs, opts...)); err != nil {
+		return err
+	}
+	_, err = io.WriteString(cmd.ErrOrStderr(), failures.Summary())
+	return err
 }
 
-func getRuleEncoders(conn *nftLib.Conn, table *nftLib.Table, chain *nftLib.Chain) ([]*nftenc.RuleEncoder, error) {
-	var encs []*nftenc.RuleEncoder
-	rules, err := conn.GetRules(table, chain)
-	if err != nil {
-		return nil, errors.WithMessagef(
-			err, "failed to obtain rules from the netfilter for the table name='%s' family='%s' and chain=%s",
-			table.Name, nftenc.TableFamily(table.Family), chain.Name,
-		)
+// printStream writes the text of the encoder to w as it is produced
+func printStream(w io.Writer, enc nftenc.StreamEncoder) error {
+	out := bufio.NewWriter(w)
+	n, err := enc.WriteTo(out)
+	if err == nil && n > 0 {
+		err = out.WriteByte('\n')
 	}
-	for _, rule := range rules {
-		encs = append(encs, nftenc.NewRuleEncoder(rule))
+	if e := out.Flush(); err == nil {
+		err = e
 	}
-	return encs, nil
+	return err
 }
@@ -2,52 +2,56 @@ package cmd
 
 import (
 	"github.com/Morwran/nft-go/pkg/nftenc"
+	"github.com/Morwran/nft-go/pkg/ruleset"
 
-	nftLib "github.com/google/nftables"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 )
 
 func newSetsCommand() *cobra.Command {
+	var stream bool
 	c := &cobra.Command{
 		Use:   "sets",
 		Short: "list sets",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return listSets()
+			if stream {
+				return streamSets(cmd)
+			}
+			return listRuleset(cmd, ruleset.FetchTables|ruleset.FetchSets|ruleset.FetchSetElements)
 		},
 	}
+	c.Flags().BoolVar(&stream, "stream", false,
+		"print the elements in the kernel order as they come instead of sorting them, huge sets are listed in bounded memory")
 	return c
 }
 
-func listSets() error {
-	conn, err := nftLib.New()
+// streamSets streams the elements of every set from the kernel to the output
+// so huge sets are listed in bounded memory
+func streamSets(cmd *cobra.Command) error {
+	conn, err := newConn()
 	if err != nil {
 		return errors.WithMessage(err, "failed to create netlink connection")
 	}
 	defer conn.CloseLasting() //nolint:errcheck
 
-	return listTables(conn, func(table *nftLib.Table) ([]nftenc.Encoder, error) {
-		return getSetEncoders(conn, table)
-	})
-}
-
-func getSetEncoders(conn *nftLib.Conn, table *nftLib.Table) ([]nftenc.Encoder, error) {
-	var encs []nftenc.Encoder
-
-	sets, err := conn.GetSets(table)
+	ctx := cmd.Context()
+	rs, err := ruleset.Fetch(ctx, conn, ruleset.Filter{Content: ruleset.FetchTables | ruleset.FetchSets})
 	if err != nil {
-		return nil, errors.WithMessagef(
-			err, "failed to obtain list of sets from the netfilter for the table name='%s' family='%s'",
-			table.Name, nftenc.TableFamily(table.Family),
-		)
+		return err
 	}
-
-	for _, set := range sets {
-		elems, err := conn.GetSetElements(set)
-		if err != nil {
-			return nil, errors.WithMessagef(err, "failed to obtain set elements for the set='%s'", set.Name)
+	for _, t := range rs.Tables {
+		var items []nftenc.Encoder
+		for _, s := range t.Sets {
+			if s.Anonymous {
+				continue
+			}
+			elems := ruleset.SetElements(ctx, conn, s.Set)
+			items = append(items, nftenc.NewSetEncoder(s.Set,
+				nftenc.NewSetElemsStreamEncoder(s.KeyType, elems, encoderOptions()...)))
+		}
+		if err = printStream(cmd.OutOrStdout(), nftenc.NewTableEncoder(t.Table, items...)); err != nil {
+			return err
 		}
-		encs = append(encs, nftenc.NewSetEncoder(set, nftenc.NewSetElemsEncoder(set.KeyType, elems)))
 	}
-	return encs, nil
+	return nil
 }
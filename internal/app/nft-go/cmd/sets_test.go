@@ -0,0 +1,150 @@
+package cmd
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	nftLib "github.com/google/nftables"
+	"github.com/mdlayher/netlink"
+	"github.com/mdlayher/netlink/nltest"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+// fakeKernel answers the dumps of the tables, sets and elements added over
+// the connection of newFakeKernel.
+type fakeKernel map[uint16][]netlink.Message
+
+func newFakeKernel(t *testing.T, build func(conn *nftLib.Conn)) fakeKernel {
+	k := fakeKernel{}
+	conn, err := nftLib.New(nftLib.WithTestDial(func(req []netlink.Message) ([]netlink.Message, error) {
+		if req == nil {
+			// ack of a batch message
+			return []netlink.Message{{Header: netlink.Header{Type: netlink.Error}, Data: make([]byte, 4)}}, nil
+		}
+		for _, m := range req {
+			if typ := uint16(m.Header.Type); typ>>8 == unix.NFNL_SUBSYS_NFTABLES {
+				k[typ&0xff] = append(k[typ&0xff], m)
+			}
+		}
+		return nil, nil
+	}))
+	require.NoError(t, err)
+	build(conn)
+	require.NoError(t, conn.Flush())
+	return k
+}
+
+// dial answers a get request with the added objects, elements are selected
+// by the set name and tagged as NFTA_LIST_ELEM like the kernel dumps them.
+func (k fakeKernel) dial(req []netlink.Message) ([]netlink.Message, error) {
+	if len(req) == 0 {
+		return nil, nil
+	}
+	r := req[0]
+	newTyp := uint16(r.Header.Type)&0xff - 1
+	set := setName(r.Data[4:])
+	var out []netlink.Message
+	for _, m := range k[newTyp] {
+		if newTyp == unix.NFT_MSG_NEWSETELEM {
+			if setName(m.Data[4:]) != set {
+				continue
+			}
+			m.Data = dumpedElems(m.Data)
+		}
+		m.Header.Flags = netlink.Multi
+		m.Header.Sequence, m.Header.PID = r.Header.Sequence, r.Header.PID
+		out = append(out, m)
+	}
+	return append(out, netlink.Message{Header: netlink.Header{
+		Type:     netlink.Done,
+		Flags:    netlink.Multi,
+		Sequence: r.Header.Sequence,
+		PID:      r.Header.PID,
+	}}), nil
+}
+
+func setName(b []byte) string {
+	ad, err := netlink.NewAttributeDecoder(b)
+	if err != nil {
+		return ""
+	}
+	for ad.Next() {
+		if ad.Type() == unix.NFTA_SET_ELEM_LIST_SET {
+			return ad.String()
+		}
+	}
+	return ""
+}
+
+func dumpedElems(data []byte) []byte {
+	attrs, err := netlink.UnmarshalAttributes(data[4:])
+	if err != nil {
+		return data
+	}
+	for i, a := range attrs {
+		if a.Type&^unix.NLA_F_NESTED != unix.NFTA_SET_ELEM_LIST_ELEMENTS {
+			continue
+		}
+		elems, err := netlink.UnmarshalAttributes(a.Data)
+		if err != nil {
+			return data
+		}
+		for j := range elems {
+			elems[j].Type = unix.NFTA_LIST_ELEM | unix.NLA_F_NESTED
+		}
+		attrs[i].Data = nltest.MustMarshalAttributes(elems)
+	}
+	return append(data[:4:4], nltest.MustMarshalAttributes(attrs)...)
+}
+
+func Test_ListSetsOrder(t *testing.T) {
+	ifname := func(s string) []byte {
+		b := make([]byte, 16)
+		copy(b, s)
+		return b
+	}
+	k := newFakeKernel(t, func(conn *nftLib.Conn) {
+		tbl := conn.AddTable(&nftLib.Table{Name: "filter", Family: nftLib.TableFamilyINet})
+		require.NoError(t, conn.AddSet(&nftLib.Set{Name: "addrs", Table: tbl, KeyType: nftLib.TypeIP6Addr}, []nftLib.SetElement{
+			{Key: net.ParseIP("fe80::1")}, {Key: net.ParseIP("2001:db8::10")}, {Key: net.ParseIP("::1")}, {Key: net.ParseIP("2001:db8::2")},
+		}))
+		require.NoError(t, conn.AddSet(&nftLib.Set{Name: "ifaces", Table: tbl, KeyType: nftLib.TypeIFName}, []nftLib.SetElement{
+			{Key: ifname("eth10")}, {Key: ifname("eth1")}, {Key: ifname("br0")}, {Key: ifname("eth0")},
+		}))
+	})
+	defer func(fn func() (*nftLib.Conn, error)) { newConn = fn }(newConn)
+	newConn = func() (*nftLib.Conn, error) {
+		return nftLib.New(nftLib.WithTestDial(k.dial))
+	}
+
+	testCases := []struct {
+		name     string
+		args     []string
+		expected []string
+	}{
+		{
+			name:     "sorted by default",
+			args:     []string{"list", "sets"},
+			expected: []string{"::1, 2001:db8::2, 2001:db8::10, fe80::1", "br0, eth0, eth1, eth10"},
+		},
+		{
+			name:     "kernel order streamed",
+			args:     []string{"list", "sets", "--stream"},
+			expected: []string{"fe80::1, 2001:db8::10, ::1, 2001:db8::2", "eth10, eth1, br0, eth0"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cmd := newRootCmd()
+			var out bytes.Buffer
+			cmd.SetOut(&out)
+			cmd.SetArgs(tc.args)
+			require.NoError(t, cmd.Execute())
+			for _, elems := range tc.expected {
+				require.Contains(t, out.String(), "elements = { "+elems+" }")
+			}
+		})
+	}
+}
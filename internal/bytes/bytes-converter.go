@@ -35,8 +35,10 @@ func (b RawBytes) ReverseByte() RawBytes {
 }
 
 func (b RawBytes) LittleEndian() RawBytes {
-	// set bytes as big endian by default and then reverse it
-	return RawBytes(new(big.Int).SetBytes(b).Bytes()).ReverseByte()
+	// reverse a copy so leading zero bytes keep their weight
+	le := make(RawBytes, len(b))
+	copy(le, b)
+	return le.ReverseByte()
 }
 
 func (b RawBytes) String() string {
@@ -59,15 +61,13 @@ func (b RawBytes) Text(base int) string {
 
 func (b RawBytes) Ip() (ip net.IP) {
 	switch l := len(b); l {
-	case 4:
+	case net.IPv4len:
 		ip = make(net.IP, l)
-	case 16:
+		binary.BigEndian.PutUint32(ip, uint32(b.Uint64())) //nolint:gosec
+	case net.IPv6len:
 		ip = make(net.IP, l)
+		copy(ip, b)
 	}
-	if ip == nil {
-		return
-	}
-	binary.BigEndian.PutUint32(ip, uint32(b.Uint64())) //nolint:gosec
 	return ip
 }
 
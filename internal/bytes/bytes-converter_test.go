@@ -105,6 +105,13 @@ func Test_ByteEncoder(t *testing.T) {
 				return RawBytes([]byte{93, 184, 216, 34}).Ip().String()
 			},
 		},
+		{
+			name:     "to ip6 String",
+			expected: "2001:db8::1",
+			encode: func() any {
+				return RawBytes([]byte{0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1}).Ip().String()
+			},
+		},
 		{
 			name:     "to CIDR String",
 			expected: "10.0.0.0/8",
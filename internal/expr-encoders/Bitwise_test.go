@@ -0,0 +1,326 @@
+package encoders
+
+import (
+	"encoding/json"
+	"net"
+	"testing"
+
+	"github.com/Morwran/nft-go/pkg/nftexpr"
+
+	"github.com/google/nftables"
+	"github.com/google/nftables/expr"
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/sys/unix"
+)
+
+type bitwiseEncoderTestSuite struct {
+	suite.Suite
+}
+
+func (sui *bitwiseEncoderTestSuite) Test_BitwisePrefixAndMask() {
+	masked := func(load expr.Any, mask []byte, op expr.CmpOp, data []byte) []expr.Any {
+		return []expr.Any{
+			load,
+			&expr.Bitwise{
+				SourceRegister: 1,
+				DestRegister:   1,
+				Len:            uint32(len(mask)), //nolint:gosec
+				Mask:           mask,
+				Xor:            make([]byte, len(mask)),
+			},
+			&expr.Cmp{Op: op, Register: 1, Data: data},
+		}
+	}
+	addr := func(offset uint32, length uint32) expr.Any {
+		return &expr.Payload{
+			DestRegister: 1,
+			Base:         expr.PayloadBaseNetworkHeader,
+			Offset:       offset,
+			Len:          length,
+		}
+	}
+	testCases := []struct {
+		name     string
+		table    *nftables.Table
+		exprs    []expr.Any
+		expected string
+		expJSON  string
+	}{
+		{
+			name: "ip saddr prefix",
+			exprs: masked(addr(12, 4), []byte{255, 0, 0, 0},
+				expr.CmpOpEq, []byte{10, 0, 0, 0}),
+			expected: "ip saddr 10.0.0.0/8",
+			expJSON:  `[{"match":{"op":"==","left":{"payload":{"protocol":"ip","field":"saddr"}},"right":{"prefix":{"addr":"10.0.0.0","len":8}}}}]`,
+		},
+		{
+			name: "ip daddr != prefix",
+			exprs: masked(addr(16, 4), []byte{255, 255, 255, 0},
+				expr.CmpOpNeq, []byte{192, 168, 1, 0}),
+			expected: "ip daddr != 192.168.1.0/24",
+			expJSON:  `[{"match":{"op":"!=","left":{"payload":{"protocol":"ip","field":"daddr"}},"right":{"prefix":{"addr":"192.168.1.0","len":24}}}}]`,
+		},
+		{
+			name:  "ip6 daddr prefix",
+			table: &nftables.Table{Name: "filter", Family: nftables.TableFamilyIPv6},
+			exprs: masked(addr(24, 16), []byte{255, 255, 255, 255, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
+				expr.CmpOpEq, []byte(net.ParseIP("2001:db8::"))),
+			expected: "ip6 daddr 2001:db8::/32",
+			expJSON:  `[{"match":{"op":"==","left":{"payload":{"protocol":"ip6","field":"daddr"}},"right":{"prefix":{"addr":"2001:db8::","len":32}}}}]`,
+		},
+		{
+			name:     "ip saddr byte aligned prefix",
+			exprs:    []expr.Any{addr(12, 2), &expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{192, 168}}},
+			expected: "ip saddr 192.168.0.0/16",
+			expJSON:  `[{"match":{"op":"==","left":{"payload":{"protocol":"ip","field":"saddr"}},"right":{"prefix":{"addr":"192.168.0.0","len":16}}}}]`,
+		},
+		{
+			name: "ip daddr short masked prefix",
+			exprs: masked(addr(16, 3), []byte{255, 255, 0xf0},
+				expr.CmpOpEq, []byte{172, 16, 0x10}),
+			expected: "ip daddr 172.16.16.0/20",
+			expJSON:  `[{"match":{"op":"==","left":{"payload":{"protocol":"ip","field":"daddr"}},"right":{"prefix":{"addr":"172.16.16.0","len":20}}}}]`,
+		},
+		{
+			name:  "ip6 saddr byte aligned prefix",
+			table: &nftables.Table{Name: "filter", Family: nftables.TableFamilyIPv6},
+			exprs: []expr.Any{addr(8, 8), &expr.Cmp{Op: expr.CmpOpNeq, Register: 1,
+				Data: []byte{0xfe, 0x80, 0, 0, 0, 0, 0, 0}}},
+			expected: "ip6 saddr != fe80::/64",
+			expJSON:  `[{"match":{"op":"!=","left":{"payload":{"protocol":"ip6","field":"saddr"}},"right":{"prefix":{"addr":"fe80::","len":64}}}}]`,
+		},
+		{
+			name: "meta mark mask",
+			exprs: masked(&expr.Meta{Key: expr.MetaKeyMARK, Register: 1}, []byte{0x00, 0xff, 0, 0},
+				expr.CmpOpEq, []byte{0x00, 0x01, 0, 0}),
+			expected: "meta mark & 0xff00 == 0x100",
+			expJSON:  `[{"match":{"op":"==","left":{"\u0026":[{"meta":{"key":"mark"}},65280]},"right":256}}]`,
+		},
+		{
+			name: "ct mark mask",
+			exprs: masked(&expr.Ct{Key: expr.CtKeyMARK, Register: 1}, []byte{0x0f, 0, 0, 0},
+				expr.CmpOpNeq, []byte{0x01, 0, 0, 0}),
+			expected: "ct mark & 0xf != 0x1",
+			expJSON:  `[{"match":{"op":"!=","left":{"\u0026":[{"ct":{"key":"mark"}},15]},"right":1}}]`,
+		},
+		{
+			name: "prefix like mask of a header bitfield",
+			exprs: append([]expr.Any{
+				&expr.Meta{Key: expr.MetaKeyL4PROTO, Register: 1},
+				&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{unix.IPPROTO_GRE}},
+			}, masked(&expr.Payload{
+				DestRegister: 1,
+				Base:         expr.PayloadBaseTransportHeader,
+				Offset:       0,
+				Len:          1,
+			}, []byte{0xf8}, expr.CmpOpNeq, []byte{0x08})...),
+			expected: "gre flags != 0x1",
+		},
+		{
+			name: "non contiguous address mask is applied to the field",
+			exprs: masked(addr(12, 4), []byte{255, 0, 255, 0},
+				expr.CmpOpEq, []byte{10, 0, 1, 0}),
+			expected: "ip saddr & 255.0.255.0 == 10.0.1.0",
+		},
+	}
+
+	for _, tc := range testCases {
+		sui.Run(tc.name, func() {
+			rule := nftables.Rule{Table: tc.table, Exprs: tc.exprs}
+			str, err := NewRuleExprEncoder(&rule).Format()
+			sui.Require().NoError(err)
+			sui.Require().Equal(tc.expected, str)
+			if tc.expJSON != "" {
+				j, err := json.Marshal(NewRuleExprEncoder(&rule))
+				sui.Require().NoError(err)
+				sui.Require().Equal(tc.expJSON, string(j))
+			}
+		})
+	}
+}
+
+func (sui *bitwiseEncoderTestSuite) Test_BitwiseArith() {
+	markSet := func(key expr.Any) expr.Any {
+		switch t := key.(type) {
+		case *expr.Meta:
+			t.Register, t.SourceRegister = 1, true
+		case *expr.Ct:
+			t.Register, t.SourceRegister = 1, true
+		}
+		return key
+	}
+	shift := func(op nftexpr.BitwiseOp, n uint32) expr.Any {
+		return &nftexpr.Shift{SourceRegister: 1, DestRegister: 1, Len: 4, Op: op, Shift: n}
+	}
+	testCases := []struct {
+		name     string
+		exprs    []expr.Any
+		expected string
+		expJSON  string
+	}{
+		{
+			name: "meta mark set ct mark >> 16",
+			exprs: []expr.Any{
+				&expr.Ct{Key: expr.CtKeyMARK, Register: 1},
+				shift(nftexpr.NFT_BITWISE_RSHIFT, 16),
+				markSet(&expr.Meta{Key: expr.MetaKeyMARK}),
+			},
+			expected: "meta mark set ct mark >> 16",
+			expJSON:  `[{"mangle":{"key":{"meta":{"key":"mark"}},"value":{"\u003e\u003e":[{"ct":{"key":"mark"}},16]}}}]`,
+		},
+		{
+			name: "ct mark set meta mark & 0xffff0000 | 0x1",
+			exprs: []expr.Any{
+				&expr.Meta{Key: expr.MetaKeyMARK, Register: 1},
+				&expr.Bitwise{
+					SourceRegister: 1,
+					DestRegister:   1,
+					Len:            4,
+					Mask:           []byte{0, 0, 0xff, 0xff},
+					Xor:            []byte{1, 0, 0, 0},
+				},
+				markSet(&expr.Ct{Key: expr.CtKeyMARK}),
+			},
+			expected: "ct mark set meta mark & 0xffff0001 | 0x1",
+			expJSON:  `[{"mangle":{"key":{"ct":{"key":"mark"}},"value":{"|":[{"\u0026":[{"meta":{"key":"mark"}},4294901761]},1]}}}]`,
+		},
+		{
+			name: "shifted binary operation is parenthesized",
+			exprs: []expr.Any{
+				&expr.Meta{Key: expr.MetaKeyMARK, Register: 1},
+				&expr.Bitwise{
+					SourceRegister: 1,
+					DestRegister:   1,
+					Len:            4,
+					Mask:           []byte{0xfe, 0xff, 0xff, 0xff},
+					Xor:            []byte{1, 0, 0, 0},
+				},
+				shift(nftexpr.NFT_BITWISE_LSHIFT, 8),
+				markSet(&expr.Ct{Key: expr.CtKeyMARK}),
+			},
+			expected: "ct mark set (meta mark | 0x1) << 8",
+		},
+		{
+			name: "meta mark set jhash ip saddr mod 2 offset 100",
+			exprs: []expr.Any{
+				&expr.Payload{DestRegister: 1, Base: expr.PayloadBaseNetworkHeader, Offset: 12, Len: 4},
+				&expr.Hash{SourceRegister: 1, DestRegister: 1, Length: 4, Modulus: 2, Offset: 100, Type: expr.HashTypeJenkins},
+				markSet(&expr.Meta{Key: expr.MetaKeyMARK}),
+			},
+			expected: "meta mark set jhash ip saddr mod 2 offset 100",
+			expJSON:  `[{"mangle":{"key":{"meta":{"key":"mark"}},"value":{"jhash":{"mod":2,"offset":100,"expr":{"payload":{"protocol":"ip","field":"saddr"}}}}}}]`,
+		},
+		{
+			name: "meta mark set ip saddr >> 24",
+			exprs: []expr.Any{
+				&expr.Payload{DestRegister: 1, Base: expr.PayloadBaseNetworkHeader, Offset: 12, Len: 4},
+				&expr.Byteorder{SourceRegister: 1, DestRegister: 1, Op: expr.ByteorderNtoh, Len: 4, Size: 4},
+				shift(nftexpr.NFT_BITWISE_RSHIFT, 24),
+				markSet(&expr.Meta{Key: expr.MetaKeyMARK}),
+			},
+			expected: "meta mark set ip saddr >> 24",
+		},
+		{
+			name: "converted value compared in host order",
+			exprs: []expr.Any{
+				&expr.Ct{Key: expr.CtKeyMARK, Register: 1},
+				shift(nftexpr.NFT_BITWISE_RSHIFT, 16),
+				&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{0x10, 0, 0, 0}},
+			},
+			expected: "ct mark >> 16 == 0x10",
+			expJSON:  `[{"match":{"op":"==","left":{"\u003e\u003e":[{"ct":{"key":"mark"}},16]},"right":16}}]`,
+		},
+	}
+
+	for _, tc := range testCases {
+		sui.Run(tc.name, func() {
+			rule := nftables.Rule{
+				Table: &nftables.Table{Name: "filter", Family: nftables.TableFamilyIPv4},
+				Exprs: tc.exprs,
+			}
+			str, err := NewRuleExprEncoder(&rule).Format()
+			sui.Require().NoError(err)
+			sui.Require().Equal(tc.expected, str)
+			if tc.expJSON != "" {
+				j, err := json.Marshal(NewRuleExprEncoder(&rule))
+				sui.Require().NoError(err)
+				sui.Require().Equal(tc.expJSON, string(j))
+			}
+		})
+	}
+}
+
+func (sui *bitwiseEncoderTestSuite) Test_BitwiseFlags() {
+	tcpFlags := func(mask byte, op expr.CmpOp, data byte) []expr.Any {
+		return []expr.Any{
+			&expr.Meta{Key: expr.MetaKeyL4PROTO, Register: 1},
+			&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{unix.IPPROTO_TCP}},
+			&expr.Payload{DestRegister: 1, Base: expr.PayloadBaseTransportHeader, Offset: 13, Len: 1},
+			&expr.Bitwise{SourceRegister: 1, DestRegister: 1, Len: 1, Mask: []byte{mask}, Xor: []byte{0}},
+			&expr.Cmp{Op: op, Register: 1, Data: []byte{data}},
+		}
+	}
+	const flags = `{"payload":{"protocol":"tcp","field":"flags"}}`
+	testCases := []struct {
+		name     string
+		exprs    []expr.Any
+		expected string
+		expJSON  string
+	}{
+		{
+			name:     "tcp flags syn",
+			exprs:    tcpFlags(0x02, expr.CmpOpNeq, 0),
+			expected: "tcp flags syn",
+			expJSON:  `[{"match":{"op":"in","left":` + flags + `,"right":"syn"}}]`,
+		},
+		{
+			name:     "tcp flags syn,ack",
+			exprs:    tcpFlags(0x12, expr.CmpOpNeq, 0),
+			expected: "tcp flags syn,ack",
+			expJSON:  `[{"match":{"op":"in","left":` + flags + `,"right":["syn","ack"]}}]`,
+		},
+		{
+			name:     "tcp flags & (syn | ack) == syn",
+			exprs:    tcpFlags(0x12, expr.CmpOpEq, 0x02),
+			expected: "tcp flags & (syn | ack) == syn",
+			expJSON:  `[{"match":{"op":"==","left":{"\u0026":[` + flags + `,{"|":["syn","ack"]}]},"right":"syn"}}]`,
+		},
+		{
+			name:     "tcp flags & (fin | syn | rst | ack) == syn",
+			exprs:    tcpFlags(0x17, expr.CmpOpEq, 0x02),
+			expected: "tcp flags & (fin | syn | rst | ack) == syn",
+			expJSON:  `[{"match":{"op":"==","left":{"\u0026":[` + flags + `,{"|":["fin","syn","rst","ack"]}]},"right":"syn"}}]`,
+		},
+		{
+			name:     "tcp flags & (syn | ack) == syn | ack",
+			exprs:    tcpFlags(0x12, expr.CmpOpEq, 0x12),
+			expected: "tcp flags & (syn | ack) == syn | ack",
+			expJSON:  `[{"match":{"op":"==","left":{"\u0026":[` + flags + `,{"|":["syn","ack"]}]},"right":{"|":["syn","ack"]}}}]`,
+		},
+		{
+			name:     "tcp flags & (syn | ack) == 0x0",
+			exprs:    tcpFlags(0x12, expr.CmpOpEq, 0),
+			expected: "tcp flags & (syn | ack) == 0x0",
+			expJSON:  `[{"match":{"op":"==","left":{"\u0026":[` + flags + `,{"|":["syn","ack"]}]},"right":0}}]`,
+		},
+	}
+
+	for _, tc := range testCases {
+		sui.Run(tc.name, func() {
+			rule := nftables.Rule{
+				Table: &nftables.Table{Name: "filter", Family: nftables.TableFamilyINet},
+				Exprs: tc.exprs,
+			}
+			str, err := NewRuleExprEncoder(&rule).Format()
+			sui.Require().NoError(err)
+			sui.Require().Equal(tc.expected, str)
+			j, err := json.Marshal(NewRuleExprEncoder(&rule))
+			sui.Require().NoError(err)
+			sui.Require().Equal(tc.expJSON, string(j))
+		})
+	}
+}
+
+func Test_BitwiseEncoder(t *testing.T) {
+	suite.Run(t, new(bitwiseEncoderTestSuite))
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/google/nftables"
 	"github.com/google/nftables/expr"
 	"github.com/stretchr/testify/suite"
+	"golang.org/x/sys/unix"
 )
 
 type cmpEncoderExprBasedTestSuite struct {
@@ -102,6 +103,32 @@ func (sui *cmpEncoderExprBasedTestSuite) Test_CmpEncodeIR_ExprBased() {
 			},
 			expected: "ip version 6",
 		},
+		{
+			name: "dccp type != request",
+			exprs: []expr.Any{
+				&expr.Meta{Key: expr.MetaKeyL4PROTO, Register: 1},
+				&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{unix.IPPROTO_DCCP}},
+				&expr.Payload{
+					Base:         expr.PayloadBaseTransportHeader,
+					Offset:       8,
+					Len:          1,
+					DestRegister: 1,
+				},
+				&expr.Bitwise{
+					SourceRegister: 1,
+					DestRegister:   2,
+					Len:            1,
+					Mask:           []byte{0x1e},
+					Xor:            []byte{0x00},
+				},
+				&expr.Cmp{
+					Op:       expr.CmpOpNeq,
+					Register: 2,
+					Data:     []byte{0x00},
+				},
+			},
+			expected: "dccp type != request",
+		},
 		{
 			name: "meta cpu == 3",
 			exprs: []expr.Any{
@@ -114,6 +141,25 @@ func (sui *cmpEncoderExprBasedTestSuite) Test_CmpEncodeIR_ExprBased() {
 			},
 			expected: "meta cpu 3",
 		},
+		{
+			name: "byteorder keeps == implicit",
+			exprs: []expr.Any{
+				&expr.Meta{Key: expr.MetaKeyCPU, Register: 1},
+				&expr.Byteorder{
+					SourceRegister: 1,
+					DestRegister:   1,
+					Op:             expr.ByteorderHton,
+					Len:            4,
+					Size:           4,
+				},
+				&expr.Cmp{
+					Op:       expr.CmpOpEq,
+					Register: 1,
+					Data:     []byte{0, 0, 0, 3},
+				},
+			},
+			expected: "meta cpu 3",
+		},
 	}
 
 	for _, tc := range testCases {
@@ -50,9 +50,9 @@ func (sui *connlimitEncoderTestSuite) Test_ConnlimitEncodeIR() {
 		sui.Run(tc.name, func() {
 			ctx := &ctx{}
 			enc := &connlimitEncoder{connlimit: tc.connlimit}
-			ir, err := enc.EncodeIR(ctx)
+			stmt, err := enc.Encode(ctx)
 			sui.Require().NoError(err)
-			sui.Require().Equal(tc.expected, ir.Format())
+			sui.Require().Equal(tc.expected, stmt.String())
 		})
 	}
 }
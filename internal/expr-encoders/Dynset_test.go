@@ -1,9 +1,12 @@
 package encoders
 
 import (
+	"encoding/json"
 	"testing"
 	"time"
 
+	"github.com/Morwran/nft-go/pkg/resolver"
+
 	"github.com/google/nftables"
 	"github.com/google/nftables/expr"
 	"github.com/stretchr/testify/suite"
@@ -88,6 +91,87 @@ func (sui *dynsetIRExprTestSuite) Test_DynsetEncodeIR_ExprBased() {
 	}
 }
 
+func (sui *dynsetIRExprTestSuite) Test_DynsetStatements() {
+	table := &nftables.Table{Name: "dynset-test", Family: nftables.TableFamilyIPv4}
+	sets := resolver.NewSets()
+	for _, set := range []nftables.Set{
+		{Table: table, Name: "flood", Anonymous: true, Dynamic: true, KeyType: nftables.TypeIPAddr},
+		{Table: table, Name: "portmap", IsMap: true, KeyType: nftables.TypeIPAddr, DataType: nftables.TypeInetService},
+		{Table: table, Name: "blocked", KeyType: nftables.TypeIPAddr},
+	} {
+		sets.Add(&set, nil)
+	}
+
+	saddr := &expr.Payload{DestRegister: 1, Base: expr.PayloadBaseNetworkHeader, Offset: 12, Len: 4}
+	limit := &expr.Limit{Type: expr.LimitTypePkts, Rate: 10, Unit: expr.LimitTimeSecond, Over: true}
+	testCases := []struct {
+		name     string
+		exprs    []expr.Any
+		expected string
+		expJSON  string
+	}{
+		{
+			name: "delete",
+			exprs: []expr.Any{saddr, &expr.Dynset{
+				Operation: uint32(DynSetOPDelete), SetName: "blocked", SrcRegKey: 1,
+			}},
+			expected: "delete @blocked { ip saddr }",
+			expJSON:  `[{"set":{"op":"delete","elem":{"payload":{"protocol":"ip","field":"saddr"}},"set":"@blocked"}}]`,
+		},
+		{
+			name: "inverted add with timeout",
+			exprs: []expr.Any{saddr, &expr.Dynset{
+				Operation: uint32(DynSetOPAdd), SetName: "blocked", SrcRegKey: 1,
+				Timeout: 90 * time.Second, Invert: true,
+			}},
+			expected: "add ! @blocked { ip saddr timeout 1m30s }",
+			expJSON:  `[{"set":{"op":"add","elem":{"elem":{"val":{"payload":{"protocol":"ip","field":"saddr"}},"timeout":90}},"set":"@blocked","inv":true}}]`,
+		},
+		{
+			name: "update with limit",
+			exprs: []expr.Any{saddr, &expr.Dynset{
+				Operation: uint32(DynSetOPUpdate), SetName: "blocked", SrcRegKey: 1,
+				Exprs: []expr.Any{limit},
+			}},
+			expected: "update @blocked { ip saddr limit rate over 10/second burst 0 packets }",
+			expJSON:  `[{"set":{"op":"update","elem":{"payload":{"protocol":"ip","field":"saddr"}},"set":"@blocked","stmt":[{"limit":{"rate":10,"burst":0,"per":"second","inv":true}}]}}]`,
+		},
+		{
+			name: "map update with data",
+			exprs: []expr.Any{
+				saddr,
+				&expr.Immediate{Register: 2, Data: []byte{0x1f, 0x90}},
+				&expr.Dynset{
+					Operation: uint32(DynSetOPAdd), SetName: "portmap", SrcRegKey: 1, SrcRegData: 2,
+				},
+			},
+			expected: "add @portmap { ip saddr : 8080 }",
+			expJSON:  `[{"map":{"op":"add","elem":{"payload":{"protocol":"ip","field":"saddr"}},"data":8080,"map":"@portmap"}}]`,
+		},
+		{
+			name: "meter",
+			exprs: []expr.Any{saddr, &expr.Dynset{
+				Operation: uint32(DynSetOPUpdate), SetName: "flood", SrcRegKey: 1,
+				Timeout: 10 * time.Second, Exprs: []expr.Any{limit},
+			}},
+			expected: "meter flood { ip saddr timeout 10s limit rate over 10/second burst 0 packets }",
+			expJSON:  `[{"meter":{"name":"flood","key":{"elem":{"val":{"payload":{"protocol":"ip","field":"saddr"}},"timeout":10}},"stmt":{"limit":{"rate":10,"burst":0,"per":"second","inv":true}}}}]`,
+		},
+	}
+
+	for _, tc := range testCases {
+		sui.Run(tc.name, func() {
+			rule := &nftables.Rule{Table: table, Exprs: tc.exprs}
+			str, err := NewRuleExprEncoder(rule, WithSets(sets)).Format()
+			sui.Require().NoError(err)
+			sui.Require().Equal(tc.expected, str)
+			j, err := json.Marshal(NewRuleExprEncoder(rule, WithSets(sets)))
+			sui.Require().NoError(err)
+			sui.Require().Equal(tc.expJSON, string(j))
+		})
+	}
+}
+
 func Test_DynsetEncodeIR_Expr(t *testing.T) {
 	suite.Run(t, new(dynsetIRExprTestSuite))
 }
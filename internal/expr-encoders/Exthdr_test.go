@@ -91,6 +91,206 @@ func (sui *exthdrExprBasedTestSuite) Test_ExthdrEncodeIR_ExprBased() {
 	}
 }
 
+func (sui *exthdrExprBasedTestSuite) Test_ExthdrDescriptors() {
+	testData := []struct {
+		name     string
+		exprs    []expr.Any
+		expected string
+	}{
+		{
+			name: "tcp option maxseg size",
+			exprs: []expr.Any{
+				&expr.Exthdr{
+					Op:           expr.ExthdrOpTcpopt,
+					Type:         2,
+					Offset:       2,
+					Len:          2,
+					DestRegister: 1,
+				},
+				&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{0x05, 0x50}},
+			},
+			expected: "tcp option maxseg size 1360",
+		},
+		{
+			name: "tcp option maxseg size set",
+			exprs: []expr.Any{
+				&expr.Immediate{Register: 1, Data: []byte{0x05, 0x50}},
+				&expr.Exthdr{
+					Op:             expr.ExthdrOpTcpopt,
+					Type:           2,
+					Offset:         2,
+					Len:            2,
+					SourceRegister: 1,
+				},
+			},
+			expected: "tcp option maxseg size set 1360",
+		},
+		{
+			name: "tcp option maxseg size set rt mtu",
+			exprs: []expr.Any{
+				&expr.Rt{Register: 1, Key: expr.RtTCPMSS},
+				&expr.Exthdr{
+					Op:             expr.ExthdrOpTcpopt,
+					Type:           2,
+					Offset:         2,
+					Len:            2,
+					SourceRegister: 1,
+				},
+			},
+			expected: "tcp option maxseg size set rt mtu",
+		},
+		{
+			name: "tcp option sack-perm exists",
+			exprs: []expr.Any{
+				&expr.Exthdr{
+					Op:           expr.ExthdrOpTcpopt,
+					Type:         4,
+					Len:          1,
+					Flags:        unix.NFT_EXTHDR_F_PRESENT,
+					DestRegister: 1,
+				},
+				&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{1}},
+			},
+			expected: "tcp option sack-perm exists",
+		},
+		{
+			name: "reset tcp option timestamp",
+			exprs: []expr.Any{
+				&expr.Exthdr{
+					Op:   expr.ExthdrOpTcpopt,
+					Type: 8,
+				},
+			},
+			expected: "reset tcp option timestamp",
+		},
+		{
+			name: "frag more-fragments",
+			exprs: []expr.Any{
+				&expr.Exthdr{
+					Op:           expr.ExthdrOpIpv6,
+					Type:         unix.IPPROTO_FRAGMENT,
+					Offset:       3,
+					Len:          1,
+					DestRegister: 1,
+				},
+				&expr.Bitwise{
+					SourceRegister: 1,
+					DestRegister:   1,
+					Len:            1,
+					Mask:           []byte{0x01},
+					Xor:            []byte{0x00},
+				},
+				&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{1}},
+			},
+			expected: "frag more-fragments 1",
+		},
+		{
+			name: "frag frag-off",
+			exprs: []expr.Any{
+				&expr.Exthdr{
+					Op:           expr.ExthdrOpIpv6,
+					Type:         unix.IPPROTO_FRAGMENT,
+					Offset:       2,
+					Len:          2,
+					DestRegister: 1,
+				},
+				&expr.Bitwise{
+					SourceRegister: 1,
+					DestRegister:   1,
+					Len:            2,
+					Mask:           []byte{0xff, 0xf8},
+					Xor:            []byte{0x00, 0x00},
+				},
+				&expr.Cmp{Op: expr.CmpOpNeq, Register: 1, Data: []byte{0x00, 0x00}},
+			},
+			expected: "frag frag-off != 0",
+		},
+		{
+			name: "exthdr hbh exists",
+			exprs: []expr.Any{
+				&expr.Exthdr{
+					Op:           expr.ExthdrOpIpv6,
+					Type:         unix.IPPROTO_HOPOPTS,
+					Len:          1,
+					Flags:        unix.NFT_EXTHDR_F_PRESENT,
+					DestRegister: 1,
+				},
+				&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{1}},
+			},
+			expected: "exthdr hbh exists",
+		},
+		{
+			name: "srh last-entry",
+			exprs: []expr.Any{
+				&expr.Exthdr{
+					Op:           expr.ExthdrOpIpv6,
+					Type:         unix.IPPROTO_ROUTING,
+					Offset:       4,
+					Len:          1,
+					DestRegister: 1,
+				},
+				&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{2}},
+			},
+			expected: "srh last-entry 2",
+		},
+		{
+			name: "ip option rr exists",
+			exprs: []expr.Any{
+				&expr.Exthdr{
+					Op:           2,
+					Type:         7,
+					Len:          1,
+					Flags:        unix.NFT_EXTHDR_F_PRESENT,
+					DestRegister: 1,
+				},
+				&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{1}},
+			},
+			expected: "ip option rr exists",
+		},
+		{
+			name: "ip option lsrr addr",
+			exprs: []expr.Any{
+				&expr.Exthdr{
+					Op:           2,
+					Type:         131,
+					Offset:       3,
+					Len:          4,
+					DestRegister: 1,
+				},
+				&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{10, 0, 0, 1}},
+			},
+			expected: "ip option lsrr addr 10.0.0.1",
+		},
+	}
+
+	for _, tc := range testData {
+		sui.Run(tc.name, func() {
+			rule := &nftables.Rule{Exprs: tc.exprs}
+			str, err := NewRuleExprEncoder(rule).Format()
+			sui.Require().NoError(err)
+			sui.Require().Equal(tc.expected, str)
+		})
+	}
+}
+
+func (sui *exthdrExprBasedTestSuite) Test_ExthdrToJSON() {
+	rule := &nftables.Rule{
+		Exprs: []expr.Any{
+			&expr.Immediate{Register: 1, Data: []byte{0x05, 0x50}},
+			&expr.Exthdr{
+				Op:             expr.ExthdrOpTcpopt,
+				Type:           2,
+				Offset:         2,
+				Len:            2,
+				SourceRegister: 1,
+			},
+		},
+	}
+	b, err := NewRuleExprEncoder(rule).MarshalJSON()
+	sui.Require().NoError(err)
+	sui.Require().Equal(`[{"mangle":{"key":{"tcp option":{"name":"maxseg","field":"size"}},"value":1360}}]`, string(b))
+}
+
 func Test_ExthdrExprBased(t *testing.T) {
 	suite.Run(t, new(exthdrExprBasedTestSuite))
 }
@@ -0,0 +1,718 @@
+package encoders
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Morwran/nft-go/pkg/nftexpr"
+	"github.com/Morwran/nft-go/pkg/resolver"
+
+	"github.com/google/nftables"
+	"github.com/google/nftables/expr"
+	"github.com/google/nftables/xt"
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/sys/unix"
+)
+
+type jsonEncoderTestSuite struct {
+	suite.Suite
+}
+
+type jsonCase struct {
+	name     string
+	exprs    []expr.Any
+	expected string
+	expJSON  string
+}
+
+// jsonCases returns the cases of Test_RegisteredEncoders by the type of the
+// expression they are written for, the JSON follows the schema of
+// libnftables-json(5).
+func jsonCases() map[string][]jsonCase {
+	return map[string][]jsonCase{
+		"*expr.Bitwise": {{
+			name: "ct state established,related",
+			exprs: []expr.Any{
+				&expr.Ct{Key: expr.CtKeySTATE, Register: 1},
+				&expr.Bitwise{SourceRegister: 1, DestRegister: 1, Len: 4, Mask: []byte{6, 0, 0, 0}, Xor: []byte{0, 0, 0, 0}},
+				&expr.Cmp{Op: expr.CmpOpNeq, Register: 1, Data: []byte{0, 0, 0, 0}},
+			},
+			expected: "ct state established,related",
+			expJSON:  `[{"match":{"op":"in","left":{"ct":{"key":"state"}},"right":["established","related"]}}]`,
+		}, {
+			name: "ct state & (established | related) == 0x0",
+			exprs: []expr.Any{
+				&expr.Ct{Key: expr.CtKeySTATE, Register: 1},
+				&expr.Bitwise{SourceRegister: 1, DestRegister: 1, Len: 4, Mask: []byte{6, 0, 0, 0}, Xor: []byte{0, 0, 0, 0}},
+				&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{0, 0, 0, 0}},
+			},
+			expected: "ct state & (established | related) == 0x0",
+			expJSON:  `[{"match":{"op":"==","left":{"&":[{"ct":{"key":"state"}},{"|":["established","related"]}]},"right":0}}]`,
+		}},
+		"*expr.Byteorder": {{
+			name: "meta mark in network order",
+			exprs: []expr.Any{
+				&expr.Meta{Key: expr.MetaKeyMARK, Register: 1},
+				&expr.Byteorder{SourceRegister: 1, DestRegister: 1, Op: expr.ByteorderHton, Len: 4, Size: 4},
+				&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{0, 0, 0, 1}},
+			},
+			expected: "meta mark 1",
+			expJSON:  `[{"match":{"op":"==","left":{"meta":{"key":"mark"}},"right":1}}]`,
+		}},
+		"*expr.Cmp": {
+			{
+				name: "tcp dport 22 accept",
+				exprs: []expr.Any{
+					&expr.Meta{Key: expr.MetaKeyL4PROTO, Register: 1},
+					&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{unix.IPPROTO_TCP}},
+					&expr.Payload{DestRegister: 1, Base: expr.PayloadBaseTransportHeader, Offset: 2, Len: 2},
+					&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{0, 22}},
+					&expr.Verdict{Kind: expr.VerdictAccept},
+				},
+				expected: "tcp dport 22 accept",
+				expJSON:  `[{"match":{"op":"==","left":{"payload":{"protocol":"tcp","field":"dport"}},"right":22}},{"accept":null}]`,
+			},
+			{
+				name: "ip protocol icmp",
+				exprs: []expr.Any{
+					&expr.Payload{DestRegister: 1, Base: expr.PayloadBaseNetworkHeader, Offset: 9, Len: 1},
+					&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{unix.IPPROTO_ICMP}},
+				},
+				expected: "ip protocol icmp",
+				expJSON:  `[{"match":{"op":"==","left":{"payload":{"protocol":"ip","field":"protocol"}},"right":"icmp"}}]`,
+			},
+			{
+				name: "meta length > 1000",
+				exprs: []expr.Any{
+					&expr.Meta{Key: expr.MetaKeyLEN, Register: 1},
+					&expr.Cmp{Op: expr.CmpOpGt, Register: 1, Data: []byte{0xe8, 0x03, 0, 0}},
+				},
+				expected: "meta length > 1000",
+				expJSON:  `[{"match":{"op":">","left":{"meta":{"key":"length"}},"right":1000}}]`,
+			},
+			{
+				name: "iifname lo",
+				exprs: []expr.Any{
+					&expr.Meta{Key: expr.MetaKeyIIFNAME, Register: 1},
+					&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte("lo\x00")},
+				},
+				expected: "iifname lo",
+				expJSON:  `[{"match":{"op":"==","left":{"meta":{"key":"iifname"}},"right":"lo"}}]`,
+			},
+		},
+		"*expr.Connlimit": {
+			{
+				name:     "ct count 5",
+				exprs:    []expr.Any{&expr.Connlimit{Count: 5}},
+				expected: "ct count 5",
+				expJSON:  `[{"ct count":{"val":5}}]`,
+			},
+			{
+				name:     "ct count over 10",
+				exprs:    []expr.Any{&expr.Connlimit{Count: 10, Flags: unix.NFT_LIMIT_F_INV}},
+				expected: "ct count over 10",
+				expJSON:  `[{"ct count":{"val":10,"inv":true}}]`,
+			},
+		},
+		"*expr.Counter": {{
+			name:     "counter",
+			exprs:    []expr.Any{&expr.Counter{Bytes: 1024, Packets: 8}},
+			expected: "counter packets 0 bytes 0",
+			expJSON:  `[{"counter":{"bytes":1024,"packets":8}}]`,
+		}},
+		"*expr.Ct": {
+			{
+				name: "ct state established,new",
+				exprs: []expr.Any{
+					&expr.Ct{Key: expr.CtKeySTATE, Register: 1},
+					&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{0x0a, 0, 0, 0}},
+				},
+				expected: "ct state established,new",
+				expJSON:  `[{"match":{"op":"==","left":{"ct":{"key":"state"}},"right":["established","new"]}}]`,
+			},
+			{
+				name: "ct direction original",
+				exprs: []expr.Any{
+					&expr.Ct{Key: expr.CtKeyDIRECTION, Register: 1},
+					&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{0}},
+				},
+				expected: "ct direction original",
+				expJSON:  `[{"match":{"op":"==","left":{"ct":{"key":"direction"}},"right":"original"}}]`,
+			},
+			{
+				name: "ct mark set 1",
+				exprs: []expr.Any{
+					&expr.Immediate{Register: 1, Data: []byte{0x01, 0, 0, 0}},
+					&expr.Ct{Key: expr.CtKeyMARK, Register: 1, SourceRegister: true},
+				},
+				expected: "ct mark set 1",
+				expJSON:  `[{"mangle":{"key":{"ct":{"key":"mark"}},"value":1}}]`,
+			},
+		},
+		"*expr.Dup": {{
+			name: "dup to address device",
+			exprs: []expr.Any{
+				&expr.Immediate{Register: 1, Data: []byte{192, 168, 1, 10}},
+				&expr.Immediate{Register: 2, Data: []byte{0x92, 0x10, 0, 0}},
+				&expr.Dup{RegAddr: 1, RegDev: 2, IsRegDevSet: true},
+			},
+			expected: "dup to 192.168.1.10 device eth0",
+			expJSON:  `[{"dup":{"addr":"192.168.1.10","dev":"eth0"}}]`,
+		}},
+		"*expr.Dynset": {{
+			name: "add @blocked",
+			exprs: []expr.Any{
+				&expr.Payload{DestRegister: 1, Base: expr.PayloadBaseNetworkHeader, Offset: 12, Len: 4},
+				&expr.Dynset{SrcRegKey: 1, SetName: "blocked", Operation: uint32(unix.NFT_DYNSET_OP_ADD)},
+			},
+			expected: "add @blocked { ip saddr }",
+			expJSON:  `[{"set":{"op":"add","elem":{"payload":{"protocol":"ip","field":"saddr"}},"set":"@blocked"}}]`,
+		}},
+		"*expr.Exthdr": {
+			{
+				name: "tcp option maxseg size",
+				exprs: []expr.Any{
+					&expr.Exthdr{DestRegister: 1, Type: 2, Offset: 2, Len: 2, Op: expr.ExthdrOpTcpopt},
+					&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{0x05, 0x50}},
+				},
+				expected: "tcp option maxseg size 1360",
+				expJSON:  `[{"match":{"op":"==","left":{"tcp option":{"name":"maxseg","field":"size"}},"right":1360}}]`,
+			},
+			{
+				name: "tcp option sack-perm exists",
+				exprs: []expr.Any{
+					&expr.Exthdr{DestRegister: 1, Type: 4, Len: 1, Op: expr.ExthdrOpTcpopt, Flags: unix.NFT_EXTHDR_F_PRESENT},
+					&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{1}},
+				},
+				expected: "tcp option sack-perm exists",
+				expJSON:  `[{"match":{"op":"==","left":{"tcp option":{"name":"sack-perm"}},"right":true}}]`,
+			},
+		},
+		"*expr.Fib": {{
+			name: "fib daddr type local",
+			exprs: []expr.Any{
+				&expr.Fib{Register: 1, FlagDADDR: true, ResultADDRTYPE: true},
+				&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{unix.RTN_LOCAL, 0, 0, 0}},
+			},
+			expected: "fib daddr type local",
+			expJSON:  `[{"match":{"op":"==","left":{"fib":{"result":"type","flags":["daddr"]}},"right":"local"}}]`,
+		}},
+		"*expr.FlowOffload": {{
+			name:     "flow add @ft",
+			exprs:    []expr.Any{&expr.FlowOffload{Name: "ft"}},
+			expected: "flow add @ft",
+			expJSON:  `[{"flow":{"op":"add","flowtable":"ft"}}]`,
+		}},
+		"*expr.Hash": {{
+			name: "jhash ip saddr mod 10",
+			exprs: []expr.Any{
+				&expr.Payload{DestRegister: 1, Base: expr.PayloadBaseNetworkHeader, Offset: 12, Len: 4},
+				&expr.Hash{SourceRegister: 1, DestRegister: 1, Length: 4, Modulus: 10, Type: expr.HashTypeJenkins},
+				&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{1, 0, 0, 0}},
+			},
+			expected: "jhash ip saddr mod 10 1",
+			expJSON:  `[{"match":{"op":"==","left":{"jhash":{"mod":10,"expr":{"payload":{"protocol":"ip","field":"saddr"}}}},"right":1}}]`,
+		}},
+		"*expr.Immediate": {{
+			name: "meta mark set 16",
+			exprs: []expr.Any{
+				&expr.Immediate{Register: 1, Data: []byte{0x10, 0, 0, 0}},
+				&expr.Meta{Key: expr.MetaKeyMARK, Register: 1, SourceRegister: true},
+			},
+			expected: "meta mark set 16",
+			expJSON:  `[{"mangle":{"key":{"meta":{"key":"mark"}},"value":16}}]`,
+		}},
+		"*expr.Limit": {{
+			name:     "limit rate 10/second burst 5 packets",
+			exprs:    []expr.Any{&expr.Limit{Type: expr.LimitTypePkts, Rate: 10, Unit: expr.LimitTimeSecond, Burst: 5}},
+			expected: "limit rate 10/second burst 5 packets",
+			expJSON:  `[{"limit":{"rate":10,"burst":5,"per":"second"}}]`,
+		}},
+		"*expr.Log": {{
+			name: "log prefix level",
+			exprs: []expr.Any{&expr.Log{
+				Key:   1<<unix.NFTA_LOG_PREFIX | 1<<unix.NFTA_LOG_LEVEL,
+				Data:  []byte("dropped: "),
+				Level: expr.LogLevelWarning,
+			}},
+			expected: `log prefix "dropped: " level warn`,
+			expJSON:  `[{"log":{"prefix":"dropped: ","level":"warn"}}]`,
+		}},
+		"*expr.Lookup": {{
+			name: "ip saddr @allowed",
+			exprs: []expr.Any{
+				&expr.Payload{DestRegister: 1, Base: expr.PayloadBaseNetworkHeader, Offset: 12, Len: 4},
+				&expr.Lookup{SourceRegister: 1, SetName: "allowed"},
+			},
+			expected: "ip saddr @allowed",
+			expJSON:  `[{"match":{"op":"==","left":{"payload":{"protocol":"ip","field":"saddr"}},"right":"@allowed"}}]`,
+		}},
+		"*expr.Masq": {{
+			name:     "masquerade random",
+			exprs:    []expr.Any{&expr.Masq{Random: true}},
+			expected: "masquerade random",
+			expJSON:  `[{"masquerade":{"flags":"random"}}]`,
+		}},
+		"*expr.Match": {{
+			name:     "xt match",
+			exprs:    []expr.Any{&expr.Match{Name: "foo", Rev: 1, Info: &xt.Unknown{1, 2, 3, 4}}},
+			expected: `xt match "foo"`,
+			expJSON:  `[{"xt":{"type":"match","name":"foo"}}]`,
+		}},
+		"*expr.Meta": {{
+			name: "meta l4proto udp",
+			exprs: []expr.Any{
+				&expr.Meta{Key: expr.MetaKeyL4PROTO, Register: 1},
+				&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{unix.IPPROTO_UDP}},
+			},
+			expected: "meta l4proto udp",
+			expJSON:  `[{"match":{"op":"==","left":{"meta":{"key":"l4proto"}},"right":"udp"}}]`,
+		}},
+		"*expr.NAT": {{
+			name: "snat to 10.0.0.1:8080",
+			exprs: []expr.Any{
+				&expr.Immediate{Register: 1, Data: []byte{10, 0, 0, 1}},
+				&expr.Immediate{Register: 2, Data: []byte{0x1f, 0x90}},
+				&expr.NAT{Type: expr.NATTypeSourceNAT, Family: unix.NFPROTO_IPV4, RegAddrMin: 1, RegProtoMin: 2},
+			},
+			expected: "snat to 10.0.0.1:8080",
+			expJSON:  `[{"snat":{"addr":"10.0.0.1","port":8080}}]`,
+		}},
+		"*expr.Ndpi": {{
+			name:     "ndpi protocol HTTP",
+			exprs:    []expr.Any{&expr.Ndpi{Protocols: []string{"HTTP"}}},
+			expected: "ndpi protocol HTTP",
+			expJSON:  `[{"ndpi":{"protocols":["HTTP"]}}]`,
+		}},
+		"*expr.Notrack": {{
+			name:     "notrack",
+			exprs:    []expr.Any{&expr.Notrack{}},
+			expected: "notrack",
+			expJSON:  `[{"notrack":null}]`,
+		}},
+		"*expr.Numgen": {{
+			name: "numgen inc mod 2",
+			exprs: []expr.Any{
+				&expr.Numgen{Register: 1, Modulus: 2, Type: unix.NFT_NG_INCREMENTAL},
+				&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{0, 0, 0, 0}},
+			},
+			expected: "numgen inc mod 2 0",
+			expJSON:  `[{"match":{"op":"==","left":{"numgen":{"mode":"inc","mod":2,"offset":0}},"right":0}}]`,
+		}},
+		"*expr.Objref": {{
+			name:     "counter name cnt",
+			exprs:    []expr.Any{&expr.Objref{Type: unix.NFT_OBJECT_COUNTER, Name: "cnt"}},
+			expected: "counter name cnt",
+			expJSON:  `[{"counter":"cnt"}]`,
+		}},
+		"*expr.Payload": {
+			{
+				name: "ip saddr 10.0.0.1 drop",
+				exprs: []expr.Any{
+					&expr.Payload{DestRegister: 1, Base: expr.PayloadBaseNetworkHeader, Offset: 12, Len: 4},
+					&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{10, 0, 0, 1}},
+					&expr.Verdict{Kind: expr.VerdictDrop},
+				},
+				expected: "ip saddr 10.0.0.1 drop",
+				expJSON:  `[{"match":{"op":"==","left":{"payload":{"protocol":"ip","field":"saddr"}},"right":"10.0.0.1"}},{"drop":null}]`,
+			},
+			{
+				name: "raw payload",
+				exprs: []expr.Any{
+					&expr.Payload{DestRegister: 1, Base: expr.PayloadBaseTransportHeader, Offset: 20, Len: 2},
+					&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{0, 0x10}},
+				},
+				expected: "@th,160,16 16",
+				expJSON:  `[{"match":{"op":"==","left":{"payload":{"base":"th","offset":160,"len":16}},"right":16}}]`,
+			},
+			{
+				name: "ip ttl set 64",
+				exprs: []expr.Any{
+					&expr.Immediate{Register: 1, Data: []byte{64}},
+					&expr.Payload{
+						OperationType:  expr.PayloadWrite,
+						SourceRegister: 1,
+						Base:           expr.PayloadBaseNetworkHeader,
+						Offset:         8,
+						Len:            1,
+						CsumType:       expr.CsumTypeInet,
+						CsumOffset:     10,
+					},
+				},
+				expected: "ip ttl set 64",
+				expJSON:  `[{"mangle":{"key":{"payload":{"protocol":"ip","field":"ttl"}},"value":64}}]`,
+			},
+		},
+		"*expr.Queue": {{
+			name:     "queue flags bypass to 1-2",
+			exprs:    []expr.Any{&expr.Queue{Num: 1, Total: 2, Flag: expr.QueueFlagBypass}},
+			expected: "queue flags bypass to 1-2",
+			expJSON:  `[{"queue":{"num":{"range":[1,2]},"flags":"bypass"}}]`,
+		}},
+		"*expr.Quota": {{
+			name:     "quota over",
+			exprs:    []expr.Any{&expr.Quota{Bytes: 2 * 1024 * 1024, Consumed: 512, Over: true}},
+			expected: "quota over 2 mbytes used 512 bytes",
+			expJSON:  `[{"quota":{"val":2,"val_unit":"mbytes","used":512,"used_unit":"bytes","inv":true}}]`,
+		}},
+		"*expr.Range": {{
+			name: "tcp dport 1024-65535",
+			exprs: []expr.Any{
+				&expr.Meta{Key: expr.MetaKeyL4PROTO, Register: 1},
+				&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{unix.IPPROTO_TCP}},
+				&expr.Payload{DestRegister: 1, Base: expr.PayloadBaseTransportHeader, Offset: 2, Len: 2},
+				&expr.Range{Op: expr.CmpOpEq, Register: 1, FromData: []byte{0x04, 0}, ToData: []byte{0xff, 0xff}},
+			},
+			expected: "tcp dport 1024-65535",
+			expJSON:  `[{"match":{"op":"==","left":{"payload":{"protocol":"tcp","field":"dport"}},"right":{"range":[1024,65535]}}}]`,
+		}},
+		"*expr.Redir": {{
+			name: "redirect to :8080",
+			exprs: []expr.Any{
+				&expr.Immediate{Register: 1, Data: []byte{0x1f, 0x90}},
+				&expr.Redir{RegisterProtoMin: 1},
+			},
+			expected: "redirect to :8080",
+			expJSON:  `[{"redirect":{"port":8080}}]`,
+		}},
+		"*expr.Reject": {
+			{
+				name:     "reject with tcp reset",
+				exprs:    []expr.Any{&expr.Reject{Type: unix.NFT_REJECT_TCP_RST}},
+				expected: "reject with tcp reset 0",
+				expJSON:  `[{"reject":{"type":"tcp reset"}}]`,
+			},
+			{
+				name:     "reject with icmpx",
+				exprs:    []expr.Any{&expr.Reject{Type: unix.NFT_REJECT_ICMPX_UNREACH, Code: unix.NFT_REJECT_ICMPX_ADMIN_PROHIBITED}},
+				expected: "reject with icmpx 3",
+				expJSON:  `[{"reject":{"type":"icmpx","expr":"admin-prohibited"}}]`,
+			},
+		},
+		"*expr.Rt": {{
+			name: "rt mtu",
+			exprs: []expr.Any{
+				&expr.Rt{Register: 1, Key: expr.RtTCPMSS},
+				&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{0xdc, 0x05}},
+			},
+			expected: "rt mtu 1500",
+			expJSON:  `[{"match":{"op":"==","left":{"rt":{"key":"mtu"}},"right":1500}}]`,
+		}},
+		"*expr.Socket": {{
+			name: "socket transparent 1",
+			exprs: []expr.Any{
+				&expr.Socket{Key: expr.SocketKeyTransparent, Register: 1},
+				&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{1}},
+			},
+			expected: "socket transparent 1",
+			expJSON:  `[{"match":{"op":"==","left":{"socket":{"key":"transparent"}},"right":1}}]`,
+		}},
+		"*expr.TProxy": {{
+			name: "tproxy to :50080",
+			exprs: []expr.Any{
+				&expr.Immediate{Register: 1, Data: []byte{0xc3, 0xa0}},
+				&expr.TProxy{Family: unix.NFPROTO_IPV4, TableFamily: unix.NFPROTO_IPV4, RegPort: 1},
+			},
+			expected: "tproxy to :50080",
+			expJSON:  `[{"tproxy":{"port":50080}}]`,
+		}},
+		"*expr.Target": {{
+			name:     "xt target",
+			exprs:    []expr.Any{&expr.Target{Name: "MARK", Rev: 2, Info: &xt.Unknown{1, 2, 3, 4}}},
+			expected: `xt target "MARK"`,
+			expJSON:  `[{"xt":{"type":"target","name":"MARK"}}]`,
+		}},
+		"*expr.Verdict": {{
+			name:     "jump next",
+			exprs:    []expr.Any{&expr.Verdict{Kind: expr.VerdictJump, Chain: "next"}},
+			expected: "jump next",
+			expJSON:  `[{"jump":{"target":"next"}}]`,
+		}},
+		"*nftexpr.Fwd": {{
+			name: "fwd to device",
+			exprs: []expr.Any{
+				&expr.Immediate{Register: 1, Data: []byte{0x92, 0x10, 0, 0}},
+				&nftexpr.Fwd{RegDev: 1},
+			},
+			expected: "fwd to eth0",
+			expJSON:  `[{"fwd":{"dev":"eth0"}}]`,
+		}},
+		"*nftexpr.Inner": {{
+			name: "vxlan ip saddr",
+			exprs: []expr.Any{
+				&nftexpr.Inner{
+					Type:  nftexpr.NFT_INNER_VXLAN,
+					Flags: nftexpr.NFT_INNER_HDRSIZE | nftexpr.NFT_INNER_NH,
+					Expr:  &expr.Payload{DestRegister: 1, Base: expr.PayloadBaseNetworkHeader, Offset: 12, Len: 4},
+				},
+				&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{10, 0, 0, 1}},
+			},
+			expected: "vxlan ip saddr 10.0.0.1",
+			expJSON:  `[{"match":{"op":"==","left":{"inner":{"tunnel":"vxlan","expr":{"payload":{"protocol":"ip","field":"saddr"}}}},"right":"10.0.0.1"}}]`,
+		}},
+		"*nftexpr.Last": {{
+			name:     "last used",
+			exprs:    []expr.Any{&nftexpr.Last{Set: true, Msecs: 2000}},
+			expected: "last used 2s",
+			expJSON:  `[{"last":{"used":2000}}]`,
+		}},
+		"*nftexpr.Osf": {{
+			name: "osf name",
+			exprs: []expr.Any{
+				&nftexpr.Osf{Register: 1, TTL: nftexpr.NF_OSF_TTL_LESS},
+				&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte("Linux\x00")},
+			},
+			expected: `osf ttl loose name "Linux"`,
+			expJSON:  `[{"match":{"op":"==","left":{"osf":{"key":"name","ttl":"loose"}},"right":"Linux"}}]`,
+		}},
+		"*nftexpr.Shift": {{
+			name: "meta mark >> 16",
+			exprs: []expr.Any{
+				&expr.Meta{Key: expr.MetaKeyMARK, Register: 1},
+				&nftexpr.Shift{SourceRegister: 1, DestRegister: 1, Len: 4, Op: nftexpr.NFT_BITWISE_RSHIFT, Shift: 16},
+				&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{1, 0, 0, 0}},
+			},
+			expected: "meta mark >> 16 == 0x1",
+			expJSON:  `[{"match":{"op":"==","left":{">>":[{"meta":{"key":"mark"}},16]},"right":1}}]`,
+		}},
+		"*nftexpr.Synproxy": {{
+			name:     "synproxy",
+			exprs:    []expr.Any{&nftexpr.Synproxy{Mss: 1460, Wscale: 7, Flags: nftexpr.NF_SYNPROXY_OPT_MSS | nftexpr.NF_SYNPROXY_OPT_WSCALE}},
+			expected: "synproxy mss 1460 wscale 7",
+			expJSON:  `[{"synproxy":{"mss":1460,"wscale":7}}]`,
+		}},
+		"*nftexpr.Unknown": {{
+			name:     "unknown",
+			exprs:    []expr.Any{&nftexpr.Unknown{Name: "foo", Data: []byte{8, 0, 1, 0}}},
+			expected: "<expr name=foo data=0x08000100>",
+			expJSON:  `[{"unknown":{"name":"foo","data":"0x08000100"}}]`,
+		}},
+		"*nftexpr.Xfrm": {{
+			name: "ipsec in reqid 1",
+			exprs: []expr.Any{
+				&nftexpr.Xfrm{Register: 1, Key: nftexpr.NFT_XFRM_KEY_REQID, Dir: nftexpr.XFRM_POLICY_IN},
+				&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{1, 0, 0, 0}},
+			},
+			expected: "ipsec in reqid 1",
+			expJSON:  `[{"match":{"op":"==","left":{"ipsec":{"key":"reqid","dir":"in"}},"right":1}}]`,
+		}},
+	}
+}
+
+// Test_RegisteredEncoders renders the rules of jsonCases, every registered
+// encoder needs a case.
+func (sui *jsonEncoderTestSuite) Test_RegisteredEncoders() {
+	offline := &resolver.Static{Ifaces: map[uint32]string{4242: "eth0"}}
+	sets := resolver.NewSets()
+	sets.Add(&nftables.Set{
+		Name:    "allowed",
+		Table:   &nftables.Table{Name: "filter", Family: nftables.TableFamilyIPv4},
+		KeyType: nftables.TypeIPAddr,
+	}, []nftables.SetElement{{Key: []byte{10, 0, 0, 1}}})
+	sets.Add(&nftables.Set{
+		Name:    "blocked",
+		Table:   &nftables.Table{Name: "filter", Family: nftables.TableFamilyIPv4},
+		KeyType: nftables.TypeIPAddr,
+		Dynamic: true,
+	}, nil)
+	cases := jsonCases()
+	for _, typ := range RegisteredTypes() {
+		sui.Run(typ, func() {
+			sui.Require().NotEmptyf(cases[typ], "no JSON case for %s", typ)
+			for _, tc := range cases[typ] {
+				sui.Run(tc.name, func() {
+					rule := nftables.Rule{
+						Table: &nftables.Table{Name: "filter", Family: nftables.TableFamilyIPv4},
+						Exprs: tc.exprs,
+					}
+					str, err := NewRuleExprEncoder(&rule, WithResolver(offline), WithSets(sets)).Format()
+					sui.Require().NoError(err)
+					j, err := json.Marshal(NewRuleExprEncoder(&rule, WithResolver(offline), WithSets(sets)))
+					sui.Require().NoError(err)
+					sui.Require().Equal(tc.expected, str)
+					sui.Require().JSONEq(tc.expJSON, string(j))
+				})
+			}
+		})
+	}
+}
+
+// Test_NftFixtures renders the rules of testdata/nft: <name>.netlink is the
+// bytecode printed by `nft --debug=netlink -f <name>.nft` and <name>.json the
+// output of `nft -j list ruleset`, the text is compared with the rule listed
+// in <name>.nft.
+func (sui *jsonEncoderTestSuite) Test_NftFixtures() {
+	files, err := filepath.Glob("testdata/nft/*.nft")
+	sui.Require().NoError(err)
+	sui.Require().NotEmpty(files)
+	offline := &resolver.Static{}
+	for _, file := range files {
+		name := strings.TrimSuffix(file, ".nft")
+		sui.Run(filepath.Base(name), func() {
+			ruleset, err := os.ReadFile(file)
+			sui.Require().NoError(err)
+			bytecode, err := os.ReadFile(name + ".netlink")
+			sui.Require().NoError(err)
+			listing, err := os.ReadFile(name + ".json")
+			sui.Require().NoError(err)
+
+			rule := nftables.Rule{
+				Table: &nftables.Table{Name: "filter", Family: nftables.TableFamilyIPv4},
+				Exprs: sui.parseNetlink(string(bytecode)),
+			}
+			str, err := NewRuleExprEncoder(&rule, WithResolver(offline)).Format()
+			sui.Require().NoError(err)
+			sui.Require().Equal(listedRule(string(ruleset)), str)
+			j, err := json.Marshal(NewRuleExprEncoder(&rule, WithResolver(offline)))
+			sui.Require().NoError(err)
+			sui.Require().JSONEq(sui.listedExprs(listing), string(j))
+		})
+	}
+}
+
+// listedRule returns the rule of the chain listed by `nft list ruleset`.
+func listedRule(ruleset string) string {
+	lines := strings.Split(ruleset, "\n")
+	for i, l := range lines {
+		if strings.HasPrefix(strings.TrimSpace(l), "type ") && i+1 < len(lines) {
+			return strings.TrimSpace(lines[i+1])
+		}
+	}
+	return ""
+}
+
+// listedExprs returns the expressions of the rule listed by `nft -j list
+// ruleset`.
+func (sui *jsonEncoderTestSuite) listedExprs(listing []byte) string {
+	var doc struct {
+		Nftables []struct {
+			Rule *struct {
+				Expr json.RawMessage `json:"expr"`
+			} `json:"rule"`
+		} `json:"nftables"`
+	}
+	sui.Require().NoError(json.Unmarshal(listing, &doc))
+	for _, obj := range doc.Nftables {
+		if obj.Rule != nil {
+			return string(obj.Rule.Expr)
+		}
+	}
+	sui.FailNow("no rule listed")
+	return ""
+}
+
+// parseNetlink parses the bytecode printed by `nft --debug=netlink` for the
+// expressions the fixtures use.
+func (sui *jsonEncoderTestSuite) parseNetlink(bytecode string) []expr.Any {
+	var (
+		exprs  []expr.Any
+		regLen = map[uint32]int{}
+	)
+	reg := func(s string) uint32 {
+		n, err := strconv.ParseUint(s, 10, 32)
+		sui.Require().NoError(err)
+		return uint32(n)
+	}
+	// data is printed as host order 32 bit words
+	data := func(words []string, n int) []byte {
+		var b []byte
+		for _, w := range words {
+			v, err := strconv.ParseUint(strings.TrimPrefix(w, "0x"), 16, 32)
+			sui.Require().NoError(err)
+			b = binary.LittleEndian.AppendUint32(b, uint32(v))
+		}
+		return b[:n]
+	}
+	for _, line := range strings.Split(bytecode, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "[") {
+			continue
+		}
+		f := strings.Fields(strings.Trim(line, "[] "))
+		switch {
+		case f[0] == "meta" && f[1] == "load":
+			key := metaKeyOf(f[2])
+			exprs = append(exprs, &expr.Meta{Key: key, Register: reg(f[5])})
+			regLen[reg(f[5])] = metaLen[key]
+		case f[0] == "ct" && f[1] == "load":
+			exprs = append(exprs, &expr.Ct{Key: ctKeyOf(f[2]), Register: reg(f[5])})
+			regLen[reg(f[5])] = 4
+		case f[0] == "payload" && f[1] == "load":
+			n, err := strconv.Atoi(strings.TrimSuffix(f[2], "b"))
+			sui.Require().NoError(err)
+			offset := reg(f[7])
+			exprs = append(exprs, &expr.Payload{
+				DestRegister: reg(f[10]),
+				Base:         payloadBases[f[4]],
+				Offset:       offset,
+				Len:          uint32(n),
+			})
+			regLen[reg(f[10])] = n
+		case f[0] == "bitwise":
+			dst, src := reg(f[2]), reg(f[6])
+			n := regLen[src]
+			exprs = append(exprs, &expr.Bitwise{
+				SourceRegister: src,
+				DestRegister:   dst,
+				Len:            uint32(n),
+				Mask:           data(f[8:9], n),
+				Xor:            data(f[11:12], n),
+			})
+			regLen[dst] = n
+		case f[0] == "cmp":
+			r := reg(f[3])
+			exprs = append(exprs, &expr.Cmp{Op: cmpOps[f[1]], Register: r, Data: data(f[4:], regLen[r])})
+		case f[0] == "immediate" && f[2] == "0":
+			exprs = append(exprs, &expr.Verdict{Kind: verdicts[f[3]]})
+		default:
+			sui.FailNowf("unsupported bytecode", "%q", line)
+		}
+	}
+	return exprs
+}
+
+var (
+	metaLen = map[expr.MetaKey]int{
+		expr.MetaKeyL4PROTO: 1, expr.MetaKeyNFPROTO: 1, expr.MetaKeyPROTOCOL: 2,
+		expr.MetaKeyIIFNAME: 16, expr.MetaKeyOIFNAME: 16, expr.MetaKeyMARK: 4,
+	}
+	payloadBases = map[string]expr.PayloadBase{
+		"link":      expr.PayloadBaseLLHeader,
+		"network":   expr.PayloadBaseNetworkHeader,
+		"transport": expr.PayloadBaseTransportHeader,
+	}
+	cmpOps = map[string]expr.CmpOp{
+		"eq": expr.CmpOpEq, "neq": expr.CmpOpNeq, "lt": expr.CmpOpLt,
+		"lte": expr.CmpOpLte, "gt": expr.CmpOpGt, "gte": expr.CmpOpGte,
+	}
+	verdicts = map[string]expr.VerdictKind{
+		"accept": expr.VerdictAccept, "drop": expr.VerdictDrop,
+	}
+)
+
+func metaKeyOf(name string) expr.MetaKey {
+	for k := expr.MetaKey(0); k < 64; k++ {
+		if MetaKey(k).String() == name {
+			return k
+		}
+	}
+	return 0
+}
+
+func ctKeyOf(name string) expr.CtKey {
+	for k := expr.CtKey(0); k < 64; k++ {
+		if CtKey(k).String() == name {
+			return k
+		}
+	}
+	return 0
+}
+
+func Test_JSONEncoder(t *testing.T) {
+	suite.Run(t, new(jsonEncoderTestSuite))
+}
@@ -0,0 +1,193 @@
+package encoders
+
+import (
+	"encoding/json"
+	"net"
+	"testing"
+
+	"github.com/Morwran/nft-go/pkg/resolver"
+
+	"github.com/google/nftables"
+	"github.com/google/nftables/expr"
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/sys/unix"
+)
+
+type natIRExprTestSuite struct {
+	suite.Suite
+}
+
+func (sui *natIRExprTestSuite) Test_NATEncodeIR_ExprBased() {
+	ipTable := &nftables.Table{Name: "nat", Family: nftables.TableFamilyIPv4}
+	inetTable := &nftables.Table{Name: "nat", Family: nftables.TableFamilyINet}
+
+	sets := resolver.NewSets()
+	for _, entry := range []setEntry{
+		{Set: nftables.Set{Table: ipTable, Name: "backends", IsMap: true,
+			KeyType: nftables.TypeIPAddr, DataType: nftables.TypeIPAddr}},
+		{
+			Set: nftables.Set{Table: inetTable, Name: "__map0", ID: 1, Anonymous: true, IsMap: true,
+				KeyType: nftables.TypeInteger, DataType: nftables.TypeIPAddr},
+			elems: []nftables.SetElement{
+				{Key: []byte{0, 0, 0, 0}, Val: []byte{10, 0, 0, 1}},
+				{Key: []byte{1, 0, 0, 0}, Val: []byte{10, 0, 0, 2}},
+				{Key: []byte{2, 0, 0, 0}, Val: []byte{10, 0, 0, 3}},
+			},
+		},
+	} {
+		sets.Add(&entry.Set, entry.elems)
+	}
+
+	testCases := []struct {
+		name     string
+		table    *nftables.Table
+		exprs    []expr.Any
+		expected string
+		expJSON  string
+	}{
+		{
+			name:  "dnat to address and port",
+			table: ipTable,
+			exprs: []expr.Any{
+				&expr.Payload{DestRegister: 1, Base: expr.PayloadBaseTransportHeader, Offset: 2, Len: 2},
+				&expr.Cmp{Register: 1, Op: expr.CmpOpEq, Data: []byte{0x1f, 0x90}},
+				&expr.Immediate{Register: 2, Data: []byte{192, 168, 0, 1}},
+				&expr.Immediate{Register: 3, Data: []byte{0x1f, 0x90}},
+				&expr.NAT{
+					Type:        expr.NATTypeDestNAT,
+					Family:      unix.NFPROTO_IPV4,
+					RegAddrMin:  2,
+					RegProtoMin: 3,
+				},
+			},
+			expected: "th dport 8080 dnat to 192.168.0.1:8080",
+			expJSON:  `[{"match":{"op":"==","left":{"payload":{"protocol":"th","field":"dport"}},"right":8080}},{"dnat":{"addr":"192.168.0.1","port":8080}}]`,
+		},
+		{
+			name:  "snat to ranges with flags in inet table",
+			table: inetTable,
+			exprs: []expr.Any{
+				&expr.Immediate{Register: 1, Data: []byte{10, 0, 0, 1}},
+				&expr.Immediate{Register: 2, Data: []byte{10, 0, 0, 10}},
+				&expr.Immediate{Register: 3, Data: []byte{0x03, 0xe8}},
+				&expr.Immediate{Register: 4, Data: []byte{0x07, 0xd0}},
+				&expr.NAT{
+					Type:        expr.NATTypeSourceNAT,
+					Family:      unix.NFPROTO_IPV4,
+					RegAddrMin:  1,
+					RegAddrMax:  2,
+					RegProtoMin: 3,
+					RegProtoMax: 4,
+					FullyRandom: true,
+					Persistent:  true,
+				},
+			},
+			expected: "snat ip to 10.0.0.1-10.0.0.10:1000-2000 fully-random persistent",
+			expJSON:  `[{"snat":{"family":"ip","addr":{"range":["10.0.0.1","10.0.0.10"]},"port":{"range":[1000,2000]},"flags":["fully-random","persistent"]}}]`,
+		},
+		{
+			name:  "dnat ip6 to address and port",
+			table: inetTable,
+			exprs: []expr.Any{
+				&expr.Immediate{Register: 1, Data: net.ParseIP("fe80::1")},
+				&expr.Immediate{Register: 2, Data: []byte{0, 80}},
+				&expr.NAT{
+					Type:        expr.NATTypeDestNAT,
+					Family:      unix.NFPROTO_IPV6,
+					RegAddrMin:  1,
+					RegProtoMin: 2,
+				},
+			},
+			expected: "dnat ip6 to [fe80::1]:80",
+			expJSON:  `[{"dnat":{"family":"ip6","addr":"fe80::1","port":80}}]`,
+		},
+		{
+			name:  "snat prefix",
+			table: ipTable,
+			exprs: []expr.Any{
+				&expr.Immediate{Register: 1, Data: []byte{10, 0, 0, 0}},
+				&expr.Immediate{Register: 2, Data: []byte{10, 0, 0, 255}},
+				&expr.NAT{
+					Type:       expr.NATTypeSourceNAT,
+					Family:     unix.NFPROTO_IPV4,
+					RegAddrMin: 1,
+					RegAddrMax: 2,
+					Prefix:     true,
+				},
+			},
+			expected: "snat prefix to 10.0.0.0/24",
+			expJSON:  `[{"snat":{"addr":{"prefix":{"addr":"10.0.0.0","len":24}},"type_flags":"prefix"}}]`,
+		},
+		{
+			name:  "dnat to named map with concatenated data",
+			table: ipTable,
+			exprs: []expr.Any{
+				&expr.Payload{DestRegister: 1, Base: expr.PayloadBaseNetworkHeader, Offset: 16, Len: 4},
+				&expr.Lookup{SourceRegister: 1, DestRegister: 1, IsDestRegSet: true, SetName: "backends"},
+				&expr.NAT{
+					Type:        expr.NATTypeDestNAT,
+					Family:      unix.NFPROTO_IPV4,
+					RegAddrMin:  1,
+					RegProtoMin: 9,
+				},
+			},
+			expected: "dnat to ip daddr map @backends",
+			expJSON:  `[{"dnat":{"addr":{"map":{"key":{"payload":{"protocol":"ip","field":"daddr"}},"data":"@backends"}}}}]`,
+		},
+		{
+			name:  "dnat to numgen map",
+			table: inetTable,
+			exprs: []expr.Any{
+				&expr.Numgen{Register: 1, Modulus: 3, Type: unix.NFT_NG_INCREMENTAL},
+				&expr.Lookup{SourceRegister: 1, DestRegister: 1, IsDestRegSet: true, SetName: "__map0", SetID: 1},
+				&expr.NAT{
+					Type:       expr.NATTypeDestNAT,
+					Family:     unix.NFPROTO_IPV4,
+					RegAddrMin: 1,
+				},
+			},
+			expected: "dnat ip to numgen inc mod 3 map {0 : 10.0.0.1,1 : 10.0.0.2,2 : 10.0.0.3}",
+			expJSON:  `[{"dnat":{"family":"ip","addr":{"map":{"key":{"numgen":{"mode":"inc","mod":3,"offset":0}},"data":{"set":[[0,"10.0.0.1"],[1,"10.0.0.2"],[2,"10.0.0.3"]]}}}}}]`,
+		},
+		{
+			name:  "masquerade to ports",
+			table: ipTable,
+			exprs: []expr.Any{
+				&expr.Immediate{Register: 1, Data: []byte{0x04, 0x00}},
+				&expr.Immediate{Register: 2, Data: []byte{0x08, 0x00}},
+				&expr.Masq{ToPorts: true, RegProtoMin: 1, RegProtoMax: 2, Random: true},
+			},
+			expected: "masquerade to :1024-2048 random",
+			expJSON:  `[{"masquerade":{"port":{"range":[1024,2048]},"flags":"random"}}]`,
+		},
+		{
+			name:  "redirect in inet table",
+			table: inetTable,
+			exprs: []expr.Any{
+				&expr.Immediate{Register: 1, Data: []byte{0x01, 0xbb}},
+				&expr.Redir{RegisterProtoMin: 1, Flags: expr.NF_NAT_RANGE_PERSISTENT},
+			},
+			expected: "redirect to :443 persistent",
+			expJSON:  `[{"redirect":{"port":443,"flags":"persistent"}}]`,
+		},
+	}
+
+	for _, tc := range testCases {
+		sui.Run(tc.name, func() {
+			rule := &nftables.Rule{
+				Table: tc.table,
+				Exprs: tc.exprs,
+			}
+			str, err := NewRuleExprEncoder(rule, WithSets(sets)).Format()
+			sui.Require().NoError(err)
+			sui.Require().Equal(tc.expected, str)
+			j, err := json.Marshal(NewRuleExprEncoder(rule, WithSets(sets)))
+			sui.Require().NoError(err)
+			sui.Require().Equal(tc.expJSON, string(j))
+		})
+	}
+}
+
+func Test_NATEncodeIR_ExprBased(t *testing.T) {
+	suite.Run(t, new(natIRExprTestSuite))
+}
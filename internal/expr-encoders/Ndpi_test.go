@@ -0,0 +1,64 @@
+package encoders
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/nftables"
+	"github.com/google/nftables/expr"
+	"github.com/stretchr/testify/suite"
+)
+
+type ndpiEncoderTestSuite struct {
+	suite.Suite
+}
+
+func (sui *ndpiEncoderTestSuite) Test_Ndpi() {
+	testCases := []struct {
+		name     string
+		ndpi     *expr.Ndpi
+		expected string
+		expJSON  string
+	}{
+		{
+			name:     "protocols",
+			ndpi:     &expr.Ndpi{Protocols: []string{"HTTP", "DNS"}},
+			expected: "ndpi protocol HTTP,DNS drop",
+			expJSON:  `[{"ndpi":{"protocols":["HTTP","DNS"]}},{"drop":null}]`,
+		},
+		{
+			name: "inverted protocols with flags",
+			ndpi: &expr.Ndpi{
+				Flags:     expr.NFT_NDPI_FLAG_INVERT | expr.NFT_NDPI_FLAG_M_PROTO | expr.NFT_NDPI_FLAG_INPROGRESS,
+				Protocols: []string{"TLS"},
+			},
+			expected: "ndpi ! protocol TLS master-proto inprogress drop",
+			expJSON:  `[{"ndpi":{"inv":true,"protocols":["TLS"],"flags":["master-proto","inprogress"]}},{"drop":null}]`,
+		},
+		{
+			name: "hostname",
+			ndpi: &expr.Ndpi{
+				Flags:    expr.NFT_NDPI_FLAG_HOST | expr.NFT_NDPI_FLAG_RE | expr.NFT_NDPI_FLAG_EMPTY,
+				Hostname: "/example\\.com$/",
+			},
+			expected: "ndpi host /example\\.com$/ drop",
+			expJSON:  `[{"ndpi":{"hostname":"/example\\.com$/"}},{"drop":null}]`,
+		},
+	}
+
+	for _, tc := range testCases {
+		sui.Run(tc.name, func() {
+			rule := nftables.Rule{Exprs: []expr.Any{tc.ndpi, &expr.Verdict{Kind: expr.VerdictDrop}}}
+			str, err := NewRuleExprEncoder(&rule).Format()
+			sui.Require().NoError(err)
+			sui.Require().Equal(tc.expected, str)
+			j, err := json.Marshal(NewRuleExprEncoder(&rule))
+			sui.Require().NoError(err)
+			sui.Require().Equal(tc.expJSON, string(j))
+		})
+	}
+}
+
+func Test_NdpiEncoder(t *testing.T) {
+	suite.Run(t, new(ndpiEncoderTestSuite))
+}
@@ -0,0 +1,143 @@
+package encoders
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/Morwran/nft-go/pkg/nftexpr"
+	"github.com/Morwran/nft-go/pkg/resolver"
+
+	"github.com/google/nftables"
+	"github.com/google/nftables/expr"
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/sys/unix"
+)
+
+type nftexprEncoderTestSuite struct {
+	suite.Suite
+}
+
+func (sui *nftexprEncoderTestSuite) Test_ModernStatements() {
+	offline := &resolver.Static{Ifaces: map[uint32]string{4242: "eth0"}}
+	testCases := []struct {
+		name     string
+		exprs    []expr.Any
+		expected string
+		expJSON  string
+	}{
+		{
+			name: "synproxy",
+			exprs: []expr.Any{
+				&nftexpr.Synproxy{
+					Mss:    1460,
+					Wscale: 7,
+					Flags: nftexpr.NF_SYNPROXY_OPT_MSS | nftexpr.NF_SYNPROXY_OPT_WSCALE |
+						nftexpr.NF_SYNPROXY_OPT_TIMESTAMP | nftexpr.NF_SYNPROXY_OPT_SACK_PERM,
+				},
+			},
+			expected: "synproxy mss 1460 wscale 7 timestamp sack-perm",
+			expJSON:  `[{"synproxy":{"mss":1460,"wscale":7,"flags":["timestamp","sack-perm"]}}]`,
+		},
+		{
+			name: "meta secmark set",
+			exprs: []expr.Any{
+				&expr.Objref{Type: unix.NFT_OBJECT_SECMARK, Name: "sshtag"},
+			},
+			expected: "meta secmark set sshtag",
+			expJSON:  `[{"secmark":"sshtag"}]`,
+		},
+		{
+			name:     "last used",
+			exprs:    []expr.Any{&nftexpr.Last{Set: true, Msecs: 2000}},
+			expected: "last used 2s",
+			expJSON:  `[{"last":{"used":2000}}]`,
+		},
+		{
+			name:     "last used in nft duration units",
+			exprs:    []expr.Any{&nftexpr.Last{Set: true, Msecs: 3_661_500}},
+			expected: "last used 1h1m1s500ms",
+			expJSON:  `[{"last":{"used":3661500}}]`,
+		},
+		{
+			name:     "last never used",
+			exprs:    []expr.Any{&nftexpr.Last{}},
+			expected: "last used never",
+			expJSON:  `[{"last":null}]`,
+		},
+		{
+			name: "ipsec in reqid 1",
+			exprs: []expr.Any{
+				&nftexpr.Xfrm{Register: 1, Key: nftexpr.NFT_XFRM_KEY_REQID, Dir: nftexpr.XFRM_POLICY_IN},
+				&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{1, 0, 0, 0}},
+			},
+			expected: "ipsec in reqid 1",
+			expJSON:  `[{"match":{"op":"==","left":{"ipsec":{"key":"reqid","dir":"in"}},"right":1}}]`,
+		},
+		{
+			name: "ipsec out spnum 1 ip daddr",
+			exprs: []expr.Any{
+				&nftexpr.Xfrm{Register: 1, Key: nftexpr.NFT_XFRM_KEY_DADDR_IP4, Dir: nftexpr.XFRM_POLICY_OUT, Spnum: 1},
+				&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{192, 168, 1, 1}},
+			},
+			expected: "ipsec out spnum 1 ip daddr 192.168.1.1",
+			expJSON:  `[{"match":{"op":"==","left":{"ipsec":{"key":"daddr","family":"ip","dir":"out","spnum":1}},"right":"192.168.1.1"}}]`,
+		},
+		{
+			name: "osf name",
+			exprs: []expr.Any{
+				&nftexpr.Osf{Register: 1, TTL: nftexpr.NF_OSF_TTL_LESS},
+				&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte("Linux\x00")},
+			},
+			expected: `osf ttl loose name "Linux"`,
+			expJSON:  `[{"match":{"op":"==","left":{"osf":{"key":"name","ttl":"loose"}},"right":"Linux"}}]`,
+		},
+		{
+			name: "fwd to device",
+			exprs: []expr.Any{
+				&expr.Immediate{Register: 1, Data: []byte{0x92, 0x10, 0, 0}},
+				&nftexpr.Fwd{RegDev: 1},
+			},
+			expected: "fwd to eth0",
+			expJSON:  `[{"fwd":{"dev":"eth0"}}]`,
+		},
+		{
+			name: "fwd ip to address device",
+			exprs: []expr.Any{
+				&expr.Immediate{Register: 1, Data: []byte{0x92, 0x10, 0, 0}},
+				&expr.Immediate{Register: 2, Data: []byte{192, 168, 1, 1}},
+				&nftexpr.Fwd{RegDev: 1, RegAddr: 2, NFProto: unix.NFPROTO_IPV4},
+			},
+			expected: "fwd ip to 192.168.1.1 device eth0",
+			expJSON:  `[{"fwd":{"dev":"eth0","family":"ip","addr":"192.168.1.1"}}]`,
+		},
+		{
+			name: "vxlan ip saddr",
+			exprs: []expr.Any{
+				&nftexpr.Inner{
+					Type:  nftexpr.NFT_INNER_VXLAN,
+					Flags: nftexpr.NFT_INNER_HDRSIZE | nftexpr.NFT_INNER_NH,
+					Expr:  &expr.Payload{DestRegister: 1, Base: expr.PayloadBaseNetworkHeader, Offset: 12, Len: 4},
+				},
+				&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{10, 0, 0, 1}},
+			},
+			expected: "vxlan ip saddr 10.0.0.1",
+			expJSON:  `[{"match":{"op":"==","left":{"inner":{"tunnel":"vxlan","expr":{"payload":{"protocol":"ip","field":"saddr"}}}},"right":"10.0.0.1"}}]`,
+		},
+	}
+
+	for _, tc := range testCases {
+		sui.Run(tc.name, func() {
+			rule := nftables.Rule{Exprs: tc.exprs}
+			str, err := NewRuleExprEncoder(&rule, WithResolver(offline)).Format()
+			sui.Require().NoError(err)
+			sui.Require().Equal(tc.expected, str)
+			j, err := json.Marshal(NewRuleExprEncoder(&rule, WithResolver(offline)))
+			sui.Require().NoError(err)
+			sui.Require().Equal(tc.expJSON, string(j))
+		})
+	}
+}
+
+func Test_NftexprEncoder(t *testing.T) {
+	suite.Run(t, new(nftexprEncoderTestSuite))
+}
@@ -1,11 +1,16 @@
 package encoders
 
 import (
+	"strings"
 	"testing"
 
+	"github.com/Morwran/nft-go/internal/bytes"
+	pr "github.com/Morwran/nft-go/pkg/protocols"
+
 	"github.com/google/nftables"
 	"github.com/google/nftables/expr"
 	"github.com/stretchr/testify/suite"
+	"golang.org/x/sys/unix"
 )
 
 type payloadEncoderNftWithVerdictTestSuite struct {
@@ -164,6 +169,540 @@ func (sui *payloadEncoderNftWithVerdictTestSuite) Test_PayloadExprToString_WithV
 	}
 }
 
+func (sui *payloadEncoderNftWithVerdictTestSuite) Test_TransportProtocolsToString() {
+	l4proto := func(proto byte) []expr.Any {
+		return []expr.Any{
+			&expr.Meta{Key: expr.MetaKeyL4PROTO, Register: 1},
+			&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{proto}},
+		}
+	}
+	th := func(offset, length uint32, data []byte) []expr.Any {
+		return []expr.Any{
+			&expr.Payload{
+				DestRegister: 1,
+				Base:         expr.PayloadBaseTransportHeader,
+				Offset:       offset,
+				Len:          length,
+			},
+			&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: data},
+		}
+	}
+	// masked loads a byte holding a bitfield like nft does for `dccp type`
+	masked := func(offset uint32, mask, data []byte) []expr.Any {
+		return []expr.Any{
+			&expr.Payload{
+				DestRegister: 1,
+				Base:         expr.PayloadBaseTransportHeader,
+				Offset:       offset,
+				Len:          1,
+			},
+			&expr.Bitwise{SourceRegister: 1, DestRegister: 1, Len: 1, Mask: mask, Xor: []byte{0}},
+			&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: data},
+		}
+	}
+	testData := []struct {
+		name     string
+		exprs    []expr.Any
+		expected string
+	}{
+		{
+			name:     "sctp vtag",
+			exprs:    append(l4proto(unix.IPPROTO_SCTP), th(4, 4, []byte{0, 0, 0, 10})...),
+			expected: "sctp vtag 10",
+		},
+		{
+			name:     "sctp dport",
+			exprs:    append(l4proto(unix.IPPROTO_SCTP), th(2, 2, []byte{0x0b, 0x59})...),
+			expected: "sctp dport 2905",
+		},
+		{
+			name:     "udplite csumcov",
+			exprs:    append(l4proto(unix.IPPROTO_UDPLITE), th(4, 2, []byte{0, 8})...),
+			expected: "udplite csumcov 8",
+		},
+		{
+			name:     "esp spi",
+			exprs:    append(l4proto(unix.IPPROTO_ESP), th(0, 4, []byte{0, 0, 1, 0})...),
+			expected: "esp spi 256",
+		},
+		{
+			name:     "esp sequence",
+			exprs:    append(l4proto(unix.IPPROTO_ESP), th(4, 4, []byte{0, 0, 0, 22})...),
+			expected: "esp sequence 22",
+		},
+		{
+			name:     "ah spi",
+			exprs:    append(l4proto(unix.IPPROTO_AH), th(4, 4, []byte{0, 0, 0, 5})...),
+			expected: "ah spi 5",
+		},
+		{
+			name:     "comp cpi",
+			exprs:    append(l4proto(unix.IPPROTO_COMP), th(2, 2, []byte{0, 3})...),
+			expected: "comp cpi 3",
+		},
+		{
+			name:     "igmp type",
+			exprs:    append(l4proto(unix.IPPROTO_IGMP), th(0, 1, []byte{0x11})...),
+			expected: "igmp type membership-query",
+		},
+		{
+			name:     "igmp group",
+			exprs:    append(l4proto(unix.IPPROTO_IGMP), th(4, 4, []byte{224, 0, 0, 1})...),
+			expected: "igmp group 224.0.0.1",
+		},
+		{
+			name:     "gre protocol",
+			exprs:    append(l4proto(unix.IPPROTO_GRE), th(2, 2, []byte{0x08, 0x00})...),
+			expected: "gre protocol ip",
+		},
+		{
+			name:     "dccp dport",
+			exprs:    append(l4proto(unix.IPPROTO_DCCP), th(2, 2, []byte{0x13, 0x88})...),
+			expected: "dccp dport 5000",
+		},
+		{
+			name:     "dccp type",
+			exprs:    append(l4proto(unix.IPPROTO_DCCP), masked(8, []byte{0x1e}, []byte{0x0e})...),
+			expected: "dccp type reset",
+		},
+		{
+			name:     "gre flags",
+			exprs:    append(l4proto(unix.IPPROTO_GRE), masked(0, []byte{0xf8}, []byte{0x80})...),
+			expected: "gre flags 0x10",
+		},
+		{
+			name:     "gre version",
+			exprs:    append(l4proto(unix.IPPROTO_GRE), masked(1, []byte{0x07}, []byte{0x01})...),
+			expected: "gre version 1",
+		},
+		{
+			name: "ip protocol selects transport header",
+			exprs: append([]expr.Any{
+				&expr.Payload{
+					DestRegister: 1,
+					Base:         expr.PayloadBaseNetworkHeader,
+					Offset:       9,
+					Len:          1,
+				},
+				&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{unix.IPPROTO_SCTP}},
+			}, th(4, 4, []byte{0, 0, 0, 10})...),
+			expected: "sctp vtag 10",
+		},
+	}
+
+	for _, tc := range testData {
+		sui.Run(tc.name, func() {
+			rule := nftables.Rule{Exprs: tc.exprs}
+			str, err := NewRuleExprEncoder(&rule).Format()
+			sui.Require().NoError(err)
+			sui.Require().Equal(tc.expected, str)
+		})
+	}
+}
+
+func (sui *payloadEncoderNftWithVerdictTestSuite) Test_RegisteredProtocol() {
+	const myproto = 253
+	pr.RegisterProtocol(expr.PayloadBaseTransportHeader, myproto, pr.ProtoDesc{
+		Name: "myproto",
+		Offsets: pr.ProtoHdrHolder{
+			64: pr.ProtoHdrDesc{Name: "field", Desc: bytes.BytesToHexString},
+		},
+	})
+	l4proto := func(proto byte) []expr.Any {
+		return []expr.Any{
+			&expr.Meta{Key: expr.MetaKeyL4PROTO, Register: 1},
+			&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{proto}},
+		}
+	}
+	th := func(offset, length uint32, data []byte) []expr.Any {
+		return []expr.Any{
+			&expr.Payload{
+				DestRegister: 1,
+				Base:         expr.PayloadBaseTransportHeader,
+				Offset:       offset,
+				Len:          length,
+			},
+			&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: data},
+		}
+	}
+	testData := []struct {
+		name     string
+		exprs    []expr.Any
+		expected string
+		json     string
+	}{
+		{
+			name:     "header following udp",
+			exprs:    append(append(l4proto(unix.IPPROTO_UDP), th(2, 2, []byte{0x27, 0x0f})...), th(8, 2, []byte{0x12, 0x34})...),
+			expected: "udp dport 9999 myproto field 0x1234",
+			json:     `{"payload":{"protocol":"myproto","field":"field"}}`,
+		},
+		{
+			name:     "selected by l4proto",
+			exprs:    append(l4proto(myproto), th(8, 2, []byte{0x12, 0x34})...),
+			expected: "myproto field 0x1234",
+		},
+		{
+			name:     "known header field",
+			exprs:    append(l4proto(unix.IPPROTO_ICMP), th(8, 4, []byte{10, 0, 0, 1})...),
+			expected: "icmp gateway 167772161",
+		},
+	}
+
+	for _, tc := range testData {
+		sui.Run(tc.name, func() {
+			rule := nftables.Rule{Exprs: tc.exprs}
+			str, err := NewRuleExprEncoder(&rule).Format()
+			sui.Require().NoError(err)
+			sui.Require().Equal(tc.expected, str)
+			if tc.json != "" {
+				b, err := NewRuleExprEncoder(&rule).MarshalJSON()
+				sui.Require().NoError(err)
+				sui.Require().Contains(string(b), tc.json)
+			}
+		})
+	}
+}
+
+func (sui *payloadEncoderNftWithVerdictTestSuite) Test_ProtoDefs() {
+	const defs = `
+protocols:
+  - name: vxl
+    base: th
+    fields:
+      - {name: flags, offset: 128, width: 4, format: hex}
+      - {name: kind, offset: 132, width: 4, format: enum, values: {data: 1, keepalive: 2}}
+      - {name: vni, offset: 136, width: 24}
+      - {name: origin, offset: 160, width: 32, format: ip}
+`
+	sui.Require().NoError(pr.LoadProtoDefs(strings.NewReader(defs)))
+
+	invalid := []string{
+		`{"protocols":[{"name":"p","base":"xx"}]}`,
+		`{"protocols":[{"name":"p","base":"th","fields":[{"name":"f","offset":4,"width":8}]}]}`,
+		`{"protocols":[{"name":"p","base":"th","fields":[{"name":"f","offset":0,"width":16,"format":"ip"}]}]}`,
+		`{"protocols":[{"name":"p","base":"th","fields":[{"name":"f","offset":0,"width":8,"format":"oct"}]}]}`,
+		`{"protocols":[{"name":"p","base":"th","fields":[{"name":"f","offset":0,"width":8,"unit":"bits"}]}]}`,
+	}
+	for _, def := range invalid {
+		sui.Require().Error(pr.LoadProtoDefs(strings.NewReader(def)), def)
+	}
+
+	th := func(offset, length uint32, mask, data []byte) []expr.Any {
+		exprs := []expr.Any{&expr.Payload{
+			DestRegister: 1,
+			Base:         expr.PayloadBaseTransportHeader,
+			Offset:       offset,
+			Len:          length,
+		}}
+		if mask != nil {
+			exprs = append(exprs, &expr.Bitwise{
+				SourceRegister: 1,
+				DestRegister:   1,
+				Len:            length,
+				Mask:           mask,
+				Xor:            make([]byte, length),
+			})
+		}
+		return append(exprs, &expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: data})
+	}
+	testData := []struct {
+		name     string
+		exprs    []expr.Any
+		expected string
+	}{
+		{
+			name:     "hex bits",
+			exprs:    th(16, 1, []byte{0xf0}, []byte{0x30}),
+			expected: "vxl flags 0x3",
+		},
+		{
+			name:     "enum bits",
+			exprs:    th(16, 1, []byte{0x0f}, []byte{0x02}),
+			expected: "vxl kind keepalive",
+		},
+		{
+			name:     "unnamed enum value",
+			exprs:    th(16, 1, []byte{0x0f}, []byte{0x07}),
+			expected: "vxl kind 7",
+		},
+		{
+			name:     "decimal",
+			exprs:    th(17, 3, nil, []byte{0, 0x01, 0x00}),
+			expected: "vxl vni 256",
+		},
+		{
+			name:     "address",
+			exprs:    th(20, 4, nil, []byte{10, 0, 0, 1}),
+			expected: "vxl origin 10.0.0.1",
+		},
+	}
+
+	for _, tc := range testData {
+		sui.Run(tc.name, func() {
+			rule := nftables.Rule{Exprs: tc.exprs}
+			str, err := NewRuleExprEncoder(&rule).Format()
+			sui.Require().NoError(err)
+			sui.Require().Equal(tc.expected, str)
+		})
+	}
+}
+
+func (sui *payloadEncoderNftWithVerdictTestSuite) Test_SymbolicNames() {
+	names := pr.NewNameDB()
+	sui.Require().NoError(names.LoadServices(strings.NewReader("my-svc\t8080/tcp\t# custom\n")))
+	sui.Require().NoError(names.LoadProtocols(strings.NewReader("ospf\t89\tOSPFIGP\n")))
+
+	l4proto := func(proto byte) []expr.Any {
+		return []expr.Any{
+			&expr.Meta{Key: expr.MetaKeyL4PROTO, Register: 1},
+			&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{proto}},
+		}
+	}
+	nh := func(offset uint32, data []byte) []expr.Any {
+		return []expr.Any{
+			&expr.Payload{
+				DestRegister: 1,
+				Base:         expr.PayloadBaseNetworkHeader,
+				Offset:       offset,
+				Len:          uint32(len(data)), //nolint:gosec
+			},
+			&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: data},
+		}
+	}
+	th := func(offset uint32, data []byte) []expr.Any {
+		return []expr.Any{
+			&expr.Payload{
+				DestRegister: 1,
+				Base:         expr.PayloadBaseTransportHeader,
+				Offset:       offset,
+				Len:          uint32(len(data)), //nolint:gosec
+			},
+			&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: data},
+		}
+	}
+	testData := []struct {
+		name     string
+		exprs    []expr.Any
+		opts     []Option
+		expected string
+	}{
+		{
+			name:     "ports are numeric by default",
+			exprs:    append(l4proto(unix.IPPROTO_TCP), th(2, []byte{0, 22})...),
+			expected: "tcp dport 22",
+		},
+		{
+			name:     "tcp dport service name",
+			exprs:    append(l4proto(unix.IPPROTO_TCP), th(2, []byte{0, 22})...),
+			opts:     []Option{WithServiceNames(), WithNameDB(names)},
+			expected: "tcp dport ssh",
+		},
+		{
+			name:     "udp sport service name",
+			exprs:    append(l4proto(unix.IPPROTO_UDP), th(0, []byte{0, 53})...),
+			opts:     []Option{WithServiceNames(), WithNameDB(names)},
+			expected: "udp sport domain",
+		},
+		{
+			name:     "th dport service name",
+			exprs:    th(2, []byte{0, 123}),
+			opts:     []Option{WithServiceNames(), WithNameDB(names)},
+			expected: "th dport ntp",
+		},
+		{
+			name:     "service loaded from services file",
+			exprs:    append(l4proto(unix.IPPROTO_TCP), th(2, []byte{0x1f, 0x90})...),
+			opts:     []Option{WithServiceNames(), WithNameDB(names)},
+			expected: "tcp dport my-svc",
+		},
+		{
+			name:     "unknown service stays numeric",
+			exprs:    append(l4proto(unix.IPPROTO_TCP), th(2, []byte{0xea, 0x60})...),
+			opts:     []Option{WithServiceNames(), WithNameDB(names)},
+			expected: "tcp dport 60000",
+		},
+		{
+			name:     "numeric wins over service names",
+			exprs:    append(l4proto(unix.IPPROTO_TCP), th(2, []byte{0, 22})...),
+			opts:     []Option{WithServiceNames(), WithNumeric()},
+			expected: "tcp dport 22",
+		},
+		{
+			name:     "ip protocol loaded from protocols file",
+			exprs:    nh(9, []byte{89}),
+			opts:     []Option{WithNameDB(names)},
+			expected: "ip protocol ospf",
+		},
+		{
+			name:     "ip protocol numeric",
+			exprs:    nh(9, []byte{unix.IPPROTO_TCP}),
+			opts:     []Option{WithNumeric()},
+			expected: "ip protocol 6",
+		},
+	}
+
+	for _, tc := range testData {
+		sui.Run(tc.name, func() {
+			rule := nftables.Rule{Exprs: tc.exprs}
+			str, err := NewRuleExprEncoder(&rule, tc.opts...).Format()
+			sui.Require().NoError(err)
+			sui.Require().Equal(tc.expected, str)
+		})
+	}
+
+	ip6, ok := pr.LookupProtocol(expr.PayloadBaseNetworkHeader, unix.IPPROTO_IPV6)
+	sui.Require().True(ok)
+	nexthdr := ip6.Offsets[pr.IP6HDR_NEXTHDR]
+	sui.Require().Equal("udp", NewOptions().formatField("ip6", nexthdr, []byte{unix.IPPROTO_UDP}))
+	sui.Require().Equal("17", NewOptions(WithNumeric()).formatField("ip6", nexthdr, []byte{unix.IPPROTO_UDP}))
+}
+
+func (sui *payloadEncoderNftWithVerdictTestSuite) Test_DependencyElimination() {
+	meta := func(key expr.MetaKey, op expr.CmpOp, data []byte) []expr.Any {
+		return []expr.Any{
+			&expr.Meta{Key: key, Register: 1},
+			&expr.Cmp{Op: op, Register: 1, Data: data},
+		}
+	}
+	pl := func(base expr.PayloadBase, offset uint32, data []byte) []expr.Any {
+		return []expr.Any{
+			&expr.Payload{
+				DestRegister: 1,
+				Base:         base,
+				Offset:       offset,
+				Len:          uint32(len(data)), //nolint:gosec
+			},
+			&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: data},
+		}
+	}
+	join := func(parts ...[]expr.Any) (res []expr.Any) {
+		for _, p := range parts {
+			res = append(res, p...)
+		}
+		return res
+	}
+	inet := &nftables.Table{Name: "filter", Family: nftables.TableFamilyINet}
+	ip6 := &nftables.Table{Name: "filter", Family: nftables.TableFamilyIPv6}
+
+	testData := []struct {
+		name     string
+		table    *nftables.Table
+		exprs    []expr.Any
+		expected string
+	}{
+		{
+			name: "meta l4proto implied by tcp dport",
+			exprs: join(
+				meta(expr.MetaKeyL4PROTO, expr.CmpOpEq, []byte{unix.IPPROTO_TCP}),
+				pl(expr.PayloadBaseTransportHeader, 2, []byte{0, 22}),
+			),
+			expected: "tcp dport 22",
+		},
+		{
+			name: "meta nfproto implied by ip saddr in inet table",
+			exprs: join(
+				meta(expr.MetaKeyNFPROTO, expr.CmpOpEq, []byte{unix.NFPROTO_IPV4}),
+				pl(expr.PayloadBaseNetworkHeader, 12, []byte{10, 0, 0, 1}),
+			),
+			table:    inet,
+			expected: "ip saddr 10.0.0.1",
+		},
+		{
+			name: "meta nfproto ipv6 selects ip6 header",
+			exprs: join(
+				meta(expr.MetaKeyNFPROTO, expr.CmpOpEq, []byte{unix.NFPROTO_IPV6}),
+				pl(expr.PayloadBaseNetworkHeader, 6, []byte{unix.IPPROTO_UDP}),
+			),
+			table:    inet,
+			expected: "ip6 nexthdr udp",
+		},
+		{
+			name: "meta protocol implied by ip daddr",
+			exprs: join(
+				meta(expr.MetaKeyPROTOCOL, expr.CmpOpEq, []byte{0x08, 0x00}),
+				pl(expr.PayloadBaseNetworkHeader, 16, []byte{10, 0, 0, 2}),
+			),
+			expected: "ip daddr 10.0.0.2",
+		},
+		{
+			name: "dependency chain",
+			exprs: join(
+				meta(expr.MetaKeyNFPROTO, expr.CmpOpEq, []byte{unix.NFPROTO_IPV4}),
+				pl(expr.PayloadBaseNetworkHeader, 9, []byte{unix.IPPROTO_UDP}),
+				pl(expr.PayloadBaseTransportHeader, 2, []byte{0, 53}),
+			),
+			table:    inet,
+			expected: "udp dport 53",
+		},
+		{
+			name: "ip6 table header",
+			exprs: join(
+				meta(expr.MetaKeyL4PROTO, expr.CmpOpEq, []byte{unix.IPPROTO_UDP}),
+				pl(expr.PayloadBaseNetworkHeader, 6, []byte{unix.IPPROTO_UDP}),
+			),
+			table:    ip6,
+			expected: "meta l4proto udp ip6 nexthdr udp",
+		},
+		{
+			name:     "match without payload is kept",
+			exprs:    append(meta(expr.MetaKeyL4PROTO, expr.CmpOpEq, []byte{unix.IPPROTO_TCP}), &expr.Counter{}),
+			expected: "meta l4proto tcp counter packets 0 bytes 0",
+		},
+		{
+			name: "negated match is kept",
+			exprs: join(
+				meta(expr.MetaKeyL4PROTO, expr.CmpOpNeq, []byte{unix.IPPROTO_TCP}),
+				pl(expr.PayloadBaseTransportHeader, 2, []byte{0, 22}),
+			),
+			expected: "meta l4proto != tcp tcp dport 22",
+		},
+		{
+			name: "match of another protocol is kept",
+			exprs: join(
+				meta(expr.MetaKeyNFPROTO, expr.CmpOpEq, []byte{unix.NFPROTO_IPV4}),
+				meta(expr.MetaKeyL4PROTO, expr.CmpOpEq, []byte{unix.IPPROTO_TCP}),
+				pl(expr.PayloadBaseTransportHeader, 0, []byte{0, 80}),
+			),
+			table:    inet,
+			expected: "meta nfproto ipv4 tcp sport 80",
+		},
+		{
+			name: "raw masked key keeps the match",
+			exprs: join(
+				meta(expr.MetaKeyL4PROTO, expr.CmpOpEq, []byte{unix.IPPROTO_TCP}),
+				[]expr.Any{
+					&expr.Payload{DestRegister: 1, Base: expr.PayloadBaseTransportHeader, Offset: 20, Len: 1},
+					&expr.Bitwise{SourceRegister: 1, DestRegister: 1, Len: 1, Mask: []byte{0x02}, Xor: []byte{0}},
+					&expr.Cmp{Op: expr.CmpOpNeq, Register: 1, Data: []byte{0}},
+				},
+			),
+			expected: "meta l4proto tcp @th,160,8 & 0x2 != 0",
+		},
+		{
+			name: "masked field keeps the protocol",
+			exprs: join(
+				meta(expr.MetaKeyL4PROTO, expr.CmpOpEq, []byte{unix.IPPROTO_TCP}),
+				[]expr.Any{
+					&expr.Payload{DestRegister: 1, Base: expr.PayloadBaseTransportHeader, Offset: 13, Len: 1},
+					&expr.Bitwise{SourceRegister: 1, DestRegister: 1, Len: 1, Mask: []byte{0x02}, Xor: []byte{0}},
+					&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{0x02}},
+				},
+			),
+			expected: "tcp flags & syn == syn",
+		},
+	}
+
+	for _, tc := range testData {
+		sui.Run(tc.name, func() {
+			rule := nftables.Rule{Table: tc.table, Exprs: tc.exprs}
+			str, err := NewRuleExprEncoder(&rule).Format()
+			sui.Require().NoError(err)
+			sui.Require().Equal(tc.expected, str)
+		})
+	}
+}
+
 func Test_PayloadEncoderWithVerdict(t *testing.T) {
 	suite.Run(t, new(payloadEncoderNftWithVerdictTestSuite))
 }
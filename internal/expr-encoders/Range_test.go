@@ -0,0 +1,101 @@
+package encoders
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/nftables"
+	"github.com/google/nftables/expr"
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/sys/unix"
+)
+
+type rangeEncoderTestSuite struct {
+	suite.Suite
+}
+
+func (sui *rangeEncoderTestSuite) Test_RangeTypedValues() {
+	rng := func(op expr.CmpOp, from, to []byte) *expr.Range {
+		return &expr.Range{Op: op, Register: 1, FromData: from, ToData: to}
+	}
+	testCases := []struct {
+		name     string
+		exprs    []expr.Any
+		expected string
+		expJSON  string
+	}{
+		{
+			name: "tcp dport",
+			exprs: []expr.Any{
+				&expr.Meta{Key: expr.MetaKeyL4PROTO, Register: 1},
+				&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{unix.IPPROTO_TCP}},
+				&expr.Payload{DestRegister: 1, Base: expr.PayloadBaseTransportHeader, Offset: 2, Len: 2},
+				rng(expr.CmpOpEq, []byte{0x04, 0x00}, []byte{0xff, 0xff}),
+			},
+			expected: "tcp dport 1024-65535",
+			expJSON:  `[{"match":{"op":"==","left":{"payload":{"protocol":"tcp","field":"dport"}},"right":{"range":[1024,65535]}}}]`,
+		},
+		{
+			name: "ip saddr",
+			exprs: []expr.Any{
+				&expr.Payload{DestRegister: 1, Base: expr.PayloadBaseNetworkHeader, Offset: 12, Len: 4},
+				rng(expr.CmpOpEq, []byte{10, 0, 0, 1}, []byte{10, 0, 0, 9}),
+			},
+			expected: "ip saddr 10.0.0.1-10.0.0.9",
+			expJSON:  `[{"match":{"op":"==","left":{"payload":{"protocol":"ip","field":"saddr"}},"right":{"range":["10.0.0.1","10.0.0.9"]}}}]`,
+		},
+		{
+			name: "meta mark",
+			exprs: []expr.Any{
+				&expr.Meta{Key: expr.MetaKeyMARK, Register: 1},
+				rng(expr.CmpOpEq, []byte{0x10, 0, 0, 0}, []byte{0x00, 0x01, 0, 0}),
+			},
+			expected: "meta mark 16-256",
+			expJSON:  `[{"match":{"op":"==","left":{"meta":{"key":"mark"}},"right":{"range":[16,256]}}}]`,
+		},
+		{
+			name: "meta length not in range",
+			exprs: []expr.Any{
+				&expr.Meta{Key: expr.MetaKeyLEN, Register: 1},
+				rng(expr.CmpOpNeq, []byte{100, 0, 0, 0}, []byte{200, 0, 0, 0}),
+			},
+			expected: "meta length != 100-200",
+			expJSON:  `[{"match":{"op":"!=","left":{"meta":{"key":"length"}},"right":{"range":[100,200]}}}]`,
+		},
+		{
+			name: "ct mark",
+			exprs: []expr.Any{
+				&expr.Ct{Key: expr.CtKeyMARK, Register: 1},
+				rng(expr.CmpOpEq, []byte{1, 0, 0, 0}, []byte{2, 0, 0, 0}),
+			},
+			expected: "ct mark 1-2",
+			expJSON:  `[{"match":{"op":"==","left":{"ct":{"key":"mark"}},"right":{"range":[1,2]}}}]`,
+		},
+		{
+			name: "ct expiration",
+			exprs: []expr.Any{
+				&expr.Ct{Key: expr.CtKeyEXPIRATION, Register: 1},
+				rng(expr.CmpOpEq, []byte{0xe8, 0x03, 0, 0}, []byte{0xd0, 0x07, 0, 0}),
+			},
+			expected: "ct expiration 1s-2s",
+		},
+	}
+
+	for _, tc := range testCases {
+		sui.Run(tc.name, func() {
+			rule := nftables.Rule{Exprs: tc.exprs}
+			str, err := NewRuleExprEncoder(&rule).Format()
+			sui.Require().NoError(err)
+			sui.Require().Equal(tc.expected, str)
+			if tc.expJSON != "" {
+				j, err := json.Marshal(NewRuleExprEncoder(&rule))
+				sui.Require().NoError(err)
+				sui.Require().Equal(tc.expJSON, string(j))
+			}
+		})
+	}
+}
+
+func Test_RangeEncoder(t *testing.T) {
+	suite.Run(t, new(rangeEncoderTestSuite))
+}
@@ -0,0 +1,182 @@
+package encoders
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/Morwran/nft-go/pkg/resolver"
+
+	"github.com/google/nftables"
+	"github.com/google/nftables/expr"
+	"github.com/stretchr/testify/suite"
+)
+
+type resolverTestSuite struct {
+	suite.Suite
+}
+
+var offlineResolver = &resolver.Static{
+	Ifaces:  map[uint32]string{2: "eth0"},
+	Users:   map[uint32]string{1000: "alice"},
+	Groups:  map[uint32]string{10: "wheel"},
+	Cgroups: map[uint64]string{4242: "user.slice"},
+}
+
+func (sui *resolverTestSuite) Test_MetaValues() {
+	u32 := func(v uint32) []byte { return binary.LittleEndian.AppendUint32(nil, v) }
+	match := func(load expr.Any, data []byte) []expr.Any {
+		return []expr.Any{load, &expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: data}}
+	}
+	meta := func(key expr.MetaKey) expr.Any { return &expr.Meta{Key: key, Register: 1} }
+	date := time.Date(2019, 6, 6, 17, 20, 20, 0, time.UTC)
+
+	testCases := []struct {
+		name     string
+		exprs    []expr.Any
+		opts     []Option
+		expected string
+		expJSON  string
+	}{
+		{
+			name:     "iif",
+			exprs:    match(meta(expr.MetaKeyIIF), u32(2)),
+			expected: "iif eth0",
+			expJSON:  `[{"match":{"op":"==","left":{"meta":{"key":"iif"}},"right":"eth0"}}]`,
+		},
+		{
+			name:     "unknown oif stays numeric",
+			exprs:    match(meta(expr.MetaKeyOIF), u32(7)),
+			expected: "oif 7",
+			expJSON:  `[{"match":{"op":"==","left":{"meta":{"key":"oif"}},"right":7}}]`,
+		},
+		{
+			name:     "skuid",
+			exprs:    match(meta(expr.MetaKeySKUID), u32(1000)),
+			expected: "meta skuid alice",
+			expJSON:  `[{"match":{"op":"==","left":{"meta":{"key":"skuid"}},"right":"alice"}}]`,
+		},
+		{
+			name:     "skgid",
+			exprs:    match(meta(expr.MetaKeySKGID), u32(10)),
+			expected: "meta skgid wheel",
+			expJSON:  `[{"match":{"op":"==","left":{"meta":{"key":"skgid"}},"right":"wheel"}}]`,
+		},
+		{
+			name:     "time",
+			exprs:    match(meta(MetaKeyTIME), binary.LittleEndian.AppendUint64(nil, uint64(date.UnixNano()))), //nolint:gosec
+			expected: `meta time "2019-06-06 17:20:20"`,
+			expJSON:  `[{"match":{"op":"==","left":{"meta":{"key":"time"}},"right":"2019-06-06 17:20:20"}}]`,
+		},
+		{
+			name:     "day",
+			exprs:    match(meta(MetaKeyDAY), []byte{6}),
+			expected: `meta day "Saturday"`,
+			expJSON:  `[{"match":{"op":"==","left":{"meta":{"key":"day"}},"right":"Saturday"}}]`,
+		},
+		{
+			name:     "hour",
+			exprs:    match(meta(MetaKeyHOUR), u32(17*3600)),
+			expected: `meta hour "17:00"`,
+			expJSON:  `[{"match":{"op":"==","left":{"meta":{"key":"hour"}},"right":"17:00"}}]`,
+		},
+		{
+			name:  "hour in local time zone",
+			exprs: match(meta(MetaKeyHOUR), u32(17*3600+30)),
+			opts: []Option{WithResolver(&resolver.Static{
+				Loc: time.FixedZone("MSK", 3*3600),
+			})},
+			expected: `meta hour "20:00:30"`,
+		},
+		{
+			name: "socket cgroupv2",
+			exprs: match(&expr.Socket{Key: expr.SocketKeyCgroupv2, Level: 1, Register: 1},
+				binary.LittleEndian.AppendUint64(nil, 4242)),
+			expected: `socket cgroupv2 level 1 "user.slice"`,
+			expJSON:  `[{"match":{"op":"==","left":{"socket":{"key":"cgroupv2","level":1}},"right":"user.slice"}}]`,
+		},
+		{
+			name:     "numeric",
+			exprs:    append(match(meta(expr.MetaKeyIIF), u32(2)), match(meta(MetaKeyDAY), []byte{6})...),
+			opts:     []Option{WithNumeric()},
+			expected: "iif 2 meta day 6",
+			expJSON:  `[{"match":{"op":"==","left":{"meta":{"key":"iif"}},"right":2}},{"match":{"op":"==","left":{"meta":{"key":"day"}},"right":6}}]`,
+		},
+	}
+
+	for _, tc := range testCases {
+		sui.Run(tc.name, func() {
+			opts := append([]Option{WithResolver(offlineResolver)}, tc.opts...)
+			rule := nftables.Rule{Exprs: tc.exprs}
+			str, err := NewRuleExprEncoder(&rule, opts...).Format()
+			sui.Require().NoError(err)
+			sui.Require().Equal(tc.expected, str)
+			if tc.expJSON != "" {
+				j, err := json.Marshal(NewRuleExprEncoder(&rule, opts...))
+				sui.Require().NoError(err)
+				sui.Require().Equal(tc.expJSON, string(j))
+			}
+		})
+	}
+}
+
+func (sui *resolverTestSuite) Test_SetElements() {
+	elems := func(vals ...uint32) (res []nftables.SetElement) {
+		for _, v := range vals {
+			res = append(res, nftables.SetElement{Key: binary.LittleEndian.AppendUint32(nil, v)})
+		}
+		return res
+	}
+	testCases := []struct {
+		name     string
+		keyType  nftables.SetDatatype
+		elems    []nftables.SetElement
+		opts     []Option
+		expected string
+		expJSON  string
+	}{
+		{
+			name:     "uid",
+			keyType:  nftables.TypeUID,
+			elems:    elems(1000, 1001),
+			expected: "{alice,1001}",
+			expJSON:  `{"set":["alice",1001]}`,
+		},
+		{
+			name:     "iface index",
+			keyType:  nftables.TypeIFIndex,
+			elems:    elems(2),
+			expected: "{eth0}",
+			expJSON:  `{"set":["eth0"]}`,
+		},
+		{
+			name:     "numeric gid",
+			keyType:  nftables.TypeGID,
+			elems:    elems(10),
+			opts:     []Option{WithNumeric()},
+			expected: "{10}",
+			expJSON:  `{"set":[10]}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		sui.Run(tc.name, func() {
+			enc := &setEncoder{set: setEntry{
+				Set:   nftables.Set{Anonymous: true, KeyType: tc.keyType},
+				elems: tc.elems,
+			}}
+			set := enc.node(&ctx{
+				opts: NewOptions(append([]Option{WithResolver(offlineResolver)}, tc.opts...)...),
+			}, false)
+			sui.Require().Equal(tc.expected, set.String())
+			j, err := json.Marshal(set)
+			sui.Require().NoError(err)
+			sui.Require().Equal(tc.expJSON, string(j))
+		})
+	}
+}
+
+func Test_Resolver(t *testing.T) {
+	suite.Run(t, new(resolverTestSuite))
+}
@@ -0,0 +1,102 @@
+package encoders
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/Morwran/nft-go/pkg/nftexpr"
+
+	"github.com/google/nftables"
+	"github.com/google/nftables/expr"
+	"github.com/stretchr/testify/suite"
+)
+
+type (
+	unknownEncoderTestSuite struct {
+		suite.Suite
+	}
+
+	// noEncoderExpr is an expression type without an encoder
+	noEncoderExpr struct {
+		expr.Any
+	}
+)
+
+func (sui *unknownEncoderTestSuite) Test_UnknownOpaque() {
+	testCases := []struct {
+		name     string
+		exprs    []expr.Any
+		expected string
+		expJSON  string
+	}{
+		{
+			name: "unknown with data",
+			exprs: []expr.Any{
+				&nftexpr.Unknown{Name: "foo", Data: []byte{0x08, 0x00, 0x01, 0x00}},
+				&expr.Verdict{Kind: expr.VerdictAccept},
+			},
+			expected: "<expr name=foo data=0x08000100> accept",
+			expJSON:  `[{"unknown":{"name":"foo","data":"0x08000100"}},{"accept":null}]`,
+		},
+		{
+			name: "expression without encoder",
+			exprs: []expr.Any{
+				&expr.Counter{},
+				&noEncoderExpr{},
+			},
+			expected: "counter packets 0 bytes 0 <expr name=noencoderexpr>",
+			expJSON:  `[{"counter":{"bytes":0,"packets":0}},{"unknown":{"name":"noencoderexpr"}}]`,
+		},
+	}
+
+	for _, tc := range testCases {
+		sui.Run(tc.name, func() {
+			rule := nftables.Rule{Exprs: tc.exprs}
+			str, err := NewRuleExprEncoder(&rule).Format()
+			sui.Require().NoError(err)
+			sui.Require().Equal(tc.expected, str)
+			j, err := json.Marshal(NewRuleExprEncoder(&rule))
+			sui.Require().NoError(err)
+			sui.Require().Equal(tc.expJSON, string(j))
+
+			_, err = NewRuleExprEncoder(&rule, WithStrict()).Format()
+			sui.Require().Error(err)
+			_, err = json.Marshal(NewRuleExprEncoder(&rule, WithStrict()))
+			sui.Require().Error(err)
+		})
+	}
+}
+
+// Test_LenientAST checks that Format and MarshalJSON print the statements of
+// the AST, the expression at fault among them.
+func (sui *unknownEncoderTestSuite) Test_LenientAST() {
+	rule := nftables.Rule{Exprs: []expr.Any{
+		&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{1}},
+		&expr.Verdict{Kind: expr.VerdictAccept},
+	}}
+	enc := NewRuleExprEncoder(&rule, WithLenient(nil))
+	ast, err := enc.AST()
+	var exprErr *ExprError
+	sui.Require().ErrorAs(err, &exprErr)
+	sui.Require().Equal(0, exprErr.Index)
+	sui.Require().Len(ast.Stmts, 2)
+
+	str, err := enc.Format()
+	sui.Require().ErrorAs(err, &exprErr)
+	sui.Require().Equal(ast.String(), str)
+	sui.Require().Equal("<expr name=cmp> accept", str)
+
+	j, err := enc.MarshalJSON()
+	sui.Require().ErrorAs(err, &exprErr)
+	astJSON, err := ast.MarshalJSON()
+	sui.Require().NoError(err)
+	sui.Require().Equal(string(astJSON), string(j))
+	sui.Require().Equal(`[{"unknown":{"name":"cmp"}},{"accept":null}]`, string(j))
+
+	_, err = NewRuleExprEncoder(&rule).AST()
+	sui.Require().ErrorAs(err, &exprErr)
+}
+
+func Test_UnknownEncoder(t *testing.T) {
+	suite.Run(t, new(unknownEncoderTestSuite))
+}
@@ -0,0 +1,54 @@
+package encoders
+
+import (
+	"github.com/Morwran/nft-go/pkg/nftast"
+
+	"github.com/google/nftables/expr"
+)
+
+// AST decompiles the rule expressions into typed statements in one pass,
+// Format and MarshalJSON print them. Errors are reported like Format does.
+func (r *RuleExprEncoder) AST() (*nftast.Rule, error) {
+	ctx := r.newCtx()
+	stmts := make([]nftast.Stmt, 0, len(r.Exprs))
+	var failed *ExprError
+	for i, e := range r.Exprs {
+		stmt, err := r.encode(ctx, e)
+		if err != nil {
+			if !r.opts.lenient {
+				return nil, exprError(i, e, err)
+			}
+			if failed == nil {
+				failed = exprError(i, e, err)
+			}
+			stmt = unknownStmt(exprName(e), nil)
+		}
+		if stmt != nil {
+			stmts = append(stmts, ctx.deps.bind(stmt))
+		}
+	}
+
+	rule := &nftast.Rule{Stmts: make([]nftast.Stmt, 0, len(stmts))}
+	for _, stmt := range stmts {
+		// the matches implied by the following ones are left out
+		if !ctx.deps.implied(stmt) {
+			rule.Stmts = append(rule.Stmts, stmt)
+		}
+	}
+	if failed != nil {
+		return rule, failed
+	}
+	return rule, nil
+}
+
+// encodeNested decompiles the expressions attached to another one like the
+// statements of the elements a dynset adds, they share the sets of the rule.
+func (c *ctx) encodeNested(exprs []expr.Any) ([]nftast.Stmt, error) {
+	rule := *c.rule
+	rule.Exprs = exprs
+	ast, err := (&RuleExprEncoder{Rule: &rule, opts: c.nestedOpts()}).AST()
+	if err != nil {
+		return nil, err
+	}
+	return ast.Stmts, nil
+}
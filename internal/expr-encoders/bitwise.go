@@ -0,0 +1,367 @@
+package encoders
+
+import (
+	"math/big"
+	"net"
+
+	rb "github.com/Morwran/nft-go/internal/bytes"
+	"github.com/Morwran/nft-go/pkg/nftast"
+	pr "github.com/Morwran/nft-go/pkg/protocols"
+
+	nft "github.com/google/nftables"
+	"github.com/google/nftables/expr"
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+func init() {
+	register(&expr.Bitwise{}, func(e expr.Any) encoder {
+		return &bitwiseEncoder{bitwise: e.(*expr.Bitwise)}
+	})
+}
+
+type (
+	bitwiseEncoder struct {
+		bitwise *expr.Bitwise
+	}
+)
+
+func (b *bitwiseEncoder) Encode(ctx *ctx) (nftast.Stmt, error) {
+	bw := b.bitwise
+	if bw.DestRegister == unix.NFT_REG_VERDICT {
+		return nil, errors.Errorf("%T expression has invalid destination register %d", bw, bw.DestRegister)
+	}
+	src, ok := ctx.reg.Get(regID(bw.SourceRegister))
+	if !ok {
+		return nil, errors.Errorf("%T expression has no left side", bw)
+	}
+
+	val := RegValue{
+		Len:       src.Len,
+		Expr:      bw,
+		HostOrder: isHostOrder(src),
+	}
+	switch t := src.Expr.(type) {
+	case *expr.Ct:
+		if isCtFlags(t) {
+			ct := &ctEncoder{t}
+			val.Node = &nftast.Binop{Op: LogicAND.String(), Left: src.node(), Right: orFlags(ct.value(bw.Mask))}
+			val.Op, val.Value = flagsOp, ct.value
+			break
+		}
+		b.arith(src, &val)
+	case *expr.Payload:
+		b.payload(ctx, &payloadEncoder{t}, src, &val)
+	case *expr.Exthdr:
+		exthdr := &exthdrEncoder{t}
+		if val.Value = exthdr.value(bw.Mask); val.Value != nil {
+			val.Node = exthdr.key(bw.Mask)
+			break
+		}
+		b.arith(src, &val)
+	default:
+		b.arith(src, &val)
+	}
+	ctx.reg.Set(regID(bw.DestRegister), val)
+	return nil, nil
+}
+
+// payload resolves the header field the mask selects like `ip version` or the
+// address prefix like `ip saddr 10.0.0.0/8`, masks selecting no field are
+// applied to the loaded payload like `@th,104,8 & 0x12`.
+func (b *bitwiseEncoder) payload(ctx *ctx, pl *payloadEncoder, src RegValue, val *RegValue) {
+	bw := b.bitwise
+	if plen, ok := prefixLen(bw.Mask, bw.Xor); ok {
+		if key, field, ok := pl.addrField(ctx); ok {
+			val.Node = key
+			val.Value = func(d []byte) nftast.Expr { return prefixValue(d, plen, field.Datatype) }
+			return
+		}
+	}
+	// flags are tested bit by bit, their masks select no other field
+	flags := pl.isFlags(ctx)
+	if key, ok := pl.maskedKey(ctx, bw.Mask, src.Hdr); ok && !flags {
+		val.Node = key
+	} else {
+		// the mask of a header field is typed like the field like
+		// `ip saddr & 255.0.255.0` or `tcp flags & (syn | ack)`
+		var mask nftast.Expr = hexValue(rb.RawBytes(bw.Mask).Uint64())
+		if key, ok := src.Node.(nftast.Payload); ok && key.Protocol != "" && src.Value != nil {
+			mask = src.Value(bw.Mask)
+		}
+		if flags {
+			mask, val.Op = orFlags(mask), flagsOp
+		}
+		val.Node = &nftast.Binop{Op: LogicAND.String(), Left: src.node(), Right: mask}
+	}
+	val.Value = func(d []byte) nftast.Expr { return b.maskedValue(ctx, pl, d) }
+}
+
+// maskedValue types the value the masked payload is compared with: the value
+// of the field the mask selects, the raw value in hex otherwise.
+func (b *bitwiseEncoder) maskedValue(ctx *ctx, pl *payloadEncoder, data []byte) nftast.Expr {
+	// ip version is kept in the high nibble of the first byte
+	if p := pl.payload; p.Offset == 0 && p.Len == 1 &&
+		len(b.bitwise.Mask) == 1 && b.bitwise.Mask[0] == 0xF0 {
+		return nftast.Value{V: rb.RawBytes(data).Uint64() >> 4} //nolint:mnd
+	}
+	if hdr := *ctx.hdr; hdr != nil {
+		if desc, ok := hdr.Offsets[hdr.CurrentOffset]; ok {
+			return ctx.opts.field(serviceProto(hdr), desc, data)
+		}
+	}
+	return hexValue(rb.RawBytes(data).Uint64())
+}
+
+// arith applies the mask and the xor to the source as binary operations like
+// `meta mark & 0xffff0001 | 0x1`.
+func (b *bitwiseEncoder) arith(src RegValue, val *RegValue) {
+	node := src.node()
+	for _, o := range b.ops(val.HostOrder) {
+		node = &nftast.Binop{Op: o.op.String(), Left: node, Right: hexValue(o.val.Uint64())}
+	}
+	val.Node = node
+	if val.HostOrder {
+		val.Value = hostOrderHex
+	}
+}
+
+// flagsOp is the JSON operator of a flags test like `ct state
+// established,related`: the masked register is compared with zero.
+const flagsOp = "in"
+
+// orFlags joins a list of flags like nft prints masks: `syn | ack`.
+func orFlags(e nftast.Expr) nftast.Expr {
+	l, ok := e.(*nftast.List)
+	if !ok || len(l.Elems) == 0 {
+		return e
+	}
+	ret := l.Elems[0]
+	for _, f := range l.Elems[1:] {
+		ret = &nftast.Binop{Op: LogicOR.String(), Left: ret, Right: f}
+	}
+	return ret
+}
+
+// listFlags lists the flags joined by orFlags like nft prints flag tests:
+// `established,related`.
+func listFlags(e nftast.Expr) nftast.Expr {
+	b, ok := e.(*nftast.Binop)
+	if !ok || b.Op != LogicOR.String() {
+		return e
+	}
+	left, right := listFlags(b.Left), listFlags(b.Right)
+	l := &nftast.List{}
+	for _, f := range [...]nftast.Expr{left, right} {
+		if t, ok := f.(*nftast.List); ok {
+			l.Elems = append(l.Elems, t.Elems...)
+			continue
+		}
+		l.Elems = append(l.Elems, f)
+	}
+	return l
+}
+
+type bitwiseOp struct {
+	op  LogicOp
+	val *big.Int
+}
+
+// ops splits the mask and xor of the expression into the and, xor and or
+// operations nft prints. Host order values have the mask and xor stored in
+// host byte order too.
+func (b *bitwiseEncoder) ops(host bool) (ops []bitwiseOp) {
+	bw := b.bitwise
+	maskB, xorB := bw.Mask, bw.Xor
+	if host {
+		maskB, xorB = rb.RawBytes(maskB).LittleEndian(), rb.RawBytes(xorB).LittleEndian()
+	}
+	bits := int(bw.Len) * 8 //nolint:mnd
+	mask, xor, or := evalBitwise(maskB, xorB, bits)
+	if !isFullMask(mask, bits) {
+		ops = append(ops, bitwiseOp{LogicAND, mask})
+	}
+	if xor.Sign() != 0 {
+		ops = append(ops, bitwiseOp{LogicXOR, xor})
+	}
+	if or.Sign() != 0 {
+		ops = append(ops, bitwiseOp{LogicOR, or})
+	}
+	return ops
+}
+
+// prefixLen returns the length of the network prefix when the mask is
+// contiguous (255.255.0.0) and the value is not xor'ed.
+func prefixLen(maskB, xorB []byte) (int, bool) {
+	if !isZero(xorB) {
+		return 0, false
+	}
+	mask := new(big.Int).SetBytes(maskB)
+	size := len(maskB) * 8 //nolint:mnd
+	if mask.Sign() == 0 {
+		return 0, false
+	}
+	plen := size - int(mask.TrailingZeroBits())              //nolint:gosec
+	full := new(big.Int).Lsh(big.NewInt(1), uint(plen))      //nolint:gosec
+	full.Sub(full, big.NewInt(1)).Lsh(full, uint(size-plen)) //nolint:gosec
+	return plen, full.Cmp(mask) == 0
+}
+
+// prefixValue is the address compared with a prefix of an address field like
+// `10.0.0.0/8`. The prefixes of whole bytes are loaded without the rest of the
+// address like `192.168.0.0/16`, the address is padded with zeros to the size
+// of typ. The full length prefix leaves the address as is.
+func prefixValue(b []byte, plen int, typ nft.SetDatatype) nftast.Expr {
+	size, _ := addrSize(typ)
+	addr := make([]byte, max(size, len(b)))
+	copy(addr, b)
+	if plen == len(addr)*8 { //nolint:mnd
+		return addrValue(addr)
+	}
+	return &nftast.Prefix{Addr: addrValue(addr), Len: int64(plen)}
+}
+
+// addrSize returns the size of the addresses of the datatype.
+func addrSize(typ nft.SetDatatype) (int, bool) {
+	switch typ {
+	case nft.TypeIPAddr:
+		return net.IPv4len, true
+	case nft.TypeIP6Addr:
+		return net.IPv6len, true
+	}
+	return 0, false
+}
+
+// isAddrPayload reports whether the expression loads an ip or ip6 address.
+func isAddrPayload(e expr.Any) bool {
+	pl, ok := e.(*expr.Payload)
+	if !ok || pl.Base != expr.PayloadBaseNetworkHeader {
+		return false
+	}
+	offset := pr.HeaderOffset(pl.Offset).BytesToBits()
+	switch pl.Len {
+	case net.IPv4len:
+		return offset == pr.IPHDR_SADDR || offset == pr.IPHDR_DADDR
+	case net.IPv6len:
+		return offset == pr.IP6HDR_SADDR || offset == pr.IP6HDR_DADDR
+	}
+	return false
+}
+
+// isHostOrder reports whether the register holds an integer in host byte
+// order: marks, counters and the other meta and ct keys the kernel keeps as
+// host integers, hashes, random numbers and converted values.
+func isHostOrder(r RegValue) bool {
+	if r.HostOrder {
+		return true
+	}
+	switch t := r.Expr.(type) {
+	case *expr.Meta:
+		switch t.Key {
+		case expr.MetaKeyIIFNAME, expr.MetaKeyOIFNAME, expr.MetaKeyBRIIIFNAME,
+			expr.MetaKeyBRIOIFNAME, expr.MetaKeyPROTOCOL:
+			return false
+		}
+		return true
+	case *expr.Ct:
+		switch t.Key {
+		case expr.CtKeyMARK, expr.CtKeySECMARK, expr.CtKeyEXPIRATION, expr.CtKeyPKTS,
+			expr.CtKeyBYTES, expr.CtKeyAVGPKT, expr.CtKeyZONE:
+			return true
+		}
+		return isCtFlags(t)
+	case *expr.Hash, *expr.Numgen:
+		return true
+	}
+	return false
+}
+
+// isCtFlags reports whether the ct key holds flags masked like
+// `ct state established,related`.
+func isCtFlags(ct *expr.Ct) bool {
+	switch ct.Key {
+	case expr.CtKeySTATE, expr.CtKeySTATUS, expr.CtKeyEVENTMASK:
+		return true
+	}
+	return false
+}
+
+// hostOrderHex types the host order integers nft prints in hex like marks.
+func hostOrderHex(b []byte) nftast.Expr {
+	return hexValue(hostOrderUint(b))
+}
+
+func hostOrderUint(b []byte) uint64 {
+	return rb.RawBytes(b).LittleEndian().Uint64()
+}
+
+// hostOrderValue types the host order integers loaded by hashes, number
+// generators and routing keys.
+func hostOrderValue(b []byte) nftast.Expr {
+	return nftast.Value{V: hostOrderUint(b)}
+}
+
+func isZero(b []byte) bool {
+	for _, v := range b {
+		if v != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func evalBitwise(maskB, xorB []byte, length int) (mask, xor, or *big.Int) {
+	mask = new(big.Int).SetBytes(maskB)
+	xor = new(big.Int).SetBytes(xorB)
+	or = big.NewInt(0)
+
+	if scan0(mask, 0) != length || xor.Uint64() != 0 {
+		or = new(big.Int).And(mask, xor)
+		or = new(big.Int).Xor(or, xor)
+		xor = new(big.Int).And(xor, mask)
+		mask = new(big.Int).Or(mask, or)
+	}
+	return
+}
+
+const (
+	LogicAND LogicOp = iota
+	LogicOR
+	LogicXOR
+	LogicLShift
+	LogicRShift
+)
+
+type (
+	LogicOp    uint32
+	BitwiseOps uint32
+)
+
+func (l LogicOp) String() string {
+	switch l {
+	case LogicAND:
+		return "&"
+	case LogicOR:
+		return "|"
+	case LogicXOR:
+		return "^"
+	case LogicLShift:
+		return "<<"
+	case LogicRShift:
+		return ">>"
+	}
+	return ""
+}
+
+func isFullMask(mask *big.Int, bits int) bool {
+	return mask.BitLen() == bits && scan0(mask, 0) == -1
+}
+
+func scan0(x *big.Int, start int) int {
+	for i := start; i < x.BitLen(); i++ {
+		if x.Bit(i) == 0 {
+			return i
+		}
+	}
+	return -1
+}
@@ -1,6 +1,9 @@
 package encoders
 
 import (
+	"github.com/Morwran/nft-go/internal/bytes"
+	"github.com/Morwran/nft-go/pkg/nftast"
+
 	"github.com/google/nftables/expr"
 	"github.com/pkg/errors"
 	"golang.org/x/sys/unix"
@@ -30,50 +33,48 @@ func (b ByteorderOp) String() string {
 	return ""
 }
 
-func (b *byteorderEncoder) EncodeIR(ctx *ctx) (irNode, error) {
+func (b *byteorderEncoder) Encode(ctx *ctx) (nftast.Stmt, error) {
 	bo := b.bo
 	srcReg, ok := ctx.reg.Get(regID(bo.SourceRegister))
 	if !ok {
 		return nil, errors.Errorf("%T expression has no left hand side", bo)
 	}
-	op := ByteorderOp(bo.Op).String()
-	if op == "" {
+	if ByteorderOp(bo.Op).String() == "" {
 		return nil, errors.Errorf("invalid byteorder operation: %d", bo.Op)
 	}
 	if bo.DestRegister == unix.NFT_REG_VERDICT {
 		return nil, errors.Errorf("invalid destination register %d", bo.DestRegister)
 	}
-	ctx.reg.Set(regID(bo.DestRegister), regVal{
-		HumanExpr: srcReg.HumanExpr,
+	ctx.reg.Set(regID(bo.DestRegister), RegValue{
+		Node:      srcReg.node(),
 		Expr:      bo,
 		Len:       srcReg.Len,
-		Op:        op,
+		Op:        srcReg.Op,
+		Value:     b.value(ctx, srcReg),
+		HostOrder: bo.Op == expr.ByteorderNtoh,
 	})
-	return nil, ErrNoIR
+	return nil, nil
 }
 
-func (b *byteorderEncoder) EncodeJSON(ctx *ctx) ([]byte, error) {
-	bo := b.bo
-	srcReg, ok := ctx.reg.Get(regID(bo.SourceRegister))
-	if !ok {
-		return nil, errors.Errorf("%T expression has no left hand side", bo)
+// value types the values compared with the converted register by converting
+// them back to the byte order of the source.
+func (b *byteorderEncoder) value(ctx *ctx, srcReg RegValue) func([]byte) nftast.Expr {
+	if srcReg.Value != nil || srcReg.Desc != nil || srcReg.JSONValue != nil {
+		return func(data []byte) nftast.Expr { return ctx.rhs(srcReg, b.swap(data)) }
 	}
-
-	op := ByteorderOp(bo.Op).String()
-	if op == "" {
-		return nil, errors.Errorf("invalid byteorder operation: %d", bo.Op)
+	if b.bo.Op == expr.ByteorderNtoh {
+		return hostOrderValue
 	}
+	return nil
+}
 
-	if bo.DestRegister == unix.NFT_REG_VERDICT {
-		return nil, errors.Errorf("invalid destination register %d", bo.DestRegister)
+// swap reverses the byte order of each Size long word of the data.
+func (b *byteorderEncoder) swap(data []byte) []byte {
+	size := int(b.bo.Size)
+	res := make([]byte, len(data))
+	copy(res, data)
+	for i := 0; size > 1 && i+size <= len(res); i += size {
+		bytes.RawBytes(res[i : i+size]).ReverseByte()
 	}
-
-	ctx.reg.Set(regID(bo.DestRegister), regVal{
-		Expr: srcReg.Expr,
-		Data: srcReg.Data,
-		Len:  srcReg.Len,
-		Op:   op,
-	})
-
-	return nil, ErrNoJSON
+	return res
 }
@@ -1,15 +1,14 @@
 package encoders
 
 import (
-	"bytes"
-	"encoding/json"
 	"fmt"
+	"strconv"
+	"strings"
 
-	rb "github.com/Morwran/nft-go/internal/bytes"
+	"github.com/Morwran/nft-go/pkg/nftast"
 
 	"github.com/google/nftables/expr"
 	"github.com/pkg/errors"
-	"golang.org/x/sys/unix"
 )
 
 func init() {
@@ -18,108 +17,89 @@ func init() {
 	})
 }
 
-type (
-	cmpEncoder struct {
-		cmp *expr.Cmp
-	}
-	cmpIR struct{ L, Op, R string }
-)
+type cmpEncoder struct {
+	cmp *expr.Cmp
+}
 
-func (b *cmpEncoder) EncodeIR(ctx *ctx) (irNode, error) {
+func (b *cmpEncoder) Encode(ctx *ctx) (nftast.Stmt, error) {
 	cmp := b.cmp
 	srcReg, ok := ctx.reg.Get(regID(cmp.Register))
 	if !ok {
 		return nil, errors.Errorf("%T expression has no left hand side", cmp)
 	}
-	left := srcReg.HumanExpr
-	right := ""
-	l, r := b.formatCmpLR(ctx, srcReg)
-	if l != "" {
-		left = l
+	left, op, right := srcReg.node(), CmpOp(cmp.Op).String(), ctx.rhs(srcReg, cmp.Data)
+	if mask, ok := left.(*nftast.Binop); ok && srcReg.Op == flagsOp {
+		if cmp.Op == expr.CmpOpNeq && isZero(cmp.Data) {
+			// a flags test like `tcp flags syn,ack`
+			left, op, right = mask.Left, flagsOp, listFlags(mask.Right)
+		} else {
+			right = orFlags(right)
+		}
 	}
-	if r != "" {
-		right = r
+	switch t := srcReg.Expr.(type) {
+	case *expr.Meta:
+		(&metaEncoder{t}).selectHeader(ctx, cmp)
+	case *expr.Payload:
+		(&payloadEncoder{t}).selectUpperHeader(ctx, cmp)
 	}
+	return &nftast.Match{Left: left, Op: op, Right: right}, nil
+}
 
-	op := CmpOp(cmp.Op).String()
-	if cmp.Op == expr.CmpOpEq {
-		op = ""
+// rhs types the right hand side value (cmp, range) compared with the register
+// content. Values of the registers loaded by encoders registered from outside
+// are typed from their text and JSON forms.
+func (c *ctx) rhs(srcReg RegValue, data []byte) nftast.Expr {
+	switch {
+	case srcReg.Value != nil:
+		return srcReg.Value(data)
+	case srcReg.JSONValue != nil && srcReg.Desc != nil:
+		return constant(srcReg.JSONValue(data), srcReg.Desc(data))
+	case srcReg.JSONValue != nil:
+		v := srcReg.JSONValue(data)
+		return constant(v, fmt.Sprint(v))
+	case srcReg.Desc != nil:
+		return typedValue(srcReg.Desc(data))
 	}
-	return cmpIR{L: left, Op: op, R: right}, nil
+	return bytesValue(data)
 }
 
-func (b *cmpEncoder) EncodeJSON(ctx *ctx) ([]byte, error) {
-	cmp := b.cmp
-	srcReg, ok := ctx.reg.Get(regID(cmp.Register))
-	if !ok || srcReg.Data == nil {
-		return nil, errors.Errorf("%T expression has no left hand side", cmp)
-	}
-	var right any
-	switch t := srcReg.Expr.(type) {
-	case *expr.Meta:
-		switch t.Key {
-		case expr.MetaKeyL4PROTO:
-			switch rb.RawBytes(cmp.Data).Uint64() {
-			case unix.IPPROTO_TCP:
-				right = "tcp"
-			case unix.IPPROTO_UDP:
-				right = "udp"
-			default:
-				right = "unknown" //nolint:goconst
-			}
-		case expr.MetaKeyIIFNAME, expr.MetaKeyOIFNAME:
-			right = string(bytes.TrimRight(cmp.Data, "\x00"))
-		case expr.MetaKeyNFTRACE:
-			right = rb.RawBytes(cmp.Data).Uint64()
-		default:
-			right = rb.RawBytes(cmp.Data)
+// constant makes the right hand side value from its JSON form and the text
+// nft prints it with, flag lists become lists.
+func constant(v any, text string) nftast.Expr {
+	if flags, ok := v.([]string); ok {
+		l := &nftast.List{Elems: make([]nftast.Expr, 0, len(flags))}
+		for _, f := range flags {
+			l.Elems = append(l.Elems, nftast.Value{V: f})
 		}
-	default:
-		right = rb.RawBytes(cmp.Data)
+		return l
 	}
-
-	cmpJson := map[string]interface{}{
-		"match": struct {
-			Op    string `json:"op"`
-			Left  any    `json:"left"`
-			Right any    `json:"right"`
-		}{
-			Op:    CmpOp(cmp.Op).String(),
-			Left:  srcReg.Data,
-			Right: right,
-		},
+	if fmt.Sprint(v) == text {
+		text = ""
 	}
+	return nftast.Value{V: v, Text: text}
+}
 
-	return json.Marshal(cmpJson)
+// typedValue types a value formatted for the text output: decimal numbers
+// become numbers, comma separated flags become lists.
+func typedValue(s string) nftast.Expr {
+	return constant(typedJSON(s), s)
 }
 
-func (b *cmpEncoder) formatCmpLR(ctx *ctx, srcReg regVal) (left, right string) {
-	cmp := b.cmp
-	switch t := srcReg.Expr.(type) {
-	case *expr.Meta:
-		metaBuilder := &metaEncoder{t}
-		right = metaBuilder.buildFromCmpData(ctx, cmp)
-	case *expr.Bitwise:
-		bitwiseBuilder := &bitwiseEncoder{t}
-		right = bitwiseBuilder.buildFromCmpData(ctx, cmp)
-	case *expr.Ct:
-		right = CtDesk[t.Key](cmp.Data)
-	case *expr.Payload:
-		payloadBuilder := &payloadEncoder{t}
-		left, right = payloadBuilder.buildLRFromCmpData(ctx, cmp)
-	default:
-		right = rb.RawBytes(cmp.Data).Text(rb.BaseDec)
+// typedJSON converts a value formatted for the text output into its JSON
+// form: decimal numbers become numbers, comma separated flags become arrays.
+func typedJSON(s string) any {
+	if n, err := strconv.ParseUint(s, 10, 64); err == nil {
+		return n
 	}
-	return left, right
+	if strings.Contains(s, ",") {
+		return strings.Split(s, ",")
+	}
+	return s
 }
 
-func (n cmpIR) Format() (res string) {
-	if n.Op != "" && n.R != "" {
-		return fmt.Sprintf("%s %s %s", n.L, n.Op, n.R)
-	} else if n.R != "" {
-		return fmt.Sprintf("%s %s", n.L, n.R)
-	}
-	return n.L
+// hexValue is an integer nft prints in hex like masks and marks.
+func hexValue(v uint64) nftast.Value {
+	return nftast.Value{V: v, Text: fmt.Sprintf("0x%x", v)}
 }
 
 type CmpOp expr.CmpOp
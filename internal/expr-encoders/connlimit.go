@@ -1,9 +1,10 @@
 package encoders
 
 import (
-	"encoding/json"
 	"fmt"
 
+	"github.com/Morwran/nft-go/pkg/nftast"
+
 	"github.com/google/nftables/expr"
 	"golang.org/x/sys/unix"
 )
@@ -14,24 +15,29 @@ func init() {
 	})
 }
 
-type connlimitEncoder struct {
-	connlimit *expr.Connlimit
-}
+type (
+	connlimitEncoder struct {
+		connlimit *expr.Connlimit
+	}
 
-func (b *connlimitEncoder) EncodeIR(ctx *ctx) (irNode, error) {
+	connlimitArgs struct {
+		Val uint32 `json:"val"`
+		Inv bool   `json:"inv,omitempty"`
+
+		over bool
+	}
+)
+
+func (b *connlimitEncoder) Encode(ctx *ctx) (nftast.Stmt, error) {
 	c := b.connlimit
-	return simpleIR(fmt.Sprintf("ct count %s%d",
-		map[bool]string{true: "over ", false: ""}[c.Flags != 0], c.Count)), nil
+	return &nftast.Statement{Name: "ct count", Args: &connlimitArgs{
+		Val:  c.Count,
+		Inv:  c.Flags&unix.NFT_LIMIT_F_INV != 0,
+		over: c.Flags != 0,
+	}}, nil
 }
-func (b *connlimitEncoder) EncodeJSON(ctx *ctx) ([]byte, error) {
-	cl := map[string]interface{}{
-		"ct count": struct {
-			Val uint32 `json:"val"`
-			Inv bool   `json:"inv,omitempty"`
-		}{
-			Val: b.connlimit.Count,
-			Inv: b.connlimit.Flags&unix.NFT_LIMIT_F_INV != 0,
-		},
-	}
-	return json.Marshal(cl)
+
+func (a *connlimitArgs) String() string {
+	return fmt.Sprintf("ct count %s%d",
+		map[bool]string{true: "over ", false: ""}[a.over], a.Val)
 }
@@ -1,7 +1,7 @@
 package encoders
 
 import (
-	"fmt"
+	"github.com/Morwran/nft-go/pkg/nftast"
 
 	"github.com/google/nftables/expr"
 )
@@ -16,10 +16,20 @@ type counterEncoder struct {
 	counter *expr.Counter
 }
 
-func (b *counterEncoder) EncodeIR(ctx *ctx) (irNode, error) {
-	return simpleIR("counter packets 0 bytes 0"), nil
+func (b *counterEncoder) Encode(ctx *ctx) (nftast.Stmt, error) {
+	return &nftast.Statement{Name: "counter", Args: &counterArgs{
+		Bytes:   b.counter.Bytes,
+		Packets: b.counter.Packets,
+	}}, nil
 }
 
-func (b *counterEncoder) EncodeJSON(ctx *ctx) ([]byte, error) {
-	return []byte(fmt.Sprintf(`{"counter":{"bytes":%d,"packets":%d}}`, b.counter.Bytes, b.counter.Packets)), nil
+type counterArgs struct {
+	Bytes   uint64 `json:"bytes"`
+	Packets uint64 `json:"packets"`
+}
+
+// String prints the counter like `nft list` does for the rules created
+// without the counter values.
+func (a *counterArgs) String() string {
+	return "counter packets 0 bytes 0"
 }
@@ -1,12 +1,10 @@
 package encoders
 
 import (
-	"encoding/binary"
-	"encoding/json"
-	"fmt"
 	"strings"
 
 	"github.com/Morwran/nft-go/internal/bytes"
+	"github.com/Morwran/nft-go/pkg/nftast"
 	pr "github.com/Morwran/nft-go/pkg/protocols"
 
 	"github.com/google/nftables/expr"
@@ -23,68 +21,43 @@ type ctEncoder struct {
 	ct *expr.Ct
 }
 
-func (b *ctEncoder) EncodeIR(ctx *ctx) (irNode, error) {
+func (b *ctEncoder) Encode(ctx *ctx) (nftast.Stmt, error) {
 	ct := b.ct
-	human := fmt.Sprintf("ct %s", CtKey(ct.Key))
+	key := nftast.Ct{Key: CtKey(ct.Key).String()}
 	if !ct.SourceRegister {
 		if ct.Register == 0 {
 			return nil, errors.Errorf("%T expression has invalid destination register %d", ct, ct.Register)
 		}
 		ctx.reg.Set(regID(ct.Register),
-			regVal{
-				HumanExpr: human,
-				Expr:      ct,
+			RegValue{
+				Node:  key,
+				Expr:  ct,
+				Value: b.value,
 			})
-		return nil, ErrNoIR
+		return nil, nil
 	}
 	srcReg, ok := ctx.reg.Get(regID(ct.Register))
 	if !ok {
 		return nil, errors.Errorf("%T statement has no expression", ct)
 	}
-	if imm, ok := srcReg.Expr.(*expr.Immediate); ok && len(imm.Data) >= 4 {
-		val := binary.LittleEndian.Uint32(imm.Data)
-		return simpleIR(fmt.Sprintf("%s set %d", human, val)), nil
-	}
-	rhs := srcReg.HumanExpr
-	return simpleIR(fmt.Sprintf("%s set %s", human, rhs)), nil
-}
-
-func (b *ctEncoder) buildCtWithMask(base string, mask []byte) string {
-	return fmt.Sprintf("%s %s", base, CtDesk[b.ct.Key](mask))
+	return mangleStmt(key, srcReg.typed(b.value)), nil
 }
 
-func (b *ctEncoder) EncodeJSON(ctx *ctx) ([]byte, error) {
-	ct := b.ct
-	ctJson := map[string]interface{}{
-		"ct": struct {
-			Key string `json:"key"`
-		}{
-			Key: CtKey(ct.Key).String(),
-		},
-	}
-	if !ct.SourceRegister {
-		if ct.Register == 0 {
-			return nil, errors.Errorf("%T expression has invalid destination register %d", ct, ct.Register)
-		}
-		ctx.reg.Set(regID(ct.Register), regVal{Data: ctJson})
-		return nil, ErrNoJSON
+// value types the value the key is compared with or set to, the mark is kept
+// in host byte order.
+func (b *ctEncoder) value(data []byte) nftast.Expr {
+	desc, ok := CtDesk[b.ct.Key]
+	if !ok {
+		return bytesValue(data)
 	}
-
-	srcReg, ok := ctx.reg.Get(regID(ct.Register))
-	if !ok || srcReg.Data == nil {
-		return nil, errors.Errorf("%T statement has no expression", ct)
+	if isCtFlags(b.ct) && isZero(data) {
+		// no flags are printed as a number like `ct state & new == 0x0`
+		return hexValue(0)
 	}
-
-	mangle := map[string]interface{}{
-		"mangle": struct {
-			Key any `json:"key"`
-			Val any `json:"value"`
-		}{
-			Key: ctJson,
-			Val: srcReg.Data,
-		},
+	if b.ct.Key == expr.CtKeyMARK {
+		return constant(bytes.RawBytes(data).LittleEndian().Uint64(), desc(data))
 	}
-	return json.Marshal(mangle)
+	return typedValue(desc(data))
 }
 
 type (
@@ -0,0 +1,119 @@
+package encoders
+
+import (
+	"github.com/Morwran/nft-go/pkg/nftast"
+	pr "github.com/Morwran/nft-go/pkg/protocols"
+
+	"github.com/google/nftables/expr"
+)
+
+type (
+	// depTracker keeps protocol matches (`meta l4proto tcp`, `meta nfproto ipv4`,
+	// `ip protocol tcp`) which select the header of the following payload
+	// expressions. Such a match is implied by a statement whose rendered key
+	// is a field of the selected header (`tcp dport 22`) and is dropped from
+	// the output like nft does. Raw keys like `@th,104,8` keep it.
+	depTracker struct {
+		pending *dependency
+		active  map[expr.PayloadBase]*dependency
+		dropped map[nftast.Stmt]bool
+	}
+
+	dependency struct {
+		base  expr.PayloadBase
+		proto string
+		stmt  nftast.Stmt
+	}
+
+	// keyed is implemented by the arguments of the statements and expressions
+	// built from keys like `tcp dport set 22` or `tcp dport map { ... }`.
+	keyed interface {
+		keys() []nftast.Expr
+	}
+)
+
+// expect announces that the match being encoded selects the proto header at
+// the base, the statement of the match is bound later by bind.
+func (t *depTracker) expect(proto pr.ProtoDesc) {
+	t.pending = &dependency{base: proto.Base, proto: proto.Name}
+}
+
+// bind resolves the dependencies the statement implies and attaches the
+// statement of the match announced by expect, statements are returned as they
+// are.
+func (t *depTracker) bind(s nftast.Stmt) nftast.Stmt {
+	t.resolve(s)
+	dep := t.pending
+	if dep == nil {
+		return s
+	}
+	t.pending = nil
+	dep.stmt = s
+	if t.active == nil {
+		t.active = make(map[expr.PayloadBase]*dependency)
+	}
+	t.active[dep.base] = dep
+	return s
+}
+
+// resolve marks the dependency matches as implied by the statement when its
+// keys are fields of the headers they select.
+func (t *depTracker) resolve(s nftast.Stmt) {
+	for base, dep := range t.active {
+		if !qualified(stmtKeys(s), dep.proto) {
+			continue
+		}
+		if dep.stmt != nil {
+			if t.dropped == nil {
+				t.dropped = make(map[nftast.Stmt]bool)
+			}
+			t.dropped[dep.stmt] = true
+		}
+		delete(t.active, base)
+	}
+}
+
+// stmtKeys returns the keys of the statement: the left side of a match or
+// the keys of the statements setting or adding them.
+func stmtKeys(s nftast.Stmt) []nftast.Expr {
+	switch t := s.(type) {
+	case *nftast.Match:
+		return []nftast.Expr{t.Left}
+	case *nftast.Statement:
+		if k, ok := t.Args.(keyed); ok {
+			return k.keys()
+		}
+	}
+	return nil
+}
+
+// qualified reports whether one of the keys is a field of the proto header,
+// binary operations and concatenations are looked into.
+func qualified(keys []nftast.Expr, proto string) bool {
+	for _, key := range keys {
+		switch t := key.(type) {
+		case nftast.Payload:
+			if t.Protocol == proto {
+				return true
+			}
+		case *nftast.Binop:
+			if qualified([]nftast.Expr{t.Left, t.Right}, proto) {
+				return true
+			}
+		case *nftast.Concat:
+			if qualified(t.Exprs, proto) {
+				return true
+			}
+		case *nftast.Other:
+			if k, ok := t.Args.(keyed); ok && qualified(k.keys(), proto) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// implied reports whether the match is implied by the following ones.
+func (t *depTracker) implied(s nftast.Stmt) bool {
+	return t.dropped[s]
+}
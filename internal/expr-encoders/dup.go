@@ -1,79 +1,83 @@
 package encoders
 
 import (
-	"encoding/json"
 	"fmt"
 	"strings"
 
+	rb "github.com/Morwran/nft-go/internal/bytes"
+	"github.com/Morwran/nft-go/pkg/nftast"
+
+	nft "github.com/google/nftables"
 	"github.com/google/nftables/expr"
 	"github.com/pkg/errors"
 )
 
+// ifindexLen is the size of an interface index loaded into a register.
+const ifindexLen = 4
+
 func init() {
 	register(&expr.Dup{}, func(e expr.Any) encoder {
 		return &dupEncoder{dup: e.(*expr.Dup)}
 	})
 }
 
-type dupEncoder struct {
-	dup *expr.Dup
-}
+type (
+	dupEncoder struct {
+		dup *expr.Dup
+	}
 
-func (b *dupEncoder) EncodeIR(ctx *ctx) (irNode, error) {
-	var addr, dev string
-	sb := strings.Builder{}
-	sb.WriteString("dup")
+	dupArgs struct {
+		Addr nftast.Expr `json:"addr,omitempty"`
+		Dev  nftast.Expr `json:"dev,omitempty"`
+	}
+)
+
+func (b *dupEncoder) Encode(ctx *ctx) (nftast.Stmt, error) {
+	args := &dupArgs{}
 	dup := b.dup
 	if dup.RegAddr != 0 {
 		srcRegAddr, ok := ctx.reg.Get(regID(dup.RegAddr))
 		if !ok {
 			return nil, errors.Errorf("%T statement has no destination expression", dup)
 		}
-		addr = srcRegAddr.HumanExpr
-		if addr != "" {
-			sb.WriteString(fmt.Sprintf(" to %s", addr))
-		}
+		args.Addr = srcRegAddr.typed(func(b []byte) nftast.Expr {
+			if ip := rb.RawBytes(b).Ip(); ip != nil {
+				return nftast.Value{V: ip.String()}
+			}
+			return bytesValue(b)
+		})
 	}
 	if dup.RegDev != 0 {
 		srcRegDev, ok := ctx.reg.Get(regID(dup.RegDev))
 		if !ok {
 			return nil, errors.Errorf("%T statement has no destination expression", dup)
 		}
-		dev = srcRegDev.HumanExpr
-
-		if addr != "" && dev != "" {
-			sb.WriteString(fmt.Sprintf(" device %s", dev))
-		}
+		args.Dev = srcRegDev.typed(func(b []byte) nftast.Expr {
+			return devValue(ctx, b)
+		})
 	}
-	return simpleIR(sb.String()), nil
+	return &nftast.Statement{Name: "dup", Args: args}, nil
 }
 
-func (b *dupEncoder) EncodeJSON(ctx *ctx) ([]byte, error) {
-	var addr, dev any
-	dup := b.dup
-	if dup.RegAddr != 0 {
-		srcRegAddr, ok := ctx.reg.Get(regID(dup.RegAddr))
-		if !ok || srcRegAddr.Data == nil {
-			return nil, errors.Errorf("%T statement has no destination expression", dup)
-		}
-		addr = srcRegAddr.Data
-	}
-	if dup.RegDev != 0 {
-		srcRegDev, ok := ctx.reg.Get(regID(dup.RegDev))
-		if !ok || srcRegDev.Data == nil {
-			return nil, errors.Errorf("%T statement has no destination expression", dup)
+func (d *dupArgs) String() string {
+	sb := strings.Builder{}
+	sb.WriteString("dup")
+	if d.Addr != nil {
+		sb.WriteString(fmt.Sprintf(" to %s", d.Addr))
+		if d.Dev != nil {
+			sb.WriteString(fmt.Sprintf(" device %s", d.Dev))
 		}
-		dev = srcRegDev.Data
 	}
+	return sb.String()
+}
 
-	dupJson := map[string]interface{}{
-		"dup": struct {
-			RegAddr any `json:"addr,omitempty"`
-			RegDev  any `json:"dev,omitempty"`
-		}{
-			RegAddr: addr,
-			RegDev:  dev,
-		},
+// devValue types an interface index loaded by an immediate as the name of
+// the interface.
+func devValue(ctx *ctx, b []byte) nftast.Expr {
+	if len(b) == ifindexLen {
+		if v, ok := ctx.opts.value(nft.TypeIFIndex, b); ok {
+			return v
+		}
 	}
-	return json.Marshal(dupJson)
+	return bytesValue(b)
 }
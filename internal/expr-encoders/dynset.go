@@ -1,12 +1,12 @@
 package encoders
 
 import (
-	"encoding/json"
 	"fmt"
 	"strings"
 	"time"
 
-	"github.com/google/nftables"
+	"github.com/Morwran/nft-go/pkg/nftast"
+
 	"github.com/google/nftables/expr"
 	"github.com/pkg/errors"
 	"golang.org/x/sys/unix"
@@ -18,107 +18,164 @@ func init() {
 	})
 }
 
-type dynsetEncoder struct {
-	dynset *expr.Dynset
-}
+type (
+	dynsetEncoder struct {
+		dynset *expr.Dynset
+	}
+
+	// dynsetArgs are the arguments of the statements adding or updating the
+	// elements of a set like `add @blocked { ip saddr timeout 1m }` or of a
+	// map, Set or Map names it
+	dynsetArgs struct {
+		Op    string       `json:"op"`
+		Elem  nftast.Expr  `json:"elem"`
+		Data  nftast.Expr  `json:"data,omitempty"`
+		Map   string       `json:"map,omitempty"`
+		Set   string       `json:"set,omitempty"`
+		Stmts *nftast.Rule `json:"stmt,omitempty"`
+		Inv   bool         `json:"inv,omitempty"`
+	}
+
+	// meterArgs are the arguments of a `meter` statement
+	meterArgs struct {
+		Name string      `json:"name"`
+		Key  nftast.Expr `json:"key"`
+		Stmt nftast.Stmt `json:"stmt"`
+	}
+
+	// elemArgs are the element added with a timeout like `ip saddr timeout 1m`
+	elemArgs struct {
+		Val     nftast.Expr `json:"val"`
+		Timeout uint64      `json:"timeout"`
+
+		timeout time.Duration
+	}
+)
 
-func (b *dynsetEncoder) EncodeIR(ctx *ctx) (irNode, error) {
+func (b *dynsetEncoder) Encode(ctx *ctx) (nftast.Stmt, error) {
 	dyn := b.dynset
 	if ctx.rule == nil {
 		return nil, errors.New("ctx has no rule")
 	}
-
 	srcRegKey, ok := ctx.reg.Get(regID(dyn.SrcRegKey))
 	if !ok {
 		return nil, errors.Errorf("%T statement has no key expression", dyn)
 	}
-	exp := srcRegKey.HumanExpr
-
-	tmpRule := nftables.Rule{
-		Table: ctx.rule.Table,
-		Exprs: dyn.Exprs,
+	elem := srcRegKey.node()
+	if dyn.Timeout != 0 {
+		elem = &nftast.Other{Name: "elem", Args: &elemArgs{
+			Val:     elem,
+			Timeout: uint64(dyn.Timeout / time.Second),
+			timeout: dyn.Timeout,
+		}}
 	}
-	exprsStr, err := NewRuleExprEncoder(&tmpRule).Format()
-	if err != nil {
-		return nil, err
+	var stmts *nftast.Rule
+	if len(dyn.Exprs) != 0 {
+		nested, err := ctx.encodeNested(dyn.Exprs)
+		if err != nil {
+			return nil, err
+		}
+		stmts = &nftast.Rule{Stmts: nested}
 	}
 
-	if dyn.Timeout != 0 {
-		exp = fmt.Sprintf("%s timeout %s", exp, dyn.Timeout)
+	set, isSet := b.set(ctx)
+	if isSet && isMeter(set) && stmts != nil && len(stmts.Stmts) == 1 {
+		return &nftast.Statement{Name: "meter", Args: &meterArgs{
+			Name: dyn.SetName,
+			Key:  elem,
+			Stmt: stmts.Stmts[0],
+		}}, nil
 	}
 
-	setName := fmt.Sprintf("@%s", dyn.SetName)
-	sb := strings.Builder{}
+	args := &dynsetArgs{
+		Op:    DynSetOP(dyn.Operation).String(),
+		Elem:  elem,
+		Stmts: stmts,
+		Inv:   dyn.Invert,
+	}
+	if dyn.SrcRegData == 0 {
+		args.Set = fmt.Sprintf("@%s", dyn.SetName)
+		return &nftast.Statement{Name: "set", Args: args}, nil
+	}
+	srcRegData, ok := ctx.reg.Get(regID(dyn.SrcRegData))
+	if !ok {
+		return nil, errors.Errorf("%T statement has no data expression", dyn)
+	}
+	args.Data = srcRegData.node()
+	if imm, ok := srcRegData.Expr.(*expr.Immediate); ok && isSet && set.IsMap {
+		args.Data = (&setValues{setEntry: set, opts: ctx.opts}).value(set.DataType, imm.Data)
+	}
+	args.Map = fmt.Sprintf("@%s", dyn.SetName)
+	return &nftast.Statement{Name: "map", Args: args}, nil
+}
 
-	sb.WriteString(fmt.Sprintf("%s %s { %s", DynSetOP(dyn.Operation), setName, exp))
+func (a *dynsetArgs) keys() []nftast.Expr { return []nftast.Expr{a.Elem} }
 
-	if exprsStr != "" {
-		sb.WriteString(" ")
-		sb.WriteString(exprsStr)
+func (a *dynsetArgs) String() string {
+	sb := strings.Builder{}
+	sb.WriteString(a.Op)
+	if a.Inv {
+		sb.WriteString(" !")
 	}
-
-	if srcRegData, ok := ctx.reg.Get(regID(dyn.SrcRegData)); ok {
-		if exprData := srcRegData.HumanExpr; exprData != "" {
-			sb.WriteString(fmt.Sprintf(" : %s", exprData))
-		}
+	sb.WriteString(fmt.Sprintf(" %s%s { %s", a.Set, a.Map, a.Elem))
+	if a.Stmts != nil {
+		sb.WriteString(fmt.Sprintf(" %s", a.Stmts))
+	}
+	if a.Data != nil {
+		sb.WriteString(fmt.Sprintf(" : %s", a.Data))
 	}
-
 	sb.WriteString(" }")
+	return sb.String()
+}
 
-	return simpleIR(sb.String()), nil
+func (m *meterArgs) keys() []nftast.Expr { return []nftast.Expr{m.Key} }
+
+func (m *meterArgs) String() string {
+	return fmt.Sprintf("meter %s { %s %s }", m.Name, m.Key, m.Stmt)
 }
 
-func (b *dynsetEncoder) EncodeJSON(ctx *ctx) ([]byte, error) {
-	dyn := b.dynset
-	srcRegKey, ok := ctx.reg.Get(regID(dyn.SrcRegKey))
-	if !ok || srcRegKey.Data == nil {
-		return nil, errors.Errorf("%T statement has no key expression", dyn)
-	}
-	exp := srcRegKey.Data
-	if dyn.Timeout != 0 {
-		exp = map[string]interface{}{
-			"elem": struct {
-				Val     any           `val:"json"`
-				Timeout time.Duration `timeout:"json"`
-			}{
-				Val:     exp,
-				Timeout: dyn.Timeout,
-			},
-		}
-	}
-	setName := fmt.Sprintf(`@%s`, dyn.SetName)
-	srcRegData, ok := ctx.reg.Get(regID(dyn.SrcRegData))
+func (e *elemArgs) keys() []nftast.Expr { return []nftast.Expr{e.Val} }
 
-	if ok && srcRegData.Data != nil {
-		exp = map[string]interface{}{
-			"map": struct {
-				Op   string `json:"op"`
-				Elem any    `json:"elem"`
-				Data any    `json:"data"`
-				Map  string `json:"map"`
-			}{
-				Op:   DynSetOP(dyn.Operation).String(),
-				Elem: exp,
-				Data: srcRegData.Data,
-				Map:  setName,
-			},
+func (e *elemArgs) String() string {
+	return fmt.Sprintf("%s timeout %s", e.Val, formatTimeout(e.timeout))
+}
+
+// set returns the updated set when it is known. It is needed only to tell
+// meters apart and to type map data, so rules are still rendered when the
+// sets can not be fetched.
+func (b *dynsetEncoder) set(ctx *ctx) (setEntry, bool) {
+	set, ok, err := ctx.set(b.dynset.SetName, b.dynset.SetID)
+	return set, ok && err == nil
+}
+
+// isMeter reports whether the set was created by a `meter` statement, nft
+// makes such sets anonymous and dynamic.
+func isMeter(set setEntry) bool {
+	return set.Anonymous && set.Dynamic
+}
+
+// formatTimeout formats the duration like nft does: `1h30m`, `10s`, `500ms`.
+func formatTimeout(d time.Duration) string {
+	var sb strings.Builder
+	for _, u := range [...]struct {
+		unit time.Duration
+		name string
+	}{
+		{24 * time.Hour, "d"},
+		{time.Hour, "h"},
+		{time.Minute, "m"},
+		{time.Second, "s"},
+		{time.Millisecond, "ms"},
+	} {
+		if n := d / u.unit; n > 0 {
+			sb.WriteString(fmt.Sprintf("%d%s", n, u.name))
+			d -= n * u.unit
 		}
-		return json.Marshal(exp)
-	}
-	exp = map[string]interface{}{
-		"set": struct {
-			Op   string     `json:"op"`
-			Elem any        `json:"elem"`
-			Set  string     `json:"set"`
-			Stmt []expr.Any `json:"stmt,omitempty"`
-		}{
-			Op:   DynSetOP(dyn.Operation).String(),
-			Elem: exp,
-			Set:  setName,
-			Stmt: dyn.Exprs,
-		},
-	}
-	return json.Marshal(exp)
+	}
+	if sb.Len() == 0 {
+		return "0s"
+	}
+	return sb.String()
 }
 
 type DynSetOP uint32
@@ -126,7 +183,7 @@ type DynSetOP uint32
 const (
 	DynSetOPAdd    DynSetOP = unix.NFT_DYNSET_OP_ADD
 	DynSetOPUpdate DynSetOP = unix.NFT_DYNSET_OP_UPDATE
-	DynSetOPDelete DynSetOP = iota
+	DynSetOPDelete DynSetOP = 2 // NFT_DYNSET_OP_DELETE is missing in x/sys/unix
 )
 
 func (d DynSetOP) String() string {
@@ -1,28 +1,24 @@
 package encoders
 
 import (
-	"encoding/json"
-	"errors"
 	"fmt"
-	"strings"
 
+	"github.com/Morwran/nft-go/pkg/nftast"
+	"github.com/Morwran/nft-go/pkg/nftexpr"
 	pr "github.com/Morwran/nft-go/pkg/protocols"
+	"github.com/Morwran/nft-go/pkg/resolver"
 
-	"github.com/H-BF/corlib/pkg/atomic"
 	nft "github.com/google/nftables"
 	"github.com/google/nftables/expr"
 )
 
-var setsHolder atomic.Value[setCache]
-
-func init() {
-	setsHolder.Store(setCache{}, nil)
+type RuleExprEncoder struct {
+	*nft.Rule
+	opts Options
 }
 
-type RuleExprEncoder nft.Rule
-
-func NewRuleExprEncoder(r *nft.Rule) *RuleExprEncoder {
-	return (*RuleExprEncoder)(r)
+func NewRuleExprEncoder(r *nft.Rule, opts ...Option) *RuleExprEncoder {
+	return &RuleExprEncoder{Rule: r, opts: NewOptions(opts...)}
 }
 
 func (r *RuleExprEncoder) String() string {
@@ -39,79 +35,72 @@ func (r *RuleExprEncoder) MustString() string {
 }
 
 // Format — convert nftables rule expressions to a string line of human format.
+// It prints the statements of AST. The error of an expression which can not
+// be decoded is an *ExprError. In lenient mode such expressions are rendered
+// opaque and the text is returned along with the error of the first of them.
 func (r *RuleExprEncoder) Format() (string, error) {
-	var set setCache
-	setsHolder.Fetch(func(sc setCache) {
-		set = sc
-	})
-	ctx := &ctx{
-		reg:  regHolder{},
-		hdr:  new(pr.ProtoDescPtr),
-		sets: set,
-		rule: (*nft.Rule)(r),
+	rule, err := r.AST()
+	if rule == nil {
+		return "", err
 	}
-	nodes := make([]irNode, 0, len(r.Exprs))
+	return rule.String(), err
+}
 
-	for _, e := range r.Exprs {
-		b, err := makeEncoder(e)
-		if err != nil {
-			return "", fmt.Errorf("failed to make encoder for %T: %w", e, err)
-		}
-		n, err := b.EncodeIR(ctx)
-		if err != nil && !errors.Is(err, ErrNoIR) {
-			return "", fmt.Errorf("failed to build IR for %T: %w", e, err)
-		}
-		if n != nil {
-			nodes = append(nodes, n)
-		}
+// MarshalJSON — convert nftables rule to json format, it prints the
+// statements of AST. Errors are reported like Format does, expressions which
+// can not be decoded are rendered as unknown ones in lenient mode.
+func (r *RuleExprEncoder) MarshalJSON() ([]byte, error) {
+	rule, err := r.AST()
+	if rule == nil {
+		return nil, err
 	}
-	var sb strings.Builder
-	for i, n := range nodes {
-		l, _ := sb.WriteString(n.Format())
-		if i < len(nodes)-1 && l > 0 {
-			_ = sb.WriteByte(' ')
-		}
+	b, jerr := rule.MarshalJSON()
+	if jerr != nil {
+		return nil, jerr
 	}
-	return sb.String(), nil
+	return b, err
 }
 
-// MarshalJSON — convert nftables rule to json format
-func (r *RuleExprEncoder) MarshalJSON() ([]byte, error) {
-	var out []json.RawMessage
-	ctx := &ctx{reg: regHolder{}}
-	for _, e := range r.Exprs {
-		b, err := makeEncoder(e)
-		if err != nil {
-			return nil, err
-		}
-		j, err := b.EncodeJSON(ctx)
-		if err != nil && !errors.Is(err, ErrNoJSON) {
-			return nil, err
-		}
-		if j == nil || string(j) == "{}" {
-			continue
-		}
-
-		out = append(out, json.RawMessage(j))
+func (r *RuleExprEncoder) encode(ctx *ctx, e expr.Any) (nftast.Stmt, error) {
+	b, err := makeEncoder(e, r.opts)
+	if err != nil {
+		return nil, err
 	}
+	return b.Encode(ctx)
+}
 
-	return json.Marshal(out)
+func (r *RuleExprEncoder) newCtx() *ctx {
+	sets := r.opts.sets
+	if sets == nil {
+		sets = resolver.SystemSets()
+	}
+	return &ctx{
+		reg:  regHolder{},
+		hdr:  new(pr.ProtoDescPtr),
+		sets: sets,
+		rule: r.Rule,
+		opts: r.opts,
+	}
 }
 
 type (
 	encoderFn func(expr.Any) encoder
 
 	encoder interface {
-		EncodeIR(*ctx) (irNode, error)
-		EncodeJSON(*ctx) ([]byte, error)
+		// Encode returns the statement of the expression, nil when the
+		// expression only loads a register.
+		Encode(*ctx) (nftast.Stmt, error)
 	}
 )
 
-func makeEncoder(e expr.Any) (encoder, error) {
-	if fn, ok := registry[fmt.Sprintf("%T", e)]; ok {
+// makeEncoder returns the encoder of the expression. Expressions without an
+// encoder are rendered as opaque ones unless strict mode is on.
+func makeEncoder(e expr.Any, opts Options) (encoder, error) {
+	if fn, ok := encoderOf(e); ok {
 		return fn(e), nil
 	}
-	return nil, fmt.Errorf("no encoder for type '%T'", e)
+	if opts.strict {
+		return nil, fmt.Errorf("no encoder for type '%T'", e)
+	}
+	return &unknownEncoder{unknown: &nftexpr.Unknown{Name: exprName(e)}}, nil
 }
-
-var ErrNoJSON = errors.New("statement has no json marshaler")
@@ -5,6 +5,8 @@ import (
 	"net"
 	"testing"
 
+	"github.com/Morwran/nft-go/pkg/resolver"
+
 	"github.com/google/nftables"
 	"github.com/google/nftables/binaryutil"
 	"github.com/google/nftables/expr"
@@ -24,7 +26,7 @@ func (sui *encodersTestSuite) Test_MultipleExprToString() {
 	testData := []struct {
 		name     string
 		exprs    nftables.Rule
-		preRun   func()
+		sets     func() *resolver.Sets
 		expected string
 	}{
 		{
@@ -76,34 +78,27 @@ func (sui *encodersTestSuite) Test_MultipleExprToString() {
 
 		{
 			name: "Expression 4",
-			preRun: func() {
-				var set setCache
+			sets: func() *resolver.Sets {
 				table := nftables.Table{Name: tableName}
-				set.Put(
-					setKey{
-						tableName: table.Name,
-						setName:   "ipSet",
-						setId:     1,
-					},
-					setEntry{
-						Set: nftables.Set{
-							Table:   &table,
-							Name:    "ipSet",
-							KeyType: nftables.TypeIPAddr,
+				sets := resolver.NewSets()
+				sets.Add(
+					&nftables.Set{
+						Table:   &table,
+						Name:    "ipSet",
+						KeyType: nftables.TypeIPAddr,
+					},
+					[]nftables.SetElement{
+						{
+							Key:         []byte(net.ParseIP("10.34.11.179").To4()),
+							IntervalEnd: true,
 						},
-						elems: []nftables.SetElement{
-							{
-								Key:         []byte(net.ParseIP("10.34.11.179").To4()),
-								IntervalEnd: true,
-							},
-							{
-								Key:         []byte(net.ParseIP("10.34.11.180").To4()),
-								IntervalEnd: true,
-							},
+						{
+							Key:         []byte(net.ParseIP("10.34.11.180").To4()),
+							IntervalEnd: true,
 						},
 					},
 				)
-				setsHolder.Store(set, nil)
+				return sets
 			},
 			exprs: nftables.Rule{
 				Table: &nftables.Table{Name: tableName},
@@ -126,35 +121,29 @@ func (sui *encodersTestSuite) Test_MultipleExprToString() {
 		},
 		{
 			name: "Expression 5",
-			preRun: func() {
-				var set setCache
+			sets: func() *resolver.Sets {
 				table := nftables.Table{Name: tableName}
-				set.Put(
-					setKey{
-						tableName: table.Name,
-						setName:   "__set0",
-					},
-					setEntry{
-						Set: nftables.Set{
-							Table:     &table,
-							Name:      "__set0",
-							Anonymous: true,
-							Constant:  true,
-							KeyType:   nftables.TypeInetService,
+				sets := resolver.NewSets()
+				sets.Add(
+					&nftables.Set{
+						Table:     &table,
+						Name:      "__set0",
+						Anonymous: true,
+						Constant:  true,
+						KeyType:   nftables.TypeInetService,
+					},
+					[]nftables.SetElement{
+						{
+							Key:         binaryutil.BigEndian.PutUint16(80),
+							IntervalEnd: true,
 						},
-						elems: []nftables.SetElement{
-							{
-								Key:         binaryutil.BigEndian.PutUint16(80),
-								IntervalEnd: true,
-							},
-							{
-								Key:         binaryutil.BigEndian.PutUint16(443),
-								IntervalEnd: true,
-							},
+						{
+							Key:         binaryutil.BigEndian.PutUint16(443),
+							IntervalEnd: true,
 						},
 					},
 				)
-				setsHolder.Store(set, nil)
+				return sets
 			},
 			exprs: nftables.Rule{
 				Table: &nftables.Table{Name: tableName},
@@ -192,7 +181,7 @@ func (sui *encodersTestSuite) Test_MultipleExprToString() {
 					},
 				},
 			},
-			expected: "ip daddr != 93.184.216.34 meta l4proto tcp dport {80,443} meta l4proto tcp",
+			expected: "ip daddr != 93.184.216.34 tcp dport {80,443} meta l4proto tcp",
 		},
 		{
 			name: "Expression 7",
@@ -237,7 +226,7 @@ func (sui *encodersTestSuite) Test_MultipleExprToString() {
 					},
 				},
 			},
-			expected: "meta l4proto tcp dport != 80",
+			expected: "tcp dport != 80",
 		},
 		{
 			name: "Expression 9",
@@ -267,7 +256,7 @@ func (sui *encodersTestSuite) Test_MultipleExprToString() {
 					},
 				},
 			},
-			expected: "meta l4proto tcp sport >= 80 sport <= 100",
+			expected: "tcp sport >= 80 tcp sport <= 100",
 		},
 
 		{
@@ -319,7 +308,7 @@ func (sui *encodersTestSuite) Test_MultipleExprToString() {
 					},
 				},
 			},
-			expected: "meta l4proto icmp type echo-reply",
+			expected: "icmp type echo-reply",
 		},
 
 		{
@@ -407,10 +396,11 @@ func (sui *encodersTestSuite) Test_MultipleExprToString() {
 	}
 	for _, t := range testData {
 		sui.Run(t.name, func() {
-			if t.preRun != nil {
-				t.preRun()
+			var opts []Option
+			if t.sets != nil {
+				opts = append(opts, WithSets(t.sets()))
 			}
-			str, err := NewRuleExprEncoder(&t.exprs).Format()
+			str, err := NewRuleExprEncoder(&t.exprs, opts...).Format()
 			sui.Require().NoError(err)
 			fmt.Println(str)
 			sui.Require().Equal(t.expected, str)
@@ -0,0 +1,37 @@
+package encoders
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/nftables/expr"
+)
+
+// ExprError is the error of an expression of a rule which can not be decoded.
+type ExprError struct {
+	// Index is the position of the expression in the rule
+	Index int
+	// Expr is the name of the expression like "cmp"
+	Expr string
+	Err  error
+}
+
+func (e *ExprError) Error() string {
+	return fmt.Sprintf("expression %d (%s): %v", e.Index, e.Expr, e.Err)
+}
+
+func (e *ExprError) Unwrap() error {
+	return e.Err
+}
+
+func exprError(i int, e expr.Any, err error) *ExprError {
+	return &ExprError{Index: i, Expr: exprName(e), Err: err}
+}
+
+var (
+	// ErrNoIR is returned by EncodeText of the encoders of expressions which
+	// only load a register.
+	ErrNoIR = errors.New("statement has no intermediate representation")
+	// ErrNoJSON is returned by EncodeJSON like ErrNoIR.
+	ErrNoJSON = errors.New("statement has no json marshaler")
+)
@@ -0,0 +1,224 @@
+package encoders
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/Morwran/nft-go/pkg/nftast"
+	pr "github.com/Morwran/nft-go/pkg/protocols"
+
+	nft "github.com/google/nftables"
+	"github.com/google/nftables/expr"
+)
+
+type (
+	// ExprEncoder renders the expressions of a type registered with
+	// RegisterEncoder.
+	ExprEncoder interface {
+		// EncodeText returns the statement in the nft syntax. Expressions which
+		// only load a register store their value with SetRegister and return
+		// ErrNoIR.
+		EncodeText(*Context) (string, error)
+		// EncodeJSON returns the statement in the libnftables JSON schema or
+		// ErrNoJSON like EncodeText.
+		EncodeJSON(*Context) ([]byte, error)
+	}
+
+	// ExprEncoderFn makes the encoder of an expression.
+	ExprEncoderFn func(expr.Any) ExprEncoder
+
+	// Context is the state shared by the encoders of the rule expressions:
+	// the registers and the protocol of the last matched header.
+	Context struct {
+		ctx *ctx
+		// loaded are the registers set by the expression being encoded
+		loaded map[regID]bool
+	}
+
+	// extEncoder runs an ExprEncoder registered from outside of the package
+	extEncoder struct {
+		enc ExprEncoder
+	}
+
+	// builtinEncoder exposes an encoder of the package as an ExprEncoder
+	builtinEncoder struct {
+		enc encoder
+	}
+)
+
+// RegisterEncoder sets the encoder of the expressions of the same type as e,
+// a built-in encoder of the type is replaced. It is meant to be called from
+// init functions of the modules carrying their own expressions.
+func RegisterEncoder(e expr.Any, fn ExprEncoderFn) {
+	register(e, extEncoderFn(fn))
+}
+
+// ReplaceEncoder registers fn like RegisterEncoder and returns the function
+// restoring the encoder of the type it replaced, or removing fn when there
+// was none. Tests swap encoders with it.
+func ReplaceEncoder(e expr.Any, fn ExprEncoderFn) (restore func()) {
+	return replace(e, extEncoderFn(fn))
+}
+
+func extEncoderFn(fn ExprEncoderFn) encoderFn {
+	return func(e expr.Any) encoder {
+		return &extEncoder{enc: fn(e)}
+	}
+}
+
+// LookupEncoder returns the encoder of the expressions of the same type as e,
+// an encoder replacing a built-in one may fall back to it.
+func LookupEncoder(e expr.Any) (ExprEncoderFn, bool) {
+	fn, ok := encoderOf(e)
+	if !ok {
+		return nil, false
+	}
+	return func(e expr.Any) ExprEncoder {
+		enc := fn(e)
+		if ext, ok := enc.(*extEncoder); ok {
+			return ext.enc
+		}
+		return &builtinEncoder{enc: enc}
+	}, true
+}
+
+// Encode runs the text and JSON encoders of the expression on the same
+// context, the values they load into a register are merged.
+func (e *extEncoder) Encode(ctx *ctx) (nftast.Stmt, error) {
+	c := &Context{ctx: ctx, loaded: make(map[regID]bool)}
+	text, err := e.enc.EncodeText(c)
+	if err != nil && !errors.Is(err, ErrNoIR) {
+		return nil, err
+	}
+	raw, err := e.enc.EncodeJSON(c)
+	if err != nil && !errors.Is(err, ErrNoJSON) {
+		return nil, err
+	}
+	if text == "" && raw == nil {
+		return nil, nil
+	}
+	return extStmt(text, raw)
+}
+
+// extStmt makes the statement of an encoder registered from outside of the
+// package from its text and JSON forms.
+func extStmt(text string, raw []byte) (nftast.Stmt, error) {
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &obj); err != nil || len(obj) != 1 {
+		return &nftast.Statement{Args: &extValue{text: text, json: json.RawMessage(raw)}}, nil //nolint:nilerr
+	}
+	for name, args := range obj {
+		return &nftast.Statement{Name: name, Args: &extValue{text: text, json: args}}, nil
+	}
+	return nil, nil
+}
+
+// extValue is a value of an encoder registered from outside of the package,
+// it knows its text and JSON forms only.
+type extValue struct {
+	text string
+	json any
+}
+
+func (v *extValue) String() string { return v.text }
+
+func (v *extValue) MarshalJSON() ([]byte, error) {
+	if v.json == nil {
+		return []byte("null"), nil
+	}
+	return json.Marshal(v.json)
+}
+
+func (e *builtinEncoder) EncodeText(c *Context) (string, error) {
+	stmt, err := e.enc.Encode(c.ctx)
+	if err != nil {
+		return "", err
+	}
+	if stmt == nil {
+		return "", ErrNoIR
+	}
+	return stmt.String(), nil
+}
+
+func (e *builtinEncoder) EncodeJSON(c *Context) ([]byte, error) {
+	stmt, err := e.enc.Encode(c.ctx)
+	if err != nil {
+		return nil, err
+	}
+	if stmt == nil {
+		return nil, ErrNoJSON
+	}
+	return stmt.JSON(), nil
+}
+
+// Rule returns the rule being rendered.
+func (c *Context) Rule() *nft.Rule { return c.ctx.rule }
+
+// Options returns the rendering options.
+func (c *Context) Options() Options { return c.ctx.opts }
+
+// Register returns the value loaded into the register, the text and JSON
+// forms of typed values are printed from them.
+func (c *Context) Register(reg uint32) (RegValue, bool) {
+	v, ok := c.ctx.reg.Get(regID(reg))
+	if !ok || v.Node == nil {
+		return v, ok
+	}
+	v.HumanExpr, v.Data = v.Node.String(), v.Node
+	if v.Desc == nil {
+		v.Desc = func(b []byte) string { return c.ctx.rhs(v, b).String() }
+	}
+	if v.JSONValue == nil {
+		v.JSONValue = func(b []byte) any { return c.ctx.rhs(v, b) }
+	}
+	return v, true
+}
+
+// SetRegister stores the value an expression loads into the register. The
+// values the text and JSON encoders of the expression store are merged.
+func (c *Context) SetRegister(reg uint32, v RegValue) {
+	id := regID(reg)
+	if old, ok := c.ctx.reg.Get(id); ok && c.loaded[id] {
+		v = v.merge(old)
+	}
+	if c.loaded != nil {
+		c.loaded[id] = true
+	}
+	c.ctx.reg.Set(id, v)
+}
+
+// merge fills the fields of the value unset by the encoder with the ones of
+// the value stored by the other encoder of the expression.
+func (v RegValue) merge(old RegValue) RegValue {
+	if v.HumanExpr == "" {
+		v.HumanExpr = old.HumanExpr
+	}
+	if v.Data == nil {
+		v.Data = old.Data
+	}
+	if v.Desc == nil {
+		v.Desc = old.Desc
+	}
+	if v.JSONValue == nil {
+		v.JSONValue = old.JSONValue
+	}
+	if v.Node == nil {
+		v.Node = old.Node
+	}
+	if v.Value == nil {
+		v.Value = old.Value
+	}
+	return v
+}
+
+// Protocol returns the protocol of the last matched header, nil when no
+// header has been matched yet.
+func (c *Context) Protocol() *pr.ProtoDesc {
+	return *c.ctx.hdr
+}
+
+// SetProtocol sets the protocol the following payload expressions are
+// decoded with, like `meta l4proto tcp` does for `th dport`.
+func (c *Context) SetProtocol(p *pr.ProtoDesc) {
+	*c.ctx.hdr = p
+}
@@ -1,9 +1,12 @@
 package encoders
 
 import (
-	"encoding/json"
 	"fmt"
 
+	"github.com/Morwran/nft-go/internal/bytes"
+	"github.com/Morwran/nft-go/pkg/nftast"
+	pr "github.com/Morwran/nft-go/pkg/protocols"
+
 	"github.com/google/nftables/expr"
 	"github.com/pkg/errors"
 	"golang.org/x/sys/unix"
@@ -15,33 +18,36 @@ func init() {
 	})
 }
 
-type exthdrEncoder struct {
-	extdhdr *expr.Exthdr
-}
-
-func (b *exthdrEncoder) EncodeIR(ctx *ctx) (irNode, error) {
-	exthdr := b.extdhdr
-	exp := ""
-	op := "exthdr"
-	switch exthdr.Op {
-	case expr.ExthdrOpTcpopt:
-		op = "tcp option"
-	case expr.ExthdrOpIpv6:
-		op = "ip option"
+type (
+	exthdrEncoder struct {
+		extdhdr *expr.Exthdr
 	}
-	if exthdr.Offset == 0 && exthdr.Flags == unix.NFT_EXTHDR_F_PRESENT {
-		exp = fmt.Sprintf("%s %d", op, exthdr.Type)
-	} else {
-		exp = fmt.Sprintf("%s @%d,%d,%d", op, exthdr.Type, exthdr.Offset, exthdr.Len)
+
+	// exthdrArgs are the header (option) name and field or the raw location
+	// of the data
+	exthdrArgs struct {
+		Name   string  `json:"name,omitempty"`
+		Field  string  `json:"field,omitempty"`
+		Base   *uint8  `json:"base,omitempty"`
+		Offset *uint32 `json:"offset,omitempty"`
+		Len    *uint32 `json:"len,omitempty"`
+
+		text string
 	}
+)
+
+func (b *exthdrEncoder) Encode(ctx *ctx) (nftast.Stmt, error) {
+	exthdr := b.extdhdr
+	key := b.key(nil)
 
 	if exthdr.DestRegister != 0 {
 		ctx.reg.Set(regID(exthdr.DestRegister),
-			regVal{
-				HumanExpr: exp,
-				Expr:      exthdr,
+			RegValue{
+				Node:  key,
+				Expr:  exthdr,
+				Value: b.value(nil),
 			})
-		return nil, ErrNoIR
+		return nil, nil
 	}
 
 	if exthdr.SourceRegister != 0 {
@@ -49,57 +55,115 @@ func (b *exthdrEncoder) EncodeIR(ctx *ctx) (irNode, error) {
 		if !ok {
 			return nil, errors.Errorf("%T statement has no expression", exthdr)
 		}
-		rhs := srcReg.HumanExpr
-
-		return simpleIR(fmt.Sprintf("%s set %s", exp, rhs)), nil
+		value := srcReg.node()
+		if imm, ok := srcReg.Expr.(*expr.Immediate); ok {
+			if _, field, ok := b.field(nil); ok {
+				value = typedValue(field.Desc(imm.Data))
+			}
+		}
+		return mangleStmt(key, value), nil
 	}
 
-	return simpleIR(fmt.Sprintf("reset %s", exp)), nil
+	return &nftast.Statement{Name: "reset", Args: &resetArgs{key}}, nil
 }
 
-func (b *exthdrEncoder) EncodeJSON(ctx *ctx) ([]byte, error) {
+// resetArgs are printed as the reset key
+type resetArgs struct {
+	nftast.Expr
+}
+
+func (r *resetArgs) String() string {
+	return fmt.Sprintf("reset %s", r.Expr)
+}
+
+// key returns the header (option) field the expression refers to, the mask
+// is applied to the field offset when the value is extracted by a following
+// bitwise expression.
+func (b *exthdrEncoder) key(mask []byte) nftast.Expr {
 	exthdr := b.extdhdr
-	op := "exthdr"
+	op := pr.ExthdrOp(exthdr.Op)
+	name := op.String()
+	if op == pr.ExthdrOpIpv6 {
+		name = "exthdr"
+	}
+	args := &exthdrArgs{text: b.buildKey(mask)}
+	if exthdr.Flags&unix.NFT_EXTHDR_F_PRESENT != 0 || b.isReset() {
+		if desc, ok := pr.Exthdrs[op].Lookup(exthdr.Type); ok {
+			args.Name = desc.Name
+			return &nftast.Other{Name: name, Args: args}
+		}
+	} else if desc, field, ok := b.field(mask); ok {
+		args.Name, args.Field = desc.Name, field.Name
+		return &nftast.Other{Name: name, Args: args}
+	}
+	args.Base, args.Offset, args.Len = &exthdr.Type, &exthdr.Offset, &exthdr.Len
+	return &nftast.Other{Name: name, Args: args}
+}
+
+func (a *exthdrArgs) String() string { return a.text }
+
+// buildKey returns the human readable name of the header (option) field like
+// `tcp option maxseg size` or `frag more-fragments`. The mask is applied to the
+// field offset when the value is extracted by a following bitwise expression.
+// Unknown headers fall back to the raw @type,offset,len notation.
+func (b *exthdrEncoder) buildKey(mask []byte) string {
+	exthdr := b.extdhdr
+	op := pr.ExthdrOp(exthdr.Op)
+	if exthdr.Flags&unix.NFT_EXTHDR_F_PRESENT != 0 || b.isReset() {
+		if desc, ok := pr.Exthdrs[op].Lookup(exthdr.Type); ok {
+			return fmt.Sprintf("%s %s", op, desc.Name)
+		}
+	} else if desc, field, ok := b.field(mask); ok {
+		if op == pr.ExthdrOpIpv6 {
+			return fmt.Sprintf("%s %s", desc.Name, field.Name)
+		}
+		return fmt.Sprintf("%s %s %s", op, desc.Name, field.Name)
+	}
+
+	name := "exthdr"
 	switch exthdr.Op {
 	case expr.ExthdrOpTcpopt:
-		op = "tcp option"
+		name = "tcp option"
 	case expr.ExthdrOpIpv6:
-		op = "ip option"
+		name = "ip option"
 	}
-
-	hdr := map[string]interface{}{
-		op: struct {
-			Base   uint8  `json:"base"`
-			Offset uint32 `json:"offset"`
-			Len    uint32 `json:"len"`
-		}{
-			Base:   exthdr.Type,
-			Offset: exthdr.Offset,
-			Len:    exthdr.Len,
-		},
+	if exthdr.Offset == 0 && exthdr.Flags == unix.NFT_EXTHDR_F_PRESENT {
+		return fmt.Sprintf("%s %d", name, exthdr.Type)
 	}
+	return fmt.Sprintf("%s @%d,%d,%d", name, exthdr.Type, exthdr.Offset, exthdr.Len)
+}
 
-	if exthdr.DestRegister != 0 {
-		ctx.reg.Set(regID(exthdr.DestRegister), regVal{Data: hdr})
-		return nil, ErrNoJSON
+// field resolves the header (option) field the expression refers to.
+func (b *exthdrEncoder) field(mask []byte) (pr.ProtoDesc, pr.ProtoHdrDesc, bool) {
+	exthdr := b.extdhdr
+	offset := pr.HeaderOffset(exthdr.Offset).BytesToBits()
+	if len(mask) > 0 {
+		offset = offset.WithBitMask(uint32(bytes.RawBytes(mask).Uint64())) //nolint:gosec
 	}
+	return pr.Exthdrs[pr.ExthdrOp(exthdr.Op)].LookupField(exthdr.Type, offset)
+}
 
-	if exthdr.SourceRegister != 0 {
-		srcReg, ok := ctx.reg.Get(regID(exthdr.SourceRegister))
-		if !ok || srcReg.Data == nil {
-			return nil, errors.Errorf("%T statement has no expression", exthdr)
-		}
-		mangle := map[string]interface{}{
-			"mangle": struct {
-				Key any `json:"key"`
-				Val any `json:"value"`
-			}{
-				Key: hdr,
-				Val: srcReg.Data,
-			},
+// value returns the typer of the values the expression is compared with,
+// presence checks compare with booleans. It is nil for unknown fields.
+func (b *exthdrEncoder) value(mask []byte) func([]byte) nftast.Expr {
+	if b.extdhdr.Flags&unix.NFT_EXTHDR_F_PRESENT != 0 {
+		return func(b []byte) nftast.Expr {
+			return nftast.Value{V: bytes.RawBytes(b).Uint64() != 0, Text: existsDesc(b)}
 		}
-		return json.Marshal(mangle)
 	}
+	if _, field, ok := b.field(mask); ok {
+		return func(b []byte) nftast.Expr { return typedValue(field.Desc(b)) }
+	}
+	return nil
+}
 
-	return json.Marshal(hdr)
+func (b *exthdrEncoder) isReset() bool {
+	return b.extdhdr.DestRegister == 0 && b.extdhdr.SourceRegister == 0
+}
+
+func existsDesc(b []byte) string {
+	if bytes.RawBytes(b).Uint64() != 0 {
+		return "exists"
+	}
+	return "missing"
 }
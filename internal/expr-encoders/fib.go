@@ -4,6 +4,10 @@ import (
 	"fmt"
 	"strings"
 
+	rb "github.com/Morwran/nft-go/internal/bytes"
+	"github.com/Morwran/nft-go/pkg/nftast"
+
+	nft "github.com/google/nftables"
 	"github.com/google/nftables/expr"
 	"github.com/pkg/errors"
 )
@@ -14,37 +18,36 @@ func init() {
 	})
 }
 
-type fibEncoder struct {
-	fib *expr.Fib
-}
+type (
+	fibEncoder struct {
+		fib *expr.Fib
+	}
 
-func (b *fibEncoder) EncodeIR(ctx *ctx) (irNode, error) {
+	fibArgs struct {
+		Result string   `json:"result"`
+		Flags  []string `json:"flags"`
+	}
+)
+
+func (b *fibEncoder) Encode(ctx *ctx) (nftast.Stmt, error) {
 	fib := b.fib
 	if fib.Register == 0 {
 		return nil, errors.Errorf("%T expression has invalid destination register %d", fib, fib.Register)
 	}
 	ctx.reg.Set(regID(fib.Register),
-		regVal{
-			HumanExpr: fmt.Sprintf("fib %s %s", strings.Join(b.FlagsToString(), ", "), b.ResultToString()),
-			Expr:      fib,
+		RegValue{
+			Node: &nftast.Other{Name: "fib", Args: &fibArgs{
+				Result: b.ResultToString(),
+				Flags:  b.FlagsToString(),
+			}},
+			Expr:  fib,
+			Value: func(d []byte) nftast.Expr { return b.result(ctx, d) },
 		})
-	return nil, ErrNoIR
+	return nil, nil
 }
-func (b *fibEncoder) EncodeJSON(ctx *ctx) ([]byte, error) {
-	fib := map[string]interface{}{
-		"fib": struct {
-			Result string   `json:"result"`
-			Flags  []string `json:"flags"`
-		}{
-			Result: b.ResultToString(),
-			Flags:  b.FlagsToString(),
-		},
-	}
-	if b.fib.Register == 0 {
-		return nil, errors.Errorf("%T expression has invalid destination register %d", b.fib, b.fib.Register)
-	}
-	ctx.reg.Set(regID(b.fib.Register), regVal{Data: fib})
-	return nil, ErrNoJSON
+
+func (a *fibArgs) String() string {
+	return fmt.Sprintf("fib %s %s", strings.Join(a.Flags, " . "), a.Result)
 }
 
 func (b *fibEncoder) ResultToString() string {
@@ -61,6 +64,42 @@ func (b *fibEncoder) ResultToString() string {
 	return "unknown"
 }
 
+// resultDesc formats the values of the result: address types by their names,
+// output interfaces by the interface names.
+func (b *fibEncoder) resultDesc(ctx *ctx, d []byte) string {
+	f := b.fib
+	switch {
+	case f.ResultADDRTYPE:
+		if v := hostOrderUint(d); v < uint64(len(fibAddrTypes)) {
+			return fibAddrTypes[v]
+		}
+	case f.ResultOIF:
+		if s, ok := ctx.opts.FormatValue(nft.TypeIFIndex, d); ok {
+			return s
+		}
+	case f.ResultOIFNAME:
+		return rb.BytesToString(d)
+	}
+	return rb.LEBytesToIntString(d)
+}
+
+// result types the values of the result like resultDesc formats them.
+func (b *fibEncoder) result(ctx *ctx, d []byte) nftast.Expr {
+	if b.fib.ResultOIF {
+		if v, ok := ctx.opts.value(nft.TypeIFIndex, d); ok {
+			return v
+		}
+	}
+	return typedValue(b.resultDesc(ctx, d))
+}
+
+// fibAddrTypes are the names of the route types (RTN_*) nft prints for
+// `fib ... type`.
+var fibAddrTypes = [...]string{
+	"unspec", "unicast", "local", "broadcast", "anycast",
+	"multicast", "blackhole", "unreachable", "prohibit",
+}
+
 func (b *fibEncoder) FlagsToString() (flags []string) {
 	f := b.fib
 	if f.FlagSADDR {
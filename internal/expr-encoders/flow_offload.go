@@ -3,6 +3,8 @@ package encoders
 import (
 	"fmt"
 
+	"github.com/Morwran/nft-go/pkg/nftast"
+
 	"github.com/google/nftables/expr"
 )
 
@@ -16,12 +18,15 @@ type flowOffloadEncoder struct {
 	flowOffload *expr.FlowOffload
 }
 
-func (b *flowOffloadEncoder) EncodeIR(ctx *ctx) (irNode, error) {
-	f := b.flowOffload
-	return simpleIR(fmt.Sprintf("flow add @%s", f.Name)), nil
+func (b *flowOffloadEncoder) Encode(ctx *ctx) (nftast.Stmt, error) {
+	return &nftast.Statement{Name: "flow", Args: &flowArgs{Op: "add", Flowtable: b.flowOffload.Name}}, nil
+}
+
+type flowArgs struct {
+	Op        string `json:"op"`
+	Flowtable string `json:"flowtable"`
 }
 
-func (b *flowOffloadEncoder) EncodeJSON(ctx *ctx) ([]byte, error) {
-	f := b.flowOffload
-	return []byte(fmt.Sprintf(`{"flow":{"op":"add","flowtable":%q}}`, f.Name)), nil
+func (a *flowArgs) String() string {
+	return fmt.Sprintf("flow %s @%s", a.Op, a.Flowtable)
 }
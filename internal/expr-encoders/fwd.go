@@ -0,0 +1,74 @@
+package encoders
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Morwran/nft-go/pkg/nftast"
+	"github.com/Morwran/nft-go/pkg/nftexpr"
+
+	"github.com/google/nftables/expr"
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+func init() {
+	register(&nftexpr.Fwd{}, func(e expr.Any) encoder {
+		return &fwdEncoder{fwd: e.(*nftexpr.Fwd)}
+	})
+}
+
+type (
+	fwdEncoder struct {
+		fwd *nftexpr.Fwd
+	}
+
+	fwdArgs struct {
+		Dev    nftast.Expr `json:"dev"`
+		Family string      `json:"family,omitempty"`
+		Addr   nftast.Expr `json:"addr,omitempty"`
+	}
+)
+
+func (b *fwdEncoder) Encode(ctx *ctx) (nftast.Stmt, error) {
+	fwd := b.fwd
+	devReg, ok := ctx.reg.Get(regID(fwd.RegDev))
+	if !ok {
+		return nil, errors.Errorf("%T statement has no device expression", fwd)
+	}
+	args := &fwdArgs{Dev: devReg.typed(func(b []byte) nftast.Expr {
+		return devValue(ctx, b)
+	})}
+	if fwd.RegAddr != 0 {
+		addrReg, ok := ctx.reg.Get(regID(fwd.RegAddr))
+		if !ok {
+			return nil, errors.Errorf("%T statement has no address expression", fwd)
+		}
+		args.Addr = addrReg.typed(func(b []byte) nftast.Expr { return addrValue(b) })
+		args.Family = b.family()
+	}
+	return &nftast.Statement{Name: "fwd", Args: args}, nil
+}
+
+func (f *fwdArgs) String() string {
+	if f.Addr == nil {
+		return fmt.Sprintf("fwd to %s", f.Dev)
+	}
+	sb := strings.Builder{}
+	sb.WriteString("fwd")
+	if f.Family != "" {
+		sb.WriteString(" " + f.Family)
+	}
+	sb.WriteString(fmt.Sprintf(" to %s device %s", f.Addr, f.Dev))
+	return sb.String()
+}
+
+func (b *fwdEncoder) family() string {
+	switch b.fwd.NFProto {
+	case unix.NFPROTO_IPV4:
+		return "ip"
+	case unix.NFPROTO_IPV6:
+		return "ip6"
+	}
+	return ""
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/Morwran/nft-go/pkg/nftast"
+
 	"github.com/google/nftables/expr"
 	"github.com/pkg/errors"
 )
@@ -14,76 +16,62 @@ func init() {
 	})
 }
 
-type hashEncoder struct {
-	hash *expr.Hash
-}
+type (
+	hashEncoder struct {
+		hash *expr.Hash
+	}
 
-func (b *hashEncoder) EncodeIR(ctx *ctx) (irNode, error) {
-	var exp string
-	sb := strings.Builder{}
+	hashArgs struct {
+		Mod    uint32      `json:"mod,omitempty"`
+		Seed   uint32      `json:"seed,omitempty"`
+		Offset uint32      `json:"offset,omitempty"`
+		Expr   nftast.Expr `json:"expr,omitempty"`
+
+		typ string
+	}
+)
+
+func (b *hashEncoder) Encode(ctx *ctx) (nftast.Stmt, error) {
 	hash := b.hash
-	sb.WriteString("symhash")
+	args := &hashArgs{
+		Mod:    hash.Modulus,
+		Seed:   hash.Seed,
+		Offset: hash.Offset,
+		typ:    HashType(hash.Type).String(),
+	}
 	if hash.Type != expr.HashTypeSym {
 		srcReg, ok := ctx.reg.Get(regID(hash.SourceRegister))
 		if !ok {
 			return nil, errors.Errorf("%T statement has no expression", hash)
 		}
-		exp = srcReg.HumanExpr
-
-		sb.WriteString(fmt.Sprintf("jhash %s", exp))
-	}
-	sb.WriteString(fmt.Sprintf(" mod %d seed 0x%x", hash.Modulus, hash.Seed))
-	if hash.Offset > 0 {
-		sb.WriteString(fmt.Sprintf(" offset %d", hash.Offset))
+		args.Expr = srcReg.node()
 	}
-
 	if hash.DestRegister == 0 {
 		return nil, errors.Errorf("%T expression has invalid destination register %d", hash, hash.DestRegister)
 	}
-
 	ctx.reg.Set(regID(hash.DestRegister),
-		regVal{
-			Expr:      hash,
-			HumanExpr: sb.String(),
+		RegValue{
+			Node:  &nftast.Other{Name: args.typ, Args: args},
+			Expr:  hash,
+			Value: hostOrderValue,
 		})
-
-	return nil, ErrNoIR
+	return nil, nil
 }
 
-func (b *hashEncoder) EncodeJSON(ctx *ctx) ([]byte, error) {
-	var exp any
-	hash := b.hash
-	if hash.Type != expr.HashTypeSym {
-		srcReg, ok := ctx.reg.Get(regID(hash.SourceRegister))
-		if !ok || srcReg.Data == nil {
-			return nil, errors.Errorf("%T statement has no expression", hash)
-		}
-		exp = srcReg.Data
+func (a *hashArgs) String() string {
+	sb := strings.Builder{}
+	sb.WriteString(a.typ)
+	if a.Expr != nil {
+		sb.WriteString(fmt.Sprintf(" %s", a.Expr))
 	}
-
-	hashJson := map[string]interface{}{
-		HashType(hash.Type).String(): struct {
-			Mod    uint32 `json:"mod,omitempty"`
-			Seed   uint32 `json:"seed,omitempty"`
-			Offset uint32 `json:"offset,omitempty"`
-			Expr   any    `json:"expr,omitempty"`
-		}{
-			Mod:    hash.Modulus,
-			Seed:   hash.Seed,
-			Offset: hash.Offset,
-			Expr:   exp,
-		},
+	sb.WriteString(fmt.Sprintf(" mod %d", a.Mod))
+	if a.Seed != 0 {
+		sb.WriteString(fmt.Sprintf(" seed 0x%x", a.Seed))
 	}
-
-	if hash.DestRegister == 0 {
-		return nil, errors.Errorf("%T expression has invalid destination register %d", hash, hash.DestRegister)
+	if a.Offset > 0 {
+		sb.WriteString(fmt.Sprintf(" offset %d", a.Offset))
 	}
-
-	ctx.reg.Set(regID(hash.DestRegister),
-		regVal{
-			Data: hashJson,
-		})
-	return nil, ErrNoJSON
+	return sb.String()
 }
 
 type HashType expr.HashType
@@ -1,7 +1,12 @@
 package encoders
 
 import (
-	"github.com/Morwran/nft-go/internal/bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	rb "github.com/Morwran/nft-go/internal/bytes"
+	"github.com/Morwran/nft-go/pkg/nftast"
 
 	"github.com/google/nftables/expr"
 )
@@ -16,16 +21,51 @@ type immediateEncoder struct {
 	immediate *expr.Immediate
 }
 
-func (b *immediateEncoder) EncodeIR(ctx *ctx) (irNode, error) {
+func (b *immediateEncoder) Encode(ctx *ctx) (nftast.Stmt, error) {
 	ctx.reg.Set(regID(b.immediate.Register),
-		regVal{
-			HumanExpr: bytes.RawBytes((b.immediate.Data)).String(),
-			Expr:      b.immediate,
+		RegValue{
+			Node: bytesValue(b.immediate.Data),
+			Expr: b.immediate,
 		})
-	return nil, ErrNoIR
+	return nil, nil
 }
-func (b *immediateEncoder) EncodeJSON(ctx *ctx) ([]byte, error) {
-	ctx.reg.Set(regID(b.immediate.Register),
-		regVal{Data: bytes.RawBytes((b.immediate.Data))})
-	return nil, ErrNoJSON
+
+// bytesValue is a constant of an unknown type: a string when it is printable
+// and an integer otherwise.
+func bytesValue(data []byte) nftast.Value {
+	n := new(big.Int).SetBytes(data)
+	if s := rb.RawBytes(data).String(); s != n.String() {
+		return nftast.Value{V: s}
+	}
+	if n.IsUint64() {
+		return nftast.Value{V: n.Uint64()}
+	}
+	return nftast.Value{V: json.Number(n.String())}
+}
+
+// typed types the value loaded into the register by an immediate with conv
+// like the value a statement sets the key to, other values are taken as they
+// are.
+func (v RegValue) typed(conv func([]byte) nftast.Expr) nftast.Expr {
+	if imm, ok := v.Expr.(*expr.Immediate); ok {
+		return conv(imm.Data)
+	}
+	return v.node()
+}
+
+// mangleArgs are the arguments of the statements setting a key like
+// `meta mark set 0x1`.
+type mangleArgs struct {
+	Key   nftast.Expr `json:"key"`
+	Value nftast.Expr `json:"value"`
+}
+
+func mangleStmt(key, value nftast.Expr) nftast.Stmt {
+	return &nftast.Statement{Name: "mangle", Args: &mangleArgs{Key: key, Value: value}}
+}
+
+func (m *mangleArgs) keys() []nftast.Expr { return []nftast.Expr{m.Key} }
+
+func (m *mangleArgs) String() string {
+	return fmt.Sprintf("%s set %s", m.Key, m.Value)
 }
@@ -0,0 +1,80 @@
+package encoders
+
+import (
+	"fmt"
+
+	"github.com/Morwran/nft-go/pkg/nftast"
+	"github.com/Morwran/nft-go/pkg/nftexpr"
+
+	"github.com/google/nftables/expr"
+	"github.com/pkg/errors"
+)
+
+func init() {
+	register(&nftexpr.Inner{}, func(e expr.Any) encoder {
+		return &innerEncoder{inner: e.(*nftexpr.Inner)}
+	})
+}
+
+type (
+	innerEncoder struct {
+		inner *nftexpr.Inner
+	}
+
+	innerArgs struct {
+		Tunnel string      `json:"tunnel"`
+		Expr   nftast.Expr `json:"expr"`
+	}
+)
+
+// Encode encodes the expression applied to the inner packet and prefixes its
+// register with the tunnel name: `vxlan ip saddr`.
+func (b *innerEncoder) Encode(ctx *ctx) (nftast.Stmt, error) {
+	inner := b.inner
+	var reg regID
+	switch t := inner.Expr.(type) {
+	case *expr.Payload:
+		reg = regID(t.DestRegister)
+	case *expr.Meta:
+		reg = regID(t.Register)
+	default:
+		return nil, errors.Errorf("%T expression has unsupported inner expression %T", inner, inner.Expr)
+	}
+	enc, err := makeEncoder(inner.Expr, ctx.opts)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = enc.Encode(ctx); err != nil {
+		return nil, err
+	}
+	src, ok := ctx.reg.Get(reg)
+	if !ok {
+		return nil, errors.Errorf("%T expression loads nothing", inner)
+	}
+	ctx.reg.Set(reg, RegValue{
+		Node: &nftast.Other{Name: "inner", Args: &innerArgs{
+			Tunnel: InnerType(inner.Type).String(),
+			Expr:   src.node(),
+		}},
+		Len:   src.Len,
+		Expr:  inner,
+		Value: func(b []byte) nftast.Expr { return ctx.rhs(src, b) },
+	})
+	return nil, nil
+}
+
+func (a *innerArgs) String() string {
+	return fmt.Sprintf("%s %s", a.Tunnel, a.Expr)
+}
+
+type InnerType nftexpr.InnerType
+
+func (t InnerType) String() string {
+	switch nftexpr.InnerType(t) {
+	case nftexpr.NFT_INNER_VXLAN:
+		return "vxlan"
+	case nftexpr.NFT_INNER_GENEVE:
+		return "geneve"
+	}
+	return "inner"
+}
@@ -0,0 +1,43 @@
+package encoders
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Morwran/nft-go/pkg/nftast"
+	"github.com/Morwran/nft-go/pkg/nftexpr"
+
+	"github.com/google/nftables/expr"
+)
+
+func init() {
+	register(&nftexpr.Last{}, func(e expr.Any) encoder {
+		return &lastEncoder{last: e.(*nftexpr.Last)}
+	})
+}
+
+type (
+	lastEncoder struct {
+		last *nftexpr.Last
+	}
+
+	lastArgs struct {
+		Used uint64 `json:"used"`
+	}
+)
+
+func (b *lastEncoder) Encode(ctx *ctx) (nftast.Stmt, error) {
+	if !b.last.Set {
+		return &nftast.Statement{Name: "last", Args: (*lastArgs)(nil)}, nil
+	}
+	return &nftast.Statement{Name: "last", Args: &lastArgs{Used: b.last.Msecs}}, nil
+}
+
+// String prints the time since the rule was last used, the rules not used
+// yet have no arguments.
+func (a *lastArgs) String() string {
+	if a == nil {
+		return "last used never"
+	}
+	return fmt.Sprintf("last used %s", formatTimeout(time.Duration(a.Used)*time.Millisecond)) //nolint:gosec
+}
@@ -1,10 +1,11 @@
 package encoders
 
 import (
-	"encoding/json"
 	"fmt"
 	"strings"
 
+	"github.com/Morwran/nft-go/pkg/nftast"
+
 	"github.com/google/nftables/expr"
 )
 
@@ -15,72 +16,53 @@ func init() {
 }
 
 type (
-	limitIR struct {
-		*expr.Limit
-	}
-
 	limitEncoder struct {
 		limit *expr.Limit
 	}
-)
 
-func (l *limitIR) Format() string {
-	if l.Type == expr.LimitTypePkts {
-		return fmt.Sprintf("limit rate %s %d/%s burst %d packets",
-			map[bool]string{true: "over", false: ""}[l.Over],
-			l.Rate, LimitTime(l.Unit), l.Burst)
+	limitArgs struct {
+		Rate      uint64 `json:"rate"`
+		Burst     uint64 `json:"burst"`
+		Per       string `json:"per,omitempty"`
+		Inv       bool   `json:"inv,omitempty"`
+		RateUnit  string `json:"rate_unit,omitempty"`
+		BurstUnit string `json:"burst_unit,omitempty"`
 	}
-	sb := strings.Builder{}
-	rateVal, rateUnit := rate(l.Rate).Rate()
-	sb.WriteString(fmt.Sprintf("limit rate %s %d/%s/%s",
-		map[bool]string{true: "over", false: ""}[l.Over],
-		rateVal, rateUnit, LimitTime(l.Unit)))
-	if l.Burst != 0 {
-		burst, burstUnit := rate(uint64(l.Burst)).Rate()
-		sb.WriteString(fmt.Sprintf(" burst %d %s", burst, burstUnit))
-	}
-	return sb.String()
-}
+)
 
-func (b *limitEncoder) EncodeIR(ctx *ctx) (irNode, error) {
-	if lt := b.limit.Type; lt != expr.LimitTypePkts &&
-		lt != expr.LimitTypePktBytes {
-		return nil, fmt.Errorf("'%T' has unsupported type of limit '%d'", b.limit, lt)
+func (b *limitEncoder) Encode(ctx *ctx) (nftast.Stmt, error) {
+	limit := b.limit
+	args := &limitArgs{
+		Per: LimitTime(limit.Unit).String(),
+		Inv: limit.Over,
 	}
-	return &limitIR{b.limit}, nil
+	switch limit.Type {
+	case expr.LimitTypePkts:
+		args.Rate, args.Burst = limit.Rate, uint64(limit.Burst)
+	case expr.LimitTypePktBytes:
+		args.Rate, args.RateUnit = rate(limit.Rate).Rate()
+		args.Burst, args.BurstUnit = rate(uint64(limit.Burst)).Rate()
+	default:
+		return nil, fmt.Errorf("'%T' has unsupported type of limit '%d'", limit, limit.Type)
+	}
+	return &nftast.Statement{Name: "limit", Args: args}, nil
 }
 
-func (b *limitEncoder) EncodeJSON(ctx *ctx) ([]byte, error) {
-	var (
-		rateVal, burst      uint64
-		rateUnit, burstUnit string
-
-		limit = b.limit
-	)
-	if limit.Type == expr.LimitTypePktBytes {
-		rateVal, rateUnit = rate(limit.Rate).Rate()
-		burst, burstUnit = rate(limit.Burst).Rate()
+// String prints the rate in packets when it has no unit and in bytes
+// otherwise.
+func (a *limitArgs) String() string {
+	over := map[bool]string{true: "over ", false: ""}[a.Inv]
+	if a.RateUnit == "" {
+		return fmt.Sprintf("limit rate %s%d/%s burst %d packets",
+			over, a.Rate, a.Per, a.Burst)
 	}
-
-	limitJson := map[string]interface{}{
-		"limit": struct {
-			Rate      uint64 `json:"rate"`
-			Burst     uint64 `json:"burst"`
-			Per       string `json:"per,omitempty"`
-			Inv       bool   `json:"inv,omitempty"`
-			RateUnit  string `json:"rate_unit,omitempty"`
-			BurstUnit string `json:"burst_unit,omitempty"`
-		}{
-			Rate:      rateVal,
-			Burst:     burst,
-			Per:       LimitTime(limit.Unit).String(),
-			Inv:       limit.Over,
-			RateUnit:  rateUnit,
-			BurstUnit: burstUnit,
-		},
+	sb := strings.Builder{}
+	sb.WriteString(fmt.Sprintf("limit rate %s%d/%s/%s",
+		over, a.Rate, a.RateUnit, a.Per))
+	if a.Burst != 0 {
+		sb.WriteString(fmt.Sprintf(" burst %d %s", a.Burst, a.BurstUnit))
 	}
-
-	return json.Marshal(limitJson)
+	return sb.String()
 }
 
 type (
@@ -109,13 +91,20 @@ func (l LimitTime) String() string {
 	return "error"
 }
 
+// FormatBytes formats the amount of bytes in the largest unit dividing it
+// like `25 mbytes`.
+func FormatBytes(bytes uint64) string {
+	val, unit := getRate(bytes)
+	return fmt.Sprintf("%d %s", val, unit)
+}
+
 func getRate(bytes uint64) (val uint64, unit string) {
 	dataUnit := [...]string{"bytes", "kbytes", "mbytes"}
 	if bytes == 0 {
 		return 0, dataUnit[0]
 	}
 	i := 0
-	for i = range dataUnit {
+	for ; i < len(dataUnit)-1; i++ {
 		if bytes%1024 != 0 {
 			break
 		}
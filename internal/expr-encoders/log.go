@@ -2,10 +2,11 @@ package encoders
 
 import (
 	"bytes"
-	"encoding/json"
 	"fmt"
 	"strings"
 
+	"github.com/Morwran/nft-go/pkg/nftast"
+
 	"github.com/google/nftables/expr"
 	"golang.org/x/sys/unix"
 )
@@ -20,83 +21,75 @@ type (
 	logEncoder struct {
 		log *expr.Log
 	}
-	logIR struct {
-		*expr.Log
+	logArgs struct {
+		Prefix     string `json:"prefix,omitempty"`
+		Group      uint16 `json:"group,omitempty"`
+		Snaplen    uint32 `json:"snaplen,omitempty"`
+		QThreshold uint16 `json:"queue-threshold,omitempty"`
+		Level      string `json:"level,omitempty"`
+		Flags      any    `json:"flags,omitempty"`
+
+		// key tells the options set to print the zero ones
+		key   uint32
+		flags []string
 	}
 
 	LogFlags expr.LogFlags
 	LogLevel expr.LogLevel
 )
 
-func (b *logEncoder) EncodeIR(ctx *ctx) (irNode, error) {
-	return &logIR{Log: b.log}, nil
-}
-
-func (b *logEncoder) EncodeJSON(ctx *ctx) ([]byte, error) {
-	var fl any
+func (b *logEncoder) Encode(ctx *ctx) (nftast.Stmt, error) {
 	l := b.log
-	flags := LogFlags(l.Flags).String()
-	if len(flags) > 1 {
-		fl = flags
-	} else if len(flags) == 1 {
-		fl = flags[0]
+	if l.Key == 0 && l.Flags == 0 {
+		return &nftast.Statement{Name: "log", Args: (*logArgs)(nil)}, nil
 	}
-	log := &struct {
-		Prefix     string `json:"prefix,omitempty"`
-		Group      uint16 `json:"group,omitempty"`
-		Snaplen    uint32 `json:"snaplen,omitempty"`
-		QThreshold uint16 `json:"queue-threshold,omitempty"`
-		Level      string `json:"level,omitempty"`
-		Flags      any    `json:"flags,omitempty"`
-	}{
-		Flags: fl,
+	args := &logArgs{key: l.Key, flags: LogFlags(l.Flags).String()}
+	if len(args.flags) > 1 {
+		args.Flags = args.flags
+	} else if len(args.flags) == 1 {
+		args.Flags = args.flags[0]
 	}
-
 	if l.Key&(1<<unix.NFTA_LOG_PREFIX) != 0 {
-		log.Prefix = string(bytes.TrimRight(l.Data, "\x00"))
+		args.Prefix = string(bytes.TrimRight(l.Data, "\x00"))
 	}
 	if l.Key&(1<<unix.NFTA_LOG_GROUP) != 0 {
-		log.Group = l.Group
+		args.Group = l.Group
 	}
 	if l.Key&(1<<unix.NFTA_LOG_SNAPLEN) != 0 {
-		log.Snaplen = l.Snaplen
+		args.Snaplen = l.Snaplen
 	}
 	if l.Key&(1<<unix.NFTA_LOG_QTHRESHOLD) != 0 {
-		log.QThreshold = l.QThreshold
+		args.QThreshold = l.QThreshold
 	}
 	if l.Key&(1<<unix.NFTA_LOG_LEVEL) != 0 {
-		log.Level = LogLevel(l.Level).String()
-	}
-	if l.Key == 0 {
-		log = nil
-	}
-	lg := map[string]interface{}{
-		"log": log,
+		args.Level = LogLevel(l.Level).String()
 	}
-	return json.Marshal(lg)
+	return &nftast.Statement{Name: "log", Args: args}, nil
 }
 
-func (l *logIR) Format() string {
+func (l *logArgs) String() string {
+	if l == nil {
+		return "log"
+	}
 	sb := strings.Builder{}
 	sb.WriteString("log")
-	if l.Key&(1<<unix.NFTA_LOG_PREFIX) != 0 {
-		sb.WriteString(fmt.Sprintf(" prefix \"%s\"", string(bytes.TrimRight(l.Data, "\x00"))))
+	if l.key&(1<<unix.NFTA_LOG_PREFIX) != 0 {
+		sb.WriteString(fmt.Sprintf(" prefix \"%s\"", l.Prefix))
 	}
-	if l.Key&(1<<unix.NFTA_LOG_GROUP) != 0 {
+	if l.key&(1<<unix.NFTA_LOG_GROUP) != 0 {
 		sb.WriteString(fmt.Sprintf(" group %d", l.Group))
 	}
-	if l.Key&(1<<unix.NFTA_LOG_SNAPLEN) != 0 {
+	if l.key&(1<<unix.NFTA_LOG_SNAPLEN) != 0 {
 		sb.WriteString(fmt.Sprintf(" snaplen %d", l.Snaplen))
 	}
-	if l.Key&(1<<unix.NFTA_LOG_QTHRESHOLD) != 0 {
+	if l.key&(1<<unix.NFTA_LOG_QTHRESHOLD) != 0 {
 		sb.WriteString(fmt.Sprintf(" queue-threshold %d", l.QThreshold))
 	}
-	if l.Key&(1<<unix.NFTA_LOG_LEVEL) != 0 {
-		sb.WriteString(fmt.Sprintf(" level %s", LogLevel(l.Level)))
+	if l.key&(1<<unix.NFTA_LOG_LEVEL) != 0 {
+		sb.WriteString(fmt.Sprintf(" level %s", l.Level))
 	}
-	flags := LogFlags(l.Flags).String()
-	if len(flags) > 0 {
-		sb.WriteString(fmt.Sprintf(" flags %s", strings.Join(flags, ", ")))
+	if len(l.flags) > 0 {
+		sb.WriteString(fmt.Sprintf(" flags %s", strings.Join(l.flags, ", ")))
 	}
 
 	return sb.String()
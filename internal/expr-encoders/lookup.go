@@ -1,17 +1,15 @@
 package encoders
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
-	"regexp"
+
+	"github.com/Morwran/nft-go/pkg/nftast"
 
 	"github.com/google/nftables/expr"
 	"golang.org/x/sys/unix"
 )
 
-var containExpressionRe = regexp.MustCompile(`[()&|^<>]`)
-
 func init() {
 	register(&expr.Lookup{}, func(e expr.Any) encoder {
 		return &lookupEncoder{lookup: e.(*expr.Lookup)}
@@ -22,116 +20,55 @@ type (
 	lookupEncoder struct {
 		lookup *expr.Lookup
 	}
-	lookupIR struct {
-		left, right string
-		invert      bool
+
+	// mapArgs are the key looked up in the map and the map like
+	// `tcp dport map { 22 : 10.0.0.1 }`
+	mapArgs struct {
+		Key  nftast.Expr `json:"key"`
+		Data nftast.Expr `json:"data"`
+
+		kind string
 	}
 )
 
-func (b *lookupEncoder) EncodeIR(ctx *ctx) (irNode, error) {
+func (b *lookupEncoder) Encode(ctx *ctx) (nftast.Stmt, error) {
 	lk := b.lookup
 	if ctx.rule == nil {
 		return nil, errors.New("ctx has no rule")
 	}
-	set, ok := ctx.sets.Get(setKey{
-		tableName: ctx.rule.Table.Name,
-		setName:   lk.SetName,
-		setId:     lk.SetID,
-	})
+	set, ok, err := ctx.set(lk.SetName, lk.SetID)
+	if err != nil {
+		return nil, err
+	}
 	if !ok {
-		if err := ctx.sets.RefreshFromTable(ctx.rule.Table); err != nil {
-			return nil, err
-		}
-		if set, ok = ctx.sets.Get(setKey{
-			tableName: ctx.rule.Table.Name,
-			setName:   lk.SetName,
-			setId:     lk.SetID,
-		}); !ok {
-			return nil, fmt.Errorf("set %s not found", lk.SetName)
-		}
+		return nil, fmt.Errorf("set %s not found", lk.SetName)
 	}
 	srcReg, ok := ctx.reg.Get(regID(lk.SourceRegister))
 	if !ok {
 		return nil, fmt.Errorf("%T expression has no left hand side", lk)
 	}
-	left := srcReg.HumanExpr
-	setB := &setEncoder{set: set}
-	sIR, err := setB.EncodeIR(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("failed to build IR for set: %w", err)
-	}
-	right := sIR.Format()
+	left := srcReg.node()
+	right := (&setEncoder{set: set}).node(ctx, isHostOrder(srcReg))
 
 	if lk.IsDestRegSet {
-		mType := "vmap"
 		if lk.DestRegister != unix.NFT_REG_VERDICT {
-			mType = "map"
-			ctx.reg.Set(regID(lk.DestRegister), regVal{
-				HumanExpr: fmt.Sprintf("%s %s %s", left, mType, right),
+			ctx.reg.Set(regID(lk.DestRegister), RegValue{
+				Node: &nftast.Other{Name: "map", Args: &mapArgs{Key: left, Data: right, kind: "map"}},
+				Expr: lk,
 			})
-			return nil, ErrNoIR
-		}
-		return simpleIR(fmt.Sprintf("%s %s %s", left, mType, right)), nil
-	}
-	return &lookupIR{left: left, right: right, invert: lk.Invert}, nil
-}
-
-func (b *lookupEncoder) EncodeJSON(ctx *ctx) ([]byte, error) {
-	lk := b.lookup
-	srcReg, ok := ctx.reg.Get(regID(lk.SourceRegister))
-	if !ok {
-		return nil, fmt.Errorf("%T expression has no left hand side", lk)
-	}
-	setName := fmt.Sprintf(`@%s`, lk.SetName)
-	if lk.IsDestRegSet {
-		mapExp := struct {
-			Key  any    `json:"key"`
-			Data string `json:"data"`
-		}{
-			Key:  srcReg.Data,
-			Data: setName,
+			return nil, nil
 		}
-
-		if lk.DestRegister != unix.NFT_REG_VERDICT {
-			m := map[string]interface{}{
-				"map": mapExp,
-			}
-			ctx.reg.Set(regID(lk.DestRegister), regVal{Data: m})
-			return nil, ErrNoJSON
-		}
-		m := map[string]interface{}{
-			"vmap": mapExp,
-		}
-		return json.Marshal(m)
+		return &nftast.Statement{Name: "vmap", Args: &mapArgs{Key: left, Data: right, kind: "vmap"}}, nil
 	}
 	op := expr.CmpOpEq
 	if lk.Invert {
 		op = expr.CmpOpNeq
 	}
-	match := map[string]interface{}{
-		"match": struct {
-			Op    string `json:"op"`
-			Left  any    `json:"left"`
-			Right any    `json:"right"`
-		}{
-			Op:    CmpOp(op).String(),
-			Left:  srcReg.Data,
-			Right: setName,
-		},
-	}
-	return json.Marshal(match)
+	return &nftast.Match{Left: left, Op: CmpOp(op).String(), Right: right}, nil
 }
 
-func (l *lookupIR) Format() string {
-	left := l.left
-	right := l.right
-	if containExpressionRe.MatchString(left) {
-		op := CmpOp(expr.CmpOpEq)
-		if l.invert {
-			op = CmpOp(expr.CmpOpNeq)
-		}
-		left = fmt.Sprintf("(%s) %s", left, op)
-	}
+func (m *mapArgs) keys() []nftast.Expr { return []nftast.Expr{m.Key} }
 
-	return fmt.Sprintf("%s %s", left, right)
+func (m *mapArgs) String() string {
+	return fmt.Sprintf("%s %s %s", m.Key, m.kind, m.Data)
 }
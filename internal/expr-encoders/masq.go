@@ -1,6 +1,8 @@
 package encoders
 
 import (
+	"github.com/Morwran/nft-go/pkg/nftast"
+
 	"github.com/google/nftables/expr"
 )
 
@@ -14,7 +16,7 @@ type masqEncoder struct {
 	masq *expr.Masq
 }
 
-func (b *masqEncoder) EncodeIR(ctx *ctx) (irNode, error) {
+func (b *masqEncoder) Encode(ctx *ctx) (nftast.Stmt, error) {
 	nb := natEncoder{
 		nat: &expr.NAT{
 			Type:        NATTypeMASQ,
@@ -26,19 +28,5 @@ func (b *masqEncoder) EncodeIR(ctx *ctx) (irNode, error) {
 		},
 	}
 
-	return nb.EncodeIR(ctx)
-}
-
-func (b *masqEncoder) EncodeJSON(ctx *ctx) ([]byte, error) {
-	nb := natEncoder{
-		nat: &expr.NAT{
-			Type:        NATTypeMASQ,
-			Persistent:  b.masq.Persistent,
-			Random:      b.masq.Random,
-			FullyRandom: b.masq.FullyRandom,
-			RegProtoMin: b.masq.RegProtoMin,
-			RegProtoMax: b.masq.RegProtoMax,
-		},
-	}
-	return nb.EncodeJSON(ctx)
+	return nb.Encode(ctx)
 }
@@ -1,8 +1,7 @@
-//nolint:dupl
 package encoders
 
 import (
-	"fmt"
+	"github.com/Morwran/nft-go/pkg/nftast"
 
 	"github.com/google/nftables/expr"
 )
@@ -17,10 +16,6 @@ type matchEncoder struct {
 	match *expr.Match
 }
 
-func (b *matchEncoder) EncodeIR(ctx *ctx) (irNode, error) {
-	return simpleIR(fmt.Sprintf(`xt match %q`, b.match.Name)), nil
-}
-
-func (b *matchEncoder) EncodeJSON(ctx *ctx) ([]byte, error) {
-	return []byte(fmt.Sprintf(`{"xt":{"type":"match","name":%q}}`, b.match.Name)), nil
+func (b *matchEncoder) Encode(ctx *ctx) (nftast.Stmt, error) {
+	return &nftast.Statement{Name: "xt", Args: &xtArgs{Type: "match", Name: b.match.Name}}, nil
 }
@@ -1,14 +1,16 @@
 package encoders
 
 import (
-	"encoding/json"
-	"fmt"
+	"bytes"
 
 	rb "github.com/Morwran/nft-go/internal/bytes"
+	"github.com/Morwran/nft-go/pkg/nftast"
 	pr "github.com/Morwran/nft-go/pkg/protocols"
 
+	nft "github.com/google/nftables"
 	"github.com/google/nftables/expr"
 	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
 )
 
 func init() {
@@ -22,103 +24,139 @@ type (
 		meta *expr.Meta
 	}
 
-	metaIR struct {
-		key MetaKey
-		exp string
-	}
-
 	MetaKey expr.MetaKey
 )
 
-func (b *metaEncoder) EncodeIR(ctx *ctx) (irNode, error) {
+// meta keys the nftables fork has no constants for
+const (
+	MetaKeyTIME expr.MetaKey = 30 + iota
+	MetaKeyDAY
+	MetaKeyHOUR
+)
+
+func (b *metaEncoder) Encode(ctx *ctx) (nftast.Stmt, error) {
 	meta := b.meta
-	metaKey := MetaKey(meta.Key)
-	metaExpr := metaKey.String()
-	if !metaKey.IsUnqualified() {
-		metaExpr = fmt.Sprintf("meta %s", metaKey)
-	}
+	key := nftast.Meta{Key: MetaKey(meta.Key).String()}
+	value := func(data []byte) nftast.Expr { return b.value(ctx, data) }
 	if !meta.SourceRegister {
 		if meta.Register == 0 {
 			return nil, errors.Errorf("%T expression has invalid destination register %d", meta, meta.Register)
 		}
 
 		ctx.reg.Set(regID(meta.Register),
-			regVal{
-				HumanExpr: metaExpr,
-				Expr:      meta,
+			RegValue{
+				Node:  key,
+				Expr:  meta,
+				Value: value,
 			})
-		return nil, ErrNoIR
+		return nil, nil
 	}
 	srcReg, ok := ctx.reg.Get(regID(meta.Register))
 	if !ok {
 		return nil, errors.Errorf("%T statement has no expression", meta)
 	}
-	metaExpr = srcReg.HumanExpr
-
-	switch t := srcReg.Expr.(type) {
-	case *expr.Immediate:
-		metaExpr = b.metaDataToString(t.Data)
-	}
-
-	return &metaIR{key: metaKey, exp: metaExpr}, nil
+	return mangleStmt(key, srcReg.typed(value)), nil
 }
 
-func (b *metaEncoder) EncodeJSON(ctx *ctx) ([]byte, error) {
-	meta := b.meta
-	metaJson := map[string]interface{}{
-		"meta": struct {
-			Key string `json:"key"`
-		}{
-			Key: MetaKey(meta.Key).String(),
-		},
+// selectHeader makes the protocol matched by `meta l4proto`, `meta nfproto`
+// or `meta protocol` the current header for the following payload expressions.
+func (b *metaEncoder) selectHeader(ctx *ctx, cmp *expr.Cmp) {
+	var (
+		proto pr.ProtoDesc
+		ok    bool
+	)
+	switch b.meta.Key {
+	case expr.MetaKeyL4PROTO:
+		proto, ok = pr.LookupProtocol(expr.PayloadBaseTransportHeader, pr.ProtoType(int(rb.RawBytes(cmp.Data).Uint64()))) //nolint:gosec
+	case expr.MetaKeyNFPROTO:
+		proto, ok = networkHeaderOf(nfProtoToEtherType[rb.RawBytes(cmp.Data).Uint64()])
+	case expr.MetaKeyPROTOCOL:
+		proto, ok = networkHeaderOf(pr.EtherType(rb.RawBytes(cmp.Data).Uint64())) //nolint:gosec
 	}
-	if !meta.SourceRegister {
-		if meta.Register == 0 {
-			return nil, errors.Errorf("%T expression has invalid destination register %d", meta, meta.Register)
+
+	if ok {
+		*ctx.hdr = &proto
+		if cmp.Op == expr.CmpOpEq {
+			ctx.deps.expect(proto)
 		}
-		ctx.reg.Set(
-			regID(meta.Register),
-			regVal{
-				Data: metaJson,
-				Expr: meta,
-			})
-		return nil, ErrNoJSON
 	}
+}
 
-	srcReg, ok := ctx.reg.Get(regID(meta.Register))
-	if !ok {
-		return nil, errors.Errorf("%T statement has no expression", meta)
-	}
+// nfProtoToEtherType maps the netfilter families which select a network header.
+var nfProtoToEtherType = map[uint64]pr.EtherType{
+	unix.NFPROTO_IPV4: pr.ETH_P_IP,
+	unix.NFPROTO_IPV6: pr.ETH_P_IPV6,
+}
 
-	mangle := map[string]interface{}{
-		"mangle": struct {
-			Key any `json:"key"`
-			Val any `json:"value"`
-		}{
-			Key: metaJson,
-			Val: srcReg.Data,
-		},
+// networkHeaderOf returns the network header carried by the ether type.
+func networkHeaderOf(t pr.EtherType) (pr.ProtoDesc, bool) {
+	switch t {
+	case pr.ETH_P_IP:
+		return pr.LookupProtocol(expr.PayloadBaseNetworkHeader, unix.IPPROTO_IP)
+	case pr.ETH_P_IPV6:
+		return pr.LookupProtocol(expr.PayloadBaseNetworkHeader, unix.IPPROTO_IPV6)
 	}
+	return pr.ProtoDesc{}, false
+}
 
-	return json.Marshal(mangle)
+// valueDesc returns the formatter of the values the meta key is compared with.
+func (b *metaEncoder) valueDesc(ctx *ctx) func([]byte) string {
+	if b.meta.Key == expr.MetaKeyL4PROTO {
+		return ctx.opts.formatProtocol
+	}
+	if typ, ok := b.datatype(); ok {
+		return func(data []byte) string {
+			s, _ := ctx.opts.FormatValue(typ, data)
+			return s
+		}
+	}
+	return b.metaDataToString
 }
 
-func (b *metaEncoder) buildFromCmpData(ctx *ctx, cmp *expr.Cmp) (res string) {
-	var protos pr.ProtoTypeHolder
+// datatype returns the datatype of the keys with values resolved into names
+// or formatted as times.
+func (b *metaEncoder) datatype() (nft.SetDatatype, bool) {
 	switch b.meta.Key {
-	case expr.MetaKeyL4PROTO, expr.MetaKeyPROTOCOL:
-		protos = pr.Protocols[expr.PayloadBaseTransportHeader]
-	case expr.MetaKeyNFPROTO:
-		protos = pr.Protocols[expr.PayloadBaseNetworkHeader]
-	}
+	case expr.MetaKeyIIF, expr.MetaKeyOIF:
+		return nft.TypeIFIndex, true
+	case expr.MetaKeySKUID:
+		return nft.TypeUID, true
+	case expr.MetaKeySKGID:
+		return nft.TypeGID, true
+	case MetaKeyTIME:
+		return nft.TypeTimeDate, true
+	case MetaKeyDAY:
+		return nft.TypeTimeDay, true
+	case MetaKeyHOUR:
+		return nft.TypeTimeHour, true
+	}
+	return nft.TypeInvalid, false
+}
 
-	res = b.metaDataToString(cmp.Data)
+// value types the value the meta key is compared with or set to.
+func (b *metaEncoder) value(ctx *ctx, data []byte) nftast.Expr {
+	return constant(b.valueJSON(ctx, data), b.valueDesc(ctx)(data))
+}
 
-	if proto, ok := protos[pr.ProtoType(int(rb.RawBytes(cmp.Data).Uint64()))]; ok { //nolint:gosec
-		res = proto.Name
-		*ctx.hdr = &proto
-	}
-	return res
+// valueJSON converts the value the meta key is compared with into its JSON form.
+func (b *metaEncoder) valueJSON(ctx *ctx, data []byte) any {
+	switch b.meta.Key {
+	case expr.MetaKeyL4PROTO:
+		if name, ok := ctx.opts.nameDB().ProtocolName(pr.ProtoType(rb.RawBytes(data).Uint64())); ok && !ctx.opts.numeric { //nolint:gosec
+			return name
+		}
+		return rb.RawBytes(data).Uint64()
+	case expr.MetaKeyIIFNAME, expr.MetaKeyOIFNAME,
+		expr.MetaKeyBRIIIFNAME, expr.MetaKeyBRIOIFNAME:
+		return string(bytes.TrimRight(data, "\x00"))
+	case expr.MetaKeyNFPROTO, expr.MetaKeyPROTOCOL:
+		return b.metaDataToString(data)
+	}
+	if typ, ok := b.datatype(); ok {
+		v, _ := ctx.opts.jsonValue(typ, data)
+		return v
+	}
+	return rb.RawBytes(data).LittleEndian().Uint64()
 }
 
 func (b *metaEncoder) metaDataToString(data []byte) string {
@@ -128,21 +166,16 @@ func (b *metaEncoder) metaDataToString(data []byte) string {
 		expr.MetaKeyBRIIIFNAME,
 		expr.MetaKeyBRIOIFNAME:
 		return rb.RawBytes(data).String()
-	case expr.MetaKeyPROTOCOL, expr.MetaKeyNFPROTO, expr.MetaKeyL4PROTO:
-		proto := pr.ProtoType(int(rb.RawBytes(data).Uint64())).String() //nolint:gosec
-
-		return proto
+	case expr.MetaKeyL4PROTO:
+		return pr.BytesToProtoString(data)
+	case expr.MetaKeyNFPROTO:
+		return rb.BytesToNfProtoString(data)
+	case expr.MetaKeyPROTOCOL:
+		return pr.BytesToEtherType(data)
 	default:
-		return rb.RawBytes(data).Text(rb.BaseDec)
-	}
-}
-
-func (m *metaIR) Format() (res string) {
-	metaExpr := fmt.Sprintf("%s set %s", m.key, m.exp)
-	if !m.key.IsUnqualified() {
-		metaExpr = fmt.Sprintf("meta %s set %s", m.key, m.exp)
+		// the rest of the keys are kept in host byte order
+		return rb.LEBytesToIntString(data)
 	}
-	return metaExpr
 }
 
 func (m MetaKey) String() string {
@@ -197,6 +230,12 @@ func (m MetaKey) String() string {
 		return "cgroup"
 	case expr.MetaKeyPRANDOM:
 		return "random"
+	case MetaKeyTIME:
+		return "time"
+	case MetaKeyDAY:
+		return "day"
+	case MetaKeyHOUR:
+		return "hour"
 	}
 	return "unknown"
 }
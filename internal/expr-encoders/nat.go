@@ -1,10 +1,14 @@
 package encoders
 
 import (
-	"encoding/json"
 	"fmt"
+	"net"
 	"strings"
 
+	rb "github.com/Morwran/nft-go/internal/bytes"
+	"github.com/Morwran/nft-go/pkg/nftast"
+
+	"github.com/google/nftables"
 	"github.com/google/nftables/expr"
 	"github.com/pkg/errors"
 	"golang.org/x/sys/unix"
@@ -26,155 +30,184 @@ type (
 		nat *expr.NAT
 	}
 
-	natIR struct {
-		*expr.NAT
-		addr  string
-		port  string
+	natArgs struct {
+		Family    string      `json:"family,omitempty"`
+		Addr      nftast.Expr `json:"addr,omitempty"`
+		Port      nftast.Expr `json:"port,omitempty"`
+		Flags     any         `json:"flags,omitempty"`
+		TypeFlags any         `json:"type_flags,omitempty"`
+
+		typ   string
 		flags []string
 	}
 
+	// natRegs holds the registers the address and port ranges are loaded
+	// from, nil when the statement does not use them.
+	natRegs struct {
+		addrMin, addrMax, protoMin, protoMax *RegValue
+	}
+
 	NATType expr.NATType
 )
 
-func (b *natEncoder) EncodeIR(ctx *ctx) (irNode, error) {
-	var addr, port string
+func (b *natEncoder) Encode(ctx *ctx) (nftast.Stmt, error) {
 	nat := b.nat
-	if nat.RegAddrMin != 0 {
-		addrMinExpr, ok := ctx.reg.Get(regID(nat.RegAddrMin))
-		if !ok {
-			return nil, errors.Errorf("%T statement has no address expression", nat)
-		}
-		addr = addrMinExpr.HumanExpr
+	regs, err := b.regs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	args := &natArgs{
+		Family: b.qualifier(ctx),
+		typ:    NATType(nat.Type).String(),
+		flags:  b.Flags(),
+	}
+	if len(args.flags) > 1 {
+		args.Flags = args.flags
+	} else if len(args.flags) == 1 {
+		args.Flags = args.flags[0]
+	}
+	if nat.Prefix {
+		args.TypeFlags = "prefix"
+	}
 
-		if nat.Family == unix.NFPROTO_IPV6 {
-			if nat.Family == unix.NFPROTO_IPV6 {
-				addr = fmt.Sprintf("[%s]", addr)
+	withPort := regs.protoMin != nil
+	if regs.addrMin != nil {
+		args.Addr = b.addrValue(regs.addrMin, withPort)
+	}
+	if regs.addrMax != nil {
+		addrMax := b.addrValue(regs.addrMax, withPort)
+		switch {
+		case args.Addr == nil:
+			args.Addr = addrMax
+		case nat.Prefix:
+			if prefix, ok := natPrefix(regs.addrMin, regs.addrMax); ok {
+				args.Addr = &nftast.Prefix{Addr: args.Addr, Len: int64(prefix)}
+				break
 			}
+			args.Addr = &nftast.Range{Min: args.Addr, Max: addrMax}
+		default:
+			args.Addr = &nftast.Range{Min: args.Addr, Max: addrMax}
 		}
 	}
-	if nat.RegAddrMax != 0 && nat.RegAddrMax != nat.RegAddrMin {
-		addrMaxExpr, ok := ctx.reg.Get(regID(nat.RegAddrMax))
-		if !ok {
-			return nil, errors.Errorf("%T statement has no address expression", nat)
-		}
-		if addr == "" {
-			addr = addrMaxExpr.HumanExpr
-			if nat.Family == unix.NFPROTO_IPV6 {
-				if nat.Family == unix.NFPROTO_IPV6 {
-					addr = fmt.Sprintf("[%s]", addr)
-				}
-			}
+	if regs.protoMin != nil {
+		args.Port = b.portValue(ctx, regs.protoMin)
+	}
+	if regs.protoMax != nil {
+		if portMax := b.portValue(ctx, regs.protoMax); args.Port == nil {
+			args.Port = portMax
 		} else {
-			addrMax := addrMaxExpr.HumanExpr
-			if addrMax != "" {
-				addr = fmt.Sprintf("%s-%s", addr, addrMax)
-			}
-			if nat.Family == unix.NFPROTO_IPV6 {
-				addr = fmt.Sprintf("%s-[%s]", addr, addrMax)
-			}
+			args.Port = &nftast.Range{Min: args.Port, Max: portMax}
 		}
 	}
-	if nat.RegProtoMin != 0 {
-		portMinExpr, ok := ctx.reg.Get(regID(nat.RegProtoMin))
+	return &nftast.Statement{Name: args.typ, Args: args}, nil
+}
+
+// args loads the registers of the statement. A map can hold the whole range
+// in its data like `dnat to ip daddr map { 10.0.0.1 : 192.168.0.1 . 8080 }`
+// then the registers following the looked up one are not set by any
+// expression and are left out.
+func (b *natEncoder) regs(ctx *ctx) (args natRegs, err error) {
+	nat := b.nat
+	load := func(reg uint32, mapped bool, what string) (*RegValue, error) {
+		if reg == 0 {
+			return nil, nil
+		}
+		r, ok := ctx.reg.Get(regID(reg))
 		if !ok {
-			return nil, errors.Errorf("%T statement has no port expression", nat)
+			if mapped {
+				return nil, nil
+			}
+			return nil, errors.Errorf("%T statement has no %s expression", nat, what)
 		}
-		port = portMinExpr.HumanExpr
+		return &r, nil
 	}
-	if nat.RegProtoMax != 0 && nat.RegProtoMax != nat.RegProtoMin {
-		portMaxExpr, ok := ctx.reg.Get(regID(nat.RegProtoMax))
-		if !ok {
-			return nil, errors.Errorf("%T statement has no port expression", nat)
+	if args.addrMin, err = load(nat.RegAddrMin, false, "address"); err != nil {
+		return args, err
+	}
+	if nat.RegAddrMax != nat.RegAddrMin {
+		if args.addrMax, err = load(nat.RegAddrMax, isMapped(args.addrMin), "address"); err != nil {
+			return args, err
 		}
-		if port == "" {
-			port = portMaxExpr.HumanExpr
-		} else {
-			portMax := portMaxExpr.HumanExpr
-			if portMax != "" {
-				port = fmt.Sprintf("%s-%s", port, portMax)
-			}
+	}
+	if args.protoMin, err = load(nat.RegProtoMin, isMapped(args.addrMin), "port"); err != nil {
+		return args, err
+	}
+	if nat.RegProtoMax != nat.RegProtoMin {
+		mapped := isMapped(args.addrMin) || isMapped(args.protoMin)
+		if args.protoMax, err = load(nat.RegProtoMax, mapped, "port"); err != nil {
+			return args, err
 		}
 	}
-	return &natIR{NAT: nat, addr: addr, port: port, flags: b.Flags()}, nil
+	return args, nil
 }
 
-func (b *natEncoder) EncodeJSON(ctx *ctx) ([]byte, error) {
-	var (
-		flag       any
-		family     string
-		addr, port any
-		nat        = b.nat
-	)
-	flags := b.Flags()
-
-	if len(flags) > 1 {
-		flag = flags
-	} else if len(flags) == 1 {
-		flag = flags[0]
+// isMapped reports whether the register holds the data looked up in a map.
+func isMapped(r *RegValue) bool {
+	if r == nil {
+		return false
 	}
+	lk, ok := r.Expr.(*expr.Lookup)
+	return ok && lk.IsDestRegSet
+}
 
-	if nat.Family == unix.NFPROTO_IPV4 || nat.Family == unix.NFPROTO_IPV6 {
-		family = b.FamilyToString()
+// qualifier returns the address family the statement is qualified with. nft
+// needs it in inet tables only, it is kept when the table is unknown.
+func (b *natEncoder) qualifier(ctx *ctx) string {
+	if ctx.rule != nil && ctx.rule.Table != nil && ctx.rule.Table.Family != nftables.TableFamilyINet {
+		return ""
 	}
-	if nat.RegAddrMin != 0 {
-		addrMinExpr, ok := ctx.reg.Get(regID(nat.RegAddrMin))
-		if !ok {
-			return nil, errors.Errorf("%T statement has no address expression", nat)
-		}
-		addr = addrMinExpr.Data
+	switch b.nat.Family {
+	case unix.NFPROTO_IPV4, unix.NFPROTO_IPV6:
+		return b.FamilyToString()
 	}
+	return ""
+}
 
-	if nat.RegAddrMax != 0 && nat.RegAddrMax != nat.RegAddrMin {
-		addrMaxExpr, ok := ctx.reg.Get(regID(nat.RegAddrMax))
-		if !ok || addrMaxExpr.Data == nil {
-			return nil, errors.Errorf("%T statement has no address expression", nat)
+// addrValue types the address, IPv6 addresses are put in brackets when
+// followed by a port like `[fe80::1]:80`.
+func (b *natEncoder) addrValue(r *RegValue, withPort bool) nftast.Expr {
+	return r.typed(func(data []byte) nftast.Expr {
+		v := addrValue(data)
+		if withPort && len(data) == net.IPv6len {
+			v.Text = fmt.Sprintf("[%s]", v.V)
 		}
-		if addr == nil {
-			addr = addrMaxExpr.Data
-		} else {
-			addr = map[string]interface{}{
-				"range": [2]any{addr, addrMaxExpr.Data},
-			}
-		}
-	}
+		return v
+	})
+}
 
-	if nat.RegProtoMin != 0 {
-		portMinExpr, ok := ctx.reg.Get(regID(nat.RegProtoMin))
-		if !ok || portMinExpr.Data == nil {
-			return nil, errors.Errorf("%T statement has no port expression", nat)
-		}
-		port = portMinExpr.Data
-	}
+func (b *natEncoder) portValue(ctx *ctx, r *RegValue) nftast.Expr {
+	return r.typed(func(data []byte) nftast.Expr {
+		return constant(rb.RawBytes(data).Uint64(), ctx.opts.formatService("", data))
+	})
+}
 
-	if nat.RegProtoMax != 0 && nat.RegProtoMax != nat.RegProtoMin {
-		portMaxExpr, ok := ctx.reg.Get(regID(nat.RegProtoMax))
-		if !ok || portMaxExpr.Data == nil {
-			return nil, errors.Errorf("%T statement has no port expression", nat)
-		}
-		if port == nil {
-			port = portMaxExpr.Data
-		} else {
-			port = map[string]interface{}{
-				"range": [2]any{port, portMaxExpr.Data},
-			}
-		}
+// natPrefix returns the length of the prefix the address range covers like
+// 10.0.0.0-10.0.0.255 for 10.0.0.0/24.
+func natPrefix(addrMin, addrMax *RegValue) (int, bool) {
+	minImm, ok1 := addrMin.Expr.(*expr.Immediate)
+	maxImm, ok2 := addrMax.Expr.(*expr.Immediate)
+	if !ok1 || !ok2 || len(minImm.Data) != len(maxImm.Data) {
+		return 0, false
 	}
-
-	natJson := map[string]interface{}{
-		NATType(nat.Type).String(): struct {
-			Family string `json:"family,omitempty"`
-			Addr   any    `json:"addr,omitempty"`
-			Port   any    `json:"port,omitempty"`
-			Flags  any    `json:"flags,omitempty"`
-		}{
-			Family: family,
-			Addr:   addr,
-			Port:   port,
-			Flags:  flag,
-		},
+	mask := make(net.IPMask, len(minImm.Data))
+	for i := range mask {
+		mask[i] = ^(minImm.Data[i] ^ maxImm.Data[i])
+	}
+	ones, size := mask.Size()
+	if size == 0 || ones == size {
+		return 0, false
+	}
+	for i := range mask {
+		if minImm.Data[i]&^mask[i] != 0 {
+			return 0, false
+		}
 	}
+	return ones, true
+}
 
-	return json.Marshal(natJson)
+// addrValue types an IP address.
+func addrValue(data []byte) nftast.Value {
+	return nftast.Value{V: rb.RawBytes(data).Ip().String()}
 }
 
 func (b *natEncoder) FamilyToString() string {
@@ -208,27 +241,27 @@ func (b *natEncoder) Flags() (flags []string) {
 	return flags
 }
 
-func (n *natIR) Format() string {
+func (n *natArgs) String() string {
 	sb := strings.Builder{}
-	sb.WriteString(NATType(n.Type).String())
-
-	if n.addr != "" || n.port != "" {
-		switch n.Family {
-		case unix.NFPROTO_IPV4:
-			sb.WriteString(" ip")
-		case unix.NFPROTO_IPV6:
-			sb.WriteString(" ip6")
+	sb.WriteString(n.typ)
+
+	if n.Addr != nil || n.Port != nil {
+		if n.Family != "" {
+			sb.WriteString(fmt.Sprintf(" %s", n.Family))
+		}
+		if n.TypeFlags != nil {
+			sb.WriteString(" prefix")
 		}
 		sb.WriteString(" to")
 	}
-	if n.addr != "" {
-		sb.WriteString(fmt.Sprintf(" %s", n.addr))
+	if n.Addr != nil {
+		sb.WriteString(fmt.Sprintf(" %s", n.Addr))
 	}
-	if n.port != "" {
-		if n.addr == "" {
+	if n.Port != nil {
+		if n.Addr == nil {
 			sb.WriteByte(' ')
 		}
-		sb.WriteString(fmt.Sprintf(":%s", n.port))
+		sb.WriteString(fmt.Sprintf(":%s", n.Port))
 	}
 
 	if len(n.flags) > 0 {
@@ -0,0 +1,85 @@
+package encoders
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Morwran/nft-go/pkg/nftast"
+
+	"github.com/google/nftables/expr"
+)
+
+func init() {
+	register(&expr.Ndpi{}, func(e expr.Any) encoder {
+		return &ndpiEncoder{ndpi: e.(*expr.Ndpi)}
+	})
+}
+
+type (
+	ndpiEncoder struct {
+		ndpi *expr.Ndpi
+	}
+
+	ndpiArgs struct {
+		Inv       bool     `json:"inv,omitempty"`
+		Hostname  string   `json:"hostname,omitempty"`
+		Protocols []string `json:"protocols,omitempty"`
+		Flags     []string `json:"flags,omitempty"`
+	}
+)
+
+func (b *ndpiEncoder) Encode(ctx *ctx) (nftast.Stmt, error) {
+	dpi := b.ndpi
+	return &nftast.Statement{Name: "ndpi", Args: &ndpiArgs{
+		Inv:       dpi.Flags&expr.NFT_NDPI_FLAG_INVERT != 0,
+		Hostname:  dpi.Hostname,
+		Protocols: dpi.Protocols,
+		Flags:     NdpiFlags(dpi.Flags).Options(),
+	}}, nil
+}
+
+func (n *ndpiArgs) String() string {
+	sb := strings.Builder{}
+	sb.WriteString("ndpi")
+	if n.Inv {
+		sb.WriteString(" !")
+	}
+	if n.Hostname != "" {
+		sb.WriteString(fmt.Sprintf(" host %s", n.Hostname))
+	}
+	if len(n.Protocols) != 0 {
+		sb.WriteString(fmt.Sprintf(" protocol %s", strings.Join(n.Protocols, ",")))
+	}
+	for _, opt := range n.Flags {
+		sb.WriteString(" " + opt)
+	}
+	return sb.String()
+}
+
+type NdpiFlags uint16
+
+// Options returns the names of the match options set by the flags. The
+// invert, host, regexp and empty flags follow from the other fields of the
+// expression and are not listed.
+func (f NdpiFlags) Options() (opts []string) {
+	for _, o := range [...]struct {
+		flag uint16
+		name string
+	}{
+		{expr.NFT_NDPI_FLAG_ERROR, "error"},
+		{expr.NFT_NDPI_FLAG_M_PROTO, "master-proto"},
+		{expr.NFT_NDPI_FLAG_P_PROTO, "app-proto"},
+		{expr.NFT_NDPI_FLAG_HAVE_MASTER, "have-master"},
+		{expr.NFT_NDPI_FLAG_INPROGRESS, "inprogress"},
+		{expr.NFT_NDPI_FLAG_JA3S, "ja3s"},
+		{expr.NFT_NDPI_FLAG_JA3C, "ja3c"},
+		{expr.NFT_NDPI_FLAG_TLSFP, "tlsfp"},
+		{expr.NFT_NDPI_FLAG_TLSV, "tlsv"},
+		{expr.NFT_NDPI_FLAG_UNTRACKED, "untracked"},
+	} {
+		if uint16(f)&o.flag != 0 {
+			opts = append(opts, o.name)
+		}
+	}
+	return opts
+}
@@ -1,6 +1,8 @@
 package encoders
 
 import (
+	"github.com/Morwran/nft-go/pkg/nftast"
+
 	"github.com/google/nftables/expr"
 )
 
@@ -14,10 +16,6 @@ type notrackEncoder struct {
 	notrack *expr.Notrack
 }
 
-func (b *notrackEncoder) EncodeIR(ctx *ctx) (irNode, error) {
-	return simpleIR("notrack"), nil
-}
-
-func (b *notrackEncoder) EncodeJSON(ctx *ctx) ([]byte, error) {
-	return []byte(`{"notrack":null}`), nil
+func (b *notrackEncoder) Encode(ctx *ctx) (nftast.Stmt, error) {
+	return &nftast.Statement{Name: "notrack"}, nil
 }
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/Morwran/nft-go/pkg/nftast"
+
 	"github.com/google/nftables/expr"
 	"github.com/pkg/errors"
 	"golang.org/x/sys/unix"
@@ -15,47 +17,43 @@ func init() {
 	})
 }
 
-type numgenEncoder struct {
-	numgen *expr.Numgen
-}
+type (
+	numgenEncoder struct {
+		numgen *expr.Numgen
+	}
 
-func (b *numgenEncoder) EncodeIR(ctx *ctx) (irNode, error) {
+	numgenArgs struct {
+		Mode   string `json:"mode"`
+		Mod    uint32 `json:"mod"`
+		Offset uint32 `json:"offset"`
+	}
+)
+
+func (b *numgenEncoder) Encode(ctx *ctx) (nftast.Stmt, error) {
 	numgen := b.numgen
 	if numgen.Register == 0 {
 		return nil, errors.Errorf("%T expression has invalid destination register %d", numgen, numgen.Register)
 	}
-	sb := strings.Builder{}
-	sb.WriteString(fmt.Sprintf("numgen %s mod %d", b.NumgenModeToString(), numgen.Modulus))
-	if numgen.Offset != 0 {
-		sb.WriteString(fmt.Sprintf(" offset %d", numgen.Offset))
-	}
 	ctx.reg.Set(regID(numgen.Register),
-		regVal{
-			HumanExpr: sb.String(),
-			Expr:      numgen,
+		RegValue{
+			Node: &nftast.Other{Name: "numgen", Args: &numgenArgs{
+				Mode:   b.NumgenModeToString(),
+				Mod:    numgen.Modulus,
+				Offset: numgen.Offset,
+			}},
+			Expr:  numgen,
+			Value: hostOrderValue,
 		})
-	return nil, ErrNoIR
+	return nil, nil
 }
 
-func (b *numgenEncoder) EncodeJSON(ctx *ctx) ([]byte, error) {
-	numgen := b.numgen
-	if numgen.Register == 0 {
-		return nil, errors.Errorf("%T expression has invalid destination register %d", numgen, numgen.Register)
-	}
-	nj := map[string]interface{}{
-		"numgen": struct {
-			Mode   string `json:"mode"`
-			Mod    uint32 `json:"mod"`
-			Offset uint32 `json:"offset"`
-		}{
-			Mode:   b.NumgenModeToString(),
-			Mod:    numgen.Modulus,
-			Offset: numgen.Offset,
-		},
+func (a *numgenArgs) String() string {
+	sb := strings.Builder{}
+	sb.WriteString(fmt.Sprintf("numgen %s mod %d", a.Mode, a.Mod))
+	if a.Offset != 0 {
+		sb.WriteString(fmt.Sprintf(" offset %d", a.Offset))
 	}
-	ctx.reg.Set(regID(numgen.Register), regVal{Data: nj})
-
-	return nil, ErrNoJSON
+	return sb.String()
 }
 
 func (b *numgenEncoder) NumgenModeToString() string {
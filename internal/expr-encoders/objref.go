@@ -1,9 +1,12 @@
 package encoders
 
 import (
+	"encoding/json"
 	"fmt"
 	"strings"
 
+	"github.com/Morwran/nft-go/pkg/nftast"
+
 	"github.com/google/nftables/expr"
 	"golang.org/x/sys/unix"
 )
@@ -18,21 +21,22 @@ type (
 	objrefEncoder struct {
 		objrerf *expr.Objref
 	}
-	objrefIR struct {
+	// objrefArgs are printed as the name of the object in JSON
+	objrefArgs struct {
 		*expr.Objref
 	}
 )
 
-func (b *objrefEncoder) EncodeIR(ctx *ctx) (irNode, error) {
-	return &objrefIR{b.objrerf}, nil
+func (b *objrefEncoder) Encode(ctx *ctx) (nftast.Stmt, error) {
+	o := b.objrerf
+	return &nftast.Statement{Name: ObjType(o.Type).String(), Args: &objrefArgs{o}}, nil
 }
 
-func (b *objrefEncoder) EncodeJSON(ctx *ctx) ([]byte, error) {
-	o := b.objrerf
-	return []byte(fmt.Sprintf(`{%q:%q}`, o.Type, o.Name)), nil
+func (o *objrefArgs) MarshalJSON() ([]byte, error) {
+	return json.Marshal(o.Name)
 }
 
-func (o *objrefIR) Format() string {
+func (o *objrefArgs) String() string {
 	sb := strings.Builder{}
 	objType := ObjType(o.Type)
 	switch objType {
@@ -0,0 +1,261 @@
+package encoders
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	rb "github.com/Morwran/nft-go/internal/bytes"
+	"github.com/Morwran/nft-go/pkg/nftast"
+	pr "github.com/Morwran/nft-go/pkg/protocols"
+	"github.com/Morwran/nft-go/pkg/resolver"
+
+	nft "github.com/google/nftables"
+)
+
+type (
+	// Option tunes the way rule expressions are rendered.
+	Option func(*Options)
+
+	// Options holds the rendering settings shared by all expression encoders
+	// of a rule.
+	Options struct {
+		names    *pr.NameDB
+		resolver resolver.Resolver
+		sets     resolver.SetResolver
+		services bool
+		numeric  bool
+		strict   bool
+		lenient  bool
+		report   func(error)
+	}
+)
+
+// WithServiceNames renders ports as service names (`tcp dport ssh`) like nft
+// does by default. Ports without a known name stay numeric.
+func WithServiceNames() Option {
+	return func(o *Options) { o.services = true }
+}
+
+// WithNumeric renders ports, protocol numbers, interface indexes, user and
+// group ids, cgroups and times as plain decimals (`ip protocol 6`, `iif 2`)
+// like `nft -nnn`. It takes precedence over WithServiceNames.
+func WithNumeric() Option {
+	return func(o *Options) { o.numeric = true }
+}
+
+// WithStrict makes rendering fail on expressions which can not be decoded,
+// by default they are rendered as opaque `<expr name=... data=0x...>` items.
+func WithStrict() Option {
+	return func(o *Options) { o.strict = true }
+}
+
+// WithLenient renders rules with expressions which can not be decoded instead
+// of failing, the expressions are rendered as opaque items. report receives
+// the error of every such rule, it may be nil.
+func WithLenient(report func(error)) Option {
+	return func(o *Options) { o.lenient, o.report = true, report }
+}
+
+// Lenient returns the report function of WithLenient and whether the option
+// is set.
+func (o Options) Lenient() (report func(error), ok bool) {
+	return o.report, o.lenient
+}
+
+// WithNameDB sets the database used to resolve service and protocol names.
+// pr.SystemNames() is used when the option is not provided.
+func WithNameDB(db *pr.NameDB) Option {
+	return func(o *Options) { o.names = db }
+}
+
+// WithResolver sets the resolver of interface indexes, users, groups, cgroups
+// and of the time zone. resolver.System() is used when the option is not
+// provided.
+func WithResolver(r resolver.Resolver) Option {
+	return func(o *Options) { o.resolver = r }
+}
+
+// WithSets sets the resolver of the sets referenced by lookups, maps and
+// dynsets. By default the sets are fetched from the kernel over a new
+// connection once per rendered rule.
+func WithSets(r resolver.SetResolver) Option {
+	return func(o *Options) { o.sets = r }
+}
+
+// NewOptions applies opts over the default settings.
+func NewOptions(opts ...Option) Options {
+	var o Options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// FormatValue formats the value of a symbolic datatype (inet_service,
+// inet_proto, iface_index, uid, gid, cgroupsv2, time, hour, day). It returns
+// false for other datatypes so the caller can use its own formatter.
+func (o Options) FormatValue(typ nft.SetDatatype, b []byte) (string, bool) {
+	switch typ {
+	case nft.TypeInetService:
+		return o.formatService("", b), true
+	case nft.TypeInetProto:
+		return o.formatProtocol(b), true
+	}
+	if v, ok := o.resolveValue(typ, b); ok {
+		if name, ok := v.(string); ok {
+			return name, true
+		}
+		return fmt.Sprint(v), true
+	}
+	return "", false
+}
+
+// resolveValue converts the host order value of a resolved datatype into its
+// name or into a number when it is unknown or numeric output is requested.
+// Times, hours and days are quoted like nft prints them.
+func (o Options) resolveValue(typ nft.SetDatatype, b []byte) (any, bool) {
+	var (
+		v    = rb.RawBytes(b).LittleEndian().Uint64()
+		name string
+		ok   bool
+	)
+	switch typ {
+	case nft.TypeIFIndex:
+		if !o.numeric {
+			name, ok = o.lookup().IfaceName(uint32(v)) //nolint:gosec
+		}
+	case nft.TypeUID:
+		if !o.numeric {
+			name, ok = o.lookup().UserName(uint32(v)) //nolint:gosec
+		}
+	case nft.TypeGID:
+		if !o.numeric {
+			name, ok = o.lookup().GroupName(uint32(v)) //nolint:gosec
+		}
+	case nft.TypeCGroupV2:
+		if !o.numeric {
+			if name, ok = o.lookup().CgroupPath(v); ok {
+				name = fmt.Sprintf("%q", name)
+			}
+		}
+	case nft.TypeTimeDate:
+		if !o.numeric {
+			t := time.Unix(0, int64(v)).In(o.lookup().Location()) //nolint:gosec
+			name, ok = fmt.Sprintf("%q", t.Format(time.DateTime)), true
+		}
+	case nft.TypeTimeHour:
+		if !o.numeric {
+			name, ok = fmt.Sprintf("%q", o.formatHour(v)), true
+		}
+	case nft.TypeTimeDay:
+		if !o.numeric && v < 7 { //nolint:mnd
+			name, ok = fmt.Sprintf("%q", time.Weekday(v)), true
+		}
+	default:
+		return nil, false
+	}
+	if ok {
+		return name, true
+	}
+	return v, true
+}
+
+// formatHour formats the seconds since midnight UTC in the local time zone
+// like `17:00` or `17:00:30`.
+func (o Options) formatHour(secs uint64) string {
+	t := time.Unix(int64(secs), 0).In(o.lookup().Location()) //nolint:gosec
+	if t.Second() != 0 {
+		return t.Format(time.TimeOnly)
+	}
+	return t.Format("15:04")
+}
+
+func (o Options) formatService(proto string, b []byte) string {
+	if o.numeric || !o.services {
+		return rb.BytesToDecimalString(b)
+	}
+	return o.nameDB().FormatService(proto, b)
+}
+
+func (o Options) formatProtocol(b []byte) string {
+	if o.numeric {
+		return rb.BytesToDecimalString(b)
+	}
+	return o.nameDB().FormatProtocol(b)
+}
+
+// formatField formats the value of a protocol header field according to its
+// datatype, proto is the name of the header the field belongs to.
+func (o Options) formatField(proto string, desc pr.ProtoHdrDesc, b []byte) string {
+	switch desc.Datatype {
+	case nft.TypeInetService:
+		return o.formatService(proto, b)
+	case nft.TypeInetProto:
+		return o.formatProtocol(b)
+	}
+	return desc.Desc(b)
+}
+
+// jsonField converts the value of a protocol header field into its JSON form:
+// ports are numbers like in `nft -j`, protocols are names unless numeric
+// output is requested.
+func (o Options) jsonField(desc pr.ProtoHdrDesc, b []byte) any {
+	switch desc.Datatype {
+	case nft.TypeInetService:
+		return rb.RawBytes(b).Uint64()
+	case nft.TypeInetProto:
+		return typedJSON(o.formatProtocol(b))
+	}
+	return typedJSON(desc.Desc(b))
+}
+
+// jsonValue converts the value of a resolved datatype into its JSON form,
+// names are strings and unresolved values are numbers.
+func (o Options) jsonValue(typ nft.SetDatatype, b []byte) (any, bool) {
+	v, ok := o.resolveValue(typ, b)
+	if name, isName := v.(string); isName {
+		if s, err := strconv.Unquote(name); err == nil {
+			return s, true
+		}
+	}
+	return v, ok
+}
+
+// value types the value of a resolved datatype like FormatValue and
+// jsonValue print it.
+func (o Options) value(typ nft.SetDatatype, b []byte) (nftast.Expr, bool) {
+	text, ok := o.FormatValue(typ, b)
+	if !ok {
+		return nil, false
+	}
+	v, _ := o.jsonValue(typ, b)
+	if v == nil {
+		return typedValue(text), true
+	}
+	return constant(v, text), true
+}
+
+// field types the value of a protocol header field like formatField and
+// jsonField print it.
+func (o Options) field(proto string, desc pr.ProtoHdrDesc, b []byte) nftast.Expr {
+	if desc.Datatype == nft.TypeTCPFlag && isZero(b) {
+		// no flags are printed as a number like `tcp flags & (syn | ack) == 0x0`
+		return hexValue(0)
+	}
+	return constant(o.jsonField(desc, b), o.formatField(proto, desc, b))
+}
+
+func (o Options) lookup() resolver.Resolver {
+	if o.resolver != nil {
+		return o.resolver
+	}
+	return resolver.System()
+}
+
+func (o Options) nameDB() *pr.NameDB {
+	if o.names != nil {
+		return o.names
+	}
+	return pr.SystemNames()
+}
@@ -0,0 +1,78 @@
+package encoders
+
+import (
+	"fmt"
+	"strings"
+
+	rb "github.com/Morwran/nft-go/internal/bytes"
+	"github.com/Morwran/nft-go/pkg/nftast"
+	"github.com/Morwran/nft-go/pkg/nftexpr"
+
+	"github.com/google/nftables/expr"
+	"github.com/pkg/errors"
+)
+
+func init() {
+	register(&nftexpr.Osf{}, func(e expr.Any) encoder {
+		return &osfEncoder{osf: e.(*nftexpr.Osf)}
+	})
+}
+
+type (
+	osfEncoder struct {
+		osf *nftexpr.Osf
+	}
+
+	osfArgs struct {
+		Key string `json:"key"`
+		TTL string `json:"ttl,omitempty"`
+	}
+)
+
+func (b *osfEncoder) Encode(ctx *ctx) (nftast.Stmt, error) {
+	osf := b.osf
+	if osf.Register == 0 {
+		return nil, errors.Errorf("%T expression has invalid destination register %d", osf, osf.Register)
+	}
+	ctx.reg.Set(regID(osf.Register), RegValue{
+		Node: &nftast.Other{Name: "osf", Args: &osfArgs{
+			Key: b.key(),
+			TTL: OsfTTL(osf.TTL).String(),
+		}},
+		Expr: osf,
+		Value: func(b []byte) nftast.Expr {
+			s := rb.BytesToString(b)
+			return nftast.Value{V: s, Text: fmt.Sprintf("%q", s)}
+		},
+	})
+	return nil, nil
+}
+
+func (a *osfArgs) String() string {
+	sb := strings.Builder{}
+	sb.WriteString("osf")
+	if a.TTL != "" {
+		sb.WriteString(fmt.Sprintf(" ttl %s", a.TTL))
+	}
+	sb.WriteString(" " + a.Key)
+	return sb.String()
+}
+
+func (b *osfEncoder) key() string {
+	if b.osf.Flags&nftexpr.NFT_OSF_F_VERSION != 0 {
+		return "version"
+	}
+	return "name"
+}
+
+type OsfTTL nftexpr.OsfTTL
+
+func (t OsfTTL) String() string {
+	switch nftexpr.OsfTTL(t) {
+	case nftexpr.NF_OSF_TTL_LESS:
+		return "loose"
+	case nftexpr.NF_OSF_TTL_NOCHECK:
+		return "skip"
+	}
+	return ""
+}
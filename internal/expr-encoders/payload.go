@@ -1,12 +1,11 @@
 package encoders
 
 import (
-	"encoding/json"
-	"fmt"
-
 	"github.com/Morwran/nft-go/internal/bytes"
+	"github.com/Morwran/nft-go/pkg/nftast"
 	pr "github.com/Morwran/nft-go/pkg/protocols"
 
+	nft "github.com/google/nftables"
 	"github.com/google/nftables/expr"
 	"github.com/pkg/errors"
 	"golang.org/x/sys/unix"
@@ -18,156 +17,204 @@ func init() {
 	})
 }
 
-// payloadBuilder converts nftables payload expressions into an internal IR and
-// JSON representation.
+// payloadEncoder decodes payload expressions into the header fields they load
+// or set.
 type payloadEncoder struct {
 	payload *expr.Payload
 }
 
-// EncodeIR returns the compiler IR representation.  When the expression writes
-// to a register we only update the register map and emit no IR node.
-func (b *payloadEncoder) EncodeIR(ctx *ctx) (irNode, error) {
-	key := b.buildKey(ctx)
+// Encode loads the field into the register or returns the statement setting
+// it when the expression writes to the packet.
+func (b *payloadEncoder) Encode(ctx *ctx) (nftast.Stmt, error) {
+	hdr := *ctx.hdr
+	key := b.key(ctx)
+	value := func(data []byte) nftast.Expr { return b.value(ctx, data) }
 
 	if b.payload.DestRegister != 0 {
-		ctx.reg.Set(regID(b.payload.DestRegister), regVal{HumanExpr: key, Expr: b.payload})
-		return nil, ErrNoIR
+		ctx.reg.Set(regID(b.payload.DestRegister), RegValue{
+			Node:  key,
+			Expr:  b.payload,
+			Hdr:   hdr,
+			Value: value,
+		})
+		return nil, nil
 	}
 
 	srcReg, ok := ctx.reg.Get(regID(b.payload.SourceRegister))
 	if !ok {
 		return nil, errors.Errorf("%T statement has no expression", b.payload)
 	}
-	return simpleIR(fmt.Sprintf("%s set %s", key, srcReg.HumanExpr)), nil
+	return mangleStmt(key, srcReg.typed(value)), nil
 }
 
-// EncodeJSON produces a JSON serialization compatible with nft‑go’s CLI tools.
-func (b *payloadEncoder) EncodeJSON(ctx *ctx) ([]byte, error) {
-	key := b.jsonKey()
-
-	if b.payload.DestRegister != 0 {
-		ctx.reg.Set(regID(b.payload.DestRegister), regVal{Data: key, Expr: b.payload})
-		return nil, ErrNoJSON
-	}
-
-	srcReg, ok := ctx.reg.Get(regID(b.payload.SourceRegister))
-	if !ok || srcReg.Data == nil {
-		return nil, errors.Errorf("%T statement has no expression", b.payload)
+// key returns the loaded field like `tcp dport`. If the offset cannot be
+// resolved it falls back to the raw @base,offset,len notation understood by
+// nft.
+func (b *payloadEncoder) key(ctx *ctx) nftast.Payload {
+	offset := pr.HeaderOffset(b.payload.Offset).BytesToBits()
+	if proto, field, ok := b.resolveField(offset, ctx); ok {
+		return nftast.Payload{Protocol: proto, Field: field}
 	}
+	return b.rawKey()
+}
 
-	mangle := map[string]any{
-		"mangle": struct {
-			Key any `json:"key"`
-			Val any `json:"value"`
-		}{Key: key, Val: srcReg.Data},
+func (b *payloadEncoder) rawKey() nftast.Payload {
+	offset, length := b.rawBits()
+	return nftast.Payload{
+		Base:   PayloadBase(b.payload.Base).String(),
+		Offset: int64(offset),
+		Len:    int64(length),
 	}
-	return json.Marshal(mangle)
 }
 
-// buildKey builds a human‑readable key (e.g. "ip saddr") suitable for IR or
-// debug output.  If the offset cannot be resolved it falls back to the raw
-// @base,offset,len notation understood by nft.
-func (b *payloadEncoder) buildKey(ctx *ctx) string {
-	offset := pr.HeaderOffset(b.payload.Offset).BytesToBits()
-	if hdr, ok := b.resolveHeader(offset, ctx, includeHeaderIfKnown(ctx)); ok {
-		return hdr
-	}
-	return fmt.Sprintf("@%s,%d,%d", PayloadBase(b.payload.Base), b.payload.Offset, b.payload.Len)
+// rawBits returns the offset and the length of the loaded data in bits like
+// the raw @base,offset,len notation counts them.
+func (b *payloadEncoder) rawBits() (offset, length uint32) {
+	const bitsPerByte = uint32(pr.BitsPerByte)
+	return b.payload.Offset * bitsPerByte, b.payload.Len * bitsPerByte
 }
 
-// buildPlWithMask is required by other packages to format a key that contains
-// a bit‑mask.  Implementation mirrors buildKey() but applies the supplied mask
-// and *always* prefixes the header name.
-func (b *payloadEncoder) buildPlWithMask(ctx *ctx, mask []byte) string {
+// maskedKey resolves the field the mask selects like `ip version`. The
+// field is resolved within hdr, the header context the payload was loaded in:
+// the unmasked offset may have resolved to another header (th sport for gre
+// flags).
+func (b *payloadEncoder) maskedKey(ctx *ctx, mask []byte, hdr pr.ProtoDescPtr) (nftast.Payload, bool) {
 	maskedOffset := pr.HeaderOffset(b.payload.Offset).
 		BytesToBits().
 		WithBitMask(uint32(bytes.RawBytes(mask).Uint64())) //nolint:gosec
 
-	// Keep caller’s header context intact
 	bak := *ctx.hdr
-	defer func() { *ctx.hdr = bak }()
-
-	if hdr, ok := b.resolveHeader(maskedOffset, ctx, alwaysIncludeHeader()); ok {
-		return hdr
+	if hdr != nil {
+		*ctx.hdr = hdr
 	}
-
-	return fmt.Sprintf("@%s,%d,%d/%#x", // fallback
-		PayloadBase(b.payload.Base),
-		b.payload.Offset, b.payload.Len,
-		bytes.RawBytes(mask).Uint64(),
-	)
+	if proto, field, ok := b.resolveField(maskedOffset, ctx); ok {
+		return nftast.Payload{Protocol: proto, Field: field}, true
+	}
+	// Keep caller’s header context intact
+	*ctx.hdr = bak
+	return nftast.Payload{}, false
 }
 
-// jsonKey returns the canonical JSON representation used when serializing
-// rulesets.  Stable on purpose – human‑readable descriptions are *not* embedded
-// to avoid churn in generated JSON.
-func (b *payloadEncoder) jsonKey() any {
-	return map[string]any{
-		"payload": struct {
-			Base   string `json:"base"`
-			Offset uint32 `json:"offset"`
-			Len    uint32 `json:"len"`
-		}{
-			Base:   PayloadBase(b.payload.Base).String(),
-			Offset: b.payload.Offset,
-			Len:    b.payload.Len,
-		},
+// addrField resolves the key of the expression when it loads an address field
+// (ip saddr, ip6 daddr).
+func (b *payloadEncoder) addrField(ctx *ctx) (nftast.Payload, pr.ProtoHdrDesc, bool) {
+	offset := pr.HeaderOffset(b.payload.Offset).BytesToBits()
+	proto, name, ok := b.resolveField(offset, ctx)
+	if !ok {
+		return nftast.Payload{}, pr.ProtoHdrDesc{}, false
 	}
+	field := (*ctx.hdr).Offsets[offset]
+	switch field.Datatype {
+	case nft.TypeIPAddr, nft.TypeIP6Addr:
+		return nftast.Payload{Protocol: proto, Field: name}, field, true
+	}
+	return nftast.Payload{}, pr.ProtoHdrDesc{}, false
 }
 
-// resolveHeader translates a byte offset into a human‑readable description
-// based on the current protocol context. The returned string may or may not
-// include the header prefix; this is controlled via the includeHeader flag.
-func (b *payloadEncoder) resolveHeader(offset pr.HeaderOffset, ctx *ctx, includeHeader includeHeaderFlag) (string, bool) {
+// resolveField resolves the protocol and field names (e.g. "tcp", "dport") of
+// the offset based on the current protocol context.
+func (b *payloadEncoder) resolveField(offset pr.HeaderOffset, ctx *ctx) (proto, field string, ok bool) {
 	// 1. Prefer the header we are already inside
-	if hdr := *ctx.hdr; hdr != nil {
+	if hdr := *ctx.hdr; hdr != nil && hdr.Base == b.payload.Base {
 		if desc, ok := hdr.Offsets[offset]; ok {
 			hdr.CurrentOffset = offset
-			if includeHeader == addHeaderName ||
-				hdr.Id == unix.IPPROTO_IP || hdr.Id == unix.IPPROTO_NONE {
-				return fmt.Sprintf("%s %s", hdr.Name, desc.Name), true
-			}
-			return desc.Name, true
+			return hdr.Name, desc.Name, true
 		}
 	}
 
 	// 2. Fall back to static protocol tables
-	proto, ok := pr.Protocols[b.payload.Base]
-	if !ok {
-		return "", false
+	header, ok := defaultHeader(ctx, b.payload.Base)
+	desc, found := header.Offsets[offset]
+	if !ok || !found {
+		// 3. Then to the headers registered by applications
+		if header, ok = pr.LookupRegistered(b.payload.Base, offset); !ok {
+			return "", "", false
+		}
+		desc = header.Offsets[offset]
 	}
+	*ctx.hdr = &header // update context for following expressions
+	header.CurrentOffset = offset
+	return header.Name, desc.Name, true
+}
 
-	protoKey := unix.IPPROTO_IP
-	if b.payload.Base == expr.PayloadBaseTransportHeader {
+// defaultHeader returns the header the payload base refers to when no protocol
+// match selected it: ip (ip6 in ip6 tables) for the network header and the
+// generic th for the transport one.
+func defaultHeader(ctx *ctx, base expr.PayloadBase) (pr.ProtoDesc, bool) {
+	protoKey := pr.ProtoType(unix.IPPROTO_IP)
+	switch {
+	case base == expr.PayloadBaseTransportHeader:
 		protoKey = unix.IPPROTO_NONE
+	case ctx.rule != nil && ctx.rule.Table != nil && ctx.rule.Table.Family == nft.TableFamilyIPv6:
+		protoKey = unix.IPPROTO_IPV6
 	}
+	return pr.LookupProtocol(base, protoKey)
+}
 
-	header := proto[pr.ProtoType(protoKey)] //nolint:gosec
-	if desc, ok := header.Offsets[offset]; ok {
-		if ctx.hdr != nil {
-			*ctx.hdr = &header // update context for following expressions
-		}
-		header.CurrentOffset = offset
-		return fmt.Sprintf("%s %s", header.Name, desc.Name), true
+// selectUpperHeader makes the protocol matched by `ip protocol sctp` or
+// `ip6 nexthdr gre` the current header for the following payload expressions.
+func (b *payloadEncoder) selectUpperHeader(ctx *ctx, cmp *expr.Cmp) {
+	hdr := *ctx.hdr
+	offset := pr.HeaderOffset(b.payload.Offset).BytesToBits()
+	if hdr == nil || hdr.Base != b.payload.Base || cmp.Op != expr.CmpOpEq || !isUpperProtoField(hdr, offset) {
+		return
+	}
+	upper := pr.ProtoType(bytes.RawBytes(cmp.Data).Uint64()) //nolint:gosec
+	if proto, ok := pr.LookupProtocol(expr.PayloadBaseTransportHeader, upper); ok {
+		*ctx.hdr = &proto
+		ctx.deps.expect(proto)
 	}
-	return "", false
 }
 
-func (b *payloadEncoder) buildLRFromCmpData(ctx *ctx, cmp *expr.Cmp) (left, right string) {
-	offset := pr.HeaderOffset(b.payload.Offset).BytesToBits()
-	left, _ = b.resolveHeader(offset, ctx, includeHeaderIfKnown(ctx))
+// serviceProto returns the transport protocol the ports of the header belong
+// to, the generic `th` header matches any of them.
+func serviceProto(hdr *pr.ProtoDesc) string {
+	if hdr.Id == unix.IPPROTO_NONE {
+		return ""
+	}
+	return hdr.Name
+}
 
-	// pretty‑print RHS when we have metadata
-	if *ctx.hdr != nil {
-		if desc, ok := (*ctx.hdr).Offsets[offset]; ok {
-			right = desc.Desc(cmp.Data)
-			return
+// value types the value the field loaded by the expression is compared with
+// or set to.
+func (b *payloadEncoder) value(ctx *ctx, data []byte) nftast.Expr {
+	offset := pr.HeaderOffset(b.payload.Offset).BytesToBits()
+	if hdr := *ctx.hdr; hdr != nil && hdr.Base == b.payload.Base {
+		if desc, ok := hdr.Offsets[offset]; ok {
+			if size, ok := addrSize(desc.Datatype); ok && len(data) < size {
+				// nft loads only the bytes of the prefixes of whole bytes
+				return prefixValue(data, len(data)*8, desc.Datatype) //nolint:mnd
+			}
+			return ctx.opts.field(serviceProto(hdr), desc, data)
 		}
 	}
+	if isAddrPayload(b.payload) {
+		return addrValue(data)
+	}
+	return bytesValue(data)
+}
 
-	// fallback to raw bytes
-	right = bytes.RawBytes(cmp.Data).String()
-	return
+// isFlags reports whether the field loaded by the expression holds flags like
+// `tcp flags`.
+func (b *payloadEncoder) isFlags(ctx *ctx) bool {
+	hdr := *ctx.hdr
+	if hdr == nil || hdr.Base != b.payload.Base {
+		return false
+	}
+	desc, ok := hdr.Offsets[pr.HeaderOffset(b.payload.Offset).BytesToBits()]
+	return ok && desc.Datatype == nft.TypeTCPFlag
+}
+
+// isUpperProtoField reports whether the field at offset carries the number of
+// the next protocol in the chain (ip protocol, ip6 nexthdr).
+func isUpperProtoField(hdr *pr.ProtoDesc, offset pr.HeaderOffset) bool {
+	switch hdr.Id {
+	case unix.IPPROTO_IP:
+		return offset == pr.IPHDR_PROTOCOL
+	case unix.IPPROTO_IPV6:
+		return offset == pr.IP6HDR_NEXTHDR
+	}
+	return false
 }
 
 type (
@@ -198,22 +245,3 @@ func (p PayloadBase) String() string {
 		return ""
 	}
 }
-
-// includeHeaderFlag signals whether resolveHeader should prefix the header name.
-// Historically buildPlFromHdrOffset() omitted the prefix *inside* a header while
-// buildPlWithMask() always included it.
-type includeHeaderFlag bool
-
-const (
-	addHeaderName       includeHeaderFlag = true  // always include header prefix
-	omitHeaderIfCurrent includeHeaderFlag = false // drop prefix if we’re already inside
-)
-
-func includeHeaderIfKnown(ctx *ctx) includeHeaderFlag {
-	if *ctx.hdr != nil {
-		return omitHeaderIfCurrent
-	}
-	return addHeaderName
-}
-
-func alwaysIncludeHeader() includeHeaderFlag { return addHeaderName }
@@ -1,11 +1,12 @@
 package encoders
 
 import (
-	"encoding/json"
 	"fmt"
 	"strconv"
 	"strings"
 
+	"github.com/Morwran/nft-go/pkg/nftast"
+
 	"github.com/google/nftables/expr"
 )
 
@@ -19,51 +20,42 @@ type (
 	queueEncoder struct {
 		que *expr.Queue
 	}
-	queueIR struct {
-		*expr.Queue
+	queueArgs struct {
+		Num   any `json:"num,omitempty"`
+		Flags any `json:"flags,omitempty"`
+
+		num, total uint16
+		flags      []string
 	}
 
 	QueueFlag expr.QueueFlag
 )
 
-func (b *queueEncoder) EncodeIR(ctx *ctx) (irNode, error) {
-	return &queueIR{b.que}, nil
-}
-
-func (b *queueEncoder) EncodeJSON(ctx *ctx) ([]byte, error) {
-	var flag any
+func (b *queueEncoder) Encode(ctx *ctx) (nftast.Stmt, error) {
 	q := b.que
-	flags := QueueFlag(q.Flag).List()
-	if len(flags) > 1 {
-		flag = flags
-	} else if len(flags) == 1 {
-		flag = flags[0]
+	args := &queueArgs{num: q.Num, total: q.Total, flags: QueueFlag(q.Flag).List()}
+	if len(args.flags) > 1 {
+		args.Flags = args.flags
+	} else if len(args.flags) == 1 {
+		args.Flags = args.flags[0]
 	}
-	que := map[string]interface{}{
-		"queue": struct {
-			Num   uint16 `json:"num,omitempty"`
-			Flags any    `json:"flags,omitempty"`
-		}{
-			Num:   q.Num,
-			Flags: flag,
-		},
+	if q.Total > 1 {
+		args.Num = map[string]any{"range": [2]uint16{q.Num, q.Num + q.Total - 1}}
+	} else if q.Num != 0 {
+		args.Num = q.Num
 	}
-
-	return json.Marshal(que)
+	return &nftast.Statement{Name: "queue", Args: args}, nil
 }
 
-func (q *queueIR) Format() string {
+func (q *queueArgs) String() string {
 	sb := strings.Builder{}
-	total := q.Total
-	exp := strconv.Itoa(int(q.Num))
-	if total > 1 {
-		total += q.Num - 1
-		exp = fmt.Sprintf("%s-%d", exp, total)
+	exp := strconv.Itoa(int(q.num))
+	if q.total > 1 {
+		exp = fmt.Sprintf("%s-%d", exp, q.num+q.total-1)
 	}
 	sb.WriteString("queue")
-	flags := QueueFlag(q.Flag).List()
-	if len(flags) > 0 {
-		sb.WriteString(fmt.Sprintf(" flags %s", strings.Join(QueueFlag(q.Flag).List(), ",")))
+	if len(q.flags) > 0 {
+		sb.WriteString(fmt.Sprintf(" flags %s", strings.Join(q.flags, ",")))
 	}
 	sb.WriteString(fmt.Sprintf(" to %s", exp))
 	return sb.String()
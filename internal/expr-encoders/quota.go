@@ -1,9 +1,10 @@
 package encoders
 
 import (
-	"encoding/json"
 	"fmt"
 
+	"github.com/Morwran/nft-go/pkg/nftast"
+
 	"github.com/google/nftables/expr"
 )
 
@@ -13,30 +14,37 @@ func init() {
 	})
 }
 
-type quotaEncoder struct {
-	quota *expr.Quota
-}
+type (
+	quotaEncoder struct {
+		quota *expr.Quota
+	}
 
-func (b *quotaEncoder) EncodeIR(ctx *ctx) (irNode, error) {
-	val, u := b.Rate()
-	return simpleIR(fmt.Sprintf("quota %s%d %s",
-		map[bool]string{true: "over ", false: ""}[b.quota.Over],
-		val, u)), nil
-}
+	quotaArgs struct {
+		Val      uint64 `json:"val"`
+		Unit     string `json:"val_unit"`
+		Used     uint64 `json:"used,omitempty"`
+		UsedUnit string `json:"used_unit,omitempty"`
+		Inv      bool   `json:"inv,omitempty"`
+	}
+)
 
-func (b *quotaEncoder) EncodeJSON(ctx *ctx) ([]byte, error) {
-	val, u := b.Rate()
-	quota := map[string]interface{}{
-		"quota": struct {
-			Val  uint64 `json:"val"`
-			Unit string `json:"val_unit"`
-		}{
-			Val:  val,
-			Unit: u,
-		},
+func (b *quotaEncoder) Encode(ctx *ctx) (nftast.Stmt, error) {
+	args := &quotaArgs{Inv: b.quota.Over}
+	args.Val, args.Unit = b.Rate()
+	if b.quota.Consumed != 0 {
+		args.Used, args.UsedUnit = getRate(b.quota.Consumed)
 	}
+	return &nftast.Statement{Name: "quota", Args: args}, nil
+}
 
-	return json.Marshal(quota)
+func (a *quotaArgs) String() string {
+	s := fmt.Sprintf("quota %s%d %s",
+		map[bool]string{true: "over ", false: ""}[a.Inv],
+		a.Val, a.Unit)
+	if a.Used != 0 {
+		s += fmt.Sprintf(" used %d %s", a.Used, a.UsedUnit)
+	}
+	return s
 }
 
 func (b *quotaEncoder) Rate() (val uint64, unit string) {
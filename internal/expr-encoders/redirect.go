@@ -1,6 +1,8 @@
 package encoders
 
 import (
+	"github.com/Morwran/nft-go/pkg/nftast"
+
 	"github.com/google/nftables/expr"
 )
 
@@ -14,22 +16,7 @@ type redirectEncoder struct {
 	redir *expr.Redir
 }
 
-func (b *redirectEncoder) EncodeIR(ctx *ctx) (irNode, error) {
-	nb := natEncoder{
-		nat: &expr.NAT{
-			Type:        NATTypeRedir,
-			Persistent:  (b.redir.Flags & expr.NF_NAT_RANGE_PERSISTENT) != 0,
-			Random:      (b.redir.Flags & expr.NF_NAT_RANGE_PROTO_RANDOM) != 0,
-			FullyRandom: (b.redir.Flags & expr.NF_NAT_RANGE_PROTO_RANDOM_FULLY) != 0,
-			RegProtoMin: b.redir.RegisterProtoMin,
-			RegProtoMax: b.redir.RegisterProtoMax,
-		},
-	}
-
-	return nb.EncodeIR(ctx)
-}
-
-func (b *redirectEncoder) EncodeJSON(ctx *ctx) ([]byte, error) {
+func (b *redirectEncoder) Encode(ctx *ctx) (nftast.Stmt, error) {
 	nb := natEncoder{
 		nat: &expr.NAT{
 			Type:        NATTypeRedir,
@@ -41,5 +28,5 @@ func (b *redirectEncoder) EncodeJSON(ctx *ctx) ([]byte, error) {
 		},
 	}
 
-	return nb.EncodeJSON(ctx)
+	return nb.Encode(ctx)
 }
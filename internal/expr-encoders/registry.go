@@ -2,49 +2,131 @@ package encoders
 
 import (
 	"fmt"
+	"maps"
+	"slices"
+	"sync"
 
+	"github.com/Morwran/nft-go/pkg/nftast"
 	pr "github.com/Morwran/nft-go/pkg/protocols"
+	"github.com/Morwran/nft-go/pkg/resolver"
 
 	nft "github.com/google/nftables"
 	"github.com/google/nftables/expr"
 )
 
-var registry = map[string]encoderFn{}
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]encoderFn{}
+)
 
 func register(e expr.Any, fn encoderFn) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
 	registry[fmt.Sprintf("%T", e)] = fn
 }
 
+// replace registers fn and returns the function restoring the registry entry
+// of the type as it was before.
+func replace(e expr.Any, fn encoderFn) (restore func()) {
+	key := fmt.Sprintf("%T", e)
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	prev, ok := registry[key]
+	registry[key] = fn
+	return func() {
+		registryMu.Lock()
+		defer registryMu.Unlock()
+		if ok {
+			registry[key] = prev
+		} else {
+			delete(registry, key)
+		}
+	}
+}
+
+func encoderOf(e expr.Any) (encoderFn, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	fn, ok := registry[fmt.Sprintf("%T", e)]
+	return fn, ok
+}
+
+// RegisteredTypes returns the sorted types of the expressions which have an
+// encoder like `*expr.Counter`.
+func RegisteredTypes() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return slices.Sorted(maps.Keys(registry))
+}
+
 type (
-	regID  uint32
-	regVal struct {
+	regID uint32
+	// RegValue is the content of a register: the expression that loaded it
+	// and the value the following statements are built from.
+	RegValue struct {
+		// HumanExpr is the text form of the value like `ip saddr`, it is
+		// printed from Node when the value is typed.
 		HumanExpr string
 		Len       int
-		Expr      expr.Any
-		Data      any
-		Op        string
+		// Expr is the expression that loaded the register
+		Expr expr.Any
+		// Data is the JSON form of the value like {"payload":{...}}, it is
+		// printed from Node when the value is typed.
+		Data any
+		Op   string
+		// Desc formats right hand side values compared with the register
+		// content when the expression that loaded it knows their type.
+		Desc func(b []byte) string
+		// JSONValue converts right hand side values compared with the
+		// register content into their JSON form (prefixes, host order marks).
+		JSONValue func(b []byte) any
+		// HostOrder is set when the register holds a value converted to host
+		// byte order (ntoh, shifts) whatever the expression that loaded it.
+		HostOrder bool
+		// Hdr is the header context a payload was loaded in, masks of the
+		// register resolve bitfields of it (gre flags, dccp type).
+		Hdr pr.ProtoDescPtr
+		// Node is the typed value like nftast.Payload{Protocol: "ip",
+		// Field: "saddr"}, the built-in encoders set it instead of HumanExpr
+		// and Data.
+		Node nftast.Expr
+		// Value types right hand side values compared with the register
+		// content like Desc and JSONValue do for HumanExpr and Data.
+		Value func(b []byte) nftast.Expr
 	}
 	regHolder struct {
-		cache map[regID]regVal
+		cache map[regID]RegValue
 	}
 )
 
-func (r *regHolder) Get(id regID) (regVal, bool) { v, ok := r.cache[id]; return v, ok }
+// node returns the typed value of the register, the values loaded by
+// encoders registered from outside of the package keep their text and JSON
+// forms.
+func (v RegValue) node() nftast.Expr {
+	if v.Node != nil {
+		return v.Node
+	}
+	return &nftast.Other{Args: &extValue{text: v.HumanExpr, json: v.Data}}
+}
+
+func (r *regHolder) Get(id regID) (RegValue, bool) { v, ok := r.cache[id]; return v, ok }
 
-func (r *regHolder) Set(id regID, v regVal) {
+func (r *regHolder) Set(id regID, v RegValue) {
 	r.ensureInit()
 	r.cache[id] = v
 }
 
 func (r *regHolder) ensureInit() {
 	if r.cache == nil {
-		r.cache = make(map[regID]regVal)
+		r.cache = make(map[regID]RegValue)
 	}
 }
 
 type ctx struct {
 	reg  regHolder
 	hdr  *pr.ProtoDescPtr
-	sets setCache
+	sets resolver.SetResolver
 	rule *nft.Rule
+	opts Options
+	deps depTracker
 }
@@ -1,10 +1,11 @@
 package encoders
 
 import (
-	"encoding/json"
 	"fmt"
 	"strings"
 
+	"github.com/Morwran/nft-go/pkg/nftast"
+
 	"github.com/google/nftables/expr"
 	"golang.org/x/sys/unix"
 )
@@ -19,32 +20,45 @@ type (
 	rejectEncoder struct {
 		reject *expr.Reject
 	}
-	rejectIR struct {
-		typeStr string
-		code    uint8
+	rejectArgs struct {
+		Type string `json:"type,omitempty"`
+		Code any    `json:"expr,omitempty"`
+
+		code uint8
 	}
 )
 
-func (b *rejectEncoder) EncodeIR(ctx *ctx) (irNode, error) {
-	return &rejectIR{typeStr: b.TypeToString(), code: b.reject.Code}, nil
+func (b *rejectEncoder) Encode(ctx *ctx) (nftast.Stmt, error) {
+	typ := b.TypeToString()
+	if typ == "" {
+		return &nftast.Statement{Name: "reject", Args: (*rejectArgs)(nil)}, nil
+	}
+	return &nftast.Statement{Name: "reject", Args: &rejectArgs{
+		Type: typ,
+		Code: b.codeJSON(),
+		code: b.reject.Code,
+	}}, nil
 }
 
-func (b *rejectEncoder) EncodeJSON(ctx *ctx) ([]byte, error) {
-	if b.TypeToString() == "" && b.reject.Code == 0 {
-		return []byte(`{"reject":null}`), nil
+// codeJSON returns the code of the reject, icmpx codes are named like in
+// `nft -j` output.
+func (b *rejectEncoder) codeJSON() any {
+	code := b.reject.Code
+	if b.reject.Type == unix.NFT_REJECT_ICMPX_UNREACH && int(code) < len(icmpxCodes) {
+		return icmpxCodes[code]
 	}
-
-	reject := map[string]interface{}{
-		"reject": struct {
-			Type string `json:"type,omitempty"`
-			Code uint8  `json:"expr,omitempty"`
-		}{
-			Type: b.TypeToString(),
-			Code: b.reject.Code,
-		},
+	if code == 0 {
+		return nil
 	}
+	return code
+}
 
-	return json.Marshal(reject)
+// icmpxCodes are the names of the NFT_REJECT_ICMPX_* codes.
+var icmpxCodes = [...]string{
+	unix.NFT_REJECT_ICMPX_NO_ROUTE:         "no-route",
+	unix.NFT_REJECT_ICMPX_PORT_UNREACH:     "port-unreachable",
+	unix.NFT_REJECT_ICMPX_HOST_UNREACH:     "host-unreachable",
+	unix.NFT_REJECT_ICMPX_ADMIN_PROHIBITED: "admin-prohibited",
 }
 
 func (b *rejectEncoder) TypeToString() string {
@@ -67,11 +81,11 @@ func (b *rejectEncoder) TypeToString() string {
 	return ""
 }
 
-func (r *rejectIR) Format() string {
+func (r *rejectArgs) String() string {
 	sb := strings.Builder{}
 	sb.WriteString("reject")
-	if typ := r.typeStr; typ != "" {
-		sb.WriteString(fmt.Sprintf(" with %s %d", typ, r.code))
+	if r != nil {
+		sb.WriteString(fmt.Sprintf(" with %s %d", r.Type, r.code))
 	}
 	return sb.String()
 }
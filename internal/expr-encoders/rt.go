@@ -3,6 +3,9 @@ package encoders
 import (
 	"fmt"
 
+	rb "github.com/Morwran/nft-go/internal/bytes"
+	"github.com/Morwran/nft-go/pkg/nftast"
+
 	"github.com/google/nftables/expr"
 	"github.com/pkg/errors"
 )
@@ -13,40 +16,43 @@ func init() {
 	})
 }
 
-type rtEncoder struct {
-	rt *expr.Rt
-}
+type (
+	rtEncoder struct {
+		rt *expr.Rt
+	}
 
-func (b *rtEncoder) EncodeIR(ctx *ctx) (irNode, error) {
+	rtArgs struct {
+		Key    string `json:"key"`
+		Family string `json:"family,omitempty"`
+	}
+)
+
+func (b *rtEncoder) Encode(ctx *ctx) (nftast.Stmt, error) {
 	rt := b.rt
 	if rt.Register == 0 {
 		return nil, errors.Errorf("%T expression has invalid destination register %d", rt, rt.Register)
 	}
+	key := RtKey(rt.Key)
 	ctx.reg.Set(regID(rt.Register),
-		regVal{
-			HumanExpr: fmt.Sprintf("rt %s %s", RtKey(rt.Key).Family(), RtKey(rt.Key)),
+		RegValue{
+			Node: &nftast.Other{Name: "rt", Args: &rtArgs{
+				Key:    key.String(),
+				Family: key.Family(),
+			}},
+			Expr: rt,
+			Value: func(b []byte) nftast.Expr {
+				return constant(key.JSON(b), key.Desc(b))
+			},
 		},
 	)
-	return nil, ErrNoIR
+	return nil, nil
 }
 
-func (b *rtEncoder) EncodeJSON(ctx *ctx) ([]byte, error) {
-	rt := b.rt
-	rtJson := map[string]interface{}{
-		"rt": struct {
-			Key    string `json:"key"`
-			Family string `json:"family,omitempty"`
-		}{
-			Key:    RtKey(rt.Key).String(),
-			Family: RtKey(rt.Key).Family(),
-		},
-	}
-
-	if rt.Register == 0 {
-		return nil, errors.Errorf("%T expression has invalid destination register %d", rt, rt.Register)
+func (a *rtArgs) String() string {
+	if a.Family != "" {
+		return fmt.Sprintf("rt %s %s", a.Family, a.Key)
 	}
-	ctx.reg.Set(regID(rt.Register), regVal{Data: rtJson})
-	return nil, ErrNoJSON
+	return fmt.Sprintf("rt %s", a.Key)
 }
 
 type RtKey expr.RtKey
@@ -65,6 +71,22 @@ func (r RtKey) String() string {
 	return "unknown"
 }
 
+// Desc formats the values of the key, next hops are addresses and the
+// classid and the mtu are kept in host byte order.
+func (r RtKey) Desc(b []byte) string {
+	if r.Family() != "" {
+		return rb.RawBytes(b).Ip().String()
+	}
+	return rb.LEBytesToIntString(b)
+}
+
+func (r RtKey) JSON(b []byte) any {
+	if r.Family() != "" {
+		return r.Desc(b)
+	}
+	return hostOrderUint(b)
+}
+
 func (r RtKey) Family() string {
 	switch expr.RtKey(r) {
 	case expr.RtNexthop4:
@@ -1,11 +1,9 @@
 package encoders
 
 import (
-	"fmt"
-	"strings"
-
-	"github.com/H-BF/corlib/pkg/dict"
 	rb "github.com/Morwran/nft-go/internal/bytes"
+	"github.com/Morwran/nft-go/pkg/nftast"
+
 	"github.com/google/nftables"
 )
 
@@ -13,36 +11,85 @@ type (
 	setEncoder struct {
 		set setEntry
 	}
-	setIR struct {
+	// setValues types the keys and the data of the set elements
+	setValues struct {
 		setEntry
+		opts  Options
+		proto string
+		// hostOrder is set when the set is looked up by a host order key like
+		// `numgen inc mod 2 map { 0 : 10.0.0.1, 1 : 10.0.0.2 }`
+		hostOrder bool
 	}
 )
 
-func (s *setEncoder) EncodeIR(ctx *ctx) (irNode, error) {
-	return &setIR{setEntry: s.set}, nil
+// node returns the reference to a named set like `@blocked` or the elements
+// of an anonymous one like `{22,80}`.
+func (s *setEncoder) node(ctx *ctx, hostOrder bool) nftast.Expr {
+	if !s.set.Anonymous {
+		return nftast.SetRef{Name: s.set.Name}
+	}
+	v := &setValues{setEntry: s.set, opts: ctx.opts, hostOrder: hostOrder}
+	if ctx.hdr != nil && *ctx.hdr != nil {
+		v.proto = serviceProto(*ctx.hdr)
+	}
+	set := &nftast.Set{Elems: make([]nftast.Expr, 0, len(s.set.elems))}
+	for _, e := range s.set.elems {
+		key := v.key(e.Key)
+		if v.hasData() {
+			key = &nftast.Mapping{Key: key, Data: v.value(v.DataType, e.Val)}
+		}
+		set.Elems = append(set.Elems, key)
+	}
+	return set
 }
 
-func (s *setIR) Format() string {
-	if !s.Anonymous {
-		return fmt.Sprintf("@%s", s.Name)
+// key types the key of an element, integers looked up by host order keys are
+// kept in host byte order.
+func (s *setValues) key(k []byte) nftast.Expr {
+	if s.hostOrder && s.KeyType == nftables.TypeInteger {
+		return nftast.Value{V: rb.RawBytes(k).LittleEndian().Uint64()}
 	}
+	return s.value(s.KeyType, k)
+}
+
+// value types a key or a data value of the given type.
+func (s *setValues) value(typ nftables.SetDatatype, k []byte) nftast.Expr {
+	return constant(s.valueToJSON(typ, k), s.valueToString(typ, k))
+}
 
-	var b strings.Builder
-	b.WriteByte('{')
+// hasData reports whether the elements map keys to data values, verdict maps
+// are printed by their keys only.
+func (s *setValues) hasData() bool {
+	return s.IsMap && s.DataType != nftables.TypeVerdict
+}
 
-	for i, e := range s.elems {
-		b.WriteString(s.keyToString(e.Key))
-		if i < len(s.elems)-1 {
-			b.WriteByte(',')
-		}
+// valueToJSON converts a key or a data value of the given type into JSON.
+func (s *setValues) valueToJSON(typ nftables.SetDatatype, k []byte) any {
+	switch typ {
+	case nftables.TypeInetService:
+		return rb.RawBytes(k).Uint64()
+	case nftables.TypeIPAddr,
+		nftables.TypeIP6Addr,
+		nftables.TypeVerdict,
+		nftables.TypeString,
+		nftables.TypeIFName:
+		return s.valueToString(typ, k)
 	}
-
-	b.WriteByte('}')
-	return b.String()
+	if v, ok := s.opts.jsonValue(typ, k); ok {
+		return v
+	}
+	return typedJSON(s.valueToString(typ, k))
 }
 
-func (s *setIR) keyToString(k []byte) string {
-	switch s.KeyType {
+// valueToString formats a key or a data value of the given type.
+func (s *setValues) valueToString(typ nftables.SetDatatype, k []byte) string {
+	switch typ {
+	case nftables.TypeInetService:
+		return s.opts.formatService(s.proto, k)
+
+	case nftables.TypeInetProto:
+		return s.opts.formatProtocol(k)
+
 	case nftables.TypeVerdict,
 		nftables.TypeString,
 		nftables.TypeIFName:
@@ -56,57 +103,45 @@ func (s *setIR) keyToString(k []byte) string {
 		nftables.TypeLLAddr,
 		nftables.TypeEtherAddr,
 		nftables.TypeTCPFlag,
-		nftables.TypeMark,
-		nftables.TypeUID,
-		nftables.TypeGID:
+		nftables.TypeMark:
 		return rb.RawBytes(k).Text(rb.BaseHex)
 
+	case nftables.TypeIFIndex,
+		nftables.TypeUID,
+		nftables.TypeGID,
+		nftables.TypeCGroupV2,
+		nftables.TypeTimeDate,
+		nftables.TypeTimeHour,
+		nftables.TypeTimeDay:
+		v, _ := s.opts.FormatValue(typ, k)
+		return v
+
 	default:
 		return rb.RawBytes(k).Text(rb.BaseDec)
 	}
 }
 
-type (
-	setCache struct {
-		dict.HDict[setKey, setEntry]
-	}
-	setEntry struct {
-		nftables.Set
-		elems []nftables.SetElement
-	}
-
-	setKey struct {
-		tableName string
-		setName   string
-		setId     uint32
-	}
-)
+type setEntry struct {
+	nftables.Set
+	elems []nftables.SetElement
+}
 
-func (s *setCache) RefreshFromTable(t *nftables.Table) error {
-	conn, err := nftables.New()
-	if err != nil {
-		return err
+// set looks up the set of the rule table the expression refers to.
+func (c *ctx) set(name string, id uint32) (setEntry, bool, error) {
+	if c.rule == nil || c.rule.Table == nil || c.sets == nil {
+		return setEntry{}, false, nil
 	}
-	defer func() { _ = conn.CloseLasting() }()
-	sets, err := conn.GetSets(t)
-	if err != nil {
-		return err
+	set, elems, ok, err := c.sets.LookupSet(c.rule.Table, name, id)
+	if err != nil || !ok {
+		return setEntry{}, false, err
 	}
-	for _, set := range sets {
-		if set != nil {
-			elems, err := conn.GetSetElements(set)
-			if err != nil {
-				return err
-			}
-			s.Put(setKey{
-				tableName: set.Table.Name,
-				setName:   set.Name,
-				setId:     set.ID,
-			}, setEntry{
-				Set:   *set,
-				elems: elems,
-			})
-		}
-	}
-	return nil
+	return setEntry{Set: *set, elems: elems}, true, nil
+}
+
+// nestedOpts returns the options of the encoders of nested expressions, they
+// share the sets of the rule.
+func (c *ctx) nestedOpts() Options {
+	opts := c.opts
+	opts.sets = c.sets
+	return opts
 }
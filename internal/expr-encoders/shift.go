@@ -0,0 +1,61 @@
+package encoders
+
+import (
+	"github.com/Morwran/nft-go/pkg/nftast"
+	"github.com/Morwran/nft-go/pkg/nftexpr"
+
+	"github.com/google/nftables/expr"
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+func init() {
+	register(&nftexpr.Shift{}, func(e expr.Any) encoder {
+		return &shiftEncoder{shift: e.(*nftexpr.Shift)}
+	})
+}
+
+type shiftEncoder struct {
+	shift *nftexpr.Shift
+}
+
+func (b *shiftEncoder) Encode(ctx *ctx) (nftast.Stmt, error) {
+	sh := b.shift
+	srcReg, op, err := b.source(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ctx.reg.Set(regID(sh.DestRegister), RegValue{
+		Node: &nftast.Binop{
+			Op:    op.String(),
+			Left:  srcReg.node(),
+			Right: nftast.Value{V: uint64(sh.Shift)},
+		},
+		Len:       srcReg.Len,
+		Expr:      sh,
+		Value:     hostOrderHex,
+		HostOrder: true,
+	})
+	return nil, nil
+}
+
+func (b *shiftEncoder) source(ctx *ctx) (RegValue, LogicOp, error) {
+	sh := b.shift
+	var op LogicOp
+	switch sh.Op {
+	case nftexpr.NFT_BITWISE_LSHIFT:
+		op = LogicLShift
+	case nftexpr.NFT_BITWISE_RSHIFT:
+		op = LogicRShift
+	default:
+		return RegValue{}, op, errors.Errorf("invalid bitwise operation: %d", sh.Op)
+	}
+	if sh.DestRegister == unix.NFT_REG_VERDICT {
+		return RegValue{}, op, errors.Errorf("%T expression has invalid destination register %d", sh, sh.DestRegister)
+	}
+	srcReg, ok := ctx.reg.Get(regID(sh.SourceRegister))
+	if !ok {
+		return RegValue{}, op, errors.Errorf("%T expression has no left side", sh)
+	}
+	return srcReg, op, nil
+}
@@ -2,8 +2,10 @@ package encoders
 
 import (
 	"fmt"
-	"strings"
 
+	"github.com/Morwran/nft-go/pkg/nftast"
+
+	nft "github.com/google/nftables"
 	"github.com/google/nftables/expr"
 	"github.com/pkg/errors"
 )
@@ -14,38 +16,43 @@ func init() {
 	})
 }
 
-type socketEncoder struct {
-	socket *expr.Socket
-}
+type (
+	socketEncoder struct {
+		socket *expr.Socket
+	}
+
+	socketArgs struct {
+		Key   string  `json:"key"`
+		Level *uint32 `json:"level,omitempty"`
+	}
+)
 
-func (b *socketEncoder) EncodeIR(ctx *ctx) (irNode, error) {
-	sb := strings.Builder{}
+func (b *socketEncoder) Encode(ctx *ctx) (nftast.Stmt, error) {
 	sock := b.socket
 	if sock.Register == 0 {
 		return nil, errors.Errorf("%T expression has invalid destination register %d", sock, sock.Register)
 	}
-	sb.WriteString(fmt.Sprintf("socket %s", SocketKey(sock.Key)))
+	args := &socketArgs{Key: SocketKey(sock.Key).String()}
+	val := RegValue{Node: &nftast.Other{Name: "socket", Args: args}, Expr: sock}
 	if sock.Key == expr.SocketKeyCgroupv2 {
-		sb.WriteString(fmt.Sprintf(" level %d", sock.Level))
+		level := sock.Level
+		args.Level = &level
+		val.Value = func(b []byte) nftast.Expr {
+			if v, ok := ctx.opts.value(nft.TypeCGroupV2, b); ok {
+				return v
+			}
+			return bytesValue(b)
+		}
 	}
-	ctx.reg.Set(regID(sock.Register), regVal{HumanExpr: sb.String(), Expr: sock})
-	return nil, ErrNoIR
+	ctx.reg.Set(regID(sock.Register), val)
+	return nil, nil
 }
 
-func (b *socketEncoder) EncodeJSON(ctx *ctx) ([]byte, error) {
-	sock := b.socket
-	if sock.Register == 0 {
-		return nil, errors.Errorf("%T expression has invalid destination register %d", sock, sock.Register)
-	}
-	sockJson := map[string]interface{}{
-		"socket": struct {
-			Key string `json:"omitempty"`
-		}{
-			SocketKey(sock.Key).String(),
-		},
+func (a *socketArgs) String() string {
+	if a.Level != nil {
+		return fmt.Sprintf("socket %s level %d", a.Key, *a.Level)
 	}
-	ctx.reg.Set(regID(sock.Register), regVal{Data: sockJson})
-	return nil, ErrNoJSON
+	return fmt.Sprintf("socket %s", a.Key)
 }
 
 type SocketKey expr.SocketKey
@@ -0,0 +1,74 @@
+package encoders
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Morwran/nft-go/pkg/nftast"
+	"github.com/Morwran/nft-go/pkg/nftexpr"
+
+	"github.com/google/nftables/expr"
+)
+
+func init() {
+	register(&nftexpr.Synproxy{}, func(e expr.Any) encoder {
+		return &synproxyEncoder{synproxy: e.(*nftexpr.Synproxy)}
+	})
+}
+
+type (
+	synproxyEncoder struct {
+		synproxy *nftexpr.Synproxy
+	}
+
+	synproxyArgs struct {
+		Mss    uint16   `json:"mss,omitempty"`
+		Wscale uint8    `json:"wscale,omitempty"`
+		Flags  []string `json:"flags,omitempty"`
+
+		flags nftexpr.SynproxyFlags
+	}
+)
+
+func (b *synproxyEncoder) Encode(ctx *ctx) (nftast.Stmt, error) {
+	sp := b.synproxy
+	args := &synproxyArgs{
+		Flags: SynproxyFlags(sp.Flags).Options(),
+		flags: sp.Flags,
+	}
+	if sp.Flags&nftexpr.NF_SYNPROXY_OPT_MSS != 0 {
+		args.Mss = sp.Mss
+	}
+	if sp.Flags&nftexpr.NF_SYNPROXY_OPT_WSCALE != 0 {
+		args.Wscale = sp.Wscale
+	}
+	return &nftast.Statement{Name: "synproxy", Args: args}, nil
+}
+
+func (s *synproxyArgs) String() string {
+	sb := strings.Builder{}
+	sb.WriteString("synproxy")
+	if s.flags&nftexpr.NF_SYNPROXY_OPT_MSS != 0 {
+		sb.WriteString(fmt.Sprintf(" mss %d", s.Mss))
+	}
+	if s.flags&nftexpr.NF_SYNPROXY_OPT_WSCALE != 0 {
+		sb.WriteString(fmt.Sprintf(" wscale %d", s.Wscale))
+	}
+	for _, opt := range s.Flags {
+		sb.WriteString(" " + opt)
+	}
+	return sb.String()
+}
+
+type SynproxyFlags nftexpr.SynproxyFlags
+
+// Options returns the names of the TCP options without a value
+func (f SynproxyFlags) Options() (opts []string) {
+	if f&SynproxyFlags(nftexpr.NF_SYNPROXY_OPT_TIMESTAMP) != 0 {
+		opts = append(opts, "timestamp")
+	}
+	if f&SynproxyFlags(nftexpr.NF_SYNPROXY_OPT_SACK_PERM) != 0 {
+		opts = append(opts, "sack-perm")
+	}
+	return opts
+}
@@ -1,9 +1,10 @@
-//nolint:dupl
 package encoders
 
 import (
 	"fmt"
 
+	"github.com/Morwran/nft-go/pkg/nftast"
+
 	"github.com/google/nftables/expr"
 )
 
@@ -17,10 +18,16 @@ type targetEncoder struct {
 	target *expr.Target
 }
 
-func (b *targetEncoder) EncodeIR(ctx *ctx) (irNode, error) {
-	return simpleIR(fmt.Sprintf(`xt target %q`, b.target.Name)), nil
+func (b *targetEncoder) Encode(ctx *ctx) (nftast.Stmt, error) {
+	return &nftast.Statement{Name: "xt", Args: &xtArgs{Type: "target", Name: b.target.Name}}, nil
+}
+
+// xtArgs are the arguments of the iptables extensions nft only names.
+type xtArgs struct {
+	Type string `json:"type"`
+	Name string `json:"name"`
 }
 
-func (b *targetEncoder) EncodeJSON(ctx *ctx) ([]byte, error) {
-	return []byte(fmt.Sprintf(`{"xt":{"type":"target","name":%q}}`, b.target.Name)), nil
+func (a *xtArgs) String() string {
+	return fmt.Sprintf("xt %s %q", a.Type, a.Name)
 }
@@ -1,10 +1,11 @@
 package encoders
 
 import (
-	"encoding/json"
 	"fmt"
 	"strings"
 
+	"github.com/Morwran/nft-go/pkg/nftast"
+
 	"github.com/google/nftables/expr"
 	"golang.org/x/sys/unix"
 )
@@ -19,63 +20,48 @@ type (
 	tproxyEncoder struct {
 		tproxy *expr.TProxy
 	}
-	tproxyIR struct {
-		*expr.TProxy
-		addr, port string
+	tproxyArgs struct {
+		Family string      `json:"family,omitempty"`
+		Addr   nftast.Expr `json:"addr,omitempty"`
+		Port   nftast.Expr `json:"port,omitempty"`
 	}
 
 	Family byte
 )
 
-func (b *tproxyEncoder) EncodeIR(ctx *ctx) (irNode, error) {
-	addrExpr, _ := ctx.reg.Get(regID(b.tproxy.RegAddr))
-	portExpr, _ := ctx.reg.Get(regID(b.tproxy.RegPort))
-
-	return &tproxyIR{TProxy: b.tproxy, addr: addrExpr.HumanExpr, port: portExpr.HumanExpr}, nil
-}
-
-func (b *tproxyEncoder) EncodeJSON(ctx *ctx) ([]byte, error) {
+func (b *tproxyEncoder) Encode(ctx *ctx) (nftast.Stmt, error) {
 	tp := b.tproxy
-	addrExpr, _ := ctx.reg.Get(regID(tp.RegAddr))
-	portExpr, _ := ctx.reg.Get(regID(tp.RegPort))
-	root := struct {
-		Family string `json:"op,omitempty"`
-		Addr   any    `json:"addr,omitempty"`
-		Port   any    `json:"port,omitempty"`
-	}{
-		Addr: addrExpr.Data,
-		Port: portExpr.Data,
-	}
+	args := &tproxyArgs{}
 	if tp.TableFamily == unix.NFPROTO_INET && tp.Family != unix.NFPROTO_UNSPEC {
-		root.Family = Family(tp.Family).String()
+		args.Family = Family(tp.Family).String()
 	}
-	tproxy := map[string]interface{}{
-		"tproxy": root,
+	// the values are typed like the ones of nat statements, IPv6 addresses
+	// are always put in brackets
+	nat := &natEncoder{}
+	if addrExpr, ok := ctx.reg.Get(regID(tp.RegAddr)); ok && tp.RegAddr != 0 {
+		args.Addr = nat.addrValue(&addrExpr, tp.Family == unix.NFPROTO_IPV6)
 	}
-
-	return json.Marshal(tproxy)
+	if portExpr, ok := ctx.reg.Get(regID(tp.RegPort)); ok && tp.RegPort != 0 {
+		args.Port = nat.portValue(ctx, &portExpr)
+	}
+	return &nftast.Statement{Name: "tproxy", Args: args}, nil
 }
 
-func (t *tproxyIR) Format() string {
+func (t *tproxyArgs) String() string {
 	sb := strings.Builder{}
 	sb.WriteString("tproxy")
-	if t.TableFamily == unix.NFPROTO_INET && t.Family != unix.NFPROTO_UNSPEC {
-		sb.WriteString(fmt.Sprintf(" %s", Family(t.Family)))
+	if t.Family != "" {
+		sb.WriteString(fmt.Sprintf(" %s", t.Family))
 	}
 	sb.WriteString(" to")
-	if t.addr != "" {
-		if t.Family == unix.NFPROTO_IPV6 {
-			if t.Family == unix.NFPROTO_IPV6 {
-				t.addr = fmt.Sprintf("[%s]", t.addr)
-			}
-		}
-		sb.WriteString(fmt.Sprintf(" %s", t.addr))
+	if t.Addr != nil {
+		sb.WriteString(fmt.Sprintf(" %s", t.Addr))
 	}
-	if t.port != "" {
-		if t.addr == " " {
+	if t.Port != nil {
+		if t.Addr == nil {
 			sb.WriteByte(' ')
 		}
-		sb.WriteString(fmt.Sprintf(":%s", t.port))
+		sb.WriteString(fmt.Sprintf(":%s", t.Port))
 	}
 	return sb.String()
 }
@@ -0,0 +1,70 @@
+package encoders
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/Morwran/nft-go/pkg/nftast"
+	"github.com/Morwran/nft-go/pkg/nftexpr"
+
+	"github.com/google/nftables/expr"
+	"github.com/pkg/errors"
+)
+
+func init() {
+	register(&nftexpr.Unknown{}, func(e expr.Any) encoder {
+		return &unknownEncoder{unknown: e.(*nftexpr.Unknown)}
+	})
+}
+
+type (
+	unknownEncoder struct {
+		unknown *nftexpr.Unknown
+	}
+
+	unknownArgs struct {
+		Name string `json:"name"`
+		Data string `json:"data,omitempty"`
+	}
+)
+
+func (b *unknownEncoder) Encode(ctx *ctx) (nftast.Stmt, error) {
+	if ctx.opts.strict {
+		return nil, errors.Errorf("unknown expression '%s'", b.unknown.Name)
+	}
+	return unknownStmt(b.unknown.Name, b.unknown.Data), nil
+}
+
+// unknownStmt is the opaque statement of an expression which can not be
+// decoded.
+func unknownStmt(name string, data []byte) nftast.Stmt {
+	return &nftast.Statement{Name: "unknown", Args: &unknownArgs{Name: name, Data: hexData(data)}}
+}
+
+func (u *unknownArgs) String() string {
+	if u.Data == "" {
+		return fmt.Sprintf("<expr name=%s>", u.Name)
+	}
+	return fmt.Sprintf("<expr name=%s data=%s>", u.Name, u.Data)
+}
+
+func hexData(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("0x%x", b)
+}
+
+// exprName returns the name of an expression type without an encoder, e.g.
+// "flowoffload" for *expr.FlowOffload.
+func exprName(e expr.Any) string {
+	t := reflect.TypeOf(e)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil {
+		return "nil"
+	}
+	return strings.ToLower(t.Name())
+}
@@ -1,7 +1,7 @@
 package encoders
 
 import (
-	"fmt"
+	"github.com/Morwran/nft-go/pkg/nftast"
 
 	"github.com/google/nftables/expr"
 )
@@ -16,20 +16,8 @@ type verdictEncoder struct {
 	verdict *expr.Verdict
 }
 
-func (b *verdictEncoder) EncodeIR(ctx *ctx) (irNode, error) {
-	verdict := b.verdict
-	if verdict.Chain == "" {
-		return simpleIR(VerdictKind(verdict.Kind).String()), nil
-	}
-	return simpleIR(fmt.Sprintf("%s %s", VerdictKind(verdict.Kind).String(), verdict.Chain)), nil
-}
-
-func (b *verdictEncoder) EncodeJSON(ctx *ctx) ([]byte, error) {
-	verdict := b.verdict
-	if verdict.Chain == "" {
-		return []byte(fmt.Sprintf(`{%q:null}`, VerdictKind(verdict.Kind).String())), nil
-	}
-	return []byte(fmt.Sprintf(`{%q:{"target":%q}}`, VerdictKind(verdict.Kind).String(), verdict.Chain)), nil
+func (b *verdictEncoder) Encode(ctx *ctx) (nftast.Stmt, error) {
+	return &nftast.Verdict{Kind: VerdictKind(b.verdict.Kind).String(), Chain: b.verdict.Chain}, nil
 }
 
 type VerdictKind expr.VerdictKind
@@ -0,0 +1,128 @@
+package encoders
+
+import (
+	"fmt"
+	"strings"
+
+	rb "github.com/Morwran/nft-go/internal/bytes"
+	"github.com/Morwran/nft-go/pkg/nftast"
+	"github.com/Morwran/nft-go/pkg/nftexpr"
+
+	"github.com/google/nftables/expr"
+	"github.com/pkg/errors"
+)
+
+func init() {
+	register(&nftexpr.Xfrm{}, func(e expr.Any) encoder {
+		return &xfrmEncoder{xfrm: e.(*nftexpr.Xfrm)}
+	})
+}
+
+type (
+	xfrmEncoder struct {
+		xfrm *nftexpr.Xfrm
+	}
+
+	xfrmArgs struct {
+		Key    string `json:"key"`
+		Family string `json:"family,omitempty"`
+		Dir    string `json:"dir"`
+		Spnum  uint32 `json:"spnum,omitempty"`
+	}
+)
+
+func (b *xfrmEncoder) Encode(ctx *ctx) (nftast.Stmt, error) {
+	x := b.xfrm
+	if x.Register == 0 {
+		return nil, errors.Errorf("%T expression has invalid destination register %d", x, x.Register)
+	}
+	key := XfrmKey(x.Key)
+	ctx.reg.Set(regID(x.Register), RegValue{
+		Node: &nftast.Other{Name: "ipsec", Args: &xfrmArgs{
+			Key:    key.String(),
+			Family: key.Family(),
+			Dir:    XfrmDir(x.Dir).String(),
+			Spnum:  x.Spnum,
+		}},
+		Expr: x,
+		Value: func(b []byte) nftast.Expr {
+			return constant(key.JSON(b), key.Desc(b))
+		},
+	})
+	return nil, nil
+}
+
+func (a *xfrmArgs) String() string {
+	sb := strings.Builder{}
+	sb.WriteString(fmt.Sprintf("ipsec %s", a.Dir))
+	if a.Spnum != 0 {
+		sb.WriteString(fmt.Sprintf(" spnum %d", a.Spnum))
+	}
+	if a.Family != "" {
+		sb.WriteString(" " + a.Family)
+	}
+	sb.WriteString(" " + a.Key)
+	return sb.String()
+}
+
+type (
+	XfrmKey nftexpr.XfrmKey
+	XfrmDir nftexpr.XfrmDir
+)
+
+func (k XfrmKey) String() string {
+	switch nftexpr.XfrmKey(k) {
+	case nftexpr.NFT_XFRM_KEY_DADDR_IP4, nftexpr.NFT_XFRM_KEY_DADDR_IP6:
+		return "daddr"
+	case nftexpr.NFT_XFRM_KEY_SADDR_IP4, nftexpr.NFT_XFRM_KEY_SADDR_IP6:
+		return "saddr"
+	case nftexpr.NFT_XFRM_KEY_REQID:
+		return "reqid"
+	case nftexpr.NFT_XFRM_KEY_SPI:
+		return "spi"
+	}
+	return "unknown"
+}
+
+// Family returns the family of the address keys
+func (k XfrmKey) Family() string {
+	switch nftexpr.XfrmKey(k) {
+	case nftexpr.NFT_XFRM_KEY_DADDR_IP4, nftexpr.NFT_XFRM_KEY_SADDR_IP4:
+		return "ip"
+	case nftexpr.NFT_XFRM_KEY_DADDR_IP6, nftexpr.NFT_XFRM_KEY_SADDR_IP6:
+		return "ip6"
+	}
+	return ""
+}
+
+// Desc formats the values of the key, the reqid is kept in host byte order
+// and the spi in network byte order.
+func (k XfrmKey) Desc(b []byte) string {
+	switch nftexpr.XfrmKey(k) {
+	case nftexpr.NFT_XFRM_KEY_REQID:
+		return rb.LEBytesToIntString(b)
+	case nftexpr.NFT_XFRM_KEY_SPI:
+		return rb.BytesToDecimalString(b)
+	}
+	if k.Family() != "" {
+		return rb.RawBytes(b).Ip().String()
+	}
+	return rb.RawBytes(b).Text(rb.BaseDec)
+}
+
+func (k XfrmKey) JSON(b []byte) any {
+	switch nftexpr.XfrmKey(k) {
+	case nftexpr.NFT_XFRM_KEY_REQID:
+		return rb.RawBytes(b).LittleEndian().Uint64()
+	case nftexpr.NFT_XFRM_KEY_SPI:
+		return rb.RawBytes(b).Uint64()
+	}
+	return k.Desc(b)
+}
+
+func (d XfrmDir) String() string {
+	if nftexpr.XfrmDir(d) == nftexpr.XFRM_POLICY_OUT {
+		return "out"
+	}
+	return "in"
+}
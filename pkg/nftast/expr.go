@@ -0,0 +1,388 @@
+package nftast
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+)
+
+type (
+	// Expr is an operand of a match: Payload, Meta, Ct, Value, SetRef or
+	// *Set, *List, *Mapping, *Prefix, *Range, *Concat, *Binop, *Other. Leaf
+	// operands are values so they can be compared with `==`. String prints
+	// the expression in the nft syntax, MarshalJSON in the libnftables JSON
+	// schema.
+	Expr interface {
+		fmt.Stringer
+		json.Marshaler
+		expr()
+	}
+
+	// Payload is a header field like `tcp dport`, raw payloads like
+	// `@th,16,16` have Base, Offset and Len set instead.
+	Payload struct {
+		Protocol string
+		Field    string
+		Base     string
+		Offset   int64
+		Len      int64
+	}
+
+	// Meta is a meta key like `meta l4proto`.
+	Meta struct {
+		Key string
+	}
+
+	// Ct is a conntrack key like `ct state` or `ct original saddr`.
+	Ct struct {
+		Key string
+		Dir string
+	}
+
+	// Value is a constant: an integer, float64, string or bool. Symbolic
+	// constants keep their name like "tcp" or "established". Text is the
+	// way nft prints the constant when it is not V itself like `ssh` for
+	// the port 22 or `0xff` for a mask.
+	Value struct {
+		V    any
+		Text string
+	}
+
+	// SetRef refers to a named set like `@blocked`.
+	SetRef struct {
+		Name string
+	}
+
+	// Set lists the elements of an anonymous set like `{22,80}`.
+	Set struct {
+		Elems []Expr
+	}
+
+	// List is a list of flags like `established,related`.
+	List struct {
+		Elems []Expr
+	}
+
+	// Mapping is an element of an anonymous map like `10.0.0.1 : 8080`.
+	Mapping struct {
+		Key, Data Expr
+	}
+
+	// Prefix is an address prefix like `10.0.0.0/8`.
+	Prefix struct {
+		Addr Expr
+		Len  int64
+	}
+
+	// Range is a range of values like `1000-2000`.
+	Range struct {
+		Min, Max Expr
+	}
+
+	// Concat is a concatenation like `ip saddr . tcp dport`.
+	Concat struct {
+		Exprs []Expr
+	}
+
+	// Binop is a binary operation like `ct mark & 0xff`.
+	Binop struct {
+		Op          string
+		Left, Right Expr
+	}
+
+	// Other is any other expression like `fib daddr type`, Name is the key
+	// of its JSON object and Args the value. Args prints the expression in
+	// the nft syntax when it is a fmt.Stringer.
+	Other struct {
+		Name string
+		Args any
+	}
+)
+
+func (Payload) expr()  {}
+func (Meta) expr()     {}
+func (Ct) expr()       {}
+func (Value) expr()    {}
+func (SetRef) expr()   {}
+func (*Set) expr()     {}
+func (*List) expr()    {}
+func (*Mapping) expr() {}
+func (*Prefix) expr()  {}
+func (*Range) expr()   {}
+func (*Concat) expr()  {}
+func (*Binop) expr()   {}
+func (*Other) expr()   {}
+
+func (p Payload) String() string {
+	if p.Protocol != "" {
+		return fmt.Sprintf("%s %s", p.Protocol, p.Field)
+	}
+	return fmt.Sprintf("@%s,%d,%d", p.Base, p.Offset, p.Len)
+}
+
+func (p Payload) MarshalJSON() ([]byte, error) {
+	if p.Protocol != "" {
+		return json.Marshal(map[string]any{
+			"payload": struct {
+				Protocol string `json:"protocol"`
+				Field    string `json:"field"`
+			}{Protocol: p.Protocol, Field: p.Field},
+		})
+	}
+	return json.Marshal(map[string]any{
+		"payload": struct {
+			Base   string `json:"base"`
+			Offset int64  `json:"offset"`
+			Len    int64  `json:"len"`
+		}{Base: p.Base, Offset: p.Offset, Len: p.Len},
+	})
+}
+
+// String prints the key, the keys of interfaces go without the `meta`
+// keyword like `iifname "eth0"`.
+func (m Meta) String() string {
+	switch m.Key {
+	case "iif", "oif", "iifname", "oifname", "iifgroup", "oifgroup":
+		return m.Key
+	}
+	return fmt.Sprintf("meta %s", m.Key)
+}
+
+func (m Meta) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]any{
+		"meta": struct {
+			Key string `json:"key"`
+		}{Key: m.Key},
+	})
+}
+
+func (c Ct) String() string {
+	if c.Dir != "" {
+		return fmt.Sprintf("ct %s %s", c.Dir, c.Key)
+	}
+	return fmt.Sprintf("ct %s", c.Key)
+}
+
+func (c Ct) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]any{
+		"ct": struct {
+			Key string `json:"key"`
+			Dir string `json:"dir,omitempty"`
+		}{Key: c.Key, Dir: c.Dir},
+	})
+}
+
+func (v Value) String() string {
+	if v.Text != "" {
+		return v.Text
+	}
+	return fmt.Sprint(v.V)
+}
+
+func (v Value) MarshalJSON() ([]byte, error) {
+	return json.Marshal(v.V)
+}
+
+func (s SetRef) String() string {
+	return fmt.Sprintf("@%s", s.Name)
+}
+
+func (s SetRef) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+func (s *Set) String() string {
+	return fmt.Sprintf("{%s}", join(s.Elems, ","))
+}
+
+func (s *Set) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]any{"set": elems(s.Elems)})
+}
+
+func (l *List) String() string {
+	return join(l.Elems, ",")
+}
+
+func (l *List) MarshalJSON() ([]byte, error) {
+	return json.Marshal(elems(l.Elems))
+}
+
+func (m *Mapping) String() string {
+	return fmt.Sprintf("%s : %s", m.Key, m.Data)
+}
+
+func (m *Mapping) MarshalJSON() ([]byte, error) {
+	return json.Marshal([2]Expr{m.Key, m.Data})
+}
+
+func (p *Prefix) String() string {
+	return fmt.Sprintf("%s/%d", p.Addr, p.Len)
+}
+
+func (p *Prefix) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]any{
+		"prefix": struct {
+			Addr Expr  `json:"addr"`
+			Len  int64 `json:"len"`
+		}{Addr: p.Addr, Len: p.Len},
+	})
+}
+
+func (r *Range) String() string {
+	return fmt.Sprintf("%s-%s", r.Min, r.Max)
+}
+
+func (r *Range) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]any{"range": [2]Expr{r.Min, r.Max}})
+}
+
+func (c *Concat) String() string {
+	return join(c.Exprs, " . ")
+}
+
+func (c *Concat) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]any{"concat": elems(c.Exprs)})
+}
+
+// String prints the operation like C does: operands binding weaker than the
+// operation are put in parentheses like `(meta mark | 0x1) << 8`.
+func (b *Binop) String() string {
+	left, right := b.Left.String(), b.Right.String()
+	if l, ok := b.Left.(*Binop); ok && precedence(l.Op) < precedence(b.Op) {
+		left = fmt.Sprintf("(%s)", left)
+	}
+	if r, ok := b.Right.(*Binop); ok && precedence(r.Op) <= precedence(b.Op) {
+		right = fmt.Sprintf("(%s)", right)
+	}
+	return fmt.Sprintf("%s %s %s", left, b.Op, right)
+}
+
+// MarshalJSON prints the operands of chained operations of the same kind in
+// one list like {"|":["fin","syn","rst"]}.
+func (b *Binop) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]any{b.Op: b.operands(b.Op)})
+}
+
+func (b *Binop) operands(op string) []Expr {
+	var ret []Expr
+	for _, e := range [...]Expr{b.Left, b.Right} {
+		if t, ok := e.(*Binop); ok && t.Op == op {
+			ret = append(ret, t.operands(op)...)
+			continue
+		}
+		ret = append(ret, e)
+	}
+	return ret
+}
+
+// precedence returns the C precedence of the operation.
+func precedence(op string) int {
+	switch op {
+	case "<<", ">>":
+		return 4 //nolint:mnd
+	case "&":
+		return 3 //nolint:mnd
+	case "^":
+		return 2 //nolint:mnd
+	case "|":
+		return 1
+	}
+	return 0
+}
+
+func (o *Other) String() string {
+	if a, ok := o.Args.(fmt.Stringer); ok {
+		return a.String()
+	}
+	return o.Name
+}
+
+// MarshalJSON prints the expression as the object {Name: Args}, expressions
+// without a name are printed as their arguments.
+func (o *Other) MarshalJSON() ([]byte, error) {
+	if o.Name == "" {
+		return json.Marshal(o.Args)
+	}
+	return json.Marshal(map[string]any{o.Name: o.Args})
+}
+
+func join(list []Expr, sep string) string {
+	parts := make([]string, 0, len(list))
+	for _, e := range list {
+		parts = append(parts, e.String())
+	}
+	return strings.Join(parts, sep)
+}
+
+// elems keeps the JSON of empty lists an array
+func elems(list []Expr) []Expr {
+	if list == nil {
+		return []Expr{}
+	}
+	return list
+}
+
+// contains reports whether the value is the operand, one of its elements or
+// within its range.
+func contains(e Expr, v any) bool {
+	switch t := e.(type) {
+	case Value:
+		return normalize(t.V) == v
+	case *Set:
+		return containsAny(t.Elems, v)
+	case *List:
+		return containsAny(t.Elems, v)
+	case *Range:
+		lo, ok1 := t.Min.(Value)
+		hi, ok2 := t.Max.(Value)
+		x, ok3 := v.(int64)
+		if ok1 && ok2 && ok3 {
+			l, ok1 := normalize(lo.V).(int64)
+			h, ok2 := normalize(hi.V).(int64)
+			return ok1 && ok2 && l <= x && x <= h
+		}
+	}
+	return false
+}
+
+func containsAny(list []Expr, v any) bool {
+	for _, el := range list {
+		if contains(el, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalize converts integers to int64 so that values of any integer type
+// compare equal.
+func normalize(v any) any {
+	switch t := v.(type) {
+	case int:
+		return int64(t)
+	case int8:
+		return int64(t)
+	case int16:
+		return int64(t)
+	case int32:
+		return int64(t)
+	case uint:
+		if uint64(t) <= math.MaxInt64 {
+			return int64(t) //nolint:gosec
+		}
+	case uint8:
+		return int64(t)
+	case uint16:
+		return int64(t)
+	case uint32:
+		return int64(t)
+	case uint64:
+		if t <= math.MaxInt64 {
+			return int64(t)
+		}
+	case float32:
+		return float64(t)
+	}
+	return v
+}
@@ -0,0 +1,211 @@
+package nftast
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+type (
+	// Rule is a decompiled rule, its statements follow in the order nft
+	// prints them.
+	Rule struct {
+		Family string
+		Table  string
+		Chain  string
+		Handle uint64
+		Stmts  []Stmt
+	}
+
+	// Stmt is a statement of a rule: a *Match, a *Verdict or a *Statement.
+	Stmt interface {
+		// String returns the statement in the nft syntax
+		String() string
+		// JSON returns the statement in the libnftables JSON schema
+		JSON() json.RawMessage
+	}
+
+	// Match compares the value of the left expression with the right one
+	// like `tcp dport { 22, 80 }` or `ct state established,related`.
+	Match struct {
+		Left  Expr
+		Op    string
+		Right Expr
+	}
+
+	// Verdict ends the rule evaluation like `accept` or `jump chain`.
+	Verdict struct {
+		Kind  string
+		Chain string
+	}
+
+	// Statement is any other statement like `counter` or `snat to 10.0.0.1`,
+	// Name is the key of its JSON object and Args the value. Args prints the
+	// statement in the nft syntax when it is a fmt.Stringer.
+	Statement struct {
+		Name string
+		Args any
+	}
+)
+
+var (
+	_ Stmt = (*Match)(nil)
+	_ Stmt = (*Verdict)(nil)
+	_ Stmt = (*Statement)(nil)
+)
+
+// String implements Stmt, `==` and `in` are implied unless the left side is
+// a binary operation.
+func (m *Match) String() string {
+	left := m.Left.String()
+	if m.Right == nil {
+		return left
+	}
+	right := m.Right.String()
+	if _, ok := m.Left.(*Binop); ok {
+		op := m.Op
+		if op == "in" {
+			op = "=="
+		}
+		switch m.Right.(type) {
+		case SetRef, *Set:
+			// lookups print the key in parentheses like `(meta mark & 0xff) @marks`
+			return fmt.Sprintf("(%s) %s %s", left, op, right)
+		}
+		return fmt.Sprintf("%s %s %s", left, op, right)
+	}
+	if m.Op == "==" || m.Op == "in" || m.Op == "" {
+		return fmt.Sprintf("%s %s", left, right)
+	}
+	return fmt.Sprintf("%s %s %s", left, m.Op, right)
+}
+
+// JSON implements Stmt
+func (m *Match) JSON() json.RawMessage { return marshal(m) }
+
+func (m *Match) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]any{
+		"match": struct {
+			Op    string `json:"op"`
+			Left  Expr   `json:"left"`
+			Right Expr   `json:"right"`
+		}{Op: m.Op, Left: m.Left, Right: m.Right},
+	})
+}
+
+// String implements Stmt
+func (v *Verdict) String() string {
+	if v.Chain == "" {
+		return v.Kind
+	}
+	return fmt.Sprintf("%s %s", v.Kind, v.Chain)
+}
+
+// JSON implements Stmt
+func (v *Verdict) JSON() json.RawMessage { return marshal(v) }
+
+func (v *Verdict) MarshalJSON() ([]byte, error) {
+	if v.Chain == "" {
+		return json.Marshal(map[string]any{v.Kind: nil})
+	}
+	return json.Marshal(map[string]any{
+		v.Kind: struct {
+			Target string `json:"target"`
+		}{Target: v.Chain},
+	})
+}
+
+// String implements Stmt
+func (s *Statement) String() string {
+	if a, ok := s.Args.(fmt.Stringer); ok {
+		return a.String()
+	}
+	return s.Name
+}
+
+// JSON implements Stmt
+func (s *Statement) JSON() json.RawMessage { return marshal(s) }
+
+// MarshalJSON prints the statement as the object {Name: Args}, statements
+// without a name are printed as their arguments.
+func (s *Statement) MarshalJSON() ([]byte, error) {
+	if s.Name == "" {
+		return json.Marshal(s.Args)
+	}
+	return json.Marshal(map[string]any{s.Name: s.Args})
+}
+
+func marshal(v json.Marshaler) json.RawMessage {
+	b, err := v.MarshalJSON()
+	if err != nil {
+		return nil
+	}
+	return b
+}
+
+// String prints the statements of the rule in the nft syntax.
+func (r *Rule) String() string {
+	parts := make([]string, 0, len(r.Stmts))
+	for _, s := range r.Stmts {
+		if t := s.String(); t != "" {
+			parts = append(parts, t)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// MarshalJSON prints the statements of the rule in the libnftables JSON
+// schema.
+func (r *Rule) MarshalJSON() ([]byte, error) {
+	stmts := make([]json.RawMessage, 0, len(r.Stmts))
+	for _, s := range r.Stmts {
+		j := s.JSON()
+		if j == nil {
+			return nil, fmt.Errorf("failed to print statement '%s'", s)
+		}
+		stmts = append(stmts, j)
+	}
+	return json.Marshal(stmts)
+}
+
+// Matches returns the matches of the rule.
+func (r *Rule) Matches() []*Match {
+	var ret []*Match
+	for _, s := range r.Stmts {
+		if m, ok := s.(*Match); ok {
+			ret = append(ret, m)
+		}
+	}
+	return ret
+}
+
+// Match returns the first match of the left expression like
+// Payload{Protocol: "tcp", Field: "dport"}.
+func (r *Rule) Match(left Expr) (*Match, bool) {
+	for _, m := range r.Matches() {
+		if m.Left == left {
+			return m, true
+		}
+	}
+	return nil, false
+}
+
+// Verdict returns the verdict of the rule.
+func (r *Rule) Verdict() (*Verdict, bool) {
+	for _, s := range r.Stmts {
+		if v, ok := s.(*Verdict); ok {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// Contains reports whether the match accepts the value: it is equal to the
+// right value, an element of the right set or within the right range. Named
+// sets are not looked into.
+func (m *Match) Contains(v any) bool {
+	if m.Op != "==" && m.Op != "in" {
+		return false
+	}
+	return contains(m.Right, normalize(v))
+}
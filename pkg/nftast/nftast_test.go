@@ -0,0 +1,174 @@
+package nftast
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type nftastTestSuite struct {
+	suite.Suite
+}
+
+func (sui *nftastTestSuite) Test_Printers() {
+	testCases := []struct {
+		name     string
+		stmt     Stmt
+		expected string
+		expJSON  string
+	}{
+		{
+			name:     "implicit eq",
+			stmt:     &Match{Left: Payload{Protocol: "tcp", Field: "dport"}, Op: "==", Right: Value{V: uint64(22), Text: "ssh"}},
+			expected: "tcp dport ssh",
+			expJSON:  `{"match":{"op":"==","left":{"payload":{"protocol":"tcp","field":"dport"}},"right":22}}`,
+		},
+		{
+			name:     "raw payload",
+			stmt:     &Match{Left: Payload{Base: "th", Offset: 16, Len: 16}, Op: "!=", Right: Value{V: uint64(22)}},
+			expected: "@th,16,16 != 22",
+			expJSON:  `{"match":{"op":"!=","left":{"payload":{"base":"th","offset":16,"len":16}},"right":22}}`,
+		},
+		{
+			name: "flags",
+			stmt: &Match{Left: Ct{Key: "state"}, Op: "in", Right: &List{Elems: []Expr{
+				Value{V: "established"}, Value{V: "related"},
+			}}},
+			expected: "ct state established,related",
+			expJSON:  `{"match":{"op":"in","left":{"ct":{"key":"state"}},"right":["established","related"]}}`,
+		},
+		{
+			name: "binop lookup",
+			stmt: &Match{
+				Left:  &Binop{Op: "&", Left: Meta{Key: "mark"}, Right: Value{V: uint64(255), Text: "0xff"}},
+				Op:    "==",
+				Right: SetRef{Name: "marks"},
+			},
+			expected: "(meta mark & 0xff) == @marks",
+			expJSON:  `{"match":{"op":"==","left":{"&":[{"meta":{"key":"mark"}},255]},"right":"@marks"}}`,
+		},
+		{
+			name: "chained binop",
+			stmt: &Match{
+				Left: &Binop{Op: "&", Left: Payload{Protocol: "tcp", Field: "flags"}, Right: &Binop{Op: "|",
+					Left:  &Binop{Op: "|", Left: Value{V: "fin"}, Right: Value{V: "syn"}},
+					Right: Value{V: "rst"},
+				}},
+				Op:    "==",
+				Right: Value{V: "syn"},
+			},
+			expected: "tcp flags & (fin | syn | rst) == syn",
+			expJSON:  `{"match":{"op":"==","left":{"&":[{"payload":{"protocol":"tcp","field":"flags"}},{"|":["fin","syn","rst"]}]},"right":"syn"}}`,
+		},
+		{
+			name: "anonymous set",
+			stmt: &Match{Left: Payload{Protocol: "tcp", Field: "dport"}, Op: "==", Right: &Set{Elems: []Expr{
+				Value{V: uint64(22)},
+				&Range{Min: Value{V: uint64(1000)}, Max: Value{V: uint64(2000)}},
+			}}},
+			expected: "tcp dport {22,1000-2000}",
+			expJSON:  `{"match":{"op":"==","left":{"payload":{"protocol":"tcp","field":"dport"}},"right":{"set":[22,{"range":[1000,2000]}]}}}`,
+		},
+		{
+			name:     "verdict",
+			stmt:     &Verdict{Kind: "jump", Chain: "filter"},
+			expected: "jump filter",
+			expJSON:  `{"jump":{"target":"filter"}}`,
+		},
+	}
+	for _, tc := range testCases {
+		sui.Run(tc.name, func() {
+			sui.Require().Equal(tc.expected, tc.stmt.String())
+			sui.Require().JSONEq(tc.expJSON, string(tc.stmt.JSON()))
+		})
+	}
+}
+
+func (sui *nftastTestSuite) Test_MatchContains() {
+	m := &Match{
+		Left: Payload{Protocol: "tcp", Field: "dport"},
+		Op:   "==",
+		Right: &Set{Elems: []Expr{
+			Value{V: uint64(22), Text: "ssh"},
+			&Range{Min: Value{V: uint64(1000)}, Max: Value{V: uint64(2000)}},
+		}},
+	}
+	sui.Require().True(m.Contains(22))
+	sui.Require().True(m.Contains(uint16(1500)))
+	sui.Require().False(m.Contains(80))
+}
+
+func (sui *nftastTestSuite) Test_Print() {
+	testCases := []struct {
+		name     string
+		stmt     Stmt
+		expected string
+		expJSON  string
+	}{
+		{
+			name: "implied equality",
+			stmt: &Match{
+				Left:  Payload{Protocol: "tcp", Field: "dport"},
+				Op:    "==",
+				Right: &Set{Elems: []Expr{Value{V: uint64(22), Text: "ssh"}, Value{V: uint64(80)}}},
+			},
+			expected: "tcp dport {ssh,80}",
+			expJSON:  `{"match":{"op":"==","left":{"payload":{"protocol":"tcp","field":"dport"}},"right":{"set":[22,80]}}}`,
+		},
+		{
+			name: "flags",
+			stmt: &Match{
+				Left:  Ct{Key: "state"},
+				Op:    "in",
+				Right: &List{Elems: []Expr{Value{V: "established"}, Value{V: "related"}}},
+			},
+			expected: "ct state established,related",
+			expJSON:  `{"match":{"op":"in","left":{"ct":{"key":"state"}},"right":["established","related"]}}`,
+		},
+		{
+			name: "masked flags",
+			stmt: &Match{
+				Left: &Binop{Op: "&", Left: Payload{Protocol: "tcp", Field: "flags"}, Right: &Binop{Op: "|",
+					Left:  &Binop{Op: "|", Left: Value{V: "fin"}, Right: Value{V: "syn"}},
+					Right: Value{V: "ack"},
+				}},
+				Op:    "==",
+				Right: Value{V: "syn"},
+			},
+			expected: "tcp flags & (fin | syn | ack) == syn",
+			expJSON:  `{"match":{"op":"==","left":{"&":[{"payload":{"protocol":"tcp","field":"flags"}},{"|":["fin","syn","ack"]}]},"right":"syn"}}`,
+		},
+		{
+			name: "lookup by an operation",
+			stmt: &Match{
+				Left:  &Binop{Op: "&", Left: Meta{Key: "mark"}, Right: Value{V: uint64(255), Text: "0xff"}},
+				Op:    "!=",
+				Right: SetRef{Name: "marks"},
+			},
+			expected: "(meta mark & 0xff) != @marks",
+			expJSON:  `{"match":{"op":"!=","left":{"&":[{"meta":{"key":"mark"}},255]},"right":"@marks"}}`,
+		},
+		{
+			name:     "verdict",
+			stmt:     &Verdict{Kind: "jump", Chain: "ssh"},
+			expected: "jump ssh",
+			expJSON:  `{"jump":{"target":"ssh"}}`,
+		},
+		{
+			name:     "statement",
+			stmt:     &Statement{Name: "notrack"},
+			expected: "notrack",
+			expJSON:  `{"notrack":null}`,
+		},
+	}
+	for _, tc := range testCases {
+		sui.Run(tc.name, func() {
+			sui.Require().Equal(tc.expected, tc.stmt.String())
+			sui.Require().JSONEq(tc.expJSON, string(tc.stmt.JSON()))
+		})
+	}
+}
+
+func Test_Nftast(t *testing.T) {
+	suite.Run(t, new(nftastTestSuite))
+}
@@ -1,6 +1,9 @@
 package nftenc
 
-import "fmt"
+import (
+	"fmt"
+	"io"
+)
 
 type Encoder interface {
 	fmt.Stringer
@@ -8,3 +11,12 @@ type Encoder interface {
 	Format() (string, error)
 	MustString() string
 }
+
+// StreamEncoder writes the text and the JSON to the writer as they are
+// produced instead of building them in memory.
+type StreamEncoder interface {
+	Encoder
+	io.WriterTo
+	// EncodeJSON writes the JSON of MarshalJSON to the writer
+	EncodeJSON(w io.Writer) error
+}
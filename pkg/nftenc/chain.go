@@ -3,7 +3,7 @@ package nftenc
 import (
 	"encoding/json"
 	"fmt"
-	"strings"
+	"io"
 
 	nftLib "github.com/google/nftables"
 )
@@ -19,7 +19,7 @@ type (
 	ChainPolicy   nftLib.ChainPolicy
 )
 
-var _ Encoder = (*ChainEncoder)(nil)
+var _ StreamEncoder = (*ChainEncoder)(nil)
 
 func NewChainEncoder(c *nftLib.Chain, rules ...*RuleEncoder) *ChainEncoder {
 	return &ChainEncoder{chain: c, rules: rules}
@@ -36,24 +36,29 @@ func (enc *ChainEncoder) MustString() string {
 	return str
 }
 func (enc *ChainEncoder) Format() (string, error) {
-	sb := strings.Builder{}
+	return formatStream(enc)
+}
+
+// WriteTo writes the chain in the nft syntax rule by rule.
+func (enc *ChainEncoder) WriteTo(w io.Writer) (int64, error) {
+	sw := &streamWriter{w: w}
 	chain := enc.chain
-	sb.WriteString(fmt.Sprintf("chain %s { # handle %d\n", chain.Name, chain.Handle))
+	sw.WriteString(fmt.Sprintf("chain %s { # handle %d\n", chain.Name, chain.Handle))
 	if chain.Type != "" || chain.Hooknum != nil || chain.Priority != nil || chain.Policy != nil {
-		sb.WriteString("\t\t")
+		sw.WriteString("\t\t")
 		if chain.Type != "" {
-			sb.WriteString(fmt.Sprintf("type %s ", chain.Type))
+			sw.WriteString(fmt.Sprintf("type %s ", chain.Type))
 		}
 		if chain.Hooknum != nil {
-			sb.WriteString(fmt.Sprintf("hook %s ", ChainHook(*chain.Hooknum)))
+			sw.WriteString(fmt.Sprintf("hook %s ", ChainHook(*chain.Hooknum)))
 		}
 		if chain.Priority != nil {
-			sb.WriteString(fmt.Sprintf("priority %s; ", ChainPriority(*chain.Priority)))
+			sw.WriteString(fmt.Sprintf("priority %s; ", ChainPriority(*chain.Priority)))
 		}
 		if chain.Policy != nil {
-			sb.WriteString(fmt.Sprintf("policy %s;", ChainPolicy(*chain.Policy)))
+			sw.WriteString(fmt.Sprintf("policy %s;", ChainPolicy(*chain.Policy)))
 		}
-		sb.WriteByte('\n')
+		sw.WriteString("\n")
 	}
 
 	for _, rule := range enc.rules {
@@ -62,18 +67,29 @@ func (enc *ChainEncoder) Format() (string, error) {
 		}
 		human, err := rule.Format()
 		if err != nil {
-			return "", err
+			return sw.n, err
 		}
 		if human == "" {
 			continue
 		}
-		sb.WriteString("\t\t")
-		sb.WriteString(human)
-		sb.WriteByte('\n')
+		sw.WriteString("\t\t")
+		sw.WriteString(human)
+		sw.WriteString("\n")
+	}
+	sw.WriteString("\t}")
+	return sw.n, sw.err
+}
+
+// EncodeJSON writes the chain JSON object, the rules are not part of it.
+func (enc *ChainEncoder) EncodeJSON(w io.Writer) error {
+	b, err := enc.MarshalJSON()
+	if err != nil {
+		return err
 	}
-	sb.WriteString("\t}")
-	return sb.String(), nil
+	_, err = w.Write(b)
+	return err
 }
+
 func (enc *ChainEncoder) MarshalJSON() ([]byte, error) {
 	chain := struct {
 		Family   string           `json:"family"`
@@ -85,14 +101,20 @@ func (enc *ChainEncoder) MarshalJSON() ([]byte, error) {
 		Priority string           `json:"priority,omitempty"`
 		Policy   string           `json:"policy,omitempty"`
 	}{
-		Family:   TableFamily(enc.chain.Table.Family).String(),
-		Table:    enc.chain.Table.Name,
-		Handle:   enc.chain.Handle,
-		Name:     enc.chain.Name,
-		Type:     enc.chain.Type,
-		Hook:     ChainHook(*enc.chain.Hooknum).String(),
-		Priority: ChainPriority(*enc.chain.Priority).String(),
-		Policy:   ChainPolicy(*enc.chain.Policy).String(),
+		Family: TableFamily(enc.chain.Table.Family).String(),
+		Table:  enc.chain.Table.Name,
+		Handle: enc.chain.Handle,
+		Name:   enc.chain.Name,
+		Type:   enc.chain.Type,
+	}
+	if enc.chain.Hooknum != nil {
+		chain.Hook = ChainHook(*enc.chain.Hooknum).String()
+	}
+	if enc.chain.Priority != nil {
+		chain.Priority = ChainPriority(*enc.chain.Priority).String()
+	}
+	if enc.chain.Policy != nil {
+		chain.Policy = ChainPolicy(*enc.chain.Policy).String()
 	}
 
 	return json.Marshal(map[string]any{"chain": chain})
@@ -1,10 +1,21 @@
 package nftenc
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"iter"
+	"math/rand/v2"
 	"net"
+	"strings"
 	"testing"
 
+	exprenc "github.com/Morwran/nft-go/internal/expr-encoders"
+	"github.com/Morwran/nft-go/pkg/nftast"
+	"github.com/Morwran/nft-go/pkg/ruleset"
+
 	"github.com/google/nftables"
 	"github.com/google/nftables/expr"
 	"github.com/google/nftables/userdata"
@@ -204,6 +215,479 @@ func (sui *encodersTestSuite) Test_TableEncode() {
 	}
 }
 
+func (sui *encodersTestSuite) Test_SetElemsEncode() {
+	ports := []nftables.SetElement{
+		{Key: []byte{0x01, 0xbb}},
+		{Key: []byte{0x00, 0x16}},
+		{Key: []byte{0xea, 0x60}},
+	}
+	protos := []nftables.SetElement{
+		{Key: []byte{unix.IPPROTO_UDP}},
+		{Key: []byte{unix.IPPROTO_TCP}},
+	}
+	testCases := []struct {
+		name     string
+		setType  nftables.SetDatatype
+		elems    []nftables.SetElement
+		opts     []Option
+		expected string
+	}{
+		{
+			name:     "inet_service numeric by default",
+			setType:  nftables.TypeInetService,
+			elems:    ports,
+			expected: "22, 443, 60000",
+		},
+		{
+			name:     "inet_service names",
+			setType:  nftables.TypeInetService,
+			elems:    ports,
+			opts:     []Option{WithServiceNames()},
+			expected: "ssh, https, 60000",
+		},
+		{
+			name:     "inet_proto names",
+			setType:  nftables.TypeInetProto,
+			elems:    protos,
+			expected: "tcp, udp",
+		},
+		{
+			name:     "inet_proto numeric",
+			setType:  nftables.TypeInetProto,
+			elems:    protos,
+			opts:     []Option{WithNumeric()},
+			expected: "6, 17",
+		},
+	}
+
+	for _, tc := range testCases {
+		sui.Run(tc.name, func() {
+			str, err := NewSetElemsEncoder(tc.setType, tc.elems, tc.opts...).Format()
+			sui.Require().NoError(err)
+			sui.Require().Equal(tc.expected, str)
+		})
+	}
+}
+
+// sipExpr stands for an expression of a patched kernel nft-go knows nothing of
+type sipExpr struct {
+	expr.Any
+	Register uint32
+}
+
+type sipEncoder struct{ sip *sipExpr }
+
+func (e *sipEncoder) EncodeText(c *EncodeContext) (string, error) {
+	c.SetRegister(e.sip.Register, RegValue{
+		HumanExpr: "sip method",
+		Expr:      e.sip,
+		Desc:      func(b []byte) string { return string(b) },
+	})
+	return "", ErrNoIR
+}
+
+func (e *sipEncoder) EncodeJSON(c *EncodeContext) ([]byte, error) {
+	c.SetRegister(e.sip.Register, RegValue{
+		Data:      map[string]any{"sip": map[string]string{"key": "method"}},
+		Expr:      e.sip,
+		JSONValue: func(b []byte) any { return string(b) },
+	})
+	return nil, ErrNoJSON
+}
+
+// quietCounter prints counters without their values, the JSON is left to the
+// built-in encoder
+type quietCounter struct {
+	builtin ExprEncoder
+}
+
+func (e *quietCounter) EncodeText(*EncodeContext) (string, error) { return "counter", nil }
+
+func (e *quietCounter) EncodeJSON(c *EncodeContext) ([]byte, error) { return e.builtin.EncodeJSON(c) }
+
+func (sui *encodersTestSuite) Test_CustomEncoders() {
+	defer ReplaceEncoder(&sipExpr{}, func(e expr.Any) ExprEncoder {
+		return &sipEncoder{sip: e.(*sipExpr)}
+	})()
+	builtin, ok := LookupEncoder(&expr.Counter{})
+	sui.Require().True(ok)
+	restore := ReplaceEncoder(&expr.Counter{}, func(e expr.Any) ExprEncoder {
+		return &quietCounter{builtin: builtin(e)}
+	})
+	defer restore()
+
+	rule := &nftables.Rule{
+		Table:  &nftables.Table{Name: "filter", Family: nftables.TableFamilyIPv4},
+		Chain:  &nftables.Chain{Name: "INPUT"},
+		Handle: 1,
+		Exprs: []expr.Any{
+			&sipExpr{Register: 1},
+			&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte("INVITE")},
+			&expr.Counter{Packets: 1, Bytes: 100},
+			&expr.Verdict{Kind: expr.VerdictDrop},
+		},
+	}
+	sui.Require().Contains(RegisteredTypes(), "*nftenc.sipExpr")
+	enc := NewRuleEncoder(rule)
+	str, err := enc.Format()
+	sui.Require().NoError(err)
+	sui.Require().Equal("sip method INVITE counter drop # handle 1", str)
+	j, err := enc.MarshalJSON()
+	sui.Require().NoError(err)
+	sui.Require().Equal(`{"rule":{"family":"ip","table":"filter","chain":"INPUT","handle":1,"exprs":[{"match":{"op":"==","left":{"sip":{"key":"method"}},"right":"INVITE"}},{"counter":{"bytes":100,"packets":1}},{"drop":null}]}}`, string(j))
+
+	restore()
+	str, err = NewRuleEncoder(rule).Format()
+	sui.Require().NoError(err)
+	sui.Require().Equal("sip method INVITE counter packets 0 bytes 0 drop # handle 1", str)
+}
+
+func (sui *encodersTestSuite) Test_RuleAST() {
+	rule := &nftables.Rule{
+		Table:  &nftables.Table{Name: "filter", Family: nftables.TableFamilyIPv4},
+		Chain:  &nftables.Chain{Name: "INPUT"},
+		Handle: 7,
+		Exprs: []expr.Any{
+			&expr.Meta{Key: expr.MetaKeyL4PROTO, Register: 1},
+			&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{unix.IPPROTO_TCP}},
+			&expr.Payload{DestRegister: 1, Base: expr.PayloadBaseTransportHeader, Offset: 2, Len: 2},
+			&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{0, 22}},
+			&expr.Payload{DestRegister: 1, Base: expr.PayloadBaseNetworkHeader, Offset: 12, Len: 4},
+			&expr.Bitwise{SourceRegister: 1, DestRegister: 1, Len: 4,
+				Mask: []byte{255, 0, 0, 0}, Xor: []byte{0, 0, 0, 0}},
+			&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{10, 0, 0, 0}},
+			&expr.Counter{},
+			&expr.Verdict{Kind: expr.VerdictJump, Chain: "ssh"},
+		},
+	}
+	enc := NewRuleEncoder(rule)
+	ast, err := enc.AST()
+	sui.Require().NoError(err)
+
+	exprs, err := exprenc.NewRuleExprEncoder(rule).Format()
+	sui.Require().NoError(err)
+	sui.Require().Equal(exprs, ast.String())
+	exprsJSON, err := json.Marshal(exprenc.NewRuleExprEncoder(rule))
+	sui.Require().NoError(err)
+	astJSON, err := json.Marshal(ast)
+	sui.Require().NoError(err)
+	sui.Require().Equal(string(exprsJSON), string(astJSON))
+
+	sui.Require().Equal("INPUT", ast.Chain)
+	sui.Require().Equal(uint64(7), ast.Handle)
+	sui.Require().Len(ast.Stmts, 4)
+
+	dport, ok := ast.Match(nftast.Payload{Protocol: "tcp", Field: "dport"})
+	sui.Require().True(ok)
+	sui.Require().True(dport.Contains(22))
+	sui.Require().False(dport.Contains(80))
+	sui.Require().Equal("tcp dport 22", dport.String())
+
+	saddr, ok := ast.Match(nftast.Payload{Protocol: "ip", Field: "saddr"})
+	sui.Require().True(ok)
+	sui.Require().Equal(&nftast.Prefix{Addr: nftast.Value{V: "10.0.0.0"}, Len: 8}, saddr.Right)
+
+	verdict, ok := ast.Verdict()
+	sui.Require().True(ok)
+	sui.Require().Equal("jump", verdict.Kind)
+	sui.Require().Equal("ssh", verdict.Chain)
+
+	counter, ok := ast.Stmts[2].(*nftast.Statement)
+	sui.Require().True(ok)
+	sui.Require().Equal("counter", counter.Name)
+}
+
+func (sui *encodersTestSuite) Test_RulesetEncode() {
+	policy := nftables.ChainPolicyDrop
+	tbl := &nftables.Table{Family: nftables.TableFamilyIPv4, Name: "filter"}
+	blocked := &nftables.Set{Name: "blocked", Table: tbl, KeyType: nftables.TypeIPAddr, Constant: true}
+	input := &nftables.Chain{
+		Name:     "input",
+		Handle:   1,
+		Hooknum:  nftables.ChainHookInput,
+		Priority: nftables.ChainPriorityFilter,
+		Table:    tbl,
+		Type:     nftables.ChainTypeFilter,
+		Policy:   &policy,
+	}
+	ssh := &nftables.Chain{Name: "ssh", Handle: 2, Table: tbl}
+	rs := &ruleset.Ruleset{Tables: []*ruleset.Table{
+		{
+			Table: tbl,
+			Objects: []nftables.Obj{
+				&nftables.CounterObj{Table: tbl, Name: "hits", Packets: 3, Bytes: 180},
+				&nftables.QuotaObj{Table: tbl, Name: "daily", Bytes: 25 * 1024 * 1024, Over: true},
+			},
+			Sets: []*ruleset.Set{
+				{Set: blocked, Elems: []nftables.SetElement{{Key: []byte{10, 0, 0, 1}}}},
+				{Set: &nftables.Set{Name: "__set0", Table: tbl, Anonymous: true, KeyType: nftables.TypeInetService}},
+			},
+			Flowtables: []*nftables.Flowtable{{
+				Table:    tbl,
+				Name:     "ft",
+				Handle:   3,
+				Hooknum:  nftables.FlowtableHookIngress,
+				Priority: nftables.FlowtablePriorityFilter,
+				Devices:  []string{"eth0", "eth1"},
+				Flags:    nftables.FlowtableFlagsCounter,
+			}},
+			Chains: []*ruleset.Chain{
+				{
+					Chain: input,
+					Rules: []*nftables.Rule{{
+						Table:  tbl,
+						Chain:  input,
+						Handle: 4,
+						Exprs: []expr.Any{
+							&expr.Payload{DestRegister: 1, Base: expr.PayloadBaseNetworkHeader, Offset: 12, Len: 4},
+							&expr.Lookup{SourceRegister: 1, SetName: "blocked"},
+							&expr.Verdict{Kind: expr.VerdictDrop},
+						},
+					}},
+				},
+				{Chain: ssh},
+			},
+		},
+		{Table: &nftables.Table{Family: nftables.TableFamilyINet, Name: "nat"}},
+	}}
+
+	enc := NewRulesetEncoder(rs)
+	str, err := enc.Format()
+	sui.Require().NoError(err)
+	sui.Require().Equal(`table ip filter {
+	counter hits {
+		packets 3 bytes 180
+	}
+	quota daily {
+		over 25 mbytes used 0 bytes
+	}
+	set blocked {
+		type ipv4_addr
+		flags constant
+		elements = { 10.0.0.1 }
+	}
+	flowtable ft { # handle 3
+		hook ingress priority filter
+		devices = { eth0, eth1 }
+		counter
+	}
+	chain input { # handle 1
+		type filter hook input priority filter; policy drop;
+		ip saddr @blocked drop # handle 4
+	}
+	chain ssh { # handle 2
+	}
+}
+table inet nat {
+}`, str)
+
+	j, err := enc.MarshalJSON()
+	sui.Require().NoError(err)
+	sui.Require().Equal(`{"nftables":[`+
+		`{"table":{"family":"ip","name":"filter"}},`+
+		`{"counter":{"family":"ip","name":"hits","table":"filter","packets":3,"bytes":180}},`+
+		`{"quota":{"family":"ip","name":"daily","table":"filter","bytes":26214400,"used":0,"inv":true}},`+
+		`{"set":{"family":"ip","name":"blocked","table":"filter","type":"ipv4_addr","flags":["constant"],"elem":["10.0.0.1"]}},`+
+		`{"flowtable":{"family":"ip","name":"ft","table":"filter","handle":3,"hook":"ingress","prio":0,"dev":["eth0","eth1"]}},`+
+		`{"chain":{"family":"ip","table":"filter","name":"input","handle":1,"type":"filter","hook":"input","priority":"filter","policy":"drop"}},`+
+		`{"rule":{"family":"ip","table":"filter","chain":"input","handle":4,"exprs":[{"match":{"op":"==","left":{"payload":{"protocol":"ip","field":"saddr"}},"right":"@blocked"}},{"drop":null}]}},`+
+		`{"chain":{"family":"ip","table":"filter","name":"ssh","handle":2}},`+
+		`{"table":{"family":"inet","name":"nat"}}]}`, string(j))
+}
+
+func (sui *encodersTestSuite) Test_StreamEncode() {
+	tbl := &nftables.Table{Family: nftables.TableFamilyIPv4, Name: "filter"}
+	set := &nftables.Set{Name: "blocklist", Table: tbl, KeyType: nftables.TypeIPAddr, Constant: true}
+	elems := func(keys ...[]byte) iter.Seq2[nftables.SetElement, error] {
+		return func(yield func(nftables.SetElement, error) bool) {
+			for _, k := range keys {
+				if k == nil {
+					yield(nftables.SetElement{}, errors.New("dump failed"))
+					return
+				}
+				if !yield(nftables.SetElement{Key: k}, nil) {
+					return
+				}
+			}
+		}
+	}
+
+	enc := NewTableEncoder(tbl, NewSetEncoder(set,
+		NewSetElemsStreamEncoder(set.KeyType, elems([]byte{10, 0, 0, 2}, []byte{10, 0, 0, 1}))))
+	sb := strings.Builder{}
+	n, err := enc.WriteTo(&sb)
+	sui.Require().NoError(err)
+	sui.Require().Equal(int64(sb.Len()), n)
+	sui.Require().Equal(`table ip filter {
+	set blocklist {
+		type ipv4_addr
+		flags constant
+		elements = { 10.0.0.2, 10.0.0.1 }
+	}
+}`, sb.String())
+	buf := bytes.Buffer{}
+	sui.Require().NoError(enc.EncodeJSON(&buf))
+	sui.Require().Equal(`[{"table":{"family":"ip","name":"filter"}},`+
+		`{"set":{"family":"ip","name":"blocklist","table":"filter","type":"ipv4_addr","flags":["constant"],"elem":["10.0.0.2","10.0.0.1"]}}]`,
+		buf.String())
+
+	enc = NewTableEncoder(tbl, NewSetEncoder(set,
+		NewSetElemsStreamEncoder(set.KeyType, elems([]byte{10, 0, 0, 1}, nil))))
+	_, err = enc.WriteTo(io.Discard)
+	sui.Require().EqualError(err, "dump failed")
+	sui.Require().EqualError(enc.EncodeJSON(io.Discard), "dump failed")
+}
+
+func (sui *encodersTestSuite) Test_DecodeErrors() {
+	tbl := &nftables.Table{Family: nftables.TableFamilyIPv4, Name: "filter"}
+	chain := &nftables.Chain{Name: "input", Table: tbl, Handle: 1}
+	rule := func(handle uint64, exprs ...expr.Any) *RuleEncoder {
+		return &RuleEncoder{rule: &nftables.Rule{Table: tbl, Chain: chain, Handle: handle, Exprs: exprs}}
+	}
+	rules := []*RuleEncoder{
+		rule(2, &expr.Verdict{Kind: expr.VerdictAccept}),
+		rule(3, &expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{6}}, &expr.Verdict{Kind: expr.VerdictDrop}),
+		rule(4, &expr.Counter{}, &expr.Verdict{Kind: expr.VerdictDrop}),
+	}
+	const cause = "expression 0 (cmp): *expr.Cmp expression has no left hand side"
+
+	_, err := NewTableEncoder(tbl, NewChainEncoder(chain, rules...)).Format()
+	sui.Require().EqualError(err, "table ip filter chain input rule handle 3: "+cause)
+	var ruleErr *RuleError
+	sui.Require().ErrorAs(err, &ruleErr)
+	sui.Require().Equal(uint64(3), ruleErr.Handle)
+	var exprErr *ExprError
+	sui.Require().ErrorAs(err, &exprErr)
+	sui.Require().Equal(0, exprErr.Index)
+
+	var failures DecodeErrors
+	for _, r := range rules {
+		r.opts = []Option{WithLenient(failures.Add)}
+	}
+	enc := NewTableEncoder(tbl, NewChainEncoder(chain, rules...))
+	str, err := enc.Format()
+	sui.Require().NoError(err)
+	sui.Require().Equal(`table ip filter {
+	chain input { # handle 1
+		accept # handle 2
+		<expr name=cmp> drop # handle 3 # nft-go: cannot decode: `+cause+`
+		counter packets 0 bytes 0 drop # handle 4
+	}
+}`, str)
+	b, err := enc.MarshalJSON()
+	sui.Require().NoError(err)
+	sui.Require().Contains(string(b), `"handle":3,"exprs":[{"unknown":{"name":"cmp"}},{"drop":null}]`)
+	sui.Require().Len(failures.Errors(), 1)
+	sui.Require().Equal("# nft-go: 1 rule(s) could not be decoded:\n"+
+		"#\ttable ip filter chain input rule handle 3: "+cause+"\n", failures.Summary())
+}
+
+func (sui *encodersTestSuite) Test_SetElemsOrder() {
+	ifname := func(s string) []byte {
+		b := make([]byte, 16)
+		copy(b, s)
+		return b
+	}
+	testCases := []struct {
+		name    string
+		setType nftables.SetDatatype
+		keys    [][]byte
+		exp     string
+	}{
+		{
+			name:    "ipv6",
+			setType: nftables.TypeIP6Addr,
+			keys: [][]byte{
+				net.ParseIP("fe80::1"), net.ParseIP("2001:db8::2"), net.ParseIP("2001:db8::10"), net.ParseIP("::1"),
+			},
+			exp: "::1, 2001:db8::2, 2001:db8::10, fe80::1",
+		},
+		{
+			name:    "ports",
+			setType: nftables.TypeInetService,
+			keys:    [][]byte{{0x1f, 0x90}, {0, 22}, {0x01, 0xbb}, {0, 80}},
+			exp:     "22, 80, 443, 8080",
+		},
+		{
+			name:    "ether addresses",
+			setType: nftables.TypeEtherAddr,
+			keys:    [][]byte{{0, 0, 0, 0, 1, 0}, {0, 0, 0, 0, 0, 0xff}},
+			exp:     "ff, 100",
+		},
+		{
+			name:    "strings",
+			setType: nftables.TypeIFName,
+			keys:    [][]byte{ifname("eth10"), ifname("eth1"), ifname("br0"), ifname("eth0")},
+			exp:     "br0, eth0, eth1, eth10",
+		},
+		{
+			name:    "host order",
+			setType: nftables.TypeIFIndex,
+			keys:    [][]byte{{0, 1, 0, 0}, {2, 0, 0, 0}, {1, 0, 0, 0}},
+			exp:     "1, 2, 256",
+		},
+		{
+			name:    "concatenation",
+			setType: nftables.MustConcatSetType(nftables.TypeIPAddr, nftables.TypeInetService),
+			keys: [][]byte{
+				{10, 0, 0, 2, 0, 22, 0, 0},
+				{10, 0, 0, 1, 0x01, 0xbb, 0, 0},
+				{10, 0, 0, 1, 0, 80, 0, 0},
+			},
+			exp: "10.0.0.1 . 80, 10.0.0.1 . 443, 10.0.0.2 . 22",
+		},
+	}
+	for _, tc := range testCases {
+		sui.Run(tc.name, func() {
+			elems := make(SetElems, 0, len(tc.keys))
+			for _, k := range tc.keys {
+				elems = append(elems, SetElement{Key: k})
+			}
+			types := nftables.ConcatSetTypeElements(tc.setType)
+			var got []string
+			for _, e := range elems.SortAs(tc.setType) {
+				var fields []string
+				key := e.Key
+				for _, t := range types {
+					w := len(key)
+					if len(types) > 1 {
+						w = int(t.Bytes+3) / 4 * 4
+					}
+					f := SetElemsEncoder{SetType: t, Elems: SetElems{{Key: key[:min(w, int(t.Bytes))]}}, opts: []Option{WithNumeric()}}
+					if len(types) == 1 {
+						f.Elems[0].Key = key
+					}
+					fields = append(fields, f.String())
+					key = key[w:]
+				}
+				got = append(got, strings.Join(fields, " . "))
+			}
+			sui.Require().Equal(tc.exp, strings.Join(got, ", "))
+		})
+	}
+}
+
 func Test_Encoders(t *testing.T) {
 	suite.Run(t, new(encodersTestSuite))
 }
+
+// BenchmarkSetElemsSort orders a set of 1M addresses.
+func BenchmarkSetElemsSort(b *testing.B) {
+	for _, typ := range []nftables.SetDatatype{nftables.TypeIPAddr, nftables.TypeIP6Addr} {
+		b.Run(typ.Name, func(b *testing.B) {
+			rnd := rand.New(rand.NewPCG(1, 2)) //nolint:gosec
+			elems := make(SetElems, 1_000_000)
+			for i := range elems {
+				key := make([]byte, typ.Bytes)
+				for j := range key {
+					key[j] = byte(rnd.UintN(256))
+				}
+				elems[i].Key = key
+			}
+			b.ResetTimer()
+			for range b.N {
+				elems.SortAs(typ)
+			}
+		})
+	}
+}
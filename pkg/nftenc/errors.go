@@ -0,0 +1,94 @@
+package nftenc
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+type (
+	// RuleError is the error of a rule which can not be decoded, it wraps the
+	// *ExprError of the expression at fault.
+	RuleError struct {
+		Family string
+		Table  string
+		Chain  string
+		Handle uint64
+		Err    error
+	}
+
+	// DecodeErrors collects the errors of the rules rendered in lenient mode:
+	//
+	//	var failures DecodeErrors
+	//	enc := NewRulesetEncoder(rs, WithLenient(failures.Add))
+	//
+	// A rule rendered both as text and JSON is reported once.
+	DecodeErrors struct {
+		mu   sync.Mutex
+		errs []error
+		seen map[ruleKey]bool
+	}
+
+	ruleKey struct {
+		family, table, chain string
+		handle               uint64
+	}
+)
+
+func (e *RuleError) Error() string {
+	sb := strings.Builder{}
+	if e.Table != "" {
+		sb.WriteString(fmt.Sprintf("table %s %s ", e.Family, e.Table))
+	}
+	if e.Chain != "" {
+		sb.WriteString(fmt.Sprintf("chain %s ", e.Chain))
+	}
+	sb.WriteString(fmt.Sprintf("rule handle %d: %v", e.Handle, e.Err))
+	return sb.String()
+}
+
+func (e *RuleError) Unwrap() error {
+	return e.Err
+}
+
+// Add records the error unless the rule it is a *RuleError of has been
+// recorded already.
+func (d *DecodeErrors) Add(err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var rerr *RuleError
+	if errors.As(err, &rerr) {
+		key := ruleKey{rerr.Family, rerr.Table, rerr.Chain, rerr.Handle}
+		if d.seen[key] {
+			return
+		}
+		if d.seen == nil {
+			d.seen = make(map[ruleKey]bool)
+		}
+		d.seen[key] = true
+	}
+	d.errs = append(d.errs, err)
+}
+
+// Errors returns the recorded errors in the order they were added.
+func (d *DecodeErrors) Errors() []error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]error(nil), d.errs...)
+}
+
+// Summary lists the recorded errors as nft comments, it is empty when
+// there are none.
+func (d *DecodeErrors) Summary() string {
+	errs := d.Errors()
+	if len(errs) == 0 {
+		return ""
+	}
+	sb := strings.Builder{}
+	sb.WriteString(fmt.Sprintf("# nft-go: %d rule(s) could not be decoded:\n", len(errs)))
+	for _, err := range errs {
+		sb.WriteString(fmt.Sprintf("#\t%v\n", err))
+	}
+	return sb.String()
+}
@@ -5,6 +5,38 @@ import expr "github.com/Morwran/nft-go/internal/expr-encoders"
 type (
 	// Export some internal types
 	VerdictKind = expr.VerdictKind
+	Option      = expr.Option
+
+	// Export the extension API
+	ExprEncoder   = expr.ExprEncoder
+	ExprEncoderFn = expr.ExprEncoderFn
+	EncodeContext = expr.Context
+	RegValue      = expr.RegValue
+
+	// ExprError is the error of an expression which can not be decoded
+	ExprError = expr.ExprError
+)
+
+var (
+	// Export rendering options
+	WithServiceNames = expr.WithServiceNames
+	WithNumeric      = expr.WithNumeric
+	WithNameDB       = expr.WithNameDB
+	WithStrict       = expr.WithStrict
+	WithLenient      = expr.WithLenient
+	WithResolver     = expr.WithResolver
+	WithSets         = expr.WithSets
+
+	// Export the encoder registry
+	RegisterEncoder = expr.RegisterEncoder
+	ReplaceEncoder  = expr.ReplaceEncoder
+	LookupEncoder   = expr.LookupEncoder
+	RegisteredTypes = expr.RegisteredTypes
+
+	// ErrNoIR and ErrNoJSON are returned by encoders of expressions which
+	// only load a register
+	ErrNoIR   = expr.ErrNoIR
+	ErrNoJSON = expr.ErrNoJSON
 )
 
 const (
@@ -0,0 +1,124 @@
+package nftenc
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	nftLib "github.com/google/nftables"
+)
+
+type (
+	// FlowtableEncoder is an encoder for a flowtable.
+	FlowtableEncoder struct {
+		ft *nftLib.Flowtable
+	}
+
+	FlowtableHook     nftLib.FlowtableHook
+	FlowtablePriority nftLib.FlowtablePriority
+)
+
+var _ Encoder = (*FlowtableEncoder)(nil)
+
+// NewFlowtableEncoder creates a new FlowtableEncoder.
+func NewFlowtableEncoder(ft *nftLib.Flowtable) *FlowtableEncoder {
+	return &FlowtableEncoder{ft: ft}
+}
+
+func (enc *FlowtableEncoder) String() string {
+	str, _ := enc.Format()
+	return str
+}
+
+func (enc *FlowtableEncoder) MustString() string {
+	str, err := enc.Format()
+	if err != nil {
+		panic(err)
+	}
+	return str
+}
+
+// Format returns the flowtable in the nft syntax like
+//
+//	flowtable name { # handle 1
+//		hook ingress priority filter
+//		devices = { eth0, eth1 }
+//		flags offload
+//		counter
+//	}
+func (enc *FlowtableEncoder) Format() (string, error) {
+	sb := strings.Builder{}
+	ft := enc.ft
+	sb.WriteString(fmt.Sprintf("flowtable %s { # handle %d\n", ft.Name, ft.Handle))
+	if ft.Hooknum != nil || ft.Priority != nil {
+		sb.WriteString("\t\t")
+		if ft.Hooknum != nil {
+			sb.WriteString(fmt.Sprintf("hook %s ", FlowtableHook(*ft.Hooknum)))
+		}
+		if ft.Priority != nil {
+			sb.WriteString(fmt.Sprintf("priority %s", FlowtablePriority(*ft.Priority)))
+		}
+		sb.WriteByte('\n')
+	}
+	if len(ft.Devices) != 0 {
+		sb.WriteString(fmt.Sprintf("\t\tdevices = { %s }\n", strings.Join(ft.Devices, ", ")))
+	}
+	if ft.Flags&nftLib.FlowtableFlagsHWOffload != 0 {
+		sb.WriteString("\t\tflags offload\n")
+	}
+	if ft.Flags&nftLib.FlowtableFlagsCounter != 0 {
+		sb.WriteString("\t\tcounter\n")
+	}
+	sb.WriteString("\t}")
+	return sb.String(), nil
+}
+
+// MarshalJSON encodes the flowtable to JSON.
+func (enc *FlowtableEncoder) MarshalJSON() ([]byte, error) {
+	ft := enc.ft
+	flowtable := struct {
+		Family string `json:"family"`
+		Name   string `json:"name"`
+		Table  string `json:"table"`
+		Handle uint64 `json:"handle"`
+		Hook   string `json:"hook,omitempty"`
+		Prio   *int32 `json:"prio,omitempty"`
+		Dev    any    `json:"dev,omitempty"`
+	}{
+		Name:   ft.Name,
+		Handle: ft.Handle,
+	}
+	if ft.Table != nil {
+		flowtable.Family, flowtable.Table = TableFamily(ft.Table.Family).String(), ft.Table.Name
+	}
+	if ft.Hooknum != nil {
+		flowtable.Hook = FlowtableHook(*ft.Hooknum).String()
+	}
+	if ft.Priority != nil {
+		prio := int32(*ft.Priority)
+		flowtable.Prio = &prio
+	}
+	switch len(ft.Devices) {
+	case 0:
+	case 1:
+		flowtable.Dev = ft.Devices[0]
+	default:
+		flowtable.Dev = ft.Devices
+	}
+	return json.Marshal(map[string]any{"flowtable": flowtable})
+}
+
+func (h FlowtableHook) String() string {
+	if nftLib.FlowtableHook(h) == *nftLib.FlowtableHookIngress {
+		return "ingress"
+	}
+	return "unknown"
+}
+
+func (p FlowtablePriority) String() string {
+	if nftLib.FlowtablePriority(p) == *nftLib.FlowtablePriorityFilter {
+		return "filter"
+	}
+	return strconv.Itoa(int(p))
+}
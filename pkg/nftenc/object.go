@@ -0,0 +1,104 @@
+package nftenc
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	expr "github.com/Morwran/nft-go/internal/expr-encoders"
+
+	nftLib "github.com/google/nftables"
+)
+
+type (
+	// ObjEncoder is an encoder for a stateful object: a named counter or
+	// quota.
+	ObjEncoder struct {
+		obj nftLib.Obj
+	}
+)
+
+var _ Encoder = (*ObjEncoder)(nil)
+
+// NewObjEncoder creates a new ObjEncoder.
+func NewObjEncoder(o nftLib.Obj) *ObjEncoder {
+	return &ObjEncoder{obj: o}
+}
+
+func (enc *ObjEncoder) String() string {
+	str, _ := enc.Format()
+	return str
+}
+
+func (enc *ObjEncoder) MustString() string {
+	str, err := enc.Format()
+	if err != nil {
+		panic(err)
+	}
+	return str
+}
+
+// Format returns the object in the nft syntax like
+//
+//	counter name {
+//		packets 0 bytes 0
+//	}
+func (enc *ObjEncoder) Format() (string, error) {
+	sb := strings.Builder{}
+	switch o := enc.obj.(type) {
+	case *nftLib.CounterObj:
+		sb.WriteString(fmt.Sprintf("counter %s {\n\t\tpackets %d bytes %d\n\t}", o.Name, o.Packets, o.Bytes))
+	case *nftLib.QuotaObj:
+		sb.WriteString(fmt.Sprintf("quota %s {\n\t\t", o.Name))
+		if o.Over {
+			sb.WriteString("over ")
+		}
+		sb.WriteString(fmt.Sprintf("%s used %s\n\t}", expr.FormatBytes(o.Bytes), expr.FormatBytes(o.Consumed)))
+	default:
+		return "", fmt.Errorf("unsupported object type %T", o)
+	}
+	return sb.String(), nil
+}
+
+// MarshalJSON encodes the object to JSON.
+func (enc *ObjEncoder) MarshalJSON() ([]byte, error) {
+	type head struct {
+		Family string `json:"family"`
+		Name   string `json:"name"`
+		Table  string `json:"table"`
+	}
+	headOf := func(t *nftLib.Table, name string) head {
+		h := head{Name: name}
+		if t != nil {
+			h.Family, h.Table = TableFamily(t.Family).String(), t.Name
+		}
+		return h
+	}
+	switch o := enc.obj.(type) {
+	case *nftLib.CounterObj:
+		counter := struct {
+			head
+			Packets uint64 `json:"packets"`
+			Bytes   uint64 `json:"bytes"`
+		}{
+			head:    headOf(o.Table, o.Name),
+			Packets: o.Packets,
+			Bytes:   o.Bytes,
+		}
+		return json.Marshal(map[string]any{"counter": counter})
+	case *nftLib.QuotaObj:
+		quota := struct {
+			head
+			Bytes uint64 `json:"bytes"`
+			Used  uint64 `json:"used"`
+			Inv   bool   `json:"inv,omitempty"`
+		}{
+			head:  headOf(o.Table, o.Name),
+			Bytes: o.Bytes,
+			Used:  o.Consumed,
+			Inv:   o.Over,
+		}
+		return json.Marshal(map[string]any{"quota": quota})
+	}
+	return nil, fmt.Errorf("unsupported object type %T", enc.obj)
+}
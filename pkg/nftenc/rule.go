@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	exprenc "github.com/Morwran/nft-go/internal/expr-encoders"
+	"github.com/Morwran/nft-go/pkg/nftast"
 
 	nftLib "github.com/google/nftables"
 	userdata "github.com/google/nftables/userdata"
@@ -14,6 +15,7 @@ import (
 type (
 	RuleEncoder struct {
 		rule *nftLib.Rule
+		opts []Option
 	}
 
 	RuleNames struct {
@@ -24,8 +26,8 @@ type (
 var _ Encoder = (*RuleEncoder)(nil)
 
 // NewRuleEncoder creates a new RuleEncoder
-func NewRuleEncoder(r *nftLib.Rule) *RuleEncoder {
-	return &RuleEncoder{rule: r}
+func NewRuleEncoder(r *nftLib.Rule, opts ...Option) *RuleEncoder {
+	return &RuleEncoder{rule: r, opts: opts}
 }
 
 // String returns a human-readable representation of a rule without errors
@@ -48,9 +50,11 @@ func (r *RuleEncoder) MustString() string {
 // It returns an error if the rule is not valid
 func (enc *RuleEncoder) Format() (string, error) {
 	sb := strings.Builder{}
-	expr, err := exprenc.NewRuleExprEncoder(enc.rule).Format()
+	expr, err := exprenc.NewRuleExprEncoder(enc.rule, enc.opts...).Format()
 	if err != nil {
-		return "", err
+		if rerr := enc.fail(err); rerr != nil {
+			return "", rerr
+		}
 	}
 	if expr != "" {
 		sb.WriteString(expr)
@@ -58,28 +62,38 @@ func (enc *RuleEncoder) Format() (string, error) {
 			sb.WriteString(fmt.Sprintf(" comment %q", com))
 		}
 		sb.WriteString(fmt.Sprintf(" # handle %d", enc.rule.Handle))
+		if err != nil {
+			sb.WriteString(fmt.Sprintf(" # nft-go: cannot decode: %v", err))
+		}
 	}
 	return sb.String(), nil
 }
 
-// MarshalJSON encodes the rule to JSON
+// MarshalJSON encodes the rule to JSON, errors are handled like in Format
+// with the expressions at fault encoded as unknown ones in lenient mode.
 func (enc *RuleEncoder) MarshalJSON() ([]byte, error) {
 	rl := enc.rule
 
+	exprs, err := exprenc.NewRuleExprEncoder(rl, enc.opts...).MarshalJSON()
+	if err != nil {
+		if err = enc.fail(err); err != nil {
+			return nil, err
+		}
+	}
 	rule := struct {
-		Family  string `json:"family"`
-		Table   string `json:"table"`
-		Chain   string `json:"chain"`
-		Handle  uint64 `json:"handle"`
-		Comment string `json:"comment,omitempty"`
-		Exprs   any    `json:"exprs"`
+		Family  string          `json:"family"`
+		Table   string          `json:"table"`
+		Chain   string          `json:"chain"`
+		Handle  uint64          `json:"handle"`
+		Comment string          `json:"comment,omitempty"`
+		Exprs   json.RawMessage `json:"exprs"`
 	}{
 		Family:  TableFamily(rl.Table.Family).String(),
 		Table:   rl.Table.Name,
 		Chain:   rl.Chain.Name,
 		Handle:  rl.Handle,
 		Comment: enc.Comment(),
-		Exprs:   exprenc.NewRuleExprEncoder(rl),
+		Exprs:   exprs,
 	}
 	root := map[string]interface{}{
 		"rule": rule,
@@ -88,6 +102,52 @@ func (enc *RuleEncoder) MarshalJSON() ([]byte, error) {
 	return json.Marshal(root)
 }
 
+// fail locates the error of the rule expressions. In lenient mode the error
+// is reported and nil is returned.
+func (enc *RuleEncoder) fail(err error) error {
+	rerr := enc.ruleError(err)
+	report, lenient := exprenc.NewOptions(enc.opts...).Lenient()
+	if !lenient {
+		return rerr
+	}
+	if report != nil {
+		report(rerr)
+	}
+	return nil
+}
+
+func (enc *RuleEncoder) ruleError(err error) *RuleError {
+	rl := enc.rule
+	rerr := &RuleError{Handle: rl.Handle, Err: err}
+	if rl.Table != nil {
+		rerr.Family, rerr.Table = TableFamily(rl.Table.Family).String(), rl.Table.Name
+	}
+	if rl.Chain != nil {
+		rerr.Chain = rl.Chain.Name
+	}
+	return rerr
+}
+
+// AST decompiles the rule into typed statements, Format and MarshalJSON print
+// the same statements. Errors are handled like in Format with the expressions
+// at fault kept as unknown statements in lenient mode.
+func (enc *RuleEncoder) AST() (*nftast.Rule, error) {
+	rl := enc.rule
+	rule, err := exprenc.NewRuleExprEncoder(rl, enc.opts...).AST()
+	if err != nil && (rule == nil || enc.fail(err) != nil) {
+		return nil, enc.ruleError(err)
+	}
+	rule.Handle = rl.Handle
+	if rl.Table != nil {
+		rule.Family = TableFamily(rl.Table.Family).String()
+		rule.Table = rl.Table.Name
+	}
+	if rl.Chain != nil {
+		rule.Chain = rl.Chain.Name
+	}
+	return rule, nil
+}
+
 // Comment - return a rule comment
 func (enc *RuleEncoder) Comment() (com string) {
 	com, _ = userdata.GetString(enc.rule.UserData, userdata.TypeComment)
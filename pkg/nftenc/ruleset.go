@@ -0,0 +1,99 @@
+package nftenc
+
+import (
+	"io"
+
+	"github.com/Morwran/nft-go/pkg/ruleset"
+)
+
+type (
+	// RulesetEncoder is an encoder for a whole ruleset.
+	// It implements the Encoder interface.
+	RulesetEncoder struct {
+		tables []*TableEncoder
+	}
+)
+
+var _ StreamEncoder = (*RulesetEncoder)(nil)
+
+// NewRulesetEncoder creates a new RulesetEncoder. The rules are rendered
+// with the sets of the ruleset unless the options give another resolver,
+// anonymous sets are not listed.
+func NewRulesetEncoder(rs *ruleset.Ruleset, opts ...Option) *RulesetEncoder {
+	opts = append([]Option{WithSets(rs.SetResolver())}, opts...)
+	enc := &RulesetEncoder{tables: make([]*TableEncoder, 0, len(rs.Tables))}
+	for _, t := range rs.Tables {
+		enc.tables = append(enc.tables, NewTableEncoder(t.Table, tableItems(t, opts...)...))
+	}
+	return enc
+}
+
+func tableItems(t *ruleset.Table, opts ...Option) []Encoder {
+	var items []Encoder
+	for _, o := range t.Objects {
+		items = append(items, NewObjEncoder(o))
+	}
+	for _, s := range t.Sets {
+		if s.Anonymous {
+			continue
+		}
+		items = append(items, NewSetEncoder(s.Set, NewSetElemsEncoder(s.KeyType, s.Elems, opts...)))
+	}
+	for _, ft := range t.Flowtables {
+		items = append(items, NewFlowtableEncoder(ft))
+	}
+	for _, c := range t.Chains {
+		rules := make([]*RuleEncoder, 0, len(c.Rules))
+		for _, r := range c.Rules {
+			rules = append(rules, NewRuleEncoder(r, opts...))
+		}
+		items = append(items, NewChainEncoder(c.Chain, rules...))
+	}
+	return items
+}
+
+func (enc *RulesetEncoder) String() string {
+	str, _ := enc.Format()
+	return str
+}
+
+func (enc *RulesetEncoder) MustString() string {
+	str, err := enc.Format()
+	if err != nil {
+		panic(err)
+	}
+	return str
+}
+
+// Format returns the tables of the ruleset one per line in the nft syntax.
+func (enc *RulesetEncoder) Format() (string, error) {
+	return formatStream(enc)
+}
+
+// WriteTo writes the tables like Format table by table.
+func (enc *RulesetEncoder) WriteTo(w io.Writer) (int64, error) {
+	sw := &streamWriter{w: w}
+	for i, t := range enc.tables {
+		if i > 0 {
+			sw.WriteString("\n")
+		}
+		sw.writeTo(t)
+	}
+	return sw.n, sw.err
+}
+
+// MarshalJSON encodes the ruleset to JSON like `nft -j list ruleset`.
+func (enc *RulesetEncoder) MarshalJSON() ([]byte, error) {
+	return marshalStream(enc)
+}
+
+// EncodeJSON writes the JSON of MarshalJSON table by table.
+func (enc *RulesetEncoder) EncodeJSON(w io.Writer) error {
+	l := &jsonList{streamWriter: &streamWriter{w: w}}
+	l.WriteString(`{"nftables":[`)
+	for _, t := range enc.tables {
+		t.encodeJSONItems(l)
+	}
+	l.WriteString("]}")
+	return l.err
+}
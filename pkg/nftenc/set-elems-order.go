@@ -0,0 +1,124 @@
+package nftenc
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	nftLib "github.com/google/nftables"
+)
+
+type (
+	// keyOrder compares the keys of a set type field by field like nft
+	// orders the elements it lists.
+	keyOrder struct {
+		fields []keyField
+	}
+
+	// sortKey is the prefix of a key with the position of its element.
+	sortKey struct {
+		prefix uint64
+		pos    int
+	}
+
+	// keyField is a field of a key and its comparison, the width of the
+	// only field of a key that is not a concatenation is the key length.
+	keyField struct {
+		width     int
+		compare   func(a, b []byte) int
+		bigEndian bool
+	}
+)
+
+// hostOrderTypes are the types of values in the host byte order
+var hostOrderTypes = map[string]bool{
+	nftLib.TypeIFIndex.Name:  true,
+	nftLib.TypeUID.Name:      true,
+	nftLib.TypeGID.Name:      true,
+	nftLib.TypeCGroupV2.Name: true,
+	nftLib.TypeTimeDate.Name: true,
+	nftLib.TypeTimeHour.Name: true,
+	nftLib.TypeTimeDay.Name:  true,
+}
+
+func newKeyOrder(typ nftLib.SetDatatype) keyOrder {
+	// the type of a set from the kernel is a concatenation of one type
+	types := nftLib.ConcatSetTypeElements(typ)
+	o := keyOrder{fields: make([]keyField, 0, len(types))}
+	for _, t := range types {
+		f := keyField{compare: compareBigEndian, bigEndian: true}
+		switch {
+		case t.Name == nftLib.TypeString.Name, t.Name == nftLib.TypeIFName.Name:
+			f.compare, f.bigEndian = compareString, false
+		case hostOrderTypes[t.Name]:
+			f.compare, f.bigEndian = compareHostOrder, false
+		}
+		if len(types) > 1 {
+			const regSize = 4
+			// concatenated fields are padded to the register size
+			f.width = int(t.Bytes+regSize-1) / regSize * regSize
+		}
+		o.fields = append(o.fields, f)
+	}
+	return o
+}
+
+func (o keyOrder) compare(a, b []byte) int {
+	if len(o.fields) == 1 {
+		return o.fields[0].compare(a, b)
+	}
+	for _, f := range o.fields {
+		if f.width == 0 || len(a) < f.width || len(b) < f.width {
+			// the layout is unknown
+			return bytes.Compare(a, b)
+		}
+		if c := f.compare(a[:f.width], b[:f.width]); c != 0 {
+			return c
+		}
+		a, b = a[f.width:], b[f.width:]
+	}
+	return bytes.Compare(a, b)
+}
+
+// prefix returns a number ordered like the network order keys by their
+// significant length and leading bytes, it is 0 for other keys.
+func (o keyOrder) prefix(key []byte) uint64 {
+	if len(o.fields) != 1 || o.fields[0].width != 0 || !o.fields[0].bigEndian {
+		return 0
+	}
+	key = bytes.TrimLeft(key, "\x00")
+	var b [8]byte
+	b[0] = byte(min(len(key), 0xff))
+	copy(b[1:], key)
+	return binary.BigEndian.Uint64(b[:])
+}
+
+// compareBigEndian compares numbers, addresses and other values in the
+// network byte order.
+func compareBigEndian(a, b []byte) int {
+	if len(a) != len(b) {
+		a, b = bytes.TrimLeft(a, "\x00"), bytes.TrimLeft(b, "\x00")
+		if len(a) != len(b) {
+			return len(a) - len(b)
+		}
+	}
+	return bytes.Compare(a, b)
+}
+
+// compareHostOrder compares numbers in the little endian host byte order.
+func compareHostOrder(a, b []byte) int {
+	a, b = bytes.TrimRight(a, "\x00"), bytes.TrimRight(b, "\x00")
+	if len(a) != len(b) {
+		return len(a) - len(b)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		if a[i] != b[i] {
+			return int(a[i]) - int(b[i])
+		}
+	}
+	return 0
+}
+
+// compareString compares zero padded strings.
+func compareString(a, b []byte) int {
+	return bytes.Compare(bytes.TrimRight(a, "\x00"), bytes.TrimRight(b, "\x00"))
+}
@@ -1,13 +1,16 @@
 package nftenc
 
 import (
+	"cmp"
 	"encoding/json"
 	"fmt"
-	"strings"
+	"io"
+	"iter"
+	"slices"
 
 	rb "github.com/Morwran/nft-go/internal/bytes"
+	exprenc "github.com/Morwran/nft-go/internal/expr-encoders"
 
-	linq "github.com/ahmetb/go-linq/v3"
 	nftLib "github.com/google/nftables"
 )
 
@@ -15,15 +18,17 @@ type (
 	SetElemsEncoder struct {
 		SetType nftLib.SetDatatype
 		Elems   SetElems
+		opts    []Option
+		stream  iter.Seq2[nftLib.SetElement, error]
 	}
 
 	SetElement nftLib.SetElement
 	SetElems   []SetElement
 )
 
-var _ Encoder = (*SetElemsEncoder)(nil)
+var _ StreamEncoder = (*SetElemsEncoder)(nil)
 
-func NewSetElemsEncoder(setType nftLib.SetDatatype, elems []nftLib.SetElement) *SetElemsEncoder {
+func NewSetElemsEncoder(setType nftLib.SetDatatype, elems []nftLib.SetElement, opts ...Option) *SetElemsEncoder {
 	s := make(SetElems, len(elems))
 	for i := range elems {
 		s[i] = SetElement(elems[i])
@@ -31,6 +36,18 @@ func NewSetElemsEncoder(setType nftLib.SetDatatype, elems []nftLib.SetElement) *
 	return &SetElemsEncoder{
 		SetType: setType,
 		Elems:   s,
+		opts:    opts,
+	}
+}
+
+// NewSetElemsStreamEncoder creates a SetElemsEncoder of the elements the
+// iterator yields like ruleset.SetElements does from the kernel dump. The
+// elements are written in the order they come without being held in memory.
+func NewSetElemsStreamEncoder(setType nftLib.SetDatatype, elems iter.Seq2[nftLib.SetElement, error], opts ...Option) *SetElemsEncoder {
+	return &SetElemsEncoder{
+		SetType: setType,
+		opts:    opts,
+		stream:  elems,
 	}
 }
 
@@ -48,19 +65,75 @@ func (enc *SetElemsEncoder) MustString() string {
 }
 
 func (enc *SetElemsEncoder) Format() (string, error) {
-	elems := enc.Elems.ToStringListOrderedByType(enc.SetType)
-	return strings.Join(elems, ", "), nil
+	return formatStream(enc)
 }
 
 func (enc *SetElemsEncoder) MarshalJSON() ([]byte, error) {
-	elems := enc.Elems.ToStringListOrderedByType(enc.SetType)
+	return marshalStream(enc)
+}
+
+// WriteTo writes the elements separated by commas.
+func (enc *SetElemsEncoder) WriteTo(w io.Writer) (int64, error) {
+	sw := &streamWriter{w: w}
+	first := true
+	err := enc.each(func(elem string) error {
+		if !first {
+			sw.WriteString(", ")
+		}
+		first = false
+		sw.WriteString(elem)
+		return sw.err
+	})
+	return sw.n, err
+}
 
-	return json.Marshal(elems)
+// EncodeJSON writes the elements as a JSON array of strings.
+func (enc *SetElemsEncoder) EncodeJSON(w io.Writer) error {
+	l := &jsonList{streamWriter: &streamWriter{w: w}}
+	l.WriteString("[")
+	err := enc.each(func(elem string) error {
+		l.add(json.Marshal(elem))
+		return l.err
+	})
+	if err != nil {
+		return err
+	}
+	l.WriteString("]")
+	return l.err
 }
 
-func (s SetElems) ToStringListOrderedByType(setType nftLib.SetDatatype) []string {
+// each calls fn for every element but the interval ends in the order of the
+// type or the stream.
+func (enc *SetElemsEncoder) each(fn func(elem string) error) error {
+	formatter := getElementFormatter(enc.SetType, exprenc.NewOptions(enc.opts...))
+	if enc.stream == nil {
+		for _, elem := range enc.Elems.SortAs(enc.SetType) {
+			if elem.IntervalEnd {
+				continue
+			}
+			if err := fn(formatter(elem).String()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for elem, err := range enc.stream {
+		if err != nil {
+			return err
+		}
+		if elem.IntervalEnd {
+			continue
+		}
+		if err = fn(formatter(SetElement(elem)).String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s SetElems) ToStringListOrderedByType(setType nftLib.SetDatatype, opts ...Option) []string {
 	elems := make([]string, 0, len(s))
-	formatter := getElementFormatter(setType)
+	formatter := getElementFormatter(setType, exprenc.NewOptions(opts...))
 	for _, elem := range s.SortAs(setType) {
 		if elem.IntervalEnd {
 			continue
@@ -70,26 +143,35 @@ func (s SetElems) ToStringListOrderedByType(setType nftLib.SetDatatype) []string
 	return elems
 }
 
+// SortAs returns the elements ordered by their keys of the set type: numbers
+// and addresses by value, strings lexically and concatenations field by
+// field.
 func (s SetElems) SortAs(typ nftLib.SetDatatype) SetElems {
-	sortedElements := make(SetElems, 0, len(s))
-	linq.From(s).
-		OrderBy(func(i interface{}) interface{} {
-			elem := i.(SetElement)
-			switch typ {
-			case nftLib.TypeVerdict,
-				nftLib.TypeString,
-				nftLib.TypeIFName:
-				return 0
-			}
-			return rb.RawBytes(elem.Key).Uint64()
-		}).
-		ToSlice(&sortedElements)
-
-	return sortedElements
+	order := newKeyOrder(typ)
+	// the elements are large to swap and their keys are scattered in memory,
+	// so the positions are sorted by the leading bytes of the keys first
+	keys := make([]sortKey, len(s))
+	for i := range s {
+		keys[i] = sortKey{prefix: order.prefix(s[i].Key), pos: i}
+	}
+	slices.SortFunc(keys, func(a, b sortKey) int {
+		if c := cmp.Compare(a.prefix, b.prefix); c != 0 {
+			return c
+		}
+		return order.compare(s[a.pos].Key, s[b.pos].Key)
+	})
+	sorted := make(SetElems, len(s))
+	for i := range keys {
+		sorted[i] = s[keys[i].pos]
+	}
+	return sorted
 }
 
-func getElementFormatter(typ nftLib.SetDatatype) func(elem SetElement) fmt.Stringer {
+func getElementFormatter(typ nftLib.SetDatatype, opts exprenc.Options) func(elem SetElement) fmt.Stringer {
 	return func(elem SetElement) fmt.Stringer {
+		if str, ok := opts.FormatValue(typ, elem.Key); ok {
+			return SetElementTypeName(str)
+		}
 		switch typ {
 		case nftLib.TypeVerdict,
 			nftLib.TypeString,
@@ -121,6 +203,7 @@ type (
 	SetElementTypeIp     SetElement
 	SetElementTypeHex    SetElement
 	SetElementTypeDec    SetElement
+	SetElementTypeName   string
 )
 
 func (s SetElementTypeString) String() string {
@@ -139,3 +222,7 @@ func (s SetElementTypeHex) String() string {
 func (s SetElementTypeDec) String() string {
 	return rb.RawBytes(s.Key).Text(baseDec)
 }
+
+func (s SetElementTypeName) String() string {
+	return string(s)
+}
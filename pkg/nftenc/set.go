@@ -3,6 +3,7 @@ package nftenc
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"strings"
 
 	nftLib "github.com/google/nftables"
@@ -15,7 +16,7 @@ type (
 	}
 )
 
-var _ Encoder = (*SetEncoder)(nil)
+var _ StreamEncoder = (*SetEncoder)(nil)
 
 func NewSetEncoder(s *nftLib.Set, elemsEnc *SetElemsEncoder) *SetEncoder {
 	return &SetEncoder{set: s, elemsEnc: elemsEnc}
@@ -33,48 +34,59 @@ func (enc *SetEncoder) MustString() string {
 	return str
 }
 func (enc *SetEncoder) Format() (string, error) {
-	sb := strings.Builder{}
+	return formatStream(enc)
+}
+
+// WriteTo writes the set in the nft syntax, the elements are streamed.
+func (enc *SetEncoder) WriteTo(w io.Writer) (int64, error) {
 	s := enc.set
 	if s.Anonymous {
-		return "", ErrSetIsAnonymous
+		return 0, ErrSetIsAnonymous
 	}
-
-	sb.WriteString(fmt.Sprintf("set %s {\n\t\ttype %s\n\t\tflags %s\n\t\telements = { ",
+	sw := &streamWriter{w: w}
+	sw.WriteString(fmt.Sprintf("set %s {\n\t\ttype %s\n\t\tflags %s\n\t\telements = { ",
 		s.Name, s.KeyType.Name, strings.Join(enc.FlagsToStringLinst(), ",")))
-
-	elems, err := enc.elemsEnc.Format()
-	if err != nil {
-		return "", err
-	}
-	sb.WriteString(elems)
-
-	sb.WriteString(" }\n\t}")
-	return sb.String(), nil
+	sw.writeTo(enc.elemsEnc)
+	sw.WriteString(" }\n\t}")
+	return sw.n, sw.err
 }
 
 func (enc *SetEncoder) MarshalJSON() ([]byte, error) {
+	return marshalStream(enc)
+}
+
+// EncodeJSON writes the set JSON object, the elements are streamed.
+func (enc *SetEncoder) EncodeJSON(w io.Writer) error {
+	if enc.set.Anonymous {
+		return ErrSetIsAnonymous
+	}
 	set := struct {
-		Family   string   `json:"family"`
-		Name     string   `json:"name"`
-		Table    string   `json:"table"`
-		Type     string   `json:"type"`
-		Flags    []string `json:"flags"`
-		Elements any      `json:"elem"`
+		Family string   `json:"family"`
+		Name   string   `json:"name"`
+		Table  string   `json:"table"`
+		Type   string   `json:"type"`
+		Flags  []string `json:"flags"`
 	}{
-		Family:   TableFamily(enc.set.Table.Family).String(),
-		Name:     enc.set.Name,
-		Table:    enc.set.Table.Name,
-		Type:     enc.set.KeyType.Name,
-		Flags:    enc.FlagsToStringLinst(),
-		Elements: enc.elemsEnc,
+		Family: TableFamily(enc.set.Table.Family).String(),
+		Name:   enc.set.Name,
+		Table:  enc.set.Table.Name,
+		Type:   enc.set.KeyType.Name,
+		Flags:  enc.FlagsToStringLinst(),
 	}
-	root := map[string]any{
-		"set": set,
+	head, err := json.Marshal(set)
+	if err != nil {
+		return err
 	}
-	if enc.set.Anonymous {
-		return nil, ErrSetIsAnonymous
+	sw := &streamWriter{w: w}
+	sw.WriteString(`{"set":`)
+	// the elements follow the other fields of the object
+	sw.Write(head[:len(head)-1])
+	sw.WriteString(`,"elem":`)
+	if sw.err == nil {
+		sw.err = enc.elemsEnc.EncodeJSON(w)
 	}
-	return json.Marshal(root)
+	sw.WriteString("}}")
+	return sw.err
 }
 
 func (enc *SetEncoder) FlagsToStringLinst() (flags []string) {
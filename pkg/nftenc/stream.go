@@ -0,0 +1,85 @@
+package nftenc
+
+import (
+	"bytes"
+	"io"
+	"strings"
+)
+
+// streamWriter counts the bytes written and keeps the first error so
+// encoders check it once at the end.
+type streamWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (sw *streamWriter) WriteString(s string) {
+	if sw.err != nil {
+		return
+	}
+	n, err := io.WriteString(sw.w, s)
+	sw.n += int64(n)
+	sw.err = err
+}
+
+func (sw *streamWriter) Write(b []byte) {
+	if sw.err != nil {
+		return
+	}
+	n, err := sw.w.Write(b)
+	sw.n += int64(n)
+	sw.err = err
+}
+
+// writeTo writes the text of the stream encoder as a part of a larger one.
+func (sw *streamWriter) writeTo(enc StreamEncoder) {
+	if sw.err != nil {
+		return
+	}
+	n, err := enc.WriteTo(sw.w)
+	sw.n += n
+	sw.err = err
+}
+
+// jsonList writes the items of a JSON array separated by commas.
+type jsonList struct {
+	*streamWriter
+	count int
+}
+
+func (l *jsonList) next() {
+	if l.count > 0 {
+		l.WriteString(",")
+	}
+	l.count++
+}
+
+func (l *jsonList) add(item []byte, err error) {
+	if err != nil {
+		if l.err == nil {
+			l.err = err
+		}
+		return
+	}
+	l.next()
+	l.Write(item)
+}
+
+// formatStream returns the text of the stream encoder.
+func formatStream(enc StreamEncoder) (string, error) {
+	sb := strings.Builder{}
+	if _, err := enc.WriteTo(&sb); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// marshalStream returns the JSON of the stream encoder.
+func marshalStream(enc StreamEncoder) ([]byte, error) {
+	buf := bytes.Buffer{}
+	if err := enc.EncodeJSON(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -3,7 +3,7 @@ package nftenc
 import (
 	"encoding/json"
 	"fmt"
-	"strings"
+	"io"
 
 	nftLib "github.com/google/nftables"
 )
@@ -20,17 +20,24 @@ type (
 	TableFamily nftLib.TableFamily
 )
 
-var _ Encoder = (*TableEncoder)(nil)
+var _ StreamEncoder = (*TableEncoder)(nil)
+
+// itemOrder is the order nft lists the table items in
+var itemOrder = [...]Encoder{
+	(*ObjEncoder)(nil),
+	(*SetEncoder)(nil),
+	(*FlowtableEncoder)(nil),
+	(*ChainEncoder)(nil),
+}
 
 // NewTableEncoder creates a new TableEncoder.
 // It takes a table and a list of items to encode.
-// The items must be of type *RuleEncoder.
-// If an item is not of type *RuleEncoder, it panics.
+// The items must be of type *ObjEncoder, *SetEncoder, *FlowtableEncoder
+// or *ChainEncoder, otherwise it panics.
 func NewTableEncoder(t *nftLib.Table, items ...Encoder) *TableEncoder {
 	for _, item := range items {
 		switch item.(type) {
-		case *SetEncoder:
-		case *ChainEncoder:
+		case *ObjEncoder, *SetEncoder, *FlowtableEncoder, *ChainEncoder:
 		default:
 			panic(fmt.Sprintf("unsupported table item type %T", item))
 		}
@@ -66,34 +73,49 @@ func (enc *TableEncoder) MustString() string {
 //	  ...
 //	}
 func (enc *TableEncoder) Format() (string, error) {
+	return formatStream(enc)
+}
+
+// WriteTo writes the table like Format item by item.
+func (enc *TableEncoder) WriteTo(w io.Writer) (int64, error) {
 	tbl := enc.table
-	sb := strings.Builder{}
+	sw := &streamWriter{w: w}
 	m := enc.ItemsToMap()
-
-	write := func(typ Encoder) error {
-		if group, ok := m[fmt.Sprintf("%T", typ)]; ok {
-			str, err := enc.formatItems(group...)
-			if err != nil {
-				return err
+	sw.WriteString(fmt.Sprintf("table %s %s {\n", TableFamily(tbl.Family), tbl.Name))
+	for _, typ := range itemOrder {
+		for _, item := range m[fmt.Sprintf("%T", typ)] {
+			sw.WriteString("\t")
+			if s, ok := item.(StreamEncoder); ok {
+				sw.writeTo(s)
+			} else if sw.err == nil {
+				var str string
+				str, sw.err = item.Format()
+				sw.WriteString(str)
 			}
-			sb.WriteString(str)
+			sw.WriteString("\n")
 		}
-		return nil
-	}
-
-	sb.WriteString(fmt.Sprintf("table %s %s {\n", TableFamily(tbl.Family), tbl.Name))
-	if err := write((*SetEncoder)(nil)); err != nil {
-		return "", err
-	}
-	if err := write((*ChainEncoder)(nil)); err != nil {
-		return "", err
 	}
-	sb.WriteByte('}')
-	return sb.String(), nil
+	sw.WriteString("}")
+	return sw.n, sw.err
 }
 
 // MarshalJSON encodes the table to JSON.
 func (enc *TableEncoder) MarshalJSON() ([]byte, error) {
+	return marshalStream(enc)
+}
+
+// EncodeJSON writes the JSON array of the table and its items item by item.
+func (enc *TableEncoder) EncodeJSON(w io.Writer) error {
+	l := &jsonList{streamWriter: &streamWriter{w: w}}
+	l.WriteString("[")
+	enc.encodeJSONItems(l)
+	l.WriteString("]")
+	return l.err
+}
+
+// encodeJSONItems adds the table followed by its items and the rules of its
+// chains to the list.
+func (enc *TableEncoder) encodeJSONItems(l *jsonList) {
 	t := struct {
 		Family string `json:"family"`
 		Name   string `json:"name"`
@@ -101,44 +123,26 @@ func (enc *TableEncoder) MarshalJSON() ([]byte, error) {
 		Family: TableFamily(enc.table.Family).String(),
 		Name:   enc.table.Name,
 	}
-
-	tbl, err := json.Marshal(map[string]any{"table": t})
-	if err != nil {
-		return nil, err
-	}
-	out := append([]json.RawMessage(nil), tbl)
+	l.add(json.Marshal(map[string]any{"table": t}))
 	m := enc.ItemsToMap()
-	encode := func(typ Encoder) error {
-		if group, ok := m[fmt.Sprintf("%T", typ)]; ok {
-			for _, item := range group {
-				itemJson, e := item.MarshalJSON()
-				if e != nil {
-					return e
-				}
-				out = append(out, itemJson)
-				if ch, ok := item.(*ChainEncoder); ok {
-					var ruleJson []byte
-					for _, rule := range ch.rules {
-						ruleJson, e = rule.MarshalJSON()
-						if e != nil {
-							return e
-						}
-						out = append(out, ruleJson)
-					}
+	for _, typ := range itemOrder {
+		for _, item := range m[fmt.Sprintf("%T", typ)] {
+			if l.err != nil {
+				return
+			}
+			if s, ok := item.(StreamEncoder); ok {
+				l.next()
+				l.err = s.EncodeJSON(l.w)
+			} else {
+				l.add(item.MarshalJSON())
+			}
+			if ch, ok := item.(*ChainEncoder); ok {
+				for _, rule := range ch.rules {
+					l.add(rule.MarshalJSON())
 				}
 			}
 		}
-		return nil
-	}
-	if err = encode((*SetEncoder)(nil)); err != nil {
-		return nil, err
 	}
-
-	if err = encode((*ChainEncoder)(nil)); err != nil {
-		return nil, err
-	}
-
-	return json.Marshal(out)
 }
 
 func (enc *TableEncoder) ItemsToMap() map[string][]Encoder {
@@ -153,23 +157,6 @@ func (enc *TableEncoder) ItemsToMap() map[string][]Encoder {
 	return m
 }
 
-func (enc *TableEncoder) formatItems(items ...Encoder) (string, error) {
-	sb := strings.Builder{}
-	for _, item := range items {
-		if item == nil {
-			continue
-		}
-		itemStr, err := item.Format()
-		if err != nil {
-			return "", err
-		}
-		sb.WriteByte('\t')
-		sb.WriteString(itemStr)
-		sb.WriteByte('\n')
-	}
-	return sb.String(), nil
-}
-
 // String returns the string representation of the table family.
 // It returns "unspec" for unspecified family
 func (t TableFamily) String() string {
@@ -0,0 +1,19 @@
+package nftexpr
+
+import "github.com/google/nftables/expr"
+
+// fwd expression attributes
+const (
+	NFTA_FWD_SREG_DEV  = 0x01
+	NFTA_FWD_SREG_ADDR = 0x02
+	NFTA_FWD_NFPROTO   = 0x03
+)
+
+// Fwd is the `fwd to eth1` statement of netdev chains, with a next hop
+// address it is `fwd ip to 192.168.1.1 device eth1`.
+type Fwd struct {
+	expr.Any
+	RegDev  uint32
+	RegAddr uint32
+	NFProto uint32
+}
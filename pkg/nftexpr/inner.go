@@ -0,0 +1,41 @@
+package nftexpr
+
+import "github.com/google/nftables/expr"
+
+// inner expression attributes
+const (
+	NFTA_INNER_NUM     = 0x01
+	NFTA_INNER_TYPE    = 0x02
+	NFTA_INNER_FLAGS   = 0x03
+	NFTA_INNER_HDRSIZE = 0x04
+	NFTA_INNER_EXPR    = 0x05
+)
+
+// InnerType is the tunnel the inner headers are encapsulated in
+type InnerType uint32
+
+const (
+	NFT_INNER_UNSPEC InnerType = iota
+	NFT_INNER_VXLAN
+	NFT_INNER_GENEVE
+)
+
+// inner flags tell which inner headers are parsed
+const (
+	NFT_INNER_HDRSIZE = 1 << iota
+	NFT_INNER_LL
+	NFT_INNER_NH
+	NFT_INNER_TH
+)
+
+// Inner matches the headers encapsulated in a tunnel (kernel 6.2+) like
+// `vxlan ip saddr 10.0.0.1`, Expr is the payload or meta expression applied to
+// the inner packet.
+type Inner struct {
+	expr.Any
+	Num     uint32
+	Type    InnerType
+	Flags   uint32
+	HdrSize uint32
+	Expr    expr.Any
+}
@@ -0,0 +1,18 @@
+package nftexpr
+
+import "github.com/google/nftables/expr"
+
+// last expression attributes
+const (
+	NFTA_LAST_SET   = 0x01
+	NFTA_LAST_MSECS = 0x02
+)
+
+// Last is the `last used 2s` statement, it records when the rule matched last
+type Last struct {
+	expr.Any
+	// Set is false until the rule matches for the first time
+	Set bool
+	// Msecs is the time in milliseconds passed since the last match
+	Msecs uint64
+}
@@ -0,0 +1,30 @@
+package nftexpr
+
+import "github.com/google/nftables/expr"
+
+// osf expression attributes
+const (
+	NFTA_OSF_DREG  = 0x01
+	NFTA_OSF_TTL   = 0x02
+	NFTA_OSF_FLAGS = 0x03
+)
+
+// OsfTTL tells how the ttl of the packet is checked against the fingerprint
+type OsfTTL uint8
+
+const (
+	NF_OSF_TTL_TRUE    OsfTTL = 0
+	NF_OSF_TTL_LESS    OsfTTL = 1
+	NF_OSF_TTL_NOCHECK OsfTTL = 2
+)
+
+// NFT_OSF_F_VERSION makes osf load the version of the os instead of its name
+const NFT_OSF_F_VERSION = 0x01
+
+// Osf is the `osf name` expression loading the passively fingerprinted os
+type Osf struct {
+	expr.Any
+	Register uint32
+	TTL      OsfTTL
+	Flags    uint32
+}
@@ -0,0 +1,30 @@
+package nftexpr
+
+import "github.com/google/nftables/expr"
+
+// bitwise expression attributes the nftables fork does not decode
+const (
+	NFTA_BITWISE_OP   = 0x06
+	NFTA_BITWISE_DATA = 0x07
+)
+
+// BitwiseOp is the operation of the bitwise expression
+type BitwiseOp uint32
+
+const (
+	NFT_BITWISE_BOOL BitwiseOp = iota
+	NFT_BITWISE_LSHIFT
+	NFT_BITWISE_RSHIFT
+)
+
+// Shift is the bitwise expression shifting the 32 bit words of the register
+// like `ct mark >> 16`. Mask and xor operations stay *expr.Bitwise.
+type Shift struct {
+	expr.Any
+	SourceRegister uint32
+	DestRegister   uint32
+	Len            uint32
+	Op             BitwiseOp
+	// Shift is the number of bits the words are shifted by
+	Shift uint32
+}
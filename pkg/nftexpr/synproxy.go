@@ -0,0 +1,29 @@
+package nftexpr
+
+import "github.com/google/nftables/expr"
+
+// synproxy expression attributes
+const (
+	NFTA_SYNPROXY_MSS    = 0x01
+	NFTA_SYNPROXY_WSCALE = 0x02
+	NFTA_SYNPROXY_FLAGS  = 0x03
+)
+
+// SynproxyFlags are the TCP options the synproxy statement sends to the client
+type SynproxyFlags uint32
+
+const (
+	NF_SYNPROXY_OPT_MSS       SynproxyFlags = 0x01
+	NF_SYNPROXY_OPT_WSCALE    SynproxyFlags = 0x02
+	NF_SYNPROXY_OPT_SACK_PERM SynproxyFlags = 0x04
+	NF_SYNPROXY_OPT_TIMESTAMP SynproxyFlags = 0x08
+	NF_SYNPROXY_OPT_ECN       SynproxyFlags = 0x10
+)
+
+// Synproxy is the `synproxy mss 1460 wscale 7 timestamp sack-perm` statement
+type Synproxy struct {
+	expr.Any
+	Mss    uint16
+	Wscale uint8
+	Flags  SynproxyFlags
+}
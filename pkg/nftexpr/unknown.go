@@ -0,0 +1,45 @@
+// Package nftexpr holds the rule expressions which have no counterpart in
+// github.com/google/nftables/expr.
+package nftexpr
+
+import (
+	"github.com/google/nftables/expr"
+	"github.com/mdlayher/netlink"
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+// Unknown is an expression the parser has no type for. It keeps the kernel
+// name of the expression and its raw attributes so that rules containing it
+// can still be listed.
+type Unknown struct {
+	expr.Any
+	// Name is the kernel name of the expression (NFTA_EXPR_NAME)
+	Name string
+	// Data holds the raw attributes of the expression (NFTA_EXPR_DATA)
+	Data []byte
+}
+
+// Marshal returns the expression as the kernel dumped it: its name followed
+// by the raw attributes, if any. Unlike the expressions of expr, Unknown can
+// not be passed to expr.Marshal directly, see Marshal.
+func (e *Unknown) Marshal() ([]byte, error) {
+	attrs := []netlink.Attribute{{Type: unix.NFTA_EXPR_NAME, Data: []byte(e.Name + "\x00")}}
+	if e.Data != nil {
+		attrs = append(attrs, netlink.Attribute{Type: unix.NLA_F_NESTED | unix.NFTA_EXPR_DATA, Data: e.Data})
+	}
+	return netlink.MarshalAttributes(attrs)
+}
+
+// Marshal encodes the expression like expr.Marshal. The expressions of this
+// package embed a nil expr.Any which expr.Marshal would panic on, Unknown is
+// encoded by its own Marshal and the others are reported as an error.
+func Marshal(fam byte, e expr.Any) ([]byte, error) {
+	switch t := e.(type) {
+	case *Unknown:
+		return t.Marshal()
+	case *Fwd, *Inner, *Last, *Osf, *Shift, *Synproxy, *Xfrm:
+		return nil, errors.Errorf("%T expression can not be marshaled", e)
+	}
+	return expr.Marshal(fam, e)
+}
@@ -0,0 +1,42 @@
+package nftexpr
+
+import "github.com/google/nftables/expr"
+
+// xfrm expression attributes
+const (
+	NFTA_XFRM_DREG  = 0x01
+	NFTA_XFRM_KEY   = 0x02
+	NFTA_XFRM_DIR   = 0x03
+	NFTA_XFRM_SPNUM = 0x04
+)
+
+// XfrmKey is the ipsec state field loaded by the xfrm expression
+type XfrmKey uint32
+
+const (
+	NFT_XFRM_KEY_UNSPEC XfrmKey = iota
+	NFT_XFRM_KEY_DADDR_IP4
+	NFT_XFRM_KEY_DADDR_IP6
+	NFT_XFRM_KEY_SADDR_IP4
+	NFT_XFRM_KEY_SADDR_IP6
+	NFT_XFRM_KEY_REQID
+	NFT_XFRM_KEY_SPI
+)
+
+// XfrmDir is the direction of the ipsec policy
+type XfrmDir uint8
+
+const (
+	XFRM_POLICY_IN  XfrmDir = 0
+	XFRM_POLICY_OUT XfrmDir = 1
+)
+
+// Xfrm is the `ipsec in reqid` expression loading a field of the ipsec state
+// the packet was processed with.
+type Xfrm struct {
+	expr.Any
+	Register uint32
+	Key      XfrmKey
+	Dir      XfrmDir
+	Spnum    uint32
+}
@@ -0,0 +1,336 @@
+package nlparser
+
+import (
+	"encoding/binary"
+
+	"github.com/Morwran/nft-go/pkg/nftexpr"
+
+	"github.com/google/nftables/expr"
+	"github.com/mdlayher/netlink"
+	"golang.org/x/sys/unix"
+)
+
+type exprDecoder func(fam byte, data []byte) (expr.Any, error)
+
+// exprDecoders decode the expressions the nftables fork can marshal but not
+// unmarshal (byteorder, rt) or unmarshals wrong (fib flags, tproxy family,
+// dup device register, bitwise shifts), and the ones it has no type for.
+var exprDecoders = map[string]exprDecoder{
+	"bitwise":   decodeBitwise,
+	"byteorder": decodeByteorder,
+	"rt":        decodeRt,
+	"fib":       decodeFib,
+	"tproxy":    decodeTProxy,
+	"dup":       decodeDup,
+	"synproxy":  decodeSynproxy,
+	"last":      decodeLast,
+	"xfrm":      decodeXfrm,
+	"osf":       decodeOsf,
+	"fwd":       decodeFwd,
+}
+
+func init() {
+	// inner decodes the nested expression with exprsFromBytes which refers
+	// to exprDecoders itself
+	exprDecoders["inner"] = decodeInner
+}
+
+func newExprDecoder(data []byte) (*netlink.AttributeDecoder, error) {
+	ad, err := netlink.NewAttributeDecoder(data)
+	if err != nil {
+		return nil, err
+	}
+	ad.ByteOrder = binary.BigEndian
+	return ad, nil
+}
+
+// decodeBitwise decodes mask/xor operations into *expr.Bitwise and shifts
+// into *nftexpr.Shift.
+func decodeBitwise(_ byte, data []byte) (expr.Any, error) {
+	ad, err := newExprDecoder(data)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		bw    expr.Bitwise
+		op    nftexpr.BitwiseOp
+		shift uint32
+	)
+	value := func(dst *[]byte) func(*netlink.AttributeDecoder) error {
+		return func(nad *netlink.AttributeDecoder) error {
+			for nad.Next() {
+				if nad.Type() == unix.NFTA_DATA_VALUE {
+					*dst = nad.Bytes()
+				}
+			}
+			return nil
+		}
+	}
+	for ad.Next() {
+		switch ad.Type() {
+		case unix.NFTA_BITWISE_SREG:
+			bw.SourceRegister = ad.Uint32()
+		case unix.NFTA_BITWISE_DREG:
+			bw.DestRegister = ad.Uint32()
+		case unix.NFTA_BITWISE_LEN:
+			bw.Len = ad.Uint32()
+		case unix.NFTA_BITWISE_MASK:
+			ad.Nested(value(&bw.Mask))
+		case unix.NFTA_BITWISE_XOR:
+			ad.Nested(value(&bw.Xor))
+		case nftexpr.NFTA_BITWISE_OP:
+			op = nftexpr.BitwiseOp(ad.Uint32())
+		case nftexpr.NFTA_BITWISE_DATA:
+			var b []byte
+			ad.Nested(value(&b))
+			if len(b) == 4 { //nolint:mnd
+				// the shift is a host order u32
+				shift = binary.NativeEndian.Uint32(b)
+			}
+		}
+	}
+	if op == nftexpr.NFT_BITWISE_BOOL {
+		return &bw, ad.Err()
+	}
+	return &nftexpr.Shift{
+		SourceRegister: bw.SourceRegister,
+		DestRegister:   bw.DestRegister,
+		Len:            bw.Len,
+		Op:             op,
+		Shift:          shift,
+	}, ad.Err()
+}
+
+func decodeByteorder(_ byte, data []byte) (expr.Any, error) {
+	ad, err := newExprDecoder(data)
+	if err != nil {
+		return nil, err
+	}
+	e := &expr.Byteorder{}
+	for ad.Next() {
+		switch ad.Type() {
+		case unix.NFTA_BYTEORDER_SREG:
+			e.SourceRegister = ad.Uint32()
+		case unix.NFTA_BYTEORDER_DREG:
+			e.DestRegister = ad.Uint32()
+		case unix.NFTA_BYTEORDER_OP:
+			e.Op = expr.ByteorderOp(ad.Uint32())
+		case unix.NFTA_BYTEORDER_LEN:
+			e.Len = ad.Uint32()
+		case unix.NFTA_BYTEORDER_SIZE:
+			e.Size = ad.Uint32()
+		}
+	}
+	return e, ad.Err()
+}
+
+func decodeRt(_ byte, data []byte) (expr.Any, error) {
+	ad, err := newExprDecoder(data)
+	if err != nil {
+		return nil, err
+	}
+	e := &expr.Rt{}
+	for ad.Next() {
+		switch ad.Type() {
+		case unix.NFTA_RT_KEY:
+			e.Key = expr.RtKey(ad.Uint32())
+		case unix.NFTA_RT_DREG:
+			e.Register = ad.Uint32()
+		}
+	}
+	return e, ad.Err()
+}
+
+func decodeFib(_ byte, data []byte) (expr.Any, error) {
+	ad, err := newExprDecoder(data)
+	if err != nil {
+		return nil, err
+	}
+	e := &expr.Fib{}
+	for ad.Next() {
+		switch ad.Type() {
+		case unix.NFTA_FIB_DREG:
+			e.Register = ad.Uint32()
+		case unix.NFTA_FIB_RESULT:
+			// the result is an enumeration, not a bit set
+			switch ad.Uint32() {
+			case unix.NFT_FIB_RESULT_OIF:
+				e.ResultOIF = true
+			case unix.NFT_FIB_RESULT_OIFNAME:
+				e.ResultOIFNAME = true
+			case unix.NFT_FIB_RESULT_ADDRTYPE:
+				e.ResultADDRTYPE = true
+			}
+		case unix.NFTA_FIB_FLAGS:
+			flags := ad.Uint32()
+			e.FlagSADDR = flags&unix.NFTA_FIB_F_SADDR != 0
+			e.FlagDADDR = flags&unix.NFTA_FIB_F_DADDR != 0
+			e.FlagMARK = flags&unix.NFTA_FIB_F_MARK != 0
+			e.FlagIIF = flags&unix.NFTA_FIB_F_IIF != 0
+			e.FlagOIF = flags&unix.NFTA_FIB_F_OIF != 0
+			e.FlagPRESENT = flags&unix.NFTA_FIB_F_PRESENT != 0
+		}
+	}
+	return e, ad.Err()
+}
+
+func decodeTProxy(fam byte, data []byte) (expr.Any, error) {
+	ad, err := newExprDecoder(data)
+	if err != nil {
+		return nil, err
+	}
+	e := &expr.TProxy{TableFamily: fam}
+	for ad.Next() {
+		switch ad.Type() {
+		case expr.NFTA_TPROXY_FAMILY:
+			e.Family = byte(ad.Uint32())
+		case expr.NFTA_TPROXY_REG_ADDR:
+			e.RegAddr = ad.Uint32()
+		case expr.NFTA_TPROXY_REG_PORT:
+			e.RegPort = ad.Uint32()
+		}
+	}
+	return e, ad.Err()
+}
+
+func decodeDup(_ byte, data []byte) (expr.Any, error) {
+	ad, err := newExprDecoder(data)
+	if err != nil {
+		return nil, err
+	}
+	e := &expr.Dup{}
+	for ad.Next() {
+		switch ad.Type() {
+		case unix.NFTA_DUP_SREG_ADDR:
+			e.RegAddr = ad.Uint32()
+		case unix.NFTA_DUP_SREG_DEV:
+			e.RegDev = ad.Uint32()
+			e.IsRegDevSet = true
+		}
+	}
+	return e, ad.Err()
+}
+
+func decodeSynproxy(_ byte, data []byte) (expr.Any, error) {
+	ad, err := newExprDecoder(data)
+	if err != nil {
+		return nil, err
+	}
+	e := &nftexpr.Synproxy{}
+	for ad.Next() {
+		switch ad.Type() {
+		case nftexpr.NFTA_SYNPROXY_MSS:
+			e.Mss = ad.Uint16()
+		case nftexpr.NFTA_SYNPROXY_WSCALE:
+			e.Wscale = ad.Uint8()
+		case nftexpr.NFTA_SYNPROXY_FLAGS:
+			e.Flags = nftexpr.SynproxyFlags(ad.Uint32())
+		}
+	}
+	return e, ad.Err()
+}
+
+func decodeLast(_ byte, data []byte) (expr.Any, error) {
+	ad, err := newExprDecoder(data)
+	if err != nil {
+		return nil, err
+	}
+	e := &nftexpr.Last{}
+	for ad.Next() {
+		switch ad.Type() {
+		case nftexpr.NFTA_LAST_SET:
+			e.Set = ad.Uint32() != 0
+		case nftexpr.NFTA_LAST_MSECS:
+			e.Msecs = ad.Uint64()
+		}
+	}
+	return e, ad.Err()
+}
+
+func decodeXfrm(_ byte, data []byte) (expr.Any, error) {
+	ad, err := newExprDecoder(data)
+	if err != nil {
+		return nil, err
+	}
+	e := &nftexpr.Xfrm{}
+	for ad.Next() {
+		switch ad.Type() {
+		case nftexpr.NFTA_XFRM_DREG:
+			e.Register = ad.Uint32()
+		case nftexpr.NFTA_XFRM_KEY:
+			e.Key = nftexpr.XfrmKey(ad.Uint32())
+		case nftexpr.NFTA_XFRM_DIR:
+			e.Dir = nftexpr.XfrmDir(ad.Uint8())
+		case nftexpr.NFTA_XFRM_SPNUM:
+			e.Spnum = ad.Uint32()
+		}
+	}
+	return e, ad.Err()
+}
+
+func decodeOsf(_ byte, data []byte) (expr.Any, error) {
+	ad, err := newExprDecoder(data)
+	if err != nil {
+		return nil, err
+	}
+	e := &nftexpr.Osf{}
+	for ad.Next() {
+		switch ad.Type() {
+		case nftexpr.NFTA_OSF_DREG:
+			e.Register = ad.Uint32()
+		case nftexpr.NFTA_OSF_TTL:
+			e.TTL = nftexpr.OsfTTL(ad.Uint8())
+		case nftexpr.NFTA_OSF_FLAGS:
+			e.Flags = ad.Uint32()
+		}
+	}
+	return e, ad.Err()
+}
+
+func decodeFwd(_ byte, data []byte) (expr.Any, error) {
+	ad, err := newExprDecoder(data)
+	if err != nil {
+		return nil, err
+	}
+	e := &nftexpr.Fwd{}
+	for ad.Next() {
+		switch ad.Type() {
+		case nftexpr.NFTA_FWD_SREG_DEV:
+			e.RegDev = ad.Uint32()
+		case nftexpr.NFTA_FWD_SREG_ADDR:
+			e.RegAddr = ad.Uint32()
+		case nftexpr.NFTA_FWD_NFPROTO:
+			e.NFProto = ad.Uint32()
+		}
+	}
+	return e, ad.Err()
+}
+
+func decodeInner(fam byte, data []byte) (expr.Any, error) {
+	ad, err := newExprDecoder(data)
+	if err != nil {
+		return nil, err
+	}
+	e := &nftexpr.Inner{}
+	for ad.Next() {
+		switch ad.Type() {
+		case nftexpr.NFTA_INNER_NUM:
+			e.Num = ad.Uint32()
+		case nftexpr.NFTA_INNER_TYPE:
+			e.Type = nftexpr.InnerType(ad.Uint32())
+		case nftexpr.NFTA_INNER_FLAGS:
+			e.Flags = ad.Uint32()
+		case nftexpr.NFTA_INNER_HDRSIZE:
+			e.HdrSize = ad.Uint32()
+		case nftexpr.NFTA_INNER_EXPR:
+			exprs, err := exprsFromBytes(fam, ad)
+			if err != nil {
+				return nil, err
+			}
+			if len(exprs) > 0 {
+				e.Expr = exprs[0]
+			}
+		}
+	}
+	return e, ad.Err()
+}
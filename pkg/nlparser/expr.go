@@ -3,6 +3,8 @@ package nlparser
 import (
 	"encoding/binary"
 
+	"github.com/Morwran/nft-go/pkg/nftexpr"
+
 	"github.com/google/nftables/expr"
 	"github.com/mdlayher/netlink"
 	"golang.org/x/sys/unix"
@@ -52,7 +54,10 @@ func exprsFromBytes(fam byte, ad *netlink.AttributeDecoder) ([]expr.Any, error)
 			return err
 		}
 		ad.ByteOrder = binary.BigEndian
-		var name string
+		var (
+			name    string
+			hasData bool
+		)
 		for ad.Next() {
 			switch ad.Type() {
 			case unix.NFTA_EXPR_NAME:
@@ -62,6 +67,18 @@ func exprsFromBytes(fam byte, ad *netlink.AttributeDecoder) ([]expr.Any, error)
 					exprs = append(exprs, e)
 				}
 			case unix.NFTA_EXPR_DATA:
+				hasData = true
+				if decode, ok := exprDecoders[name]; ok {
+					ad.Do(func(b []byte) error {
+						e, err := decode(fam, b)
+						if err != nil {
+							return err
+						}
+						exprs = append(exprs, e)
+						return nil
+					})
+					continue
+				}
 				var e expr.Any
 				switch name {
 				case "ct":
@@ -116,11 +133,15 @@ func exprsFromBytes(fam byte, ad *netlink.AttributeDecoder) ([]expr.Any, error)
 					e = &expr.Hash{}
 				case "ndpi":
 					e = &expr.Ndpi{}
+				case "numgen":
+					e = &expr.Numgen{}
+				case "socket":
+					e = &expr.Socket{}
 				}
 				if e == nil {
-					// TODO: introduce an opaque expression type so that users know
-					// something is here.
-					continue // unsupported expression type
+					// keep unsupported expressions so that users know something is here
+					exprs = append(exprs, &nftexpr.Unknown{Name: name, Data: ad.Bytes()})
+					continue
 				}
 
 				ad.Do(func(b []byte) error {
@@ -141,6 +162,10 @@ func exprsFromBytes(fam byte, ad *netlink.AttributeDecoder) ([]expr.Any, error)
 				})
 			}
 		}
+		if _, ok := exprDecoders[name]; !ok && !hasData && name != "" && name != "notrack" {
+			// expressions without attributes are kept as well
+			exprs = append(exprs, &nftexpr.Unknown{Name: name})
+		}
 		return ad.Err()
 	})
 	return exprs, ad.Err()
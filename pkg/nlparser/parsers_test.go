@@ -1,12 +1,18 @@
 package nlparser
 
 import (
+	"encoding/binary"
+	"fmt"
 	"net"
 	"testing"
 
+	exprenc "github.com/Morwran/nft-go/internal/expr-encoders"
+	"github.com/Morwran/nft-go/pkg/nftexpr"
+
 	nftLib "github.com/google/nftables"
 	"github.com/google/nftables/expr"
 	userdata "github.com/google/nftables/userdata"
+	"github.com/google/nftables/xt"
 	"github.com/mdlayher/netlink"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/sys/unix"
@@ -147,7 +153,299 @@ func Test_Parsers(t *testing.T) {
 		case unix.NFT_MSG_NEWSETELEM, unix.NFT_MSG_DELSETELEM:
 			gotElem, err := SetElemsFromMsg(msg)
 			require.NoError(t, err)
-			require.Equal(t, setElems[0], gotElem.Elems[0])
+			require.Equal(t, setElems, gotElem.Elems)
+		}
+	}
+}
+
+func Test_SetsFromMsgs(t *testing.T) {
+	rec := NewRecorder()
+	c, err := rec.Conn()
+	require.NoError(t, err)
+	tbl := &nftLib.Table{Family: nftLib.TableFamilyIPv4, Name: "filter"}
+	c.AddTable(tbl)
+	named := &nftLib.Set{Name: "ports", Table: tbl, KeyType: nftLib.TypeInetService}
+	require.NoError(t, c.AddSet(named, []nftLib.SetElement{{Key: []byte{0, 22}}, {Key: []byte{0, 80}}}))
+	anon := &nftLib.Set{Table: tbl, KeyType: nftLib.TypeIPAddr, Anonymous: true, Constant: true}
+	require.NoError(t, c.AddSet(anon, []nftLib.SetElement{{Key: []byte{10, 0, 0, 1}}}))
+	chain := &nftLib.Chain{Name: "input", Table: tbl}
+	c.AddRule(&nftLib.Rule{Table: tbl, Chain: chain, Exprs: []expr.Any{
+		&expr.Payload{DestRegister: 1, Base: expr.PayloadBaseNetworkHeader, Offset: 16, Len: 4},
+		&expr.Lookup{SourceRegister: 1, SetName: anon.Name, SetID: anon.ID},
+		&expr.Meta{Key: expr.MetaKeyL4PROTO, Register: 1},
+		&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{unix.IPPROTO_TCP}},
+		&expr.Payload{DestRegister: 1, Base: expr.PayloadBaseTransportHeader, Offset: 2, Len: 2},
+		&expr.Lookup{SourceRegister: 1, SetName: named.Name, SetID: named.ID},
+	}})
+	require.NoError(t, c.Flush())
+
+	sets, err := SetsFromMsgs(rec.Requests())
+	require.NoError(t, err)
+	set, elems, ok, err := sets.LookupSet(tbl, "ports", 0)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, named.Name, set.Name)
+	require.Equal(t, []nftLib.SetElement{{Key: []byte{0, 22}}, {Key: []byte{0, 80}}}, elems)
+
+	var rule *nftLib.Rule
+	for _, msg := range rec.Requests() {
+		if uint16(msg.Header.Type)&0xff == unix.NFT_MSG_NEWRULE {
+			rule, err = RuleFromMsg(msg)
+			require.NoError(t, err)
 		}
 	}
+	require.NotNil(t, rule)
+	str, err := exprenc.NewRuleExprEncoder(rule, exprenc.WithSets(sets)).Format()
+	require.NoError(t, err)
+	require.Equal(t, "ip daddr {10.0.0.1} tcp dport @ports", str)
+}
+
+// encodeAttrs encodes the attributes with the fn
+func encodeAttrs(t *testing.T, fn func(ae *netlink.AttributeEncoder)) []byte {
+	ae := netlink.NewAttributeEncoder()
+	ae.ByteOrder = binary.BigEndian
+	fn(ae)
+	b, err := ae.Encode()
+	require.NoError(t, err)
+	return b
+}
+
+// encodeExpr encodes the expression element with the name and attributes
+func encodeExpr(t *testing.T, name string, data []byte) []byte {
+	return encodeAttrs(t, func(ae *netlink.AttributeEncoder) {
+		ae.String(unix.NFTA_EXPR_NAME, name)
+		ae.Bytes(unix.NLA_F_NESTED|unix.NFTA_EXPR_DATA, data)
+	})
+}
+
+// encodeExprList encodes the expressions list of a rule
+func encodeExprList(t *testing.T, elems ...[]byte) []byte {
+	return encodeAttrs(t, func(ae *netlink.AttributeEncoder) {
+		for _, elem := range elems {
+			ae.Bytes(unix.NLA_F_NESTED|unix.NFTA_LIST_ELEM, elem)
+		}
+	})
+}
+
+func Test_UnknownExpr(t *testing.T) {
+	data := encodeAttrs(t, func(ae *netlink.AttributeEncoder) {
+		ae.Uint32(1, 5)
+	})
+	exprs, err := ParseExprMsgFunc(byte(nftLib.TableFamilyIPv4),
+		encodeExprList(t, encodeExpr(t, "foo", data)))
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{&nftexpr.Unknown{Name: "foo", Data: data}}, exprs)
+
+	b, err := nftexpr.Marshal(byte(nftLib.TableFamilyIPv4), &nftexpr.Unknown{Name: "foo", Data: data})
+	require.NoError(t, err)
+	require.Equal(t, encodeExpr(t, "foo", data), b)
+
+	bare := encodeAttrs(t, func(ae *netlink.AttributeEncoder) {
+		ae.String(unix.NFTA_EXPR_NAME, "bar")
+	})
+	exprs, err = ParseExprMsgFunc(byte(nftLib.TableFamilyIPv4), encodeExprList(t, bare))
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{&nftexpr.Unknown{Name: "bar"}}, exprs)
+	b, err = nftexpr.Marshal(byte(nftLib.TableFamilyIPv4), exprs[0].(expr.Any))
+	require.NoError(t, err)
+	require.Equal(t, bare, b)
+
+	_, err = nftexpr.Marshal(byte(nftLib.TableFamilyIPv4), &nftexpr.Last{})
+	require.Error(t, err)
+}
+
+// rawExprCase is an expression encoded by hand, the nftables fork can not
+// marshal the expressions of nftexpr
+type rawExprCase struct {
+	name     string
+	data     []byte
+	expected expr.Any
+}
+
+func nftexprCases(t *testing.T) []rawExprCase {
+	payload := encodeAttrs(t, func(ae *netlink.AttributeEncoder) {
+		ae.Uint32(unix.NFTA_PAYLOAD_DREG, 1)
+		ae.Uint32(unix.NFTA_PAYLOAD_BASE, uint32(expr.PayloadBaseNetworkHeader))
+		ae.Uint32(unix.NFTA_PAYLOAD_OFFSET, 12)
+		ae.Uint32(unix.NFTA_PAYLOAD_LEN, 4)
+	})
+	unknown := encodeAttrs(t, func(ae *netlink.AttributeEncoder) {
+		ae.Uint32(1, 5)
+	})
+	return []rawExprCase{
+		{
+			name: "synproxy",
+			data: encodeAttrs(t, func(ae *netlink.AttributeEncoder) {
+				ae.Uint16(nftexpr.NFTA_SYNPROXY_MSS, 1460)
+				ae.Uint8(nftexpr.NFTA_SYNPROXY_WSCALE, 7)
+				ae.Uint32(nftexpr.NFTA_SYNPROXY_FLAGS, uint32(nftexpr.NF_SYNPROXY_OPT_MSS|nftexpr.NF_SYNPROXY_OPT_WSCALE))
+			}),
+			expected: &nftexpr.Synproxy{Mss: 1460, Wscale: 7, Flags: nftexpr.NF_SYNPROXY_OPT_MSS | nftexpr.NF_SYNPROXY_OPT_WSCALE},
+		},
+		{
+			name: "last",
+			data: encodeAttrs(t, func(ae *netlink.AttributeEncoder) {
+				ae.Uint32(nftexpr.NFTA_LAST_SET, 1)
+				ae.Uint64(nftexpr.NFTA_LAST_MSECS, 2000)
+			}),
+			expected: &nftexpr.Last{Set: true, Msecs: 2000},
+		},
+		{
+			name: "xfrm",
+			data: encodeAttrs(t, func(ae *netlink.AttributeEncoder) {
+				ae.Uint32(nftexpr.NFTA_XFRM_DREG, 1)
+				ae.Uint32(nftexpr.NFTA_XFRM_KEY, uint32(nftexpr.NFT_XFRM_KEY_REQID))
+				ae.Uint8(nftexpr.NFTA_XFRM_DIR, uint8(nftexpr.XFRM_POLICY_OUT))
+				ae.Uint32(nftexpr.NFTA_XFRM_SPNUM, 1)
+			}),
+			expected: &nftexpr.Xfrm{Register: 1, Key: nftexpr.NFT_XFRM_KEY_REQID, Dir: nftexpr.XFRM_POLICY_OUT, Spnum: 1},
+		},
+		{
+			name: "osf",
+			data: encodeAttrs(t, func(ae *netlink.AttributeEncoder) {
+				ae.Uint32(nftexpr.NFTA_OSF_DREG, 1)
+				ae.Uint8(nftexpr.NFTA_OSF_TTL, uint8(nftexpr.NF_OSF_TTL_LESS))
+				ae.Uint32(nftexpr.NFTA_OSF_FLAGS, nftexpr.NFT_OSF_F_VERSION)
+			}),
+			expected: &nftexpr.Osf{Register: 1, TTL: nftexpr.NF_OSF_TTL_LESS, Flags: nftexpr.NFT_OSF_F_VERSION},
+		},
+		{
+			name: "fwd",
+			data: encodeAttrs(t, func(ae *netlink.AttributeEncoder) {
+				ae.Uint32(nftexpr.NFTA_FWD_SREG_DEV, 1)
+				ae.Uint32(nftexpr.NFTA_FWD_SREG_ADDR, 2)
+				ae.Uint32(nftexpr.NFTA_FWD_NFPROTO, unix.NFPROTO_IPV4)
+			}),
+			expected: &nftexpr.Fwd{RegDev: 1, RegAddr: 2, NFProto: unix.NFPROTO_IPV4},
+		},
+		{
+			name: "inner",
+			data: encodeAttrs(t, func(ae *netlink.AttributeEncoder) {
+				ae.Uint32(nftexpr.NFTA_INNER_NUM, 0)
+				ae.Uint32(nftexpr.NFTA_INNER_TYPE, uint32(nftexpr.NFT_INNER_VXLAN))
+				ae.Uint32(nftexpr.NFTA_INNER_FLAGS, nftexpr.NFT_INNER_HDRSIZE|nftexpr.NFT_INNER_NH)
+				ae.Uint32(nftexpr.NFTA_INNER_HDRSIZE, 8)
+				ae.Bytes(unix.NLA_F_NESTED|nftexpr.NFTA_INNER_EXPR, encodeExpr(t, "payload", payload))
+			}),
+			expected: &nftexpr.Inner{
+				Type:    nftexpr.NFT_INNER_VXLAN,
+				Flags:   nftexpr.NFT_INNER_HDRSIZE | nftexpr.NFT_INNER_NH,
+				HdrSize: 8,
+				Expr:    &expr.Payload{DestRegister: 1, Base: expr.PayloadBaseNetworkHeader, Offset: 12, Len: 4},
+			},
+		},
+		{
+			name: "bitwise",
+			data: encodeAttrs(t, func(ae *netlink.AttributeEncoder) {
+				ae.Uint32(unix.NFTA_BITWISE_SREG, 1)
+				ae.Uint32(unix.NFTA_BITWISE_DREG, 1)
+				ae.Uint32(unix.NFTA_BITWISE_LEN, 4)
+				ae.Uint32(nftexpr.NFTA_BITWISE_OP, uint32(nftexpr.NFT_BITWISE_RSHIFT))
+				ae.Nested(nftexpr.NFTA_BITWISE_DATA, func(nae *netlink.AttributeEncoder) error {
+					nae.Bytes(unix.NFTA_DATA_VALUE, binary.NativeEndian.AppendUint32(nil, 16))
+					return nil
+				})
+			}),
+			expected: &nftexpr.Shift{SourceRegister: 1, DestRegister: 1, Len: 4, Op: nftexpr.NFT_BITWISE_RSHIFT, Shift: 16},
+		},
+		{
+			name:     "foo",
+			data:     unknown,
+			expected: &nftexpr.Unknown{Name: "foo", Data: unknown},
+		},
+	}
+}
+
+func Test_NftexprDecoders(t *testing.T) {
+	for _, tc := range nftexprCases(t) {
+		t.Run(tc.name, func(t *testing.T) {
+			exprs, err := ParseExprMsgFunc(byte(nftLib.TableFamilyIPv4),
+				encodeExprList(t, encodeExpr(t, tc.name, tc.data)))
+			require.NoError(t, err)
+			require.Equal(t, []interface{}{tc.expected}, exprs)
+		})
+	}
+}
+
+// Test_ExprRoundTrip parses back an expression of every type the encoders
+// know, an encoder without a decoder fails it.
+func Test_ExprRoundTrip(t *testing.T) {
+	samples := map[string]expr.Any{}
+	for _, e := range []expr.Any{
+		&expr.Bitwise{SourceRegister: 1, DestRegister: 1, Len: 4, Mask: []byte{0xff, 0xff, 0, 0}, Xor: []byte{0, 0, 0, 0}},
+		&expr.Byteorder{SourceRegister: 1, DestRegister: 1, Op: expr.ByteorderHton, Len: 4, Size: 2},
+		&expr.Cmp{Op: expr.CmpOpNeq, Register: 1, Data: []byte{0, 22}},
+		&expr.Connlimit{Count: 10, Flags: 1},
+		&expr.Counter{Bytes: 10, Packets: 1},
+		&expr.Ct{Key: expr.CtKeySTATE, Register: 1},
+		&expr.Dup{RegAddr: 1, RegDev: 2, IsRegDevSet: true},
+		&expr.Dynset{SrcRegKey: 1, SetName: "s", Operation: uint32(unix.NFT_DYNSET_OP_ADD)},
+		&expr.Exthdr{DestRegister: 1, Type: 2, Offset: 2, Len: 2, Op: expr.ExthdrOpTcpopt},
+		&expr.Fib{Register: 1, FlagDADDR: true, ResultADDRTYPE: true},
+		&expr.FlowOffload{Name: "ft"},
+		&expr.Hash{SourceRegister: 1, DestRegister: 2, Length: 4, Modulus: 10, Type: expr.HashTypeJenkins},
+		&expr.Immediate{Register: 1, Data: []byte{10, 0, 0, 1}},
+		&expr.Limit{Type: expr.LimitTypePkts, Rate: 10, Unit: expr.LimitTimeSecond, Burst: 5},
+		&expr.Log{Key: 1 << unix.NFTA_LOG_PREFIX, Data: []byte("pfx")},
+		&expr.Lookup{SourceRegister: 1, SetName: "s", SetID: 1},
+		&expr.Masq{Random: true},
+		&expr.Match{Name: "foo", Rev: 1, Info: &xt.Unknown{1, 2, 3, 4}},
+		&expr.Meta{Key: expr.MetaKeyL4PROTO, Register: 1},
+		&expr.NAT{Type: expr.NATTypeSourceNAT, Family: unix.NFPROTO_IPV4, RegAddrMin: 1},
+		&expr.Notrack{},
+		&expr.Numgen{Register: 1, Modulus: 2, Type: unix.NFT_NG_INCREMENTAL},
+		&expr.Objref{Type: 1, Name: "cnt"},
+		&expr.Payload{DestRegister: 1, Base: expr.PayloadBaseTransportHeader, Offset: 2, Len: 2},
+		&expr.Queue{Num: 1, Total: 1, Flag: expr.QueueFlagBypass},
+		&expr.Quota{Bytes: 100, Over: true},
+		&expr.Range{Op: expr.CmpOpEq, Register: 1, FromData: []byte{0, 1}, ToData: []byte{0, 9}},
+		&expr.Redir{RegisterProtoMin: 1},
+		&expr.Reject{Type: unix.NFT_REJECT_TCP_RST},
+		&expr.Rt{Register: 1, Key: expr.RtTCPMSS},
+		&expr.Socket{Key: expr.SocketKeyTransparent, Register: 1},
+		&expr.Target{Name: "MARK", Rev: 2, Info: &xt.Unknown{1, 2, 3, 4}},
+		&expr.TProxy{Family: unix.NFPROTO_IPV4, TableFamily: unix.NFPROTO_IPV4, RegPort: 1},
+		&expr.Verdict{Kind: expr.VerdictJump, Chain: "next"},
+		&expr.Ndpi{},
+	} {
+		samples[fmt.Sprintf("%T", e)] = e
+	}
+	raw := map[string]rawExprCase{}
+	for _, tc := range nftexprCases(t) {
+		raw[fmt.Sprintf("%T", tc.expected)] = tc
+	}
+
+	for _, typ := range exprenc.RegisteredTypes() {
+		t.Run(typ, func(t *testing.T) {
+			if tc, ok := raw[typ]; ok {
+				exprs, err := ParseExprMsgFunc(byte(nftLib.TableFamilyIPv4),
+					encodeExprList(t, encodeExpr(t, tc.name, tc.data)))
+				require.NoError(t, err)
+				require.Equal(t, []interface{}{tc.expected}, exprs)
+				return
+			}
+			e, ok := samples[typ]
+			require.Truef(t, ok, "no round trip case for %s", typ)
+
+			rec := NewRecorder()
+			c, err := rec.Conn()
+			require.NoError(t, err)
+			tbl := &nftLib.Table{Family: nftLib.TableFamilyIPv4, Name: "filter"}
+			chain := &nftLib.Chain{Name: "input", Table: tbl}
+			c.AddRule(&nftLib.Rule{Table: tbl, Chain: chain, Exprs: []expr.Any{e}})
+			require.NoError(t, c.Flush())
+
+			var parsed bool
+			for _, msg := range rec.Requests() {
+				if uint16(msg.Header.Type)&0xff != unix.NFT_MSG_NEWRULE {
+					continue
+				}
+				rule, err := RuleFromMsg(msg)
+				require.NoError(t, err)
+				require.Equal(t, []expr.Any{e}, rule.Exprs)
+				parsed = true
+			}
+			require.True(t, parsed)
+		})
+	}
 }
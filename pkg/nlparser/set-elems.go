@@ -47,15 +47,15 @@ func SetElemsFromMsg(msg netlink.Message) (*SetElems, error) {
 				return nil, err
 			}
 			ad.ByteOrder = binary.BigEndian
+			// the kernel dumps elements as NFTA_LIST_ELEM but takes any type,
+			// the nftables library numbers the elements of a batch from 1
 			for ad.Next() {
 				var elem setElemDecoder
-				if ad.Type() == unix.NFTA_LIST_ELEM {
-					ad.Do(elem.decode(fam))
-					if ad.Err() != nil {
-						return nil, ad.Err()
-					}
-					set.Elems = append(set.Elems, nftLib.SetElement(elem))
+				ad.Do(elem.decode(fam))
+				if ad.Err() != nil {
+					return nil, ad.Err()
 				}
+				set.Elems = append(set.Elems, nftLib.SetElement(elem))
 			}
 		}
 	}
@@ -0,0 +1,44 @@
+package nlparser
+
+import (
+	"github.com/Morwran/nft-go/pkg/resolver"
+
+	"github.com/mdlayher/netlink"
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+// SetsFromMsgs returns the set model filled from the NFT_MSG_NEWSET and
+// NFT_MSG_NEWSETELEM messages of a batch or a dump, the rules of the same
+// messages are encoded with it like with the kernel sets. Elements of an
+// anonymous set may refer to it by id only. Other messages are skipped.
+func SetsFromMsgs(msgs []netlink.Message) (*resolver.Sets, error) {
+	sets := resolver.NewSets()
+	for _, msg := range msgs {
+		switch uint16(msg.Header.Type) & 0xff { //nolint:mnd
+		case unix.NFT_MSG_NEWSET:
+			set, err := SetFromMsg(msg)
+			if err != nil {
+				return nil, errors.WithMessage(err, "failed to parse set")
+			}
+			sets.Add(set, nil)
+		case unix.NFT_MSG_NEWSETELEM:
+			elems, err := SetElemsFromMsg(msg)
+			if err != nil {
+				return nil, errors.WithMessage(err, "failed to parse set elements")
+			}
+			name := elems.SetName
+			if name == "" {
+				set, _, ok, _ := sets.LookupSet(elems.Table, "", elems.SetId)
+				if !ok {
+					return nil, errors.Errorf("set with id=%d is not found", elems.SetId)
+				}
+				name = set.Name
+			}
+			if err = sets.AddElements(elems.Table, name, elems.Elems); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return sets, nil
+}
@@ -0,0 +1,206 @@
+package protocols
+
+import (
+	"io"
+	"strconv"
+
+	"github.com/Morwran/nft-go/internal/bytes"
+
+	nft "github.com/google/nftables"
+	"github.com/google/nftables/expr"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+type (
+	// ProtoDefs is the content of a file of header definitions in YAML or
+	// JSON:
+	//
+	//	protocols:
+	//	  - name: myproto
+	//	    base: th
+	//	    fields:
+	//	      - {name: version, offset: 64, width: 4}
+	//	      - {name: kind, offset: 68, width: 4, format: enum, values: {data: 1, keepalive: 2}}
+	//	      - {name: tenant, offset: 72, width: 24, format: hex}
+	//	      - {name: origin, offset: 96, width: 32, format: ip}
+	ProtoDefs struct {
+		Protocols []ProtoDef `yaml:"protocols" json:"protocols"`
+	}
+
+	// ProtoDef defines the header of a protocol at a payload base (ll, nh or
+	// th). Id is the number matches like `meta l4proto` select the header by,
+	// a free one counted down from 255 is taken when it is not set.
+	ProtoDef struct {
+		Name   string     `yaml:"name" json:"name"`
+		Base   string     `yaml:"base" json:"base"`
+		Id     *ProtoType `yaml:"id" json:"id"`
+		Fields []FieldDef `yaml:"fields" json:"fields"`
+	}
+
+	// FieldDef defines a field of a header by its offset and width in bits
+	// from the start of the header in the network bit order. Fields either
+	// fit in a byte or span whole bytes. Format is decimal (the default),
+	// hex, ip or enum with the names of the values.
+	FieldDef struct {
+		Name   string            `yaml:"name" json:"name"`
+		Offset uint32            `yaml:"offset" json:"offset"`
+		Width  uint32            `yaml:"width" json:"width"`
+		Format string            `yaml:"format" json:"format"`
+		Values map[string]uint64 `yaml:"values" json:"values"`
+	}
+)
+
+var payloadBases = map[string]expr.PayloadBase{
+	"ll": expr.PayloadBaseLLHeader,
+	"nh": expr.PayloadBaseNetworkHeader,
+	"th": expr.PayloadBaseTransportHeader,
+}
+
+// LoadProtoDefs reads the header definitions from r and registers them with
+// RegisterProtocol. Nothing is registered when a definition is invalid or no
+// id is left for one.
+func LoadProtoDefs(r io.Reader) error {
+	var defs ProtoDefs
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+	if err := dec.Decode(&defs); err != nil && err != io.EOF {
+		return errors.WithMessage(err, "failed to decode protocol definitions")
+	}
+	descs := make([]ProtoDesc, 0, len(defs.Protocols))
+	// the ids claimed by the definitions can not be taken by the ones
+	// counted down from 255
+	claimed := make(map[registeredKey]string, len(defs.Protocols))
+	for _, def := range defs.Protocols {
+		desc, err := def.protoDesc()
+		if err != nil {
+			return errors.WithMessagef(err, "invalid definition of the protocol='%s'", def.Name)
+		}
+		if def.Id != nil {
+			key := registeredKey{base: desc.Base, id: *def.Id}
+			if dup, ok := claimed[key]; ok {
+				return errors.Errorf("protocols '%s' and '%s' have the same id %d", dup, def.Name, *def.Id)
+			}
+			claimed[key] = def.Name
+			desc.Id = *def.Id
+		}
+		descs = append(descs, desc)
+	}
+	for i, def := range defs.Protocols {
+		if def.Id != nil {
+			continue
+		}
+		id, ok := freeProtoType(descs[i].Base, claimed)
+		if !ok {
+			return errors.Errorf("no free id for the protocol='%s'", def.Name)
+		}
+		claimed[registeredKey{base: descs[i].Base, id: id}] = def.Name
+		descs[i].Id = id
+	}
+	for _, desc := range descs {
+		RegisterProtocol(desc.Base, desc.Id, desc)
+	}
+	return nil
+}
+
+func (def ProtoDef) protoDesc() (ProtoDesc, error) {
+	if def.Name == "" {
+		return ProtoDesc{}, errors.New("no name")
+	}
+	base, ok := payloadBases[def.Base]
+	if !ok {
+		return ProtoDesc{}, errors.Errorf("unknown base '%s', expected ll, nh or th", def.Base)
+	}
+	desc := ProtoDesc{Name: def.Name, Base: base, Offsets: make(ProtoHdrHolder, len(def.Fields))}
+	for _, f := range def.Fields {
+		offset, hdr, err := f.hdrDesc()
+		if err != nil {
+			return ProtoDesc{}, errors.WithMessagef(err, "invalid field='%s'", f.Name)
+		}
+		if dup, ok := desc.Offsets[offset]; ok {
+			return ProtoDesc{}, errors.Errorf("fields '%s' and '%s' have the same offset", dup.Name, f.Name)
+		}
+		desc.Offsets[offset] = hdr
+	}
+	return desc, nil
+}
+
+// hdrDesc returns the field descriptor and its offset the way payload
+// expressions refer to it: a field within a byte is loaded with the byte and
+// masked, so its offset counts the bits below the mask like IPHDR_VERSION.
+func (f FieldDef) hdrDesc() (HeaderOffset, ProtoHdrDesc, error) {
+	const bitsPerByte = uint32(BitsPerByte)
+	var shift uint32
+	switch {
+	case f.Name == "":
+		return 0, ProtoHdrDesc{}, errors.New("no name")
+	case f.Width == 0:
+		return 0, ProtoHdrDesc{}, errors.New("zero width")
+	case f.Offset%bitsPerByte == 0 && f.Width%bitsPerByte == 0:
+	case f.Offset%bitsPerByte+f.Width <= bitsPerByte:
+		shift = bitsPerByte - f.Offset%bitsPerByte - f.Width
+	default:
+		return 0, ProtoHdrDesc{}, errors.New("the field neither fits in a byte nor spans whole bytes")
+	}
+	hdr := ProtoHdrDesc{Name: f.Name}
+	switch f.Format {
+	case "", "decimal":
+		hdr.Desc = shifted(shift, bytes.BytesToDecimalString)
+	case "hex":
+		hdr.Desc = shifted(shift, bytes.BytesToHexString)
+	case "ip":
+		switch f.Width {
+		case 32: //nolint:mnd
+			hdr.Datatype = nft.TypeIPAddr
+		case 128: //nolint:mnd
+			hdr.Datatype = nft.TypeIP6Addr
+		default:
+			return 0, ProtoHdrDesc{}, errors.Errorf("an address is 32 or 128 bits wide, not %d", f.Width)
+		}
+		hdr.Desc = bytes.BytesToAddrString
+	case "enum":
+		names := make(map[uint64]string, len(f.Values))
+		for name, v := range f.Values {
+			if dup, ok := names[v]; ok {
+				return 0, ProtoHdrDesc{}, errors.Errorf("names '%s' and '%s' have the same value %d", dup, name, v)
+			}
+			names[v] = name
+		}
+		hdr.Desc = shifted(shift, func(b []byte) string {
+			v := bytes.RawBytes(b).Uint64()
+			if name, ok := names[v]; ok {
+				return name
+			}
+			return strconv.FormatUint(v, bytes.BaseDec)
+		})
+	default:
+		return 0, ProtoHdrDesc{}, errors.Errorf("unknown format '%s', expected decimal, hex, ip or enum", f.Format)
+	}
+	offset := HeaderOffset(f.Offset/bitsPerByte*bitsPerByte + shift)
+	return offset, hdr, nil
+}
+
+// shifted makes the formatter of whole bytes format the bits above the
+// shift of the loaded byte.
+func shifted(shift uint32, desc func(b []byte) string) func(b []byte) string {
+	if shift == 0 {
+		return desc
+	}
+	return func(b []byte) string {
+		return desc([]byte{byte(bytes.RawBytes(b).Uint64() >> shift)})
+	}
+}
+
+// freeProtoType returns the greatest id no protocol of the base is
+// registered with or claimed by.
+func freeProtoType(base expr.PayloadBase, claimed map[registeredKey]string) (ProtoType, bool) {
+	for id := 255; id >= 0; id-- {
+		if _, ok := claimed[registeredKey{base: base, id: ProtoType(id)}]; ok {
+			continue
+		}
+		if _, ok := LookupProtocol(base, ProtoType(id)); !ok {
+			return ProtoType(id), true
+		}
+	}
+	return 0, false
+}
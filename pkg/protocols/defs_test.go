@@ -0,0 +1,135 @@
+package protocols
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/nftables/expr"
+	"github.com/stretchr/testify/suite"
+)
+
+type protoDefsTestSuite struct {
+	suite.Suite
+}
+
+func (sui *protoDefsTestSuite) Test_LoadProtoDefs() {
+	const defs = `
+protocols:
+  - name: tnl
+    base: th
+    fields:
+      - {name: version, offset: 64, width: 4}
+      - {name: kind, offset: 68, width: 4, format: enum, values: {data: 1, keepalive: 2}}
+      - {name: tenant, offset: 72, width: 24, format: hex}
+      - {name: origin, offset: 96, width: 32, format: ip}
+  - name: tnl6
+    base: th
+    id: 255
+    fields:
+      - {name: origin, offset: 0, width: 128, format: ip}
+`
+	sui.Require().NoError(LoadProtoDefs(strings.NewReader(defs)))
+
+	tnl6, ok := LookupProtocol(expr.PayloadBaseTransportHeader, 255)
+	sui.Require().True(ok)
+	sui.Require().Equal("tnl6", tnl6.Name)
+	sui.Require().Equal("origin", tnl6.Offsets[0].Name)
+
+	// the id claimed by tnl6 is not counted down to
+	tnl, ok := LookupProtocol(expr.PayloadBaseTransportHeader, 254)
+	sui.Require().True(ok)
+	sui.Require().Equal("tnl", tnl.Name)
+	sui.Require().Equal(ProtoType(254), tnl.Id)
+	sui.Require().Equal(expr.PayloadBaseTransportHeader, tnl.Base)
+	sui.Require().Len(tnl.Offsets, 4)
+	sui.Require().Equal("version", tnl.Offsets[68].Name)
+	sui.Require().Equal("kind", tnl.Offsets[64].Name)
+	sui.Require().Equal("tenant", tnl.Offsets[72].Name)
+	sui.Require().Equal("origin", tnl.Offsets[96].Name)
+
+	reg, ok := LookupRegistered(expr.PayloadBaseTransportHeader, 72)
+	sui.Require().True(ok)
+	sui.Require().Equal("tnl", reg.Name)
+}
+
+func (sui *protoDefsTestSuite) Test_FieldDesc() {
+	testCases := []struct {
+		name     string
+		field    FieldDef
+		offset   HeaderOffset
+		data     []byte
+		expected string
+	}{
+		{"high nibble", FieldDef{Name: "f", Offset: 8, Width: 4}, 12, []byte{0x52}, "5"},
+		{"low nibble", FieldDef{Name: "f", Offset: 12, Width: 4}, 8, []byte{0x02}, "2"},
+		{"middle bits", FieldDef{Name: "f", Offset: 2, Width: 3, Format: "hex"}, 3, []byte{0x28}, "0x5"},
+		{"whole bytes", FieldDef{Name: "f", Offset: 16, Width: 16}, 16, []byte{0x01, 0x00}, "256"},
+		{"hex bytes", FieldDef{Name: "f", Offset: 24, Width: 24, Format: "hex"}, 24, []byte{0, 0x10, 0xff}, "0x10ff"},
+		{"ipv4", FieldDef{Name: "f", Offset: 32, Width: 32, Format: "ip"}, 32, []byte{10, 0, 0, 1}, "10.0.0.1"},
+		{"enum", FieldDef{Name: "f", Offset: 0, Width: 4, Format: "enum",
+			Values: map[string]uint64{"data": 1}}, 4, []byte{0x10}, "data"},
+		{"enum unnamed value", FieldDef{Name: "f", Offset: 0, Width: 4, Format: "enum",
+			Values: map[string]uint64{"data": 1}}, 4, []byte{0x30}, "3"},
+	}
+	for _, tc := range testCases {
+		sui.Run(tc.name, func() {
+			offset, hdr, err := tc.field.hdrDesc()
+			sui.Require().NoError(err)
+			sui.Require().Equal(tc.offset, offset)
+			sui.Require().Equal(tc.field.Name, hdr.Name)
+			sui.Require().Equal(tc.expected, hdr.Desc(tc.data))
+		})
+	}
+}
+
+func (sui *protoDefsTestSuite) Test_InvalidDefs() {
+	// the valid definition leading every document must not be registered
+	const valid = `{"name":"ok","base":"ll","id":200}`
+	testCases := []struct {
+		name string
+		defs string
+	}{
+		{"not yaml", `protocols: [`},
+		{"unknown key", `{"name":"p","base":"th","fields":[{"name":"f","offset":0,"width":8,"unit":"bits"}]}`},
+		{"no name", `{"base":"th"}`},
+		{"unknown base", `{"name":"p","base":"xx"}`},
+		{"no field name", `{"name":"p","base":"th","fields":[{"offset":0,"width":8}]}`},
+		{"zero width", `{"name":"p","base":"th","fields":[{"name":"f","offset":0,"width":0}]}`},
+		{"byte straddling field", `{"name":"p","base":"th","fields":[{"name":"f","offset":4,"width":8}]}`},
+		{"short address", `{"name":"p","base":"th","fields":[{"name":"f","offset":0,"width":16,"format":"ip"}]}`},
+		{"unknown format", `{"name":"p","base":"th","fields":[{"name":"f","offset":0,"width":8,"format":"oct"}]}`},
+		{"same enum value", `{"name":"p","base":"th","fields":[{"name":"f","offset":0,"width":8,"format":"enum","values":{"a":1,"b":1}}]}`},
+		{"same offset", `{"name":"p","base":"th","fields":[{"name":"f","offset":0,"width":8},{"name":"g","offset":0,"width":16}]}`},
+		{"same id", `{"name":"p","base":"ll","id":200}`},
+	}
+	for _, tc := range testCases {
+		sui.Run(tc.name, func() {
+			defs := tc.defs
+			if strings.HasPrefix(defs, "{") {
+				defs = fmt.Sprintf(`{"protocols":[%s,%s]}`, valid, defs)
+			}
+			sui.Require().Error(LoadProtoDefs(strings.NewReader(defs)))
+			_, ok := LookupProtocol(expr.PayloadBaseLLHeader, 200)
+			sui.Require().False(ok)
+		})
+	}
+
+	sui.Run("no free id", func() {
+		protos := make([]string, 0, 257)
+		protos = append(protos, valid)
+		for i := range 256 {
+			protos = append(protos, fmt.Sprintf(`{"name":"p%d","base":"ll"}`, i))
+		}
+		err := LoadProtoDefs(strings.NewReader(fmt.Sprintf(`{"protocols":[%s]}`, strings.Join(protos, ","))))
+		sui.Require().ErrorContains(err, "no free id")
+		_, ok := LookupProtocol(expr.PayloadBaseLLHeader, 200)
+		sui.Require().False(ok)
+		_, ok = LookupProtocol(expr.PayloadBaseLLHeader, 255)
+		sui.Require().False(ok)
+	})
+}
+
+func Test_ProtoDefs(t *testing.T) {
+	suite.Run(t, new(protoDefsTestSuite))
+}
@@ -0,0 +1,323 @@
+package protocols
+
+import (
+	"fmt"
+
+	"github.com/Morwran/nft-go/internal/bytes"
+
+	"golang.org/x/sys/unix"
+)
+
+/*
+	exthdr operations:
+		0 - IPv6 extension headers (hbh, rt, frag, dst, mh, srh)
+		1 - TCP options
+		2 - IPv4 options
+*/
+
+type (
+	ExthdrOp          uint32
+	ExthdrDescHolder  []ProtoDesc
+	ExthdrLayerHolder map[ExthdrOp]ExthdrDescHolder
+)
+
+const (
+	ExthdrOpIpv6   ExthdrOp = unix.NFT_EXTHDR_OP_IPV6
+	ExthdrOpTcpopt ExthdrOp = unix.NFT_EXTHDR_OP_TCPOPT
+	ExthdrOpIpv4   ExthdrOp = 2 // NFT_EXTHDR_OP_IPV4
+)
+
+// IPv6 extension header types
+const (
+	IPV6_EXTHDR_HOPOPTS  = unix.IPPROTO_HOPOPTS
+	IPV6_EXTHDR_ROUTING  = unix.IPPROTO_ROUTING
+	IPV6_EXTHDR_FRAGMENT = unix.IPPROTO_FRAGMENT
+	IPV6_EXTHDR_DSTOPTS  = unix.IPPROTO_DSTOPTS
+	IPV6_EXTHDR_MH       = unix.IPPROTO_MH
+)
+
+// TCP option kinds
+const (
+	TCPOPT_EOL       = 0
+	TCPOPT_NOP       = 1
+	TCPOPT_MAXSEG    = 2
+	TCPOPT_WINDOW    = 3
+	TCPOPT_SACK_PERM = 4
+	TCPOPT_SACK      = 5
+	TCPOPT_TIMESTAMP = 8
+	TCPOPT_MPTCP     = 30
+)
+
+// IPv4 option types
+const (
+	IPOPT_RR   = 7
+	IPOPT_LSRR = 131
+	IPOPT_SSRR = 137
+	IPOPT_RA   = 148
+)
+
+const (
+	EXTHDR_NEXTHDR   = HeaderOffset(byte(0) * BitsPerByte)
+	EXTHDR_HDRLENGTH = HeaderOffset(byte(1) * BitsPerByte)
+)
+
+const (
+	RTHDR_TYPE    = HeaderOffset(byte(2) * BitsPerByte)
+	RTHDR_SEGLEFT = HeaderOffset(byte(3) * BitsPerByte)
+)
+
+const (
+	SRHHDR_LASTENT = HeaderOffset(byte(4) * BitsPerByte)
+	SRHHDR_FLAGS   = HeaderOffset(byte(5) * BitsPerByte)
+	SRHHDR_TAG     = HeaderOffset(byte(6) * BitsPerByte)
+)
+
+/*
+struct ip6_frag {
+	uint8_t  ip6f_nxt;
+	uint8_t  ip6f_reserved;
+	uint16_t ip6f_offlg; // offset:13, reserved:2, more-fragments:1
+	uint32_t ip6f_ident;
+};
+*/
+
+const (
+	FRAGHDR_RESERVED  = HeaderOffset(byte(1) * BitsPerByte)
+	FRAGHDR_FRAG_OFF  = HeaderOffset(byte(2)*BitsPerByte + 3)
+	FRAGHDR_RESERVED2 = HeaderOffset(byte(3)*BitsPerByte + 1)
+	FRAGHDR_MORE      = HeaderOffset(byte(3) * BitsPerByte)
+	FRAGHDR_ID        = HeaderOffset(byte(4) * BitsPerByte)
+)
+
+const (
+	MHHDR_TYPE     = HeaderOffset(byte(2) * BitsPerByte)
+	MHHDR_RESERVED = HeaderOffset(byte(3) * BitsPerByte)
+	MHHDR_CHECKSUM = HeaderOffset(byte(4) * BitsPerByte)
+)
+
+const (
+	TCPOPT_KIND   = HeaderOffset(byte(0) * BitsPerByte)
+	TCPOPT_LENGTH = HeaderOffset(byte(1) * BitsPerByte)
+
+	TCPOPT_MAXSEG_SIZE     = HeaderOffset(byte(2) * BitsPerByte)
+	TCPOPT_WINDOW_COUNT    = HeaderOffset(byte(2) * BitsPerByte)
+	TCPOPT_SACK_LEFT       = HeaderOffset(byte(2) * BitsPerByte)
+	TCPOPT_SACK_RIGHT      = HeaderOffset(byte(6) * BitsPerByte)
+	TCPOPT_TIMESTAMP_TSVAL = HeaderOffset(byte(2) * BitsPerByte)
+	TCPOPT_TIMESTAMP_TSECR = HeaderOffset(byte(6) * BitsPerByte)
+	TCPOPT_MPTCP_SUBTYPE   = HeaderOffset(byte(2)*BitsPerByte + BitsPerHalfByte)
+)
+
+const (
+	IPOPT_TYPE   = HeaderOffset(byte(0) * BitsPerByte)
+	IPOPT_LENGTH = HeaderOffset(byte(1) * BitsPerByte)
+	IPOPT_PTR    = HeaderOffset(byte(2) * BitsPerByte)
+	IPOPT_ADDR   = HeaderOffset(byte(3) * BitsPerByte)
+	IPOPT_VALUE  = HeaderOffset(byte(2) * BitsPerByte)
+)
+
+var (
+	exthdrNexthdr   = ProtoHdrDesc{Name: "nexthdr", Desc: BytesToProtoString}
+	exthdrHdrlength = ProtoHdrDesc{Name: "hdrlength", Desc: bytes.BytesToDecimalString}
+	tcpoptKind      = ProtoHdrDesc{Name: "kind", Desc: bytes.BytesToDecimalString}
+	tcpoptLength    = ProtoHdrDesc{Name: "length", Desc: bytes.BytesToDecimalString}
+	ipoptType       = ProtoHdrDesc{Name: "type", Desc: bytes.BytesToDecimalString}
+	ipoptLength     = ProtoHdrDesc{Name: "length", Desc: bytes.BytesToDecimalString}
+)
+
+// Exthdrs describes the fields of IPv6 extension headers, TCP options and IPv4
+// options addressed by the exthdr expression. Id holds the header/option type;
+// several descriptors may share an Id (rt and srh), the first one having the
+// requested offset wins.
+var Exthdrs = ExthdrLayerHolder{
+	ExthdrOpIpv6: {
+		{
+			Name: "hbh",
+			Id:   IPV6_EXTHDR_HOPOPTS,
+			Offsets: ProtoHdrHolder{
+				EXTHDR_NEXTHDR:   exthdrNexthdr,
+				EXTHDR_HDRLENGTH: exthdrHdrlength,
+			},
+		},
+		{
+			Name: "rt",
+			Id:   IPV6_EXTHDR_ROUTING,
+			Offsets: ProtoHdrHolder{
+				EXTHDR_NEXTHDR:   exthdrNexthdr,
+				EXTHDR_HDRLENGTH: exthdrHdrlength,
+				RTHDR_TYPE:       ProtoHdrDesc{Name: "type", Desc: bytes.BytesToDecimalString},
+				RTHDR_SEGLEFT:    ProtoHdrDesc{Name: "seg-left", Desc: bytes.BytesToDecimalString},
+			},
+		},
+		{
+			Name: "srh",
+			Id:   IPV6_EXTHDR_ROUTING,
+			Offsets: ProtoHdrHolder{
+				SRHHDR_LASTENT: ProtoHdrDesc{Name: "last-entry", Desc: bytes.BytesToDecimalString},
+				SRHHDR_FLAGS:   ProtoHdrDesc{Name: "flags", Desc: bytes.BytesToDecimalString},
+				SRHHDR_TAG:     ProtoHdrDesc{Name: "tag", Desc: bytes.BytesToDecimalString},
+			},
+		},
+		{
+			Name: "frag",
+			Id:   IPV6_EXTHDR_FRAGMENT,
+			Offsets: ProtoHdrHolder{
+				EXTHDR_NEXTHDR:    exthdrNexthdr,
+				FRAGHDR_RESERVED:  ProtoHdrDesc{Name: "reserved", Desc: bytes.BytesToDecimalString},
+				FRAGHDR_FRAG_OFF:  ProtoHdrDesc{Name: "frag-off", Desc: BytesToShiftedDecimal(3)},       //nolint:mnd
+				FRAGHDR_RESERVED2: ProtoHdrDesc{Name: "reserved2", Desc: BytesToShiftedDecimal(1)},      //nolint:mnd
+				FRAGHDR_MORE:      ProtoHdrDesc{Name: "more-fragments", Desc: BytesToShiftedDecimal(0)}, //nolint:mnd
+				FRAGHDR_ID:        ProtoHdrDesc{Name: "id", Desc: bytes.BytesToDecimalString},
+			},
+		},
+		{
+			Name: "dst",
+			Id:   IPV6_EXTHDR_DSTOPTS,
+			Offsets: ProtoHdrHolder{
+				EXTHDR_NEXTHDR:   exthdrNexthdr,
+				EXTHDR_HDRLENGTH: exthdrHdrlength,
+			},
+		},
+		{
+			Name: "mh",
+			Id:   IPV6_EXTHDR_MH,
+			Offsets: ProtoHdrHolder{
+				EXTHDR_NEXTHDR:   exthdrNexthdr,
+				EXTHDR_HDRLENGTH: exthdrHdrlength,
+				MHHDR_TYPE:       ProtoHdrDesc{Name: "type", Desc: bytes.BytesToDecimalString},
+				MHHDR_RESERVED:   ProtoHdrDesc{Name: "reserved", Desc: bytes.BytesToDecimalString},
+				MHHDR_CHECKSUM:   ProtoHdrDesc{Name: "checksum", Desc: bytes.BytesToDecimalString},
+			},
+		},
+	},
+	ExthdrOpTcpopt: {
+		{Name: "eol", Id: TCPOPT_EOL, Offsets: ProtoHdrHolder{TCPOPT_KIND: tcpoptKind}},
+		{Name: "nop", Id: TCPOPT_NOP, Offsets: ProtoHdrHolder{TCPOPT_KIND: tcpoptKind}},
+		{
+			Name: "maxseg",
+			Id:   TCPOPT_MAXSEG,
+			Offsets: ProtoHdrHolder{
+				TCPOPT_KIND:        tcpoptKind,
+				TCPOPT_LENGTH:      tcpoptLength,
+				TCPOPT_MAXSEG_SIZE: ProtoHdrDesc{Name: "size", Desc: bytes.BytesToDecimalString},
+			},
+		},
+		{
+			Name: "window",
+			Id:   TCPOPT_WINDOW,
+			Offsets: ProtoHdrHolder{
+				TCPOPT_KIND:         tcpoptKind,
+				TCPOPT_LENGTH:       tcpoptLength,
+				TCPOPT_WINDOW_COUNT: ProtoHdrDesc{Name: "count", Desc: bytes.BytesToDecimalString},
+			},
+		},
+		{
+			Name: "sack-perm",
+			Id:   TCPOPT_SACK_PERM,
+			Offsets: ProtoHdrHolder{
+				TCPOPT_KIND:   tcpoptKind,
+				TCPOPT_LENGTH: tcpoptLength,
+			},
+		},
+		{
+			Name: "sack",
+			Id:   TCPOPT_SACK,
+			Offsets: ProtoHdrHolder{
+				TCPOPT_KIND:       tcpoptKind,
+				TCPOPT_LENGTH:     tcpoptLength,
+				TCPOPT_SACK_LEFT:  ProtoHdrDesc{Name: "left", Desc: bytes.BytesToDecimalString},
+				TCPOPT_SACK_RIGHT: ProtoHdrDesc{Name: "right", Desc: bytes.BytesToDecimalString},
+			},
+		},
+		{
+			Name: "timestamp",
+			Id:   TCPOPT_TIMESTAMP,
+			Offsets: ProtoHdrHolder{
+				TCPOPT_KIND:            tcpoptKind,
+				TCPOPT_LENGTH:          tcpoptLength,
+				TCPOPT_TIMESTAMP_TSVAL: ProtoHdrDesc{Name: "tsval", Desc: bytes.BytesToDecimalString},
+				TCPOPT_TIMESTAMP_TSECR: ProtoHdrDesc{Name: "tsecr", Desc: bytes.BytesToDecimalString},
+			},
+		},
+		{
+			Name: "mptcp",
+			Id:   TCPOPT_MPTCP,
+			Offsets: ProtoHdrHolder{
+				TCPOPT_KIND:          tcpoptKind,
+				TCPOPT_LENGTH:        tcpoptLength,
+				TCPOPT_MPTCP_SUBTYPE: ProtoHdrDesc{Name: "subtype", Desc: BytesToShiftedDecimal(BitsPerHalfByte)},
+			},
+		},
+	},
+	ExthdrOpIpv4: {
+		ipoptRoute("lsrr", IPOPT_LSRR),
+		ipoptRoute("rr", IPOPT_RR),
+		ipoptRoute("ssrr", IPOPT_SSRR),
+		{
+			Name: "ra",
+			Id:   IPOPT_RA,
+			Offsets: ProtoHdrHolder{
+				IPOPT_TYPE:   ipoptType,
+				IPOPT_LENGTH: ipoptLength,
+				IPOPT_VALUE:  ProtoHdrDesc{Name: "value", Desc: bytes.BytesToDecimalString},
+			},
+		},
+	},
+}
+
+func ipoptRoute(name string, id ProtoType) ProtoDesc {
+	return ProtoDesc{
+		Name: name,
+		Id:   id,
+		Offsets: ProtoHdrHolder{
+			IPOPT_TYPE:   ipoptType,
+			IPOPT_LENGTH: ipoptLength,
+			IPOPT_PTR:    ProtoHdrDesc{Name: "ptr", Desc: bytes.BytesToDecimalString},
+			IPOPT_ADDR:   ProtoHdrDesc{Name: "addr", Desc: bytes.BytesToAddrString},
+		},
+	}
+}
+
+// Lookup returns the descriptor of the header (option) with the given type.
+func (h ExthdrDescHolder) Lookup(typ uint8) (ProtoDesc, bool) {
+	for _, desc := range h {
+		if desc.Id == ProtoType(typ) {
+			return desc, true
+		}
+	}
+	return ProtoDesc{}, false
+}
+
+// LookupField returns the descriptor of the header (option) with the given type
+// which has a field at the given offset.
+func (h ExthdrDescHolder) LookupField(typ uint8, offset HeaderOffset) (ProtoDesc, ProtoHdrDesc, bool) {
+	for _, desc := range h {
+		if desc.Id != ProtoType(typ) {
+			continue
+		}
+		if field, ok := desc.Offsets[offset]; ok {
+			return desc, field, true
+		}
+	}
+	return ProtoDesc{}, ProtoHdrDesc{}, false
+}
+
+func (op ExthdrOp) String() string {
+	switch op {
+	case ExthdrOpIpv6:
+		return "exthdr"
+	case ExthdrOpTcpopt:
+		return "tcp option"
+	case ExthdrOpIpv4:
+		return "ip option"
+	}
+	return "unknown"
+}
+
+// BytesToShiftedDecimal returns a formatter for bit fields which do not start
+// at the least significant bit of the loaded bytes.
+func BytesToShiftedDecimal(shift byte) func(b []byte) string {
+	return func(b []byte) string {
+		return fmt.Sprintf("%d", bytes.RawBytes(b).Uint64()>>shift)
+	}
+}
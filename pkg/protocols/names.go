@@ -0,0 +1,216 @@
+package protocols
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Morwran/nft-go/internal/bytes"
+)
+
+const (
+	ServicesFile  = "/etc/services"
+	ProtocolsFile = "/etc/protocols"
+)
+
+type (
+	// NameDB resolves transport ports and ip protocol numbers into their
+	// symbolic names. The zero value is not usable, see NewNameDB.
+	NameDB struct {
+		services  map[serviceKey]string
+		protocols map[ProtoType]string
+	}
+
+	serviceKey struct {
+		proto string
+		port  uint16
+	}
+)
+
+// NewNameDB returns a database populated with the built-in names only.
+func NewNameDB() *NameDB {
+	db := &NameDB{
+		services:  make(map[serviceKey]string, len(builtinServices)),
+		protocols: make(map[ProtoType]string),
+	}
+	for k, v := range builtinServices {
+		db.services[k] = v
+	}
+	return db
+}
+
+// SystemNames returns the built-in names extended with the content of the
+// local /etc/services and /etc/protocols. Missing files are ignored.
+// The files are read once, subsequent calls return the same database.
+func SystemNames() *NameDB {
+	systemNamesOnce.Do(func() {
+		systemNames = NewNameDB()
+		for file, load := range map[string]func(io.Reader) error{
+			ServicesFile:  systemNames.LoadServices,
+			ProtocolsFile: systemNames.LoadProtocols,
+		} {
+			f, err := os.Open(file)
+			if err != nil {
+				continue
+			}
+			_ = load(f)
+			_ = f.Close()
+		}
+	})
+	return systemNames
+}
+
+var (
+	systemNamesOnce sync.Once
+	systemNames     *NameDB
+)
+
+// LoadServices reads entries in the services(5) format:
+//
+//	ssh	22/tcp	# SSH Remote Login Protocol
+func (db *NameDB) LoadServices(r io.Reader) error {
+	return scanEntries(r, func(fields []string) {
+		if len(fields) < 2 { //nolint:mnd
+			return
+		}
+		portProto := strings.SplitN(fields[1], "/", 2) //nolint:mnd
+		if len(portProto) != 2 {                       //nolint:mnd
+			return
+		}
+		port, err := strconv.ParseUint(portProto[0], 10, 16)
+		if err != nil {
+			return
+		}
+		key := serviceKey{proto: portProto[1], port: uint16(port)}
+		if _, ok := db.services[key]; !ok {
+			db.services[key] = fields[0]
+		}
+	})
+}
+
+// LoadProtocols reads entries in the protocols(5) format:
+//
+//	tcp	6	TCP	# transmission control protocol
+func (db *NameDB) LoadProtocols(r io.Reader) error {
+	return scanEntries(r, func(fields []string) {
+		if len(fields) < 2 { //nolint:mnd
+			return
+		}
+		num, err := strconv.ParseUint(fields[1], 10, 8)
+		if err != nil {
+			return
+		}
+		if _, ok := db.protocols[ProtoType(num)]; !ok {
+			db.protocols[ProtoType(num)] = fields[0]
+		}
+	})
+}
+
+// ServiceName returns the name of the port for the given transport protocol
+// name (tcp, udp, ...). An empty protocol matches tcp first and then udp like
+// the inet_service datatype does.
+func (db *NameDB) ServiceName(proto string, port uint16) (string, bool) {
+	if proto == "" {
+		for _, p := range [...]string{"tcp", "udp"} {
+			if name, ok := db.services[serviceKey{proto: p, port: port}]; ok {
+				return name, true
+			}
+		}
+		return "", false
+	}
+	name, ok := db.services[serviceKey{proto: proto, port: port}]
+	return name, ok
+}
+
+// ProtocolName returns the name of the ip protocol number. Built-in names take
+// precedence over the loaded ones so the output stays stable across hosts.
+func (db *NameDB) ProtocolName(p ProtoType) (string, bool) {
+	if name := p.String(); name != "unknown" {
+		return name, true
+	}
+	name, ok := db.protocols[p]
+	return name, ok
+}
+
+// FormatService formats a big endian port either as a service name or as a
+// decimal number when the name is unknown.
+func (db *NameDB) FormatService(proto string, b []byte) string {
+	if len(b) == 2 { //nolint:mnd
+		if name, ok := db.ServiceName(proto, uint16(bytes.RawBytes(b).Uint64())); ok { //nolint:gosec
+			return name
+		}
+	}
+	return bytes.BytesToDecimalString(b)
+}
+
+// FormatProtocol formats an ip protocol number either as a name or as a decimal
+// number when the name is unknown.
+func (db *NameDB) FormatProtocol(b []byte) string {
+	if name, ok := db.ProtocolName(ProtoType(bytes.RawBytes(b).Uint64())); ok { //nolint:gosec
+		return name
+	}
+	return bytes.BytesToDecimalString(b)
+}
+
+func scanEntries(r io.Reader, fn func(fields []string)) error {
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := sc.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		if fields := strings.Fields(line); len(fields) > 0 {
+			fn(fields)
+		}
+	}
+	return sc.Err()
+}
+
+var builtinServices = map[serviceKey]string{
+	{"tcp", 20}:   "ftp-data",
+	{"tcp", 21}:   "ftp",
+	{"tcp", 22}:   "ssh",
+	{"tcp", 23}:   "telnet",
+	{"tcp", 25}:   "smtp",
+	{"tcp", 43}:   "whois",
+	{"tcp", 53}:   "domain",
+	{"udp", 53}:   "domain",
+	{"udp", 67}:   "bootps",
+	{"udp", 68}:   "bootpc",
+	{"udp", 69}:   "tftp",
+	{"tcp", 80}:   "http",
+	{"tcp", 88}:   "kerberos",
+	{"udp", 88}:   "kerberos",
+	{"tcp", 110}:  "pop3",
+	{"udp", 123}:  "ntp",
+	{"tcp", 143}:  "imap2",
+	{"udp", 161}:  "snmp",
+	{"udp", 162}:  "snmp-trap",
+	{"tcp", 179}:  "bgp",
+	{"tcp", 389}:  "ldap",
+	{"tcp", 443}:  "https",
+	{"udp", 443}:  "https",
+	{"tcp", 445}:  "microsoft-ds",
+	{"udp", 500}:  "isakmp",
+	{"udp", 514}:  "syslog",
+	{"tcp", 587}:  "submission",
+	{"tcp", 636}:  "ldaps",
+	{"tcp", 853}:  "domain-s",
+	{"udp", 853}:  "domain-s",
+	{"tcp", 873}:  "rsync",
+	{"tcp", 993}:  "imaps",
+	{"tcp", 995}:  "pop3s",
+	{"udp", 1194}: "openvpn",
+	{"tcp", 1812}: "radius",
+	{"udp", 1812}: "radius",
+	{"tcp", 2049}: "nfs",
+	{"udp", 2049}: "nfs",
+	{"tcp", 3306}: "mysql",
+	{"tcp", 3389}: "ms-wbt-server",
+	{"udp", 4500}: "ipsec-nat-t",
+	{"tcp", 5432}: "postgresql",
+	{"tcp", 6379}: "redis",
+}
@@ -2,6 +2,8 @@
 package protocols
 
 import (
+	"fmt"
+
 	"golang.org/x/sys/unix"
 )
 
@@ -17,6 +19,9 @@ type (
 	IcmpCode    int
 	Icmp6Code   int
 	TcpFlagType int
+	DccpPktType int
+	IgmpType    int
+	EtherType   uint16
 )
 
 func (p ProtoType) String() string {
@@ -51,6 +56,8 @@ func (p ProtoType) String() string {
 		return "dccp"
 	case unix.IPPROTO_SCTP:
 		return "sctp"
+	case unix.IPPROTO_GRE:
+		return "gre"
 	}
 	return "unknown"
 }
@@ -263,3 +270,93 @@ func (t TcpFlagType) String() string {
 	}
 	return "unknown"
 }
+
+// DCCP_PKT_TYPE
+const (
+	DCCP_PKT_REQUEST DccpPktType = iota
+	DCCP_PKT_RESPONSE
+	DCCP_PKT_DATA
+	DCCP_PKT_ACK
+	DCCP_PKT_DATAACK
+	DCCP_PKT_CLOSEREQ
+	DCCP_PKT_CLOSE
+	DCCP_PKT_RESET
+	DCCP_PKT_SYNC
+	DCCP_PKT_SYNCACK
+)
+
+func (d DccpPktType) String() string {
+	switch d {
+	case DCCP_PKT_REQUEST:
+		return "request"
+	case DCCP_PKT_RESPONSE:
+		return "response"
+	case DCCP_PKT_DATA:
+		return "data"
+	case DCCP_PKT_ACK:
+		return "ack"
+	case DCCP_PKT_DATAACK:
+		return "dataack"
+	case DCCP_PKT_CLOSEREQ:
+		return "closereq"
+	case DCCP_PKT_CLOSE:
+		return "close"
+	case DCCP_PKT_RESET:
+		return "reset"
+	case DCCP_PKT_SYNC:
+		return "sync"
+	case DCCP_PKT_SYNCACK:
+		return "syncack"
+	}
+	return "unknown"
+}
+
+// IGMP_TYPE
+const (
+	IGMP_MEMBERSHIP_QUERY     IgmpType = 0x11
+	IGMP_V1_MEMBERSHIP_REPORT IgmpType = 0x12
+	IGMP_V2_MEMBERSHIP_REPORT IgmpType = 0x16
+	IGMP_V2_LEAVE_GROUP       IgmpType = 0x17
+	IGMP_V3_MEMBERSHIP_REPORT IgmpType = 0x22
+)
+
+func (i IgmpType) String() string {
+	switch i {
+	case IGMP_MEMBERSHIP_QUERY:
+		return "membership-query"
+	case IGMP_V1_MEMBERSHIP_REPORT:
+		return "membership-report-v1"
+	case IGMP_V2_MEMBERSHIP_REPORT:
+		return "membership-report-v2"
+	case IGMP_V2_LEAVE_GROUP:
+		return "leave-group"
+	case IGMP_V3_MEMBERSHIP_REPORT:
+		return "membership-report-v3"
+	}
+	return "unknown"
+}
+
+// ETHER_TYPE
+const (
+	ETH_P_IP     EtherType = unix.ETH_P_IP
+	ETH_P_ARP    EtherType = unix.ETH_P_ARP
+	ETH_P_8021Q  EtherType = unix.ETH_P_8021Q
+	ETH_P_IPV6   EtherType = unix.ETH_P_IPV6
+	ETH_P_8021AD EtherType = unix.ETH_P_8021AD
+)
+
+func (e EtherType) String() string {
+	switch e {
+	case ETH_P_IP:
+		return "ip"
+	case ETH_P_ARP:
+		return "arp"
+	case ETH_P_8021Q:
+		return "8021q"
+	case ETH_P_IPV6:
+		return "ip6"
+	case ETH_P_8021AD:
+		return "8021ad"
+	}
+	return fmt.Sprintf("0x%04x", uint16(e))
+}
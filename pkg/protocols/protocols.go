@@ -1,11 +1,13 @@
 package protocols
 
 import (
+	"fmt"
 	"math/bits"
 	"strings"
 
 	"github.com/Morwran/nft-go/internal/bytes"
 
+	nft "github.com/google/nftables"
 	"github.com/google/nftables/expr"
 	"golang.org/x/sys/unix"
 )
@@ -31,6 +33,10 @@ type (
 	ProtoHdrDesc   struct {
 		Name string
 		Desc func(b []byte) string
+		// Datatype marks fields holding values with symbolic names
+		// (inet_service, inet_proto) or addresses (ipv4_addr, ipv6_addr)
+		// so encoders may render them by name or as prefixes.
+		Datatype nft.SetDatatype
 	}
 	ProtoDesc struct {
 		Name          string
@@ -95,6 +101,80 @@ const (
 	THDR_DPORT HeaderOffset = HeaderOffset(byte(2) * BitsPerByte)
 )
 
+const (
+	UDPLITEHDR_SPORT    = HeaderOffset(byte(0) * BitsPerByte)
+	UDPLITEHDR_DPORT    = HeaderOffset(byte(2) * BitsPerByte)
+	UDPLITEHDR_CSUMCOV  = HeaderOffset(byte(4) * BitsPerByte)
+	UDPLITEHDR_CHECKSUM = HeaderOffset(byte(6) * BitsPerByte)
+)
+
+const (
+	SCTPHDR_SPORT    = HeaderOffset(byte(0) * BitsPerByte)
+	SCTPHDR_DPORT    = HeaderOffset(byte(2) * BitsPerByte)
+	SCTPHDR_VTAG     = HeaderOffset(byte(4) * BitsPerByte)
+	SCTPHDR_CHECKSUM = HeaderOffset(byte(8) * BitsPerByte)
+)
+
+/*
+struct dccp_hdr {
+	__be16	dccph_sport,
+		dccph_dport;
+	__u8	dccph_doff;
+	__u8	dccph_cscov:4,
+		dccph_ccval:4;
+	__sum16	dccph_checksum;
+	__u8	dccph_x:1,
+		dccph_type:4,
+		dccph_reserved:3;
+	...
+};
+*/
+
+const (
+	DCCPHDR_SPORT = HeaderOffset(byte(0) * BitsPerByte)
+	DCCPHDR_DPORT = HeaderOffset(byte(2) * BitsPerByte)
+	DCCPHDR_TYPE  = HeaderOffset(byte(8)*BitsPerByte + 1)
+)
+
+const (
+	ESPHDR_SPI      = HeaderOffset(byte(0) * BitsPerByte)
+	ESPHDR_SEQUENCE = HeaderOffset(byte(4) * BitsPerByte)
+)
+
+const (
+	AHHDR_NEXTHDR   = HeaderOffset(byte(0) * BitsPerByte)
+	AHHDR_HDRLENGTH = HeaderOffset(byte(1) * BitsPerByte)
+	AHHDR_RESERVED  = HeaderOffset(byte(2) * BitsPerByte)
+	AHHDR_SPI       = HeaderOffset(byte(4) * BitsPerByte)
+	AHHDR_SEQUENCE  = HeaderOffset(byte(8) * BitsPerByte)
+)
+
+const (
+	COMPHDR_NEXTHDR = HeaderOffset(byte(0) * BitsPerByte)
+	COMPHDR_FLAGS   = HeaderOffset(byte(1) * BitsPerByte)
+	COMPHDR_CPI     = HeaderOffset(byte(2) * BitsPerByte)
+)
+
+const (
+	IGMPHDR_TYPE     = HeaderOffset(byte(0) * BitsPerByte)
+	IGMPHDR_MRT      = HeaderOffset(byte(1) * BitsPerByte)
+	IGMPHDR_CHECKSUM = HeaderOffset(byte(2) * BitsPerByte)
+	IGMPHDR_GROUP    = HeaderOffset(byte(4) * BitsPerByte)
+)
+
+/*
+struct gre_base_hdr {
+	__be16	flags; // C R K S s Recur(3) Flags(5) Ver(3)
+	__be16	protocol;
+};
+*/
+
+const (
+	GREHDR_FLAGS    = HeaderOffset(byte(0)*BitsPerByte + 3)
+	GREHDR_VERSION  = HeaderOffset(byte(1) * BitsPerByte)
+	GREHDR_PROTOCOL = HeaderOffset(byte(2) * BitsPerByte)
+)
+
 /*
 struct iphdr {
 #if defined(__LITTLE_ENDIAN_BITFIELD)
@@ -159,7 +239,7 @@ const (
 	IP6HDR_DADDR     = HeaderOffset(byte(24) * BitsPerByte)
 )
 
-var Protocols = ProtoLayerHolder{
+var builtinProtocols = ProtoLayerHolder{
 	expr.PayloadBaseTransportHeader: {
 		unix.IPPROTO_ICMP: ProtoDesc{
 			Name:          "icmp",
@@ -195,8 +275,8 @@ var Protocols = ProtoLayerHolder{
 			Base:          expr.PayloadBaseTransportHeader,
 			CurrentOffset: UDPHDR_SPORT,
 			Offsets: ProtoHdrHolder{
-				UDPHDR_SPORT:    ProtoHdrDesc{Name: "sport", Desc: bytes.BytesToDecimalString},
-				UDPHDR_DPORT:    ProtoHdrDesc{Name: "dport", Desc: bytes.BytesToDecimalString},
+				UDPHDR_SPORT:    ProtoHdrDesc{Name: "sport", Desc: bytes.BytesToDecimalString, Datatype: nft.TypeInetService},
+				UDPHDR_DPORT:    ProtoHdrDesc{Name: "dport", Desc: bytes.BytesToDecimalString, Datatype: nft.TypeInetService},
 				UDPHDR_LENGTH:   ProtoHdrDesc{Name: "length", Desc: bytes.BytesToDecimalString},
 				UDPHDR_CHECKSUM: ProtoHdrDesc{Name: "checksum", Desc: bytes.BytesToDecimalString},
 			},
@@ -207,26 +287,118 @@ var Protocols = ProtoLayerHolder{
 			Base:          expr.PayloadBaseTransportHeader,
 			CurrentOffset: TCPHDR_SPORT,
 			Offsets: ProtoHdrHolder{
-				TCPHDR_SPORT:    ProtoHdrDesc{Name: "sport", Desc: bytes.BytesToDecimalString},
-				TCPHDR_DPORT:    ProtoHdrDesc{Name: "dport", Desc: bytes.BytesToDecimalString},
+				TCPHDR_SPORT:    ProtoHdrDesc{Name: "sport", Desc: bytes.BytesToDecimalString, Datatype: nft.TypeInetService},
+				TCPHDR_DPORT:    ProtoHdrDesc{Name: "dport", Desc: bytes.BytesToDecimalString, Datatype: nft.TypeInetService},
 				TCPHDR_SEQ:      ProtoHdrDesc{Name: "sequence", Desc: bytes.BytesToDecimalString},
 				TCPHDR_ACKSEQ:   ProtoHdrDesc{Name: "ackseq", Desc: bytes.BytesToDecimalString},
 				TCPHDR_RESERVED: ProtoHdrDesc{Name: "rederved", Desc: bytes.BytesToDecimalString},
 				TCPHDR_DOFF:     ProtoHdrDesc{Name: "doff", Desc: bytes.BytesToDecimalString},
-				TCPHDR_FLAGS:    ProtoHdrDesc{Name: "flags", Desc: BytesToTcpFlags},
+				TCPHDR_FLAGS:    ProtoHdrDesc{Name: "flags", Desc: BytesToTcpFlags, Datatype: nft.TypeTCPFlag},
 				TCPHDR_WINDOW:   ProtoHdrDesc{Name: "window", Desc: bytes.BytesToDecimalString},
 				TCPHDR_CHECKSUM: ProtoHdrDesc{Name: "checksum", Desc: bytes.BytesToDecimalString},
 				TCPHDR_URGPTR:   ProtoHdrDesc{Name: "urgptr", Desc: bytes.BytesToDecimalString},
 			},
 		},
+		unix.IPPROTO_UDPLITE: ProtoDesc{
+			Name:          "udplite",
+			Id:            unix.IPPROTO_UDPLITE,
+			Base:          expr.PayloadBaseTransportHeader,
+			CurrentOffset: UDPLITEHDR_SPORT,
+			Offsets: ProtoHdrHolder{
+				UDPLITEHDR_SPORT:    ProtoHdrDesc{Name: "sport", Desc: bytes.BytesToDecimalString, Datatype: nft.TypeInetService},
+				UDPLITEHDR_DPORT:    ProtoHdrDesc{Name: "dport", Desc: bytes.BytesToDecimalString, Datatype: nft.TypeInetService},
+				UDPLITEHDR_CSUMCOV:  ProtoHdrDesc{Name: "csumcov", Desc: bytes.BytesToDecimalString},
+				UDPLITEHDR_CHECKSUM: ProtoHdrDesc{Name: "checksum", Desc: bytes.BytesToDecimalString},
+			},
+		},
+		unix.IPPROTO_SCTP: ProtoDesc{
+			Name:          "sctp",
+			Id:            unix.IPPROTO_SCTP,
+			Base:          expr.PayloadBaseTransportHeader,
+			CurrentOffset: SCTPHDR_SPORT,
+			Offsets: ProtoHdrHolder{
+				SCTPHDR_SPORT:    ProtoHdrDesc{Name: "sport", Desc: bytes.BytesToDecimalString, Datatype: nft.TypeInetService},
+				SCTPHDR_DPORT:    ProtoHdrDesc{Name: "dport", Desc: bytes.BytesToDecimalString, Datatype: nft.TypeInetService},
+				SCTPHDR_VTAG:     ProtoHdrDesc{Name: "vtag", Desc: bytes.BytesToDecimalString},
+				SCTPHDR_CHECKSUM: ProtoHdrDesc{Name: "checksum", Desc: bytes.BytesToDecimalString},
+			},
+		},
+		unix.IPPROTO_DCCP: ProtoDesc{
+			Name:          "dccp",
+			Id:            unix.IPPROTO_DCCP,
+			Base:          expr.PayloadBaseTransportHeader,
+			CurrentOffset: DCCPHDR_SPORT,
+			Offsets: ProtoHdrHolder{
+				DCCPHDR_SPORT: ProtoHdrDesc{Name: "sport", Desc: bytes.BytesToDecimalString, Datatype: nft.TypeInetService},
+				DCCPHDR_DPORT: ProtoHdrDesc{Name: "dport", Desc: bytes.BytesToDecimalString, Datatype: nft.TypeInetService},
+				DCCPHDR_TYPE:  ProtoHdrDesc{Name: "type", Desc: BytesToDccpPktType},
+			},
+		},
+		unix.IPPROTO_ESP: ProtoDesc{
+			Name:          "esp",
+			Id:            unix.IPPROTO_ESP,
+			Base:          expr.PayloadBaseTransportHeader,
+			CurrentOffset: ESPHDR_SPI,
+			Offsets: ProtoHdrHolder{
+				ESPHDR_SPI:      ProtoHdrDesc{Name: "spi", Desc: bytes.BytesToDecimalString},
+				ESPHDR_SEQUENCE: ProtoHdrDesc{Name: "sequence", Desc: bytes.BytesToDecimalString},
+			},
+		},
+		unix.IPPROTO_AH: ProtoDesc{
+			Name:          "ah",
+			Id:            unix.IPPROTO_AH,
+			Base:          expr.PayloadBaseTransportHeader,
+			CurrentOffset: AHHDR_NEXTHDR,
+			Offsets: ProtoHdrHolder{
+				AHHDR_NEXTHDR:   ProtoHdrDesc{Name: "nexthdr", Desc: BytesToProtoString, Datatype: nft.TypeInetProto},
+				AHHDR_HDRLENGTH: ProtoHdrDesc{Name: "hdrlength", Desc: bytes.BytesToDecimalString},
+				AHHDR_RESERVED:  ProtoHdrDesc{Name: "reserved", Desc: bytes.BytesToDecimalString},
+				AHHDR_SPI:       ProtoHdrDesc{Name: "spi", Desc: bytes.BytesToDecimalString},
+				AHHDR_SEQUENCE:  ProtoHdrDesc{Name: "sequence", Desc: bytes.BytesToDecimalString},
+			},
+		},
+		unix.IPPROTO_COMP: ProtoDesc{
+			Name:          "comp",
+			Id:            unix.IPPROTO_COMP,
+			Base:          expr.PayloadBaseTransportHeader,
+			CurrentOffset: COMPHDR_NEXTHDR,
+			Offsets: ProtoHdrHolder{
+				COMPHDR_NEXTHDR: ProtoHdrDesc{Name: "nexthdr", Desc: BytesToProtoString, Datatype: nft.TypeInetProto},
+				COMPHDR_FLAGS:   ProtoHdrDesc{Name: "flags", Desc: bytes.BytesToHexString},
+				COMPHDR_CPI:     ProtoHdrDesc{Name: "cpi", Desc: bytes.BytesToDecimalString},
+			},
+		},
+		unix.IPPROTO_IGMP: ProtoDesc{
+			Name:          "igmp",
+			Id:            unix.IPPROTO_IGMP,
+			Base:          expr.PayloadBaseTransportHeader,
+			CurrentOffset: IGMPHDR_TYPE,
+			Offsets: ProtoHdrHolder{
+				IGMPHDR_TYPE:     ProtoHdrDesc{Name: "type", Desc: BytesToIgmpType},
+				IGMPHDR_MRT:      ProtoHdrDesc{Name: "mrt", Desc: bytes.BytesToDecimalString},
+				IGMPHDR_CHECKSUM: ProtoHdrDesc{Name: "checksum", Desc: bytes.BytesToDecimalString},
+				IGMPHDR_GROUP:    ProtoHdrDesc{Name: "group", Desc: bytes.BytesToAddrString},
+			},
+		},
+		unix.IPPROTO_GRE: ProtoDesc{
+			Name:          "gre",
+			Id:            unix.IPPROTO_GRE,
+			Base:          expr.PayloadBaseTransportHeader,
+			CurrentOffset: GREHDR_FLAGS,
+			Offsets: ProtoHdrHolder{
+				GREHDR_FLAGS:    ProtoHdrDesc{Name: "flags", Desc: BytesToGreFlags},
+				GREHDR_VERSION:  ProtoHdrDesc{Name: "version", Desc: BytesToGreVersion},
+				GREHDR_PROTOCOL: ProtoHdrDesc{Name: "protocol", Desc: BytesToEtherType},
+			},
+		},
 		unix.IPPROTO_NONE: ProtoDesc{
 			Name:          "th",
 			Id:            unix.IPPROTO_NONE,
 			Base:          expr.PayloadBaseTransportHeader,
 			CurrentOffset: THDR_SPORT,
 			Offsets: ProtoHdrHolder{
-				THDR_SPORT: ProtoHdrDesc{Name: "sport", Desc: bytes.BytesToDecimalString},
-				THDR_DPORT: ProtoHdrDesc{Name: "dport", Desc: bytes.BytesToDecimalString},
+				THDR_SPORT: ProtoHdrDesc{Name: "sport", Desc: bytes.BytesToDecimalString, Datatype: nft.TypeInetService},
+				THDR_DPORT: ProtoHdrDesc{Name: "dport", Desc: bytes.BytesToDecimalString, Datatype: nft.TypeInetService},
 			},
 		},
 	},
@@ -245,10 +417,10 @@ var Protocols = ProtoLayerHolder{
 				IPHDR_ID:        ProtoHdrDesc{Name: "id", Desc: bytes.BytesToDecimalString},
 				IPHDR_FRAG_OFF:  ProtoHdrDesc{Name: "frag-off", Desc: bytes.BytesToHexString},
 				IPHDR_TTL:       ProtoHdrDesc{Name: "ttl", Desc: bytes.BytesToDecimalString},
-				IPHDR_PROTOCOL:  ProtoHdrDesc{Name: "protocol", Desc: BytesToProtoString},
+				IPHDR_PROTOCOL:  ProtoHdrDesc{Name: "protocol", Desc: BytesToProtoString, Datatype: nft.TypeInetProto},
 				IPHDR_CHECKSUM:  ProtoHdrDesc{Name: "checksum", Desc: bytes.BytesToDecimalString},
-				IPHDR_SADDR:     ProtoHdrDesc{Name: "saddr", Desc: bytes.BytesToAddrString},
-				IPHDR_DADDR:     ProtoHdrDesc{Name: "daddr", Desc: bytes.BytesToAddrString},
+				IPHDR_SADDR:     ProtoHdrDesc{Name: "saddr", Desc: bytes.BytesToAddrString, Datatype: nft.TypeIPAddr},
+				IPHDR_DADDR:     ProtoHdrDesc{Name: "daddr", Desc: bytes.BytesToAddrString, Datatype: nft.TypeIPAddr},
 			},
 		},
 		unix.IPPROTO_IPV6: ProtoDesc{
@@ -260,10 +432,10 @@ var Protocols = ProtoLayerHolder{
 				IP6HDR_VERSION:   ProtoHdrDesc{Name: "version", Desc: bytes.BytesToDecimalString},
 				IP6HDR_FLOWLABEL: ProtoHdrDesc{Name: "flowlabel", Desc: bytes.BytesToDecimalString},
 				IP6HDR_LENGTH:    ProtoHdrDesc{Name: "length", Desc: bytes.BytesToDecimalString},
-				IP6HDR_NEXTHDR:   ProtoHdrDesc{Name: "nexthdr", Desc: bytes.BytesToDecimalString},
+				IP6HDR_NEXTHDR:   ProtoHdrDesc{Name: "nexthdr", Desc: BytesToProtoString, Datatype: nft.TypeInetProto},
 				IP6HDR_HOPLIMIT:  ProtoHdrDesc{Name: "hoplimit", Desc: bytes.BytesToDecimalString},
-				IP6HDR_SADDR:     ProtoHdrDesc{Name: "saddr", Desc: bytes.BytesToAddrString},
-				IP6HDR_DADDR:     ProtoHdrDesc{Name: "daddr", Desc: bytes.BytesToAddrString},
+				IP6HDR_SADDR:     ProtoHdrDesc{Name: "saddr", Desc: bytes.BytesToAddrString, Datatype: nft.TypeIP6Addr},
+				IP6HDR_DADDR:     ProtoHdrDesc{Name: "daddr", Desc: bytes.BytesToAddrString, Datatype: nft.TypeIP6Addr},
 			},
 		},
 	},
@@ -321,3 +493,23 @@ func BytesToTcpFlags(b []byte) string {
 func BytesToProtoString(b []byte) string {
 	return ProtoType(int(bytes.RawBytes(b).Uint64())).String() //nolint:gosec
 }
+
+func BytesToDccpPktType(b []byte) string {
+	return DccpPktType((bytes.RawBytes(b).Uint64() >> 1) & 0xf).String() //nolint:gosec,mnd
+}
+
+func BytesToIgmpType(b []byte) string {
+	return IgmpType(bytes.RawBytes(b).Uint64()).String() //nolint:gosec
+}
+
+func BytesToGreFlags(b []byte) string {
+	return bytes.BytesToHexString([]byte{byte(bytes.RawBytes(b).Uint64() >> 3)}) //nolint:gosec,mnd
+}
+
+func BytesToGreVersion(b []byte) string {
+	return fmt.Sprintf("%d", bytes.RawBytes(b).Uint64()&0x7) //nolint:mnd
+}
+
+func BytesToEtherType(b []byte) string {
+	return EtherType(bytes.RawBytes(b).Uint64()).String() //nolint:gosec
+}
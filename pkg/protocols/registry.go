@@ -0,0 +1,89 @@
+package protocols
+
+import (
+	"maps"
+	"sync"
+
+	"github.com/google/nftables/expr"
+)
+
+type registeredKey struct {
+	base expr.PayloadBase
+	id   ProtoType
+}
+
+// Protocols is a copy of the built-in headers the encoders started with.
+//
+// Deprecated: use LookupProtocol, it also returns the headers added by
+// RegisterProtocol. Changes made to Protocols are not seen by the encoders.
+var Protocols = cloneLayers(builtinProtocols)
+
+var (
+	registryMu sync.RWMutex
+	registry   = cloneLayers(builtinProtocols)
+	// registered keeps the protocols added by RegisterProtocol in the order
+	// they were added
+	registered []registeredKey
+)
+
+// RegisterProtocol adds the header of the protocol id at the payload base or
+// replaces the known one. Payload expressions loading a field of the header
+// are rendered like `myproto field value` once a match selected the protocol
+// (`meta l4proto 253`) or, failing that, when no known header of the base
+// has a field at the offset, e.g. a proprietary header following the udp one:
+//
+//	protocols.RegisterProtocol(expr.PayloadBaseTransportHeader, 253, protocols.ProtoDesc{
+//		Name: "myproto",
+//		Offsets: protocols.ProtoHdrHolder{
+//			64: {Name: "field", Desc: bytes.BytesToHexString},
+//		},
+//	})
+func RegisterProtocol(base expr.PayloadBase, id ProtoType, desc ProtoDesc) {
+	desc.Base, desc.Id = base, id
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if registry[base] == nil {
+		registry[base] = ProtoTypeHolder{}
+	}
+	registry[base][id] = desc
+	key := registeredKey{base: base, id: id}
+	for _, k := range registered {
+		if k == key {
+			return
+		}
+	}
+	registered = append(registered, key)
+}
+
+// LookupProtocol returns the header of the protocol id at the payload base.
+func LookupProtocol(base expr.PayloadBase, id ProtoType) (ProtoDesc, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	desc, ok := registry[base][id]
+	return desc, ok
+}
+
+// LookupRegistered returns the first header added by RegisterProtocol at the
+// payload base which has a field at the offset.
+func LookupRegistered(base expr.PayloadBase, offset HeaderOffset) (ProtoDesc, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	for _, k := range registered {
+		if k.base != base {
+			continue
+		}
+		desc := registry[base][k.id]
+		if _, ok := desc.Offsets[offset]; ok {
+			return desc, true
+		}
+	}
+	return ProtoDesc{}, false
+}
+
+func cloneLayers(layers ProtoLayerHolder) ProtoLayerHolder {
+	r := make(ProtoLayerHolder, len(layers))
+	for base, protos := range layers {
+		r[base] = maps.Clone(protos)
+	}
+	return r
+}
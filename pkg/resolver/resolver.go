@@ -0,0 +1,209 @@
+package resolver
+
+import (
+	"bufio"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	PasswdFile = "/etc/passwd"
+	GroupFile  = "/etc/group"
+	CgroupRoot = "/sys/fs/cgroup"
+)
+
+type (
+	// Resolver maps the numeric values nft prints symbolically (interface
+	// indexes, user and group ids, cgroup ids) to their names.
+	Resolver interface {
+		IfaceName(index uint32) (string, bool)
+		UserName(uid uint32) (string, bool)
+		GroupName(gid uint32) (string, bool)
+		// CgroupPath returns the path of the cgroupv2 relative to the cgroup
+		// root like `user.slice/user-1000.slice`
+		CgroupPath(id uint64) (string, bool)
+		// Location is the time zone meta time, day and hour are printed in
+		Location() *time.Location
+	}
+
+	// Static resolves the values from its maps only, it never looks at the
+	// host. It is meant for tests and for rendering rules of other hosts.
+	Static struct {
+		Ifaces  map[uint32]string
+		Users   map[uint32]string
+		Groups  map[uint32]string
+		Cgroups map[uint64]string
+		// Loc is the time zone, UTC when not set
+		Loc *time.Location
+	}
+
+	system struct {
+		ifacesMu     sync.RWMutex
+		ifaces       map[uint32]string
+		accountsOnce sync.Once
+		users        map[uint32]string
+		groups       map[uint32]string
+		cgroupsOnce  sync.Once
+		cgroups      map[uint64]string
+	}
+)
+
+var _ Resolver = (*Static)(nil)
+
+// IfaceName implements Resolver
+func (s *Static) IfaceName(index uint32) (string, bool) {
+	name, ok := s.Ifaces[index]
+	return name, ok
+}
+
+// UserName implements Resolver
+func (s *Static) UserName(uid uint32) (string, bool) {
+	name, ok := s.Users[uid]
+	return name, ok
+}
+
+// GroupName implements Resolver
+func (s *Static) GroupName(gid uint32) (string, bool) {
+	name, ok := s.Groups[gid]
+	return name, ok
+}
+
+// CgroupPath implements Resolver
+func (s *Static) CgroupPath(id uint64) (string, bool) {
+	path, ok := s.Cgroups[id]
+	return path, ok
+}
+
+// Location implements Resolver
+func (s *Static) Location() *time.Location {
+	if s.Loc != nil {
+		return s.Loc
+	}
+	return time.UTC
+}
+
+// System returns the resolver looking the values up on the local host:
+// interfaces over netlink, users and groups in /etc/passwd and /etc/group,
+// cgroups by the inode of their directory under /sys/fs/cgroup. The files
+// are read once on the first lookup, found interfaces are cached.
+func System() Resolver {
+	systemOnce.Do(func() {
+		systemResolver = &system{ifaces: make(map[uint32]string)}
+	})
+	return systemResolver
+}
+
+var (
+	systemOnce     sync.Once
+	systemResolver *system
+)
+
+func (s *system) IfaceName(index uint32) (string, bool) {
+	s.ifacesMu.RLock()
+	name, ok := s.ifaces[index]
+	s.ifacesMu.RUnlock()
+	if ok {
+		return name, true
+	}
+	iface, err := net.InterfaceByIndex(int(index))
+	if err != nil {
+		return "", false
+	}
+	s.ifacesMu.Lock()
+	s.ifaces[index] = iface.Name
+	s.ifacesMu.Unlock()
+	return iface.Name, true
+}
+
+func (s *system) UserName(uid uint32) (string, bool) {
+	s.loadAccounts()
+	name, ok := s.users[uid]
+	return name, ok
+}
+
+func (s *system) GroupName(gid uint32) (string, bool) {
+	s.loadAccounts()
+	name, ok := s.groups[gid]
+	return name, ok
+}
+
+func (s *system) CgroupPath(id uint64) (string, bool) {
+	s.cgroupsOnce.Do(func() {
+		s.cgroups = make(map[uint64]string)
+		_ = filepath.WalkDir(CgroupRoot, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || !d.IsDir() {
+				return nil //nolint:nilerr
+			}
+			fi, err := d.Info()
+			if err != nil {
+				return nil //nolint:nilerr
+			}
+			st, ok := fi.Sys().(*syscall.Stat_t)
+			if !ok {
+				return nil
+			}
+			if rel, err := filepath.Rel(CgroupRoot, path); err == nil && rel != "." {
+				s.cgroups[st.Ino] = rel
+			}
+			return nil
+		})
+	})
+	path, ok := s.cgroups[id]
+	return path, ok
+}
+
+func (s *system) Location() *time.Location {
+	return time.Local
+}
+
+func (s *system) loadAccounts() {
+	s.accountsOnce.Do(func() {
+		s.users, s.groups = make(map[uint32]string), make(map[uint32]string)
+		for file, ids := range map[string]map[uint32]string{
+			PasswdFile: s.users,
+			GroupFile:  s.groups,
+		} {
+			f, err := os.Open(file)
+			if err != nil {
+				continue
+			}
+			_ = LoadAccounts(f, ids)
+			_ = f.Close()
+		}
+	})
+}
+
+// LoadAccounts reads entries in the passwd(5) or group(5) format into the
+// id to name map, the first name of an id wins:
+//
+//	root:x:0:0:root:/root:/bin/bash
+//	wheel:x:10:alice
+func LoadAccounts(r io.Reader, ids map[uint32]string) error {
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, ":")
+		if len(fields) < 3 || fields[0] == "" { //nolint:mnd
+			continue
+		}
+		id, err := strconv.ParseUint(fields[2], 10, 32)
+		if err != nil {
+			continue
+		}
+		if _, ok := ids[uint32(id)]; !ok {
+			ids[uint32(id)] = fields[0]
+		}
+	}
+	return sc.Err()
+}
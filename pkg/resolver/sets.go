@@ -0,0 +1,208 @@
+package resolver
+
+import (
+	"fmt"
+	"sync"
+
+	nft "github.com/google/nftables"
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+type (
+	// SetResolver looks up the sets rules refer to in lookups, maps and
+	// dynsets.
+	SetResolver interface {
+		// LookupSet returns the set of the table with its elements, ok is false
+		// when there is no such set. The id matches anonymous sets referenced
+		// by id only like in a batch not yet committed.
+		LookupSet(table *nft.Table, name string, id uint32) (set *nft.Set, elems []nft.SetElement, ok bool, err error)
+	}
+
+	// Sets is an in-memory model of sets filled from a dump, a file or
+	// parsed netlink messages. It is safe for concurrent use.
+	Sets struct {
+		mu   sync.RWMutex
+		sets map[setKey]*setEntry
+	}
+
+	// CachedSetResolver is a SetResolver caching the sets it fetched,
+	// Invalidate drops them so that they are fetched again on the next lookup.
+	CachedSetResolver interface {
+		SetResolver
+		Invalidate()
+	}
+
+	// liveSets fetches the sets with their elements on their first lookup.
+	liveSets struct {
+		conn    *nft.Conn
+		mu      sync.Mutex
+		fetched map[setKey]bool
+		cache   Sets
+	}
+
+	tableKey struct {
+		family nft.TableFamily
+		name   string
+	}
+
+	setKey struct {
+		tableKey
+		name string
+	}
+
+	setEntry struct {
+		set   *nft.Set
+		elems []nft.SetElement
+	}
+)
+
+var (
+	_ SetResolver = (*Sets)(nil)
+	_ SetResolver = (*liveSets)(nil)
+)
+
+// NewSets returns an empty set model.
+func NewSets() *Sets {
+	return &Sets{}
+}
+
+// Add puts the set with its elements into the model replacing the set of
+// the same table and name.
+func (s *Sets) Add(set *nft.Set, elems []nft.SetElement) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.sets == nil {
+		s.sets = make(map[setKey]*setEntry)
+	}
+	s.sets[keyOf(set.Table, set.Name)] = &setEntry{set: set, elems: elems}
+}
+
+// AddElements appends the elements to the set added before like the
+// NFT_MSG_NEWSETELEM messages following NFT_MSG_NEWSET in a batch do.
+func (s *Sets) AddElements(table *nft.Table, name string, elems []nft.SetElement) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.sets[keyOf(table, name)]
+	if !ok {
+		return errors.Errorf("set '%s' is not found", name)
+	}
+	e.elems = append(e.elems, elems...)
+	return nil
+}
+
+// LookupSet implements SetResolver
+func (s *Sets) LookupSet(table *nft.Table, name string, id uint32) (*nft.Set, []nft.SetElement, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if e, ok := s.sets[keyOf(table, name)]; ok {
+		return e.set, e.elems, true, nil
+	}
+	if id == 0 {
+		return nil, nil, false, nil
+	}
+	tk := keyOf(table, "").tableKey
+	for k, e := range s.sets {
+		if k.tableKey == tk && e.set.ID == id {
+			return e.set, e.elems, true, nil
+		}
+	}
+	return nil, nil, false, nil
+}
+
+// LiveSets returns the resolver fetching the sets from the kernel over the
+// connection, only the sets looked up are fetched. A connection is opened for
+// every set fetched when conn is nil. The fetched sets are cached until the
+// resolver is invalidated.
+func LiveSets(conn *nft.Conn) CachedSetResolver {
+	return &liveSets{conn: conn, fetched: make(map[setKey]bool)}
+}
+
+// SystemSets returns the live resolver shared by the rules rendered without a
+// set resolver. It is to be invalidated when the sets of the host change.
+func SystemSets() CachedSetResolver {
+	systemSetsOnce.Do(func() {
+		systemSets = LiveSets(nil)
+	})
+	return systemSets
+}
+
+var (
+	systemSetsOnce sync.Once
+	systemSets     CachedSetResolver
+)
+
+// LookupSet implements SetResolver
+func (l *liveSets) LookupSet(table *nft.Table, name string, id uint32) (*nft.Set, []nft.SetElement, bool, error) {
+	if table == nil {
+		return nil, nil, false, nil
+	}
+	if err := l.load(table, name, id); err != nil {
+		return nil, nil, false, err
+	}
+	return l.cache.LookupSet(table, name, id)
+}
+
+// Invalidate implements CachedSetResolver
+func (l *liveSets) Invalidate() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.fetched = make(map[setKey]bool)
+	l.cache.mu.Lock()
+	l.cache.sets = nil
+	l.cache.mu.Unlock()
+}
+
+// load fetches the set with its elements unless it was fetched before. The
+// sets referenced by id only are looked for among all sets of the table.
+func (l *liveSets) load(table *nft.Table, name string, id uint32) (err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	key := keyOf(table, name)
+	if name == "" {
+		key.name = fmt.Sprintf("#%d", id)
+	}
+	if l.fetched[key] {
+		return nil
+	}
+	conn := l.conn
+	if conn == nil {
+		if conn, err = nft.New(); err != nil {
+			return errors.WithMessage(err, "failed to create netlink connection")
+		}
+		defer func() { _ = conn.CloseLasting() }()
+	}
+	var sets []*nft.Set
+	if name != "" {
+		set, err := conn.GetSetByName(table, name)
+		switch {
+		case errors.Is(err, unix.ENOENT):
+		case err != nil:
+			return errors.WithMessagef(err, "failed to obtain the set '%s'", name)
+		default:
+			sets = append(sets, set)
+		}
+	} else if sets, err = conn.GetSets(table); err != nil {
+		return errors.WithMessagef(err, "failed to obtain sets of the table '%s'", table.Name)
+	}
+	for _, set := range sets {
+		if set == nil || (name == "" && set.ID != id) {
+			continue
+		}
+		elems, err := conn.GetSetElements(set)
+		if err != nil {
+			return errors.WithMessagef(err, "failed to obtain elements of the set '%s'", set.Name)
+		}
+		l.cache.Add(set, elems)
+	}
+	l.fetched[key] = true
+	return nil
+}
+
+func keyOf(table *nft.Table, name string) setKey {
+	k := setKey{name: name}
+	if table != nil {
+		k.family, k.tableKey.name = table.Family, table.Name
+	}
+	return k
+}
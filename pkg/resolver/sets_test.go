@@ -0,0 +1,49 @@
+package resolver
+
+import (
+	"testing"
+
+	nft "github.com/google/nftables"
+	"github.com/mdlayher/netlink"
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/sys/unix"
+)
+
+type liveSetsTestSuite struct {
+	suite.Suite
+}
+
+func Test_LiveSets(t *testing.T) {
+	suite.Run(t, new(liveSetsTestSuite))
+}
+
+func (sui *liveSetsTestSuite) Test_FetchReferencedSetOnce() {
+	var requests []netlink.Header
+	conn, err := nft.New(nft.WithTestDial(func(req []netlink.Message) ([]netlink.Message, error) {
+		for _, m := range req {
+			requests = append(requests, m.Header)
+		}
+		return nil, unix.ENOENT
+	}))
+	sui.Require().NoError(err)
+
+	table := &nft.Table{Name: "filter", Family: nft.TableFamilyINet}
+	sets := LiveSets(conn)
+	for i := 0; i < 2; i++ {
+		_, _, ok, err := sets.LookupSet(table, "blocked", 0)
+		sui.Require().NoError(err)
+		sui.Require().False(ok)
+	}
+	sui.Require().Len(requests, 1)
+	sui.Require().Equal(netlink.HeaderType(unix.NFNL_SUBSYS_NFTABLES<<8|unix.NFT_MSG_GETSET), requests[0].Type)
+	sui.Require().Zero(requests[0].Flags&netlink.Dump, "the set is to be fetched by name")
+
+	sets.Invalidate()
+	_, _, _, err = sets.LookupSet(table, "blocked", 0)
+	sui.Require().NoError(err)
+	sui.Require().Len(requests, 2)
+}
+
+func (sui *liveSetsTestSuite) Test_SystemSetsShared() {
+	sui.Require().Same(SystemSets(), SystemSets())
+}
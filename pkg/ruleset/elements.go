@@ -0,0 +1,137 @@
+package ruleset
+
+import (
+	"context"
+	"encoding/binary"
+	"iter"
+	"syscall"
+
+	"github.com/Morwran/nft-go/pkg/nlparser"
+
+	nft "github.com/google/nftables"
+	"github.com/mdlayher/netlink"
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+// recvBufSize fits the largest datagram of a netlink dump
+const recvBufSize = 1 << 16
+
+// SetElements returns the iterator over the elements of the set in the order
+// of the kernel dump. The dump is read datagram by datagram over a socket of
+// its own in the namespace of conn, so only a datagram of elements is held in
+// memory at once whatever the size of the set. A connection with a test
+// dialer gets the whole dump at once.
+func SetElements(ctx context.Context, conn *nft.Conn, set *nft.Set) iter.Seq2[nft.SetElement, error] {
+	return func(yield func(nft.SetElement, error) bool) {
+		if conn.TestDial != nil {
+			elems, err := conn.GetSetElements(set)
+			if err != nil {
+				yield(nft.SetElement{}, errors.WithMessagef(err, "failed to obtain set elements for the set='%s'", set.Name))
+				return
+			}
+			for _, e := range elems {
+				if !yield(e, nil) {
+					return
+				}
+			}
+			return
+		}
+		if err := streamElements(ctx, conn, set, yield); err != nil {
+			yield(nft.SetElement{}, errors.WithMessagef(err, "failed to obtain set elements for the set='%s'", set.Name))
+		}
+	}
+}
+
+func streamElements(ctx context.Context, conn *nft.Conn, set *nft.Set, yield func(nft.SetElement, error) bool) error {
+	nl, err := netlink.Dial(unix.NETLINK_NETFILTER, &netlink.Config{NetNS: conn.NetNS})
+	if err != nil {
+		return errors.WithMessage(err, "failed to create netlink connection")
+	}
+	defer nl.Close() //nolint:errcheck
+
+	data, err := netlink.MarshalAttributes([]netlink.Attribute{
+		{Type: unix.NFTA_SET_ELEM_LIST_TABLE, Data: []byte(set.Table.Name + "\x00")},
+		{Type: unix.NFTA_SET_ELEM_LIST_SET, Data: []byte(set.Name + "\x00")},
+	})
+	if err != nil {
+		return err
+	}
+	req, err := nl.Send(netlink.Message{
+		Header: netlink.Header{
+			Type:  netlink.HeaderType((unix.NFNL_SUBSYS_NFTABLES << 8) | unix.NFT_MSG_GETSETELEM),
+			Flags: netlink.Request | netlink.Dump,
+		},
+		Data: append([]byte{byte(set.Table.Family), unix.NFNETLINK_V0, 0, 0}, data...),
+	})
+	if err != nil {
+		return err
+	}
+	raw, err := nl.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	buf := make([]byte, recvBufSize)
+	for {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		var (
+			n, flags int
+			rerr     error
+		)
+		err = raw.Read(func(fd uintptr) bool {
+			n, _, flags, _, rerr = unix.Recvmsg(int(fd), buf, nil, unix.MSG_DONTWAIT)
+			return rerr != unix.EAGAIN
+		})
+		if err == nil {
+			err = rerr
+		}
+		if err != nil {
+			return err
+		}
+		if flags&unix.MSG_TRUNC != 0 {
+			return errors.New("netlink message is truncated")
+		}
+		msgs, err := syscall.ParseNetlinkMessage(buf[:n])
+		if err != nil {
+			return err
+		}
+		for _, m := range msgs {
+			if m.Header.Seq != req.Header.Sequence {
+				continue
+			}
+			switch m.Header.Type {
+			case unix.NLMSG_DONE:
+				return nil
+			case unix.NLMSG_ERROR:
+				if len(m.Data) < 4 {
+					return errors.New("netlink error message is truncated")
+				}
+				if errno := int32(binary.NativeEndian.Uint32(m.Data)); errno != 0 {
+					return syscall.Errno(-errno)
+				}
+				return nil
+			}
+			elems, err := nlparser.SetElemsFromMsg(netlink.Message{
+				Header: netlink.Header{
+					Length:   m.Header.Len,
+					Type:     netlink.HeaderType(m.Header.Type),
+					Flags:    netlink.HeaderFlags(m.Header.Flags),
+					Sequence: m.Header.Seq,
+					PID:      m.Header.Pid,
+				},
+				Data: m.Data,
+			})
+			if err != nil {
+				return err
+			}
+			for _, e := range elems.Elems {
+				if !yield(e, nil) {
+					return nil
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,283 @@
+package ruleset
+
+import (
+	"context"
+
+	"github.com/Morwran/nft-go/pkg/resolver"
+
+	"github.com/H-BF/corlib/pkg/parallel"
+	nft "github.com/google/nftables"
+	"github.com/pkg/errors"
+)
+
+type (
+	// Ruleset is the state of nftables: the tables with everything they hold.
+	Ruleset struct {
+		Tables []*Table
+	}
+
+	// Table is a table with its chains, sets, stateful objects and
+	// flowtables.
+	Table struct {
+		*nft.Table
+		Chains     []*Chain
+		Sets       []*Set
+		Objects    []nft.Obj
+		Flowtables []*nft.Flowtable
+	}
+
+	// Chain is a chain with its rules.
+	Chain struct {
+		*nft.Chain
+		Rules []*nft.Rule
+	}
+
+	// Set is a set or a map with its elements, anonymous sets of rules are
+	// included.
+	Set struct {
+		*nft.Set
+		Elems []nft.SetElement
+	}
+
+	// Filter selects what Fetch obtains, the zero value selects everything.
+	Filter struct {
+		// Family limits the tables to the family when set
+		Family nft.TableFamily
+		// Table limits the tables to the one of the name when set
+		Table string
+		// Chain limits the chains to the one of the name when set
+		Chain string
+		// Content is the content of the tables to fetch, FetchAll when zero
+		Content Content
+		// Workers is the number of set element requests made at once,
+		// DefaultWorkers when zero
+		Workers int
+	}
+
+	// Content is a set of Fetch* flags.
+	Content uint32
+
+	tableKey struct {
+		family nft.TableFamily
+		name   string
+	}
+
+	chainKey struct {
+		tableKey
+		name string
+	}
+)
+
+const (
+	// FetchTables fetches the tables only
+	FetchTables Content = 1 << iota
+	FetchChains
+	// FetchRules fetches the chains with their rules
+	FetchRules
+	// FetchSets fetches the sets without their elements, SetElements
+	// streams them
+	FetchSets
+	// FetchSetElements fetches the sets with their elements
+	FetchSetElements
+	FetchObjects
+	FetchFlowtables
+
+	FetchAll = FetchTables | FetchChains | FetchRules | FetchSets | FetchSetElements | FetchObjects | FetchFlowtables
+)
+
+// DefaultWorkers is the number of set element requests Fetch makes at once
+// by default.
+const DefaultWorkers = 8
+
+// Fetch obtains the ruleset selected by the filter over the connection.
+// Chains and rules are obtained with a single dump each whatever the number
+// of tables, the set elements are requested concurrently over connections
+// made like conn. The netlink requests can not be canceled, ctx is checked
+// between them.
+func Fetch(ctx context.Context, conn *nft.Conn, filter Filter) (*Ruleset, error) {
+	what := filter.Content
+	if what == 0 {
+		what = FetchAll
+	}
+	if what&FetchRules != 0 {
+		what |= FetchChains
+	}
+	if what&FetchSetElements != 0 {
+		what |= FetchSets
+	}
+
+	var (
+		tables []*nft.Table
+		err    error
+	)
+	if filter.Family != nft.TableFamilyUnspecified {
+		tables, err = conn.ListTablesOfFamily(filter.Family)
+	} else {
+		tables, err = conn.ListTables()
+	}
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to obtain list of tables from the netfilter")
+	}
+
+	rs := &Ruleset{}
+	byKey := make(map[tableKey]*Table, len(tables))
+	for _, t := range tables {
+		if filter.Table != "" && t.Name != filter.Table {
+			continue
+		}
+		table := &Table{Table: t}
+		rs.Tables = append(rs.Tables, table)
+		byKey[keyOf(t)] = table
+	}
+	for _, table := range rs.Tables {
+		if err = ctx.Err(); err != nil {
+			return nil, err
+		}
+		t := table.Table
+		if what&FetchSets != 0 {
+			if table.Sets, err = fetchSets(conn, t); err != nil {
+				return nil, err
+			}
+		}
+		if what&FetchObjects != 0 {
+			if table.Objects, err = conn.GetObjects(t); err != nil {
+				return nil, errors.WithMessagef(err,
+					"failed to obtain objects from the netfilter for the table name='%s'", t.Name)
+			}
+		}
+		if what&FetchFlowtables != 0 {
+			if table.Flowtables, err = conn.ListFlowtables(t); err != nil {
+				return nil, errors.WithMessagef(err,
+					"failed to obtain flowtables from the netfilter for the table name='%s'", t.Name)
+			}
+		}
+	}
+	if what&FetchChains != 0 {
+		if err = fetchChains(ctx, conn, byKey, filter, what&FetchRules != 0); err != nil {
+			return nil, err
+		}
+	}
+	if what&FetchSetElements != 0 {
+		if err = fetchElements(ctx, conn, rs, filter.Workers); err != nil {
+			return nil, err
+		}
+	}
+	return rs, nil
+}
+
+func fetchSets(conn *nft.Conn, t *nft.Table) ([]*Set, error) {
+	sets, err := conn.GetSets(t)
+	if err != nil {
+		return nil, errors.WithMessagef(err,
+			"failed to obtain list of sets from the netfilter for the table name='%s'", t.Name)
+	}
+	ret := make([]*Set, 0, len(sets))
+	for _, set := range sets {
+		if set.Table == nil {
+			set.Table = t
+		}
+		ret = append(ret, &Set{Set: set})
+	}
+	return ret, nil
+}
+
+// fetchElements obtains the elements of all sets of the ruleset by the
+// bounded number of concurrent requests.
+func fetchElements(ctx context.Context, conn *nft.Conn, rs *Ruleset, workers int) error {
+	var sets []*Set
+	for _, t := range rs.Tables {
+		sets = append(sets, t.Sets...)
+	}
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+	// a lasting connection can not serve concurrent requests so every
+	// request goes over a connection of its own to the same namespace
+	elemConn, err := nft.New(nft.WithNetNSFd(conn.NetNS), nft.WithTestDial(conn.TestDial))
+	if err != nil {
+		return errors.WithMessage(err, "failed to create netlink connection")
+	}
+	defer elemConn.CloseLasting() //nolint:errcheck
+
+	return parallel.ExecAbstract(len(sets), int32(workers-1), func(i int) error { //nolint:gosec
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		s := sets[i]
+		elems, err := elemConn.GetSetElements(s.Set)
+		if err != nil {
+			return errors.WithMessagef(err, "failed to obtain set elements for the set='%s'", s.Name)
+		}
+		s.Elems = elems
+		return nil
+	})
+}
+
+// fetchChains obtains the chains and then the rules of all tables by one
+// dump each and puts them into the tables they belong to.
+func fetchChains(ctx context.Context, conn *nft.Conn, tables map[tableKey]*Table, filter Filter, rules bool) error {
+	chains, err := conn.ListChainsOfTableFamily(filter.Family)
+	if err != nil {
+		return errors.WithMessage(err, "failed to obtain list of chains from the netfilter")
+	}
+	byKey := make(map[chainKey]*Chain, len(chains))
+	for _, c := range chains {
+		if c.Table == nil || (filter.Chain != "" && c.Name != filter.Chain) {
+			continue
+		}
+		table, ok := tables[keyOf(c.Table)]
+		if !ok {
+			continue
+		}
+		c.Table = table.Table
+		chain := &Chain{Chain: c}
+		table.Chains = append(table.Chains, chain)
+		byKey[chainKey{keyOf(c.Table), c.Name}] = chain
+	}
+	if !rules || len(byKey) == 0 {
+		return nil
+	}
+	if err = ctx.Err(); err != nil {
+		return err
+	}
+	// the rules of all families come in one dump, the kernel does not
+	// filter a dump by the family alone
+	all, err := conn.GetAllRules()
+	if err != nil {
+		return errors.WithMessage(err, "failed to obtain rules from the netfilter")
+	}
+	for _, r := range all {
+		if r.Table == nil || r.Chain == nil {
+			continue
+		}
+		chain, ok := byKey[chainKey{keyOf(r.Table), r.Chain.Name}]
+		if !ok {
+			continue
+		}
+		r.Table, r.Chain = chain.Table, chain.Chain
+		chain.Rules = append(chain.Rules, r)
+	}
+	return nil
+}
+
+func keyOf(t *nft.Table) tableKey {
+	return tableKey{family: t.Family, name: t.Name}
+}
+
+// SetResolver returns the resolver of the sets of the ruleset, rules are
+// rendered with it without asking the kernel.
+func (rs *Ruleset) SetResolver() resolver.SetResolver {
+	sets := resolver.NewSets()
+	for _, t := range rs.Tables {
+		for _, s := range t.Sets {
+			set := s.Set
+			if set.Table == nil {
+				cp := *set
+				cp.Table = t.Table
+				set = &cp
+			}
+			sets.Add(set, s.Elems)
+		}
+	}
+	return sets
+}
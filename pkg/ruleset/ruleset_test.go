@@ -0,0 +1,345 @@
+package ruleset
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	nft "github.com/google/nftables"
+	"github.com/google/nftables/expr"
+	"github.com/mdlayher/netlink"
+	"github.com/mdlayher/netlink/nltest"
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/sys/unix"
+)
+
+type (
+	// fakeKernel serves nftables dumps of the objects added over its
+	// connection, it stands for the kernel in tests and benchmarks.
+	fakeKernel struct {
+		byType  map[uint16][]fakeMsg
+		byAttrs map[fakeKey][]fakeMsg
+		// latency delays every answer like a round trip to the kernel
+		latency time.Duration
+
+		mu       sync.Mutex
+		requests map[uint16]int
+	}
+
+	fakeMsg struct {
+		family byte
+		msg    netlink.Message
+	}
+
+	fakeKey struct {
+		typ    uint16
+		a1, a2 string
+	}
+
+	fetchTestSuite struct {
+		suite.Suite
+	}
+)
+
+const nftSubsys = unix.NFNL_SUBSYS_NFTABLES << 8
+
+// newFakeKernel runs build on a connection and keeps the messages it flushes.
+func newFakeKernel(build func(conn *nft.Conn)) (*fakeKernel, error) {
+	k := &fakeKernel{
+		byType:   map[uint16][]fakeMsg{},
+		byAttrs:  map[fakeKey][]fakeMsg{},
+		requests: map[uint16]int{},
+	}
+	conn, err := nft.New(nft.WithTestDial(func(req []netlink.Message) ([]netlink.Message, error) {
+		if req == nil {
+			// ack of a batch message
+			return []netlink.Message{{Header: netlink.Header{Type: netlink.Error}, Data: make([]byte, 4)}}, nil
+		}
+		for _, m := range req {
+			if m.Header.Type&0xff00 == nftSubsys {
+				k.add(m)
+			}
+		}
+		return nil, nil
+	}))
+	if err != nil {
+		return nil, err
+	}
+	build(conn)
+	return k, conn.Flush()
+}
+
+func (k *fakeKernel) add(m netlink.Message) {
+	typ := uint16(m.Header.Type) & 0xff
+	if typ == unix.NFT_MSG_NEWSETELEM {
+		m.Data = dumpedElems(m.Data)
+	}
+	attrs := decodeAttrs(m.Data[4:])
+	fm := fakeMsg{family: m.Data[0], msg: m}
+	k.byType[typ] = append(k.byType[typ], fm)
+	k.byAttrs[fakeKey{typ: typ, a1: attrs[1]}] = append(k.byAttrs[fakeKey{typ: typ, a1: attrs[1]}], fm)
+	k.byAttrs[fakeKey{typ, attrs[1], attrs[2]}] = append(k.byAttrs[fakeKey{typ, attrs[1], attrs[2]}], fm)
+}
+
+// dial answers a get request by the dump of the added objects of the
+// request family matching its table and chain or set name.
+func (k *fakeKernel) dial(req []netlink.Message) ([]netlink.Message, error) {
+	if len(req) == 0 {
+		return nil, nil
+	}
+	r := req[0]
+	k.mu.Lock()
+	k.requests[uint16(r.Header.Type)&0xff]++
+	k.mu.Unlock()
+	time.Sleep(k.latency)
+	newTyp := uint16(r.Header.Type)&0xff - 1
+	attrs := decodeAttrs(r.Data[4:])
+	msgs := k.byType[newTyp]
+	switch {
+	case attrs[2] != "":
+		msgs = k.byAttrs[fakeKey{newTyp, attrs[1], attrs[2]}]
+	case attrs[1] != "":
+		msgs = k.byAttrs[fakeKey{typ: newTyp, a1: attrs[1]}]
+	}
+	out := make([]netlink.Message, 0, len(msgs)+1)
+	for _, m := range msgs {
+		if r.Data[0] != unix.NFPROTO_UNSPEC && m.family != r.Data[0] {
+			continue
+		}
+		reply := m.msg
+		reply.Header.Flags = netlink.Multi
+		reply.Header.Sequence, reply.Header.PID = r.Header.Sequence, r.Header.PID
+		out = append(out, reply)
+	}
+	return append(out, netlink.Message{Header: netlink.Header{
+		Type:     netlink.Done,
+		Flags:    netlink.Multi,
+		Sequence: r.Header.Sequence,
+		PID:      r.Header.PID,
+	}}), nil
+}
+
+// requested returns the number of get requests of the type (NFT_MSG_GETRULE)
+// served since the last call.
+func (k *fakeKernel) requested(typ uint16) int {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	n := k.requests[typ]
+	delete(k.requests, typ)
+	return n
+}
+
+// dumpedElems renumbers the elements of the request like the kernel dump
+// tags them all as NFTA_LIST_ELEM.
+func dumpedElems(data []byte) []byte {
+	attrs, err := netlink.UnmarshalAttributes(data[4:])
+	if err != nil {
+		return data
+	}
+	for i, a := range attrs {
+		if a.Type&^unix.NLA_F_NESTED != unix.NFTA_SET_ELEM_LIST_ELEMENTS {
+			continue
+		}
+		elems, err := netlink.UnmarshalAttributes(a.Data)
+		if err != nil {
+			return data
+		}
+		for j := range elems {
+			elems[j].Type = unix.NFTA_LIST_ELEM | unix.NLA_F_NESTED
+		}
+		attrs[i].Data = nltest.MustMarshalAttributes(elems)
+	}
+	return append(data[:4:4], nltest.MustMarshalAttributes(attrs)...)
+}
+
+func decodeAttrs(b []byte) map[uint16]string {
+	ret := map[uint16]string{}
+	ad, err := netlink.NewAttributeDecoder(b)
+	if err != nil {
+		return ret
+	}
+	for ad.Next() {
+		if t := ad.Type(); t == 1 || t == 2 {
+			ret[t] = ad.String()
+		}
+	}
+	return ret
+}
+
+// addRuleset adds tables of every family with chains of the same names
+// holding the rules and sets of elements.
+func addRuleset(conn *nft.Conn, families []nft.TableFamily, chains, rules, sets, elems int) {
+	for _, fam := range families {
+		t := conn.AddTable(&nft.Table{Name: "filter", Family: fam})
+		for i := range chains {
+			c := conn.AddChain(&nft.Chain{Name: fmt.Sprintf("chain-%d", i), Table: t})
+			for j := range rules {
+				conn.AddRule(&nft.Rule{
+					Table:    t,
+					Chain:    c,
+					Exprs:    []expr.Any{&expr.Counter{}, &expr.Verdict{Kind: expr.VerdictAccept}},
+					UserData: fmt.Appendf(nil, "%d/%s/%d", fam, c.Name, j),
+				})
+			}
+		}
+		for i := range sets {
+			vals := make([]nft.SetElement, elems)
+			for j := range vals {
+				vals[j].Key = []byte{0, 0, byte(j >> 8), byte(j)}
+			}
+			_ = conn.AddSet(&nft.Set{Name: fmt.Sprintf("set-%d", i), Table: t, KeyType: nft.TypeInteger}, vals)
+		}
+	}
+}
+
+func (sui *fetchTestSuite) Test_Fetch() {
+	families := []nft.TableFamily{nft.TableFamilyIPv4, nft.TableFamilyIPv6}
+	k, err := newFakeKernel(func(conn *nft.Conn) {
+		addRuleset(conn, families, 3, 2, 2, 3)
+	})
+	sui.Require().NoError(err)
+	conn, err := nft.New(nft.WithTestDial(k.dial))
+	sui.Require().NoError(err)
+
+	sui.Run("everything", func() {
+		rs, err := Fetch(context.Background(), conn, Filter{Workers: 2})
+		sui.Require().NoError(err)
+		sui.Require().Len(rs.Tables, 2)
+		for i, t := range rs.Tables {
+			sui.Require().Equal(families[i], t.Family)
+			sui.Require().Len(t.Chains, 3)
+			for _, c := range t.Chains {
+				sui.Require().Same(t.Table, c.Table)
+				sui.Require().Len(c.Rules, 2)
+				for j, r := range c.Rules {
+					sui.Require().Same(c.Chain, r.Chain)
+					sui.Require().Equal(fmt.Sprintf("%d/%s/%d", t.Family, c.Name, j), string(r.UserData))
+				}
+			}
+			sui.Require().Len(t.Sets, 2)
+			for _, s := range t.Sets {
+				sui.Require().Len(s.Elems, 3)
+			}
+		}
+	})
+	sui.Run("chain of a family", func() {
+		rs, err := Fetch(context.Background(), conn, Filter{
+			Family:  nft.TableFamilyIPv6,
+			Chain:   "chain-1",
+			Content: FetchRules,
+		})
+		sui.Require().NoError(err)
+		sui.Require().Len(rs.Tables, 1)
+		t := rs.Tables[0]
+		sui.Require().Empty(t.Sets)
+		sui.Require().Len(t.Chains, 1)
+		sui.Require().Equal("chain-1", t.Chains[0].Name)
+		sui.Require().Len(t.Chains[0].Rules, 2)
+	})
+	sui.Run("sets streamed", func() {
+		rs, err := Fetch(context.Background(), conn, Filter{Content: FetchSets})
+		sui.Require().NoError(err)
+		sui.Require().Len(rs.Tables[0].Sets, 2)
+		set := rs.Tables[0].Sets[1]
+		sui.Require().Nil(set.Elems)
+		var keys [][]byte
+		for e, err := range SetElements(context.Background(), conn, set.Set) {
+			sui.Require().NoError(err)
+			keys = append(keys, e.Key)
+		}
+		sui.Require().Equal([][]byte{{0, 0, 0, 0}, {0, 0, 0, 1}, {0, 0, 0, 2}}, keys)
+	})
+	sui.Run("tables only", func() {
+		rs, err := Fetch(context.Background(), conn, Filter{Content: FetchTables})
+		sui.Require().NoError(err)
+		sui.Require().Len(rs.Tables, 2)
+		sui.Require().Empty(rs.Tables[0].Chains)
+	})
+	sui.Run("one dump of rules and chains for any number of chains", func() {
+		for _, chains := range []int{1, 20} {
+			k, err := newFakeKernel(func(conn *nft.Conn) {
+				addRuleset(conn, families, chains, 2, 0, 0)
+			})
+			sui.Require().NoError(err)
+			conn, err := nft.New(nft.WithTestDial(k.dial))
+			sui.Require().NoError(err)
+			rs, err := Fetch(context.Background(), conn, Filter{})
+			sui.Require().NoError(err)
+			sui.Require().Len(rs.Tables[0].Chains, chains)
+			sui.Require().Equal(1, k.requested(unix.NFT_MSG_GETCHAIN), "chains=%d", chains)
+			sui.Require().Equal(1, k.requested(unix.NFT_MSG_GETRULE), "chains=%d", chains)
+		}
+	})
+	sui.Run("canceled", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := Fetch(ctx, conn, Filter{})
+		sui.Require().ErrorIs(err, context.Canceled)
+	})
+}
+
+func Test_Fetch(t *testing.T) {
+	suite.Run(t, new(fetchTestSuite))
+}
+
+// benchKernel serves 50k rules in 1000 chains and 200 sets of 100 elements
+// answering every request in 20µs at least.
+func benchKernel(b *testing.B) *nft.Conn {
+	k, err := newFakeKernel(func(conn *nft.Conn) {
+		addRuleset(conn, []nft.TableFamily{nft.TableFamilyIPv4}, 1000, 50, 200, 100)
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	k.latency = 20 * time.Microsecond
+	conn, err := nft.New(nft.WithTestDial(k.dial))
+	if err != nil {
+		b.Fatal(err)
+	}
+	return conn
+}
+
+func BenchmarkFetch(b *testing.B) {
+	conn := benchKernel(b)
+	b.ResetTimer()
+	for range b.N {
+		if _, err := Fetch(context.Background(), conn, Filter{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkFetchPerChain fetches the rules chain by chain and the set
+// elements one set after another for comparison.
+func BenchmarkFetchPerChain(b *testing.B) {
+	conn := benchKernel(b)
+	b.ResetTimer()
+	for range b.N {
+		tables, err := conn.ListTables()
+		if err != nil {
+			b.Fatal(err)
+		}
+		for _, t := range tables {
+			chains, err := conn.ListChainsOfTableFamily(t.Family)
+			if err != nil {
+				b.Fatal(err)
+			}
+			for _, c := range chains {
+				if _, err = conn.GetRules(t, c); err != nil {
+					b.Fatal(err)
+				}
+			}
+			sets, err := conn.GetSets(t)
+			if err != nil {
+				b.Fatal(err)
+			}
+			for _, s := range sets {
+				if _, err = conn.GetSetElements(s); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	}
+}